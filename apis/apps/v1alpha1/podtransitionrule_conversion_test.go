@@ -0,0 +1,59 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"math/rand"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	"k8s.io/apimachinery/pkg/api/apitesting/roundtrip"
+	metafuzzer "k8s.io/apimachinery/pkg/apis/meta/fuzzer"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	ctrlconversion "sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+)
+
+var _ conversion.Hub = &PodTransitionRule{}
+
+func TestPodTransitionRuleConvertible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	// a single version is not converted, until later versions are added as spokes of the hub
+	convertible, err := ctrlconversion.IsConvertible(scheme, &PodTransitionRule{})
+	if err != nil || convertible {
+		t.Fatalf("expect PodTransitionRule of single version not convertible, got %v, %v", convertible, err)
+	}
+}
+
+// TestPodTransitionRuleRoundTrip fuzzes PodTransitionRules, and checks they are not changed by encoding and decoding,
+// so that objects stored in the storage version are read back as they are
+func TestPodTransitionRuleRoundTrip(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	codecs := serializer.NewCodecFactory(scheme)
+	f := fuzzer.FuzzerFor(metafuzzer.Funcs, rand.NewSource(rand.Int63()), codecs)
+	gvk := GroupVersion.WithKind("PodTransitionRule")
+	for i := 0; i < 20; i++ {
+		roundtrip.RoundTripSpecificKindWithoutProtobuf(t, gvk, scheme, codecs, f, nil)
+	}
+}
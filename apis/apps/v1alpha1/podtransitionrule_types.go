@@ -27,15 +27,101 @@ type PodTransitionRuleSpec struct {
 	// Selector select the targets controlled by podtransitionrule
 	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 
+	// SelectorTerms select the targets matching any of the terms, and take precedence over Selector if not empty.
+	// +optional
+	SelectorTerms []metav1.LabelSelector `json:"selectorTerms,omitempty"`
+
+	// NamespaceSelector selects namespaces whose pods are selected by Selector or SelectorTerms. Nil means only the
+	// namespace of podtransitionrule. If set, targets in status are keyed by namespace/name instead of pod name.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// FieldSelector further selects targets by pod fields in fields.Selector syntax, e.g. status.phase=Running,
+	// targets must match both Selector and FieldSelector. Supported fields are metadata.name, spec.nodeName,
+	// spec.restartPolicy, spec.schedulerName, spec.serviceAccountName, status.phase, status.podIP and status.nominatedNodeName.
+	// +optional
+	FieldSelector string `json:"fieldSelector,omitempty"`
+
 	// Rules is a set of rules that need to be checked in certain situations
 	Rules []TransitionRule `json:"rules,omitempty"`
+
+	// RulesFrom refers to ConfigMaps holding rules shared by PodTransitionRules, which are merged with Rules. Rules
+	// win over shared rules of the same name, and shared rules of later ConfigMaps win over those of earlier ones.
+	// +optional
+	RulesFrom []ConfigMapRulesRef `json:"rulesFrom,omitempty"`
+
+	// ManagePodCondition indicates whether to set a condition on target pods reflecting whether they pass all rules.
+	// The condition type is podtransitionrule.kusionstack.io/${PodTransitionRuleName}.
+	// +optional
+	ManagePodCondition bool `json:"managePodCondition,omitempty"`
+
+	// ReadinessGate sets a custom condition on target pods, which is True if they pass all rules and False if they
+	// are blocked, so that pods declaring it in readiness gates hold traffic while blocked.
+	// +optional
+	ReadinessGate *PodTransitionRuleReadinessGate `json:"readinessGate,omitempty"`
+
+	// DryRun evaluates rules and reports verdicts in status without enforcing them. Target pods are never written
+	// and never blocked by the PodTransitionRule, and no finalizer is added.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Paused freezes rule evaluation, all target pods pass until it is resumed. Targets are still maintained,
+	// and rules are fully evaluated again once resumed.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// OrderingPolicy is the order available policy releases pods in within the max unavailable budget. Pods are
+	// released in the order of disruption cost if empty.
+	// +optional
+	OrderingPolicy PodTransitionRuleOrderingPolicy `json:"orderingPolicy,omitempty"`
+
+	// DeletionProtection controls how deletion of podtransitionrule is blocked by pods failing to be cleaned up.
+	// +optional
+	DeletionProtection *DeletionProtection `json:"deletionProtection,omitempty"`
+}
+
+// PodTransitionRuleReadinessGate is the pod condition reflecting whether pods pass all rules
+type PodTransitionRuleReadinessGate struct {
+	// ConditionType is the type of pod condition, which should be declared in readiness gates of target pods.
+	// The condition is set True once pods are no longer targets or the podtransitionrule is deleted.
+	ConditionType corev1.PodConditionType `json:"conditionType"`
+}
+
+// DeletionProtection keeps the finalizer of podtransitionrule until all target pods are cleaned up
+type DeletionProtection struct {
+	// GracePeriodSeconds is the period since deletion timestamp after which the finalizer is removed even if target
+	// pods fail to be cleaned up. Deletion is blocked until all pods are cleaned up if unset.
+	// +optional
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+}
+
+// PodTransitionRuleOrderingPolicy is the order pods are released in
+// +kubebuilder:validation:Enum=ByPriorityClass;ByCreationTimestamp
+type PodTransitionRuleOrderingPolicy string
+
+const (
+	// OrderingByPriorityClass releases pods of lower priority first
+	OrderingByPriorityClass PodTransitionRuleOrderingPolicy = "ByPriorityClass"
+	// OrderingByCreationTimestamp releases older pods first
+	OrderingByCreationTimestamp PodTransitionRuleOrderingPolicy = "ByCreationTimestamp"
+)
+
+// ConfigMapRulesRef refers to a ConfigMap in the namespace of PodTransitionRule holding shared rules
+type ConfigMapRulesRef struct {
+	// Name is the name of ConfigMap
+	Name string `json:"name"`
+
+	// Key is the key of rules in ConfigMap data, default is rules. The value is a YAML list of rules.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 type TransitionRule struct {
 	// Name is the name of this rule.
 	Name string `json:"name,omitempty"`
 
-	// Disabled is the switch to control this rule enable or not.
+	// Disabled is the switch to control this rule enable or not. Disabled rules are skipped, and their last known
+	// states are kept in status.
 	// +optional
 	Disabled bool `json:"disabled,omitempty"`
 
@@ -50,10 +136,45 @@ type TransitionRule struct {
 	// +optional
 	Filter *TransitionRuleFilter `json:"filter,omitempty"`
 
+	// CooldownSeconds spaces out pods passing this rule, a pod newly passing the rule rejects other pods until
+	// the cooldown elapses. Zero means no cooldown.
+	// +optional
+	CooldownSeconds int32 `json:"cooldownSeconds,omitempty"`
+
+	// MaxInProgress caps the number of pods in progress across the PodTransitionRule, a pod passing this rule is in
+	// progress until it completes its operation, i.e. it is in no stage and ready, or it is not a target any more.
+	// Pods newly passing the rule are rejected once the cap is reached. Zero means no cap.
+	// +optional
+	MaxInProgress int32 `json:"maxInProgress,omitempty"`
+
+	// Priority orders rules in a stage, rules of lower priority are evaluated first. Rules are evaluated one by one,
+	// and pods rejected by a rule are not passed to the rules after it, so cheap rules of lower priority save calls
+	// of expensive rules like webhooks. Rules of the same priority are ordered by kind: available policy, label
+	// check, webhook, then others. Budget rules like available policy only count pods passed to them, so ordering
+	// them after other rules trades precise budgets for fewer calls.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// Mode is how rejections of this rule take effect, default is Enforce.
+	// +optional
+	Mode TransitionRuleMode `json:"mode,omitempty"`
+
 	// TransitionRuleDefinition describes the detail of the rule.
 	TransitionRuleDefinition `json:",inline"`
 }
 
+// TransitionRuleMode is how rejections of a rule take effect
+// +kubebuilder:validation:Enum=Enforce;Audit
+type TransitionRuleMode string
+
+const (
+	// TransitionRuleModeEnforce blocks pods rejected by the rule
+	TransitionRuleModeEnforce TransitionRuleMode = "Enforce"
+	// TransitionRuleModeAudit lets pods rejected by the rule pass, and records the rejections in RejectInfo as
+	// audited, so that a new rule can be observed before it is enforced
+	TransitionRuleModeAudit TransitionRuleMode = "Audit"
+)
+
 type TransitionRuleFilter struct {
 	// LabelSelector is used to filter resource with label match expresion.
 	// +optional
@@ -72,6 +193,193 @@ type TransitionRuleDefinition struct {
 
 	// +optional
 	Webhook *TransitionRuleWebhook `json:"webhook,omitempty"`
+
+	// TopologySpread is the rule to keep min available pods in each topology domain.
+	// +optional
+	TopologySpread *TopologySpreadRule `json:"topologySpread,omitempty"`
+
+	// WorkloadRollout is the rule to block pods while their owner Deployment or CollaSet is in the middle of rollout.
+	// +optional
+	WorkloadRollout *WorkloadRolloutRule `json:"workloadRollout,omitempty"`
+
+	// ResourceState is the rule to block pods while some resources are in specific state.
+	// +optional
+	ResourceState *ResourceStateRule `json:"resourceState,omitempty"`
+
+	// ConnectionDrain is the rule to block pods until their active connections are drained.
+	// +optional
+	ConnectionDrain *ConnectionDrainRule `json:"connectionDrain,omitempty"`
+
+	// SidecarDrain is the rule to block pods until their service mesh sidecar is drained.
+	// +optional
+	SidecarDrain *SidecarDrainRule `json:"sidecarDrain,omitempty"`
+
+	// ManualApproval is the rule to block pods until they are approved by human.
+	// +optional
+	ManualApproval *ManualApprovalRule `json:"manualApproval,omitempty"`
+
+	// PodStability is the rule to block pods which are restarting frequently.
+	// +optional
+	PodStability *PodStabilityRule `json:"podStability,omitempty"`
+
+	// AnnotationCheck is the rule to check annotations on pods, which are usually set by other operators.
+	// +optional
+	AnnotationCheck *AnnotationCheckRule `json:"annotationCheck,omitempty"`
+
+	// VolumeCheck is the rule to block pods until their persistent volumes are in a safe state, e.g. detached or snapshotted.
+	// +optional
+	VolumeCheck *VolumeCheckRule `json:"volumeCheck,omitempty"`
+
+	// AlertCheck is the rule to block pods while related alerts are firing.
+	// +optional
+	AlertCheck *AlertCheckRule `json:"alertCheck,omitempty"`
+
+	// TimeWindow is the rule to block pods outside maintenance windows.
+	// +optional
+	TimeWindow *TimeWindowRule `json:"timeWindow,omitempty"`
+
+	// Expression is the rule to check pods with a CEL expression.
+	// +optional
+	Expression *ExpressionRule `json:"expression,omitempty"`
+
+	// PDBRef is the rule to defer availability to an existing PodDisruptionBudget in the namespace of PodTransitionRule.
+	// +optional
+	PDBRef *PDBRefRule `json:"pdbRef,omitempty"`
+
+	// Canary is the rule to release a canary cohort of pods first, and hold the rest until the cohort is soaked.
+	// +optional
+	Canary *CanaryRule `json:"canary,omitempty"`
+
+	// Custom is the rule checked by a ruler registered by downstream projects embedding the controller.
+	// +optional
+	Custom *CustomRule `json:"custom,omitempty"`
+}
+
+// CustomRule is checked by the ruler registered with Type. Pods are rejected if no ruler is registered with Type.
+type CustomRule struct {
+	// Type is the name the ruler is registered with
+	Type string `json:"type"`
+
+	// Params are passed to the ruler as they are
+	// +optional
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// CanaryRule passes the first Count pods freely, and holds the rest until all pods of the canary cohort have left
+// the stage ready and stayed so for SoakSeconds. Canary pods no longer existing are ignored. The canary restarts
+// once the rest is released and no pod is in the stage.
+type CanaryRule struct {
+	// Count is the number of pods in the canary cohort, an integer or a percentage of targets rounded up.
+	Count intstr.IntOrString `json:"count"`
+
+	// SoakSeconds is the period the canary cohort must stay healthy before the rest is released.
+	// +optional
+	SoakSeconds int32 `json:"soakSeconds,omitempty"`
+}
+
+// PDBRefRule passes pods while the referenced PodDisruptionBudget allows disruptions. Pods passed by the rule
+// and still available are counted against disruptions allowed, until the PodDisruptionBudget observes them.
+type PDBRefRule struct {
+	// Name is the name of policy/v1 PodDisruptionBudget in the namespace of PodTransitionRule.
+	Name string `json:"name"`
+
+	// FailurePolicy defines how a missing PodDisruptionBudget is handled - allowed values are Ignore or Fail.
+	// With Ignore, the PodTransitionRule is admitted and pods pass the rule while the PodDisruptionBudget is missing.
+	// Defaults to Fail.
+	// +optional
+	FailurePolicy *FailurePolicyType `json:"failurePolicy,omitempty"`
+}
+
+// ExpressionRule checks pods with a CEL expression, pods pass the rule if the expression is evaluated to true.
+type ExpressionRule struct {
+	// Expression is a CEL expression returning bool, the pod is bound to variable pod,
+	// e.g. pod.metadata.annotations["foo"] == "bar"
+	Expression string `json:"expression"`
+
+	// Message is the reason of pods rejected by the rule, default is the expression
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+type TimeWindowRule struct {
+	// Daily are the maintenance windows opening every day, pods pass while any window is open.
+	Daily []DailyWindow `json:"daily"`
+
+	// TimeZone is the IANA time zone of windows, e.g. Asia/Shanghai. The namespace default is used if unset, and then UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+type DailyWindow struct {
+	// Start is the time of day the window opens, in HH:MM.
+	Start string `json:"start"`
+
+	// End is the time of day the window closes, in HH:MM. A window ending no later than its start closes the next day.
+	End string `json:"end"`
+
+	// Days are the days of week on which the window opens, e.g. Sat, all days if empty.
+	// +optional
+	Days []string `json:"days,omitempty"`
+}
+
+type AlertCheckRule struct {
+	// URL is the API listing active alerts, either Alertmanager /api/v2/alerts or Prometheus /api/v1/alerts.
+	URL string `json:"url"`
+
+	// CABundle is a PEM encoded CA bundle which will be used to validate the server certificate.
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// Matchers are labels of alerts related to a pod, Key is the alert label and the value is given or taken from
+	// the pod. Pods are blocked while any firing alert has all labels matched.
+	Matchers []Parameter `json:"matchers"`
+
+	// IntervalSeconds is the interval to query alerts again while pods are blocked, default 30s
+	// +optional
+	IntervalSeconds *int32 `json:"intervalSeconds,omitempty"`
+}
+
+type VolumeCheckRule struct {
+	// SafeAnnotation is the annotation on PVCs or their bound PVs which marks volumes safe with value true,
+	// e.g. set by a snapshot controller.
+	// +optional
+	SafeAnnotation string `json:"safeAnnotation,omitempty"`
+
+	// SafePhases are the phases of bound PVs in which volumes are safe, e.g. Released.
+	// +optional
+	SafePhases []corev1.PersistentVolumePhase `json:"safePhases,omitempty"`
+}
+
+type AnnotationCheckRule struct {
+	// Requirements are the expected annotations on pods. Pods pass only if all requirements are met.
+	Requirements []AnnotationRequirement `json:"requirements"`
+
+	// Blocking reverses the rule, pods are blocked if all requirements are met.
+	// +optional
+	Blocking bool `json:"blocking,omitempty"`
+}
+
+type AnnotationOperator string
+
+const (
+	// AnnotationOpExists requires the annotation to exist with any value
+	AnnotationOpExists AnnotationOperator = "Exists"
+	// AnnotationOpEquals requires the annotation value to equal Value
+	AnnotationOpEquals AnnotationOperator = "Equals"
+	// AnnotationOpRegex requires the annotation value to match regular expression Value
+	AnnotationOpRegex AnnotationOperator = "Regex"
+)
+
+type AnnotationRequirement struct {
+	// Key is the annotation key
+	Key string `json:"key"`
+
+	// Operator is one of Exists, Equals and Regex
+	Operator AnnotationOperator `json:"operator"`
+
+	// Value is the expected value for Equals, or the regular expression for Regex
+	// +optional
+	Value string `json:"value,omitempty"`
 }
 
 type LabelCheckRule struct {
@@ -79,6 +387,131 @@ type LabelCheckRule struct {
 	Requires *metav1.LabelSelector `json:"requires"`
 }
 
+type TopologySpreadRule struct {
+	// TopologyKey is the key of node labels. Pods on nodes with the same label value are in the same topology domain.
+	TopologyKey string `json:"topologyKey"`
+
+	// MinAvailablePerDomain is the min available pods to keep in each topology domain, which is allowed to be a integer
+	// or a percentage of the number of target pods in the domain.
+	MinAvailablePerDomain *intstr.IntOrString `json:"minAvailablePerDomain"`
+}
+
+type WorkloadRolloutRule struct {
+	// IgnoreScaling indicates pods are not blocked if the workload is only scaling, that is, all replicas are updated
+	// and the generation is observed.
+	// +optional
+	IgnoreScaling bool `json:"ignoreScaling,omitempty"`
+}
+
+type ResourceStateRule struct {
+	// APIVersion of the resource, the resource must be registered to PodTransitionRule manager to be watched.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the resource.
+	Kind string `json:"kind"`
+
+	// Selector selects resources in the namespace of PodTransitionRule. Cluster-scoped resources are always selected
+	// if matched. Nil means all resources.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// FieldPath is the dot separated path of the field in resources to check, e.g. spec.active
+	FieldPath string `json:"fieldPath"`
+
+	// BlockingValues are values of the field. Pods are blocked while the field of any selected resource is one of them.
+	BlockingValues []string `json:"blockingValues"`
+}
+
+type ConnectionDrainRule struct {
+	// Port of the drain status endpoint on pods.
+	Port int32 `json:"port"`
+
+	// Path of the drain status endpoint on pods, default is /.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// MetricName is the metric of active connections. If it is set, the endpoint responds metrics in prometheus
+	// text format, and active connections are the sum of the metric. Otherwise, the endpoint responds a JSON
+	// object like {"activeConnections": 0}.
+	// +optional
+	MetricName string `json:"metricName,omitempty"`
+
+	// MaxWaitSeconds is the max duration to wait for connections drained, pods pass the rule after that even if
+	// connections are not drained. 0 means waiting until drained.
+	// +optional
+	MaxWaitSeconds int32 `json:"maxWaitSeconds,omitempty"`
+
+	// CrashLoopPolicy is how pods with containers in CrashLoopBackOff are handled, default is Normal.
+	// +optional
+	CrashLoopPolicy CrashLoopPolicy `json:"crashLoopPolicy,omitempty"`
+}
+
+type SidecarDrainRule struct {
+	// ContainerName is the name of sidecar container, default is istio-proxy.
+	// +optional
+	ContainerName string `json:"containerName,omitempty"`
+
+	// Port of the drain endpoint on sidecar. Default is the port of the HTTP readiness probe of sidecar.
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// Path of the drain endpoint on sidecar. Default is the path of the HTTP readiness probe of sidecar.
+	// The sidecar is drained once the endpoint responds a non-2xx status code or refuses connections.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// MaxWaitSeconds is the max duration to wait for sidecar drained, pods pass the rule after that even if
+	// sidecar is not drained. 0 means waiting until drained.
+	// +optional
+	MaxWaitSeconds int32 `json:"maxWaitSeconds,omitempty"`
+
+	// CrashLoopPolicy is how pods with containers in CrashLoopBackOff are handled, default is Normal.
+	// +optional
+	CrashLoopPolicy CrashLoopPolicy `json:"crashLoopPolicy,omitempty"`
+}
+
+// CrashLoopPolicy is how drain rules handle pods with containers in CrashLoopBackOff
+// +kubebuilder:validation:Enum=Normal;AlwaysPass;AlwaysBlock
+type CrashLoopPolicy string
+
+const (
+	// CrashLoopPolicyNormal waits for crash looping pods drained like others
+	CrashLoopPolicyNormal CrashLoopPolicy = "Normal"
+	// CrashLoopPolicyAlwaysPass lets crash looping pods pass, since there is no point protecting broken pods
+	CrashLoopPolicyAlwaysPass CrashLoopPolicy = "AlwaysPass"
+	// CrashLoopPolicyAlwaysBlock blocks crash looping pods until they recover
+	CrashLoopPolicyAlwaysBlock CrashLoopPolicy = "AlwaysBlock"
+)
+
+type PodStabilityRule struct {
+	// MaxRestarts is the max restart count of each container. Pods with any container restarted more times are blocked.
+	MaxRestarts int32 `json:"maxRestarts"`
+
+	// WithinSeconds only counts containers whose last termination finished within the duration, so that pods
+	// restarted long ago are not blocked. 0 means restarts at any time are counted.
+	// +optional
+	WithinSeconds int32 `json:"withinSeconds,omitempty"`
+
+	// BlockingReasons are reasons of last termination to count, e.g. OOMKilled or Error. Empty means all reasons.
+	// +optional
+	BlockingReasons []string `json:"blockingReasons,omitempty"`
+}
+
+// ManualApprovalRule blocks pods until approved. A pod is approved by annotation
+// approve.podtransitionrule.kusionstack.io/<PodTransitionRule name> on the pod, or annotation
+// approve.podtransitionrule.kusionstack.io/<pod name> on the PodTransitionRule. The value of annotation is the approver.
+// If ApprovalLabel is set, all pods are approved at once by labeling the PodTransitionRule instead.
+type ManualApprovalRule struct {
+	// ApprovalLabel is the label on PodTransitionRule approving all pods while its value is true.
+	// +optional
+	ApprovalLabel string `json:"approvalLabel,omitempty"`
+
+	// OneShot removes ApprovalLabel from PodTransitionRule once it approves pods, so that pods entering the stage
+	// later must be approved again.
+	// +optional
+	OneShot bool `json:"oneShot,omitempty"`
+}
+
 type AvailableRule struct {
 	// MaxUnavailableValue is the expected max unavailable replicas which is allowed to be a integer or a percentage of the whole
 	// number of the target resources.
@@ -87,6 +520,18 @@ type AvailableRule struct {
 	// MinAvailableValue is the expected min available replicas which is allowed to be a integer or a percentage of the whole
 	// number of the target resources.
 	MinAvailableValue *intstr.IntOrString `json:"minAvailableValue,omitempty"`
+
+	// ContainerReadiness judges availability of pods by readiness of the named containers instead of the pod Ready
+	// condition, e.g. so that an app container restarting beside ready sidecars makes the pod unavailable.
+	// +optional
+	ContainerReadiness *ContainerReadiness `json:"containerReadiness,omitempty"`
+}
+
+// ContainerReadiness judges a pod available only if all the named containers are ready
+type ContainerReadiness struct {
+	// ContainerNames are names of containers whose Ready states decide availability. Pods missing any of them
+	// are unavailable.
+	ContainerNames []string `json:"containerNames"`
 }
 
 type TransitionRuleWebhook struct {
@@ -99,9 +544,62 @@ type TransitionRuleWebhook struct {
 	// +optional
 	FailurePolicy *FailurePolicyType `json:"failurePolicy,omitempty"`
 
+	// TimeoutSeconds is the timeout of webhook requests, default 10s. Pods of timed out requests are passed or
+	// rejected by FailurePolicy, and requested again on the next reconcile.
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+
+	// Retry is the backoff of retrying failed webhook requests. Without it, failed requests are retried on every reconcile.
+	// +optional
+	Retry *WebhookRetry `json:"retry,omitempty"`
+
 	// Parameters contains the list of parameters which will be passed in webhook body.
 	// +optional
 	Parameters []Parameter `json:"parameters,omitempty"`
+
+	// RedactHeaders are response headers whose values are redacted in webhook states, in addition to
+	// Authorization, Proxy-Authorization, Cookie and Set-Cookie which are always redacted.
+	// +optional
+	RedactHeaders []string `json:"redactHeaders,omitempty"`
+
+	// PayloadTemplate shapes the webhook request body to adapt to existing policy servers.
+	// Defaults to WebhookRequest.
+	// +optional
+	PayloadTemplate *PayloadTemplate `json:"payloadTemplate,omitempty"`
+}
+
+// PayloadTemplate shapes the webhook request body as
+// {"traceId": "", "ruleName": "", "stage": "", <ResourcesKey>: [<resource shaped by Fields>]}
+type PayloadTemplate struct {
+	// ResourcesKey is the key of the resource list in request body, defaults to resources.
+	// +optional
+	ResourcesKey string `json:"resourcesKey,omitempty"`
+
+	// Fields contains the pod fields included in each resource.
+	Fields []PayloadField `json:"fields"`
+}
+
+type PayloadField struct {
+	// Path is the dot separated path in resource where the value is put, e.g. meta.ip
+	Path string `json:"path"`
+
+	// FieldPath selects the pod field, e.g. status.podIP
+	FieldPath string `json:"fieldPath"`
+}
+
+// WebhookRetry retries failed webhook requests with exponential backoff. Once attempts are exhausted, pods are
+// passed if failure policy is Ignore, or else kept rejected and the webhook is retried with the max backoff.
+type WebhookRetry struct {
+	// MaxAttempts is the max number of consecutive failed requests, including the first one.
+	MaxAttempts int32 `json:"maxAttempts"`
+
+	// BackoffSeconds is the backoff after the first failed request, doubled after each failed request, default 1s
+	// +optional
+	BackoffSeconds *int32 `json:"backoffSeconds,omitempty"`
+
+	// MaxBackoffSeconds caps the backoff, default 60s
+	// +optional
+	MaxBackoffSeconds *int32 `json:"maxBackoffSeconds,omitempty"`
 }
 
 // FailurePolicyType specifies the type of failure policy
@@ -129,6 +627,34 @@ type ClientConfigBeta1 struct {
 	// Poll is the polling to query url.
 	// +optional
 	Poll *Poll `json:"poll,omitempty"`
+
+	// ConnectionPool configures pooled connections to the webhook, shared by both webhook and polling requests.
+	// +optional
+	ConnectionPool *ConnectionPool `json:"connectionPool,omitempty"`
+
+	// ClientCertSecretRef refers to a Secret in the namespace of the PodTransitionRule, whose tls.crt and tls.key are
+	// presented as client certificate to the webhook requiring mutual TLS. Polling requests do not present it.
+	// +optional
+	ClientCertSecretRef *corev1.LocalObjectReference `json:"clientCertSecretRef,omitempty"`
+
+	// InsecureSkipVerify skips verifying the webhook's server certificate, it should only be used for testing.
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// ConnectionPool configures connections kept to a webhook. Clients are shared by webhooks with the same endpoint and pool.
+type ConnectionPool struct {
+	// MaxIdleConnsPerHost is the max idle connections kept to the webhook host, default 2
+	// +optional
+	MaxIdleConnsPerHost *int32 `json:"maxIdleConnsPerHost,omitempty"`
+
+	// IdleTimeoutSeconds is how long idle connections are kept before closed, default 90s
+	// +optional
+	IdleTimeoutSeconds *int32 `json:"idleTimeoutSeconds,omitempty"`
+
+	// KeepAliveSeconds is the interval of TCP keep-alive probes, default 30s. Negative value disables keep-alive.
+	// +optional
+	KeepAliveSeconds *int32 `json:"keepAliveSeconds,omitempty"`
 }
 
 type Poll struct {
@@ -156,9 +682,23 @@ type Poll struct {
 type PodTransitionRuleStatus struct {
 	UpdateTime *metav1.Time `json:"updateTime,omitempty"`
 
+	// LastReconcileTime is the last time PodTransitionRule is reconciled. Unlike UpdateTime, it is refreshed even if
+	// status is not changed otherwise, at most once per interval configured on controller.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// LastReconcileDurationMillis is the wall time of the last reconcile until its status is computed, in milliseconds
+	// +optional
+	LastReconcileDurationMillis int64 `json:"lastReconcileDurationMillis,omitempty"`
+
 	// ObservedGeneration is the most recent generation observed for PodTransitionRule
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 
+	// SchemaVersion is the version of status schema this status is written with. Status of older schema version is
+	// migrated on controller startup.
+	// +optional
+	SchemaVersion int64 `json:"schemaVersion,omitempty"`
+
 	// Targets contains the target resource names this PodTransitionRule is able to select.
 	Targets []string `json:"targets,omitempty"`
 
@@ -166,9 +706,69 @@ type PodTransitionRuleStatus struct {
 	// +optional
 	RuleStates []*RuleState `json:"ruleStates,omitempty"`
 
+	// RuleStatesRef refers to the ConfigMap holding detailed RuleStates if they are too large. RuleStates in
+	// status only contain summaries in this case.
+	// +optional
+	RuleStatesRef *RuleStatesReference `json:"ruleStatesRef,omitempty"`
+
 	// Details contains all pods podtransitionrule details
 	// +optional
 	Details []*PodTransitionDetail `json:"details,omitempty"`
+
+	// CompressedStatusRef refers to the ConfigMap holding compressed Details and RuleStates if there are too many
+	// target pods. Details are empty and RuleStates only contain summaries in status in this case.
+	// +optional
+	CompressedStatusRef *CompressedStatusReference `json:"compressedStatusRef,omitempty"`
+
+	// SyncProgress shows how many targets have details synced onto pod annotations, e.g. "500/3000".
+	// It is empty once all targets are synced.
+	// +optional
+	SyncProgress string `json:"syncProgress,omitempty"`
+
+	// DeletionBlockedSince is the time the deletion of PodTransitionRule is first blocked by failures of cleaning up pods.
+	// +optional
+	DeletionBlockedSince *metav1.Time `json:"deletionBlockedSince,omitempty"`
+
+	// Represents the latest available observations of a PodTransitionRule's current state.
+	// +optional
+	Conditions []PodTransitionRuleCondition `json:"conditions,omitempty"`
+}
+
+type PodTransitionRuleConditionType string
+
+const (
+	// PodTransitionRulePodWriteConflict means annotation writes on some pods repeatedly fail,
+	// which is usually caused by another mutator conflicting on the pods.
+	PodTransitionRulePodWriteConflict PodTransitionRuleConditionType = "PodWriteConflict"
+
+	// PodTransitionRulePaused means rule evaluation is paused by spec, and all target pods pass.
+	PodTransitionRulePaused PodTransitionRuleConditionType = "Paused"
+
+	// PodTransitionRuleReady means the current generation is reconciled, and verdicts of all target pods are synced.
+	PodTransitionRuleReady PodTransitionRuleConditionType = "Ready"
+
+	// PodTransitionRuleProgressing means the last reconcile was partial, and the remainder is continued on requeue.
+	PodTransitionRuleProgressing PodTransitionRuleConditionType = "Progressing"
+
+	// PodTransitionRuleSelectorValid means Selector, SelectorTerms and FieldSelector are valid.
+	PodTransitionRuleSelectorValid PodTransitionRuleConditionType = "SelectorValid"
+)
+
+type PodTransitionRuleCondition struct {
+	// Type of PodTransitionRule condition.
+	Type PodTransitionRuleConditionType `json:"type,omitempty"`
+
+	// Status of the condition, one of True, False, Unknown.
+	Status corev1.ConditionStatus `json:"status,omitempty"`
+
+	// Last time the condition transitioned from one status to another.
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// The reason for the condition's last transition.
+	Reason string `json:"reason,omitempty"`
+
+	// A human readable message indicating details about the transition.
+	Message string `json:"message,omitempty"`
 }
 
 // RuleState defines the resource info in webhook processing progress.
@@ -176,8 +776,167 @@ type RuleState struct {
 	// Name is the name representing the rule
 	Name string `json:"name,omitempty"`
 
+	// Disabled indicates the rule is disabled, and the state is the last known state before it is disabled
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
 	// WebhookStatus is the webhook status representing processing progress
 	WebhookStatus *WebhookStatus `json:"webhookStatus,omitempty"`
+
+	// WebhookStates are the last calls of the webhook, including the last request and the last poll of each task
+	// +optional
+	WebhookStates []WebhookState `json:"webhookStates,omitempty"`
+
+	// DrainStatus is the connection drain status of pods
+	// +optional
+	DrainStatus *DrainStatus `json:"drainStatus,omitempty"`
+
+	// ApprovalStatus is the manual approval status of pods
+	// +optional
+	ApprovalStatus *ApprovalStatus `json:"approvalStatus,omitempty"`
+
+	// CooldownStatus is the cooldown status of the rule
+	// +optional
+	CooldownStatus *CooldownStatus `json:"cooldownStatus,omitempty"`
+
+	// InProgressStatus contains pods holding slots of MaxInProgress of the rule
+	// +optional
+	InProgressStatus *InProgressStatus `json:"inProgressStatus,omitempty"`
+
+	// CanaryStatus is the canary cohort status of the rule
+	// +optional
+	CanaryStatus *CanaryStatus `json:"canaryStatus,omitempty"`
+
+	// AvailableStatus is the availability of pods judged by container readiness
+	// +optional
+	AvailableStatus *AvailableStatus `json:"availableStatus,omitempty"`
+
+	// Summary is the summary of rule state, which is set instead of details if RuleStates are too large
+	// +optional
+	Summary *RuleStateSummary `json:"summary,omitempty"`
+}
+
+// AvailableStatus is the availability of pods judged by available policy
+type AvailableStatus struct {
+	// UnavailableReasons are reasons of pods judged unavailable by container readiness, keyed by pod name
+	// +optional
+	UnavailableReasons map[string]string `json:"unavailableReasons,omitempty"`
+}
+
+// RuleStatesReference refers to the ConfigMap holding detailed RuleStates
+type RuleStatesReference struct {
+	// ConfigMapName is the name of ConfigMap in the namespace of PodTransitionRule, RuleStates are in key ruleStates.
+	ConfigMapName string `json:"configMapName"`
+}
+
+// CompressedStatusReference refers to the ConfigMap holding compressed Details and RuleStates
+type CompressedStatusReference struct {
+	// ConfigMapName is the name of ConfigMap in the namespace of PodTransitionRule, the gzip and base64 encoded
+	// status is in key status.
+	ConfigMapName string `json:"configMapName"`
+}
+
+type RuleStateSummary struct {
+	// Tasks is the number of webhook tasks in processing
+	Tasks int32 `json:"tasks,omitempty"`
+
+	// Pods is the number of pods in processing
+	Pods int32 `json:"pods,omitempty"`
+}
+
+// ApprovalStatus contains pods pending manual approval and pods approved
+type ApprovalStatus struct {
+	// Pending are pods waiting for manual approval
+	// +optional
+	Pending []string `json:"pending,omitempty"`
+
+	// Approved are pods approved
+	// +optional
+	Approved []ApprovedPod `json:"approved,omitempty"`
+}
+
+type ApprovedPod struct {
+	Name string `json:"name,omitempty"`
+
+	// Approver is the value of approval annotation
+	Approver string `json:"approver,omitempty"`
+}
+
+// CooldownStatus contains the last time a pod newly passed the rule, and pods which passed the rule
+type CooldownStatus struct {
+	// LastPassTime is the last time a pod newly passed the rule
+	// +optional
+	LastPassTime *metav1.Time `json:"lastPassTime,omitempty"`
+
+	// Pods are pods which passed the rule and are still in the stage, they are not cooled down again
+	// +optional
+	Pods []string `json:"pods,omitempty"`
+}
+
+// InProgressStatus contains pods which passed the rule and have not completed their operations
+type InProgressStatus struct {
+	// Pods are pods holding slots of MaxInProgress
+	// +optional
+	Pods []string `json:"pods,omitempty"`
+}
+
+// CanaryStatus contains pods released as canary and the progress of soaking them
+type CanaryStatus struct {
+	// Released are pods released in the canary cohort
+	// +optional
+	Released []string `json:"released,omitempty"`
+
+	// HealthySince is the time since which the canary cohort is healthy, nil if it is not healthy
+	// +optional
+	HealthySince *metav1.Time `json:"healthySince,omitempty"`
+
+	// Promoted indicates the canary cohort is soaked and the rest is released
+	// +optional
+	Promoted bool `json:"promoted,omitempty"`
+}
+
+// DrainStatus contains pods waiting for connections or sidecar drained
+type DrainStatus struct {
+	Pods []DrainingPod `json:"pods,omitempty"`
+}
+
+type DrainingPod struct {
+	Name string `json:"name,omitempty"`
+
+	// BeginTime is the time the pod begins waiting for connections drained
+	BeginTime metav1.Time `json:"beginTime,omitempty"`
+
+	// ActiveConnections is the number of active connections last queried, -1 if the query fails
+	ActiveConnections int64 `json:"activeConnections"`
+
+	// SidecarState is the state of service mesh sidecar last queried
+	// +optional
+	SidecarState string `json:"sidecarState,omitempty"`
+}
+
+// WebhookState is the last call of webhook, for debugging why webhook rejects pods
+type WebhookState struct {
+	// Name is the kind of call, request or poll
+	Name string `json:"name,omitempty"`
+
+	// StatusCode is the HTTP status code of response, 0 if no response is received or the code is unknown
+	// +optional
+	StatusCode int32 `json:"statusCode,omitempty"`
+
+	// Message is the response body or the error of the call, truncated if too long
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Headers are the response headers, values of redacted headers are replaced
+	// +optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// TraceID is the trace id of request, or the task id of poll
+	// +optional
+	TraceID string `json:"traceID,omitempty"`
+
+	// LastCallTime is the time of the call
+	LastCallTime metav1.Time `json:"lastCallTime,omitempty"`
 }
 
 // WebhookStatus defines the webhook processing status
@@ -188,8 +947,45 @@ type WebhookStatus struct {
 
 	// History records history taskStates which were finished or failed. Valid for 10 minutes
 	History []TaskInfo `json:"history,omitempty"`
+
+	// CircuitState is the state of circuit breaker of the webhook endpoint
+	// +optional
+	CircuitState CircuitState `json:"circuitState,omitempty"`
+
+	// RetryStatus is the state of retrying failed requests, nil if the last request succeeded
+	// +optional
+	RetryStatus *WebhookRetryStatus `json:"retryStatus,omitempty"`
 }
 
+type WebhookRetryStatus struct {
+	// Attempts is the number of consecutive failed requests
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// LastError is the error of the last failed request
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// NextAttemptTime is the time before which the webhook is not requested again
+	// +optional
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+
+	// IgnoredPods are pods passed by failure policy Ignore after attempts are exhausted
+	// +optional
+	IgnoredPods []string `json:"ignoredPods,omitempty"`
+}
+
+// CircuitState is the state of circuit breaker
+type CircuitState string
+
+const (
+	// CircuitClosed means requests are sent to webhook normally
+	CircuitClosed CircuitState = "Closed"
+	// CircuitOpen means requests fast-fail according to the failure policy
+	CircuitOpen CircuitState = "Open"
+	// CircuitHalfOpen means a trial request is sent to test whether webhook recovers
+	CircuitHalfOpen CircuitState = "HalfOpen"
+)
+
 type TaskInfo struct {
 	TaskId string `json:"taskId,omitempty"`
 
@@ -210,14 +1006,44 @@ type PodTransitionDetail struct {
 	// Stage is pod current stage
 	Stage string `json:"stage,omitempty"`
 	// Passed indicates whether the pod passed all rules
-	Passed      bool         `json:"passed"`
-	PassedRules []string     `json:"passedRules,omitempty"`
-	RejectInfo  []RejectInfo `json:"rejectInfo,omitempty"`
+	Passed      bool     `json:"passed"`
+	PassedRules []string `json:"passedRules,omitempty"`
+	// RejectedRules are rules actively rejecting the pod
+	// +optional
+	RejectedRules []string `json:"rejectedRules,omitempty"`
+	// PendingRules are rules awaiting external state on the pod, e.g. an async webhook task or a delay,
+	// and rules not evaluated yet because the pod is rejected by a former rule
+	// +optional
+	PendingRules []string     `json:"pendingRules,omitempty"`
+	RejectInfo   []RejectInfo `json:"rejectInfo,omitempty"`
+	// DelayInfo contains rules which allow the pod to pass only after a delay
+	// +optional
+	DelayInfo []DelayInfo `json:"delayInfo,omitempty"`
+	// RejectHistory are the latest rejections of the pod, oldest first. A rejection is only recorded if it differs
+	// from the previous one.
+	// +optional
+	RejectHistory []RejectEvent `json:"rejectHistory,omitempty"`
+}
+
+// RejectEvent is a rejection of pod recorded in RejectHistory
+type RejectEvent struct {
+	RuleName string      `json:"ruleName,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+	Time     metav1.Time `json:"time,omitempty"`
 }
 
 type RejectInfo struct {
 	RuleName string `json:"ruleName,omitempty"`
 	Reason   string `json:"reason,omitempty"`
+	// Audited indicates the rejection is by a rule in Audit mode, which does not block the pod
+	// +optional
+	Audited bool `json:"audited,omitempty"`
+}
+
+// DelayInfo indicates the pod is blocked by rule until DelayUntil, and passes the rule after that
+type DelayInfo struct {
+	RuleName   string      `json:"ruleName,omitempty"`
+	DelayUntil metav1.Time `json:"delayUntil,omitempty"`
 }
 
 // +genclient
@@ -225,6 +1051,7 @@ type RejectInfo struct {
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=ptr
+// +kubebuilder:storageversion
 
 // PodTransitionRule is the Schema for the podtransitionrules API
 type PodTransitionRule struct {
@@ -316,6 +1143,8 @@ type WebhookResponse struct {
 	// if Async, use TraceId as TaskId
 	TraceId string `json:"traceId"`
 	TaskId  string `json:"taskId"`
+	// DelayUntil allows the finished pods to pass only after this time, e.g. to let connections drain
+	DelayUntil *metav1.Time `json:"delayUntil,omitempty"`
 }
 
 type PollResponse struct {
@@ -324,4 +1153,6 @@ type PollResponse struct {
 	Finished      bool     `json:"finished"`
 	FinishedNames []string `json:"finishedNames,omitempty"`
 	Stop          bool     `json:"stop"`
+	// DelayUntil allows the finished pods to pass only after this time, e.g. to let connections drain
+	DelayUntil *metav1.Time `json:"delayUntil,omitempty"`
 }
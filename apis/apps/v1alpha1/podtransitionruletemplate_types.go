@@ -0,0 +1,84 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadKind is the kind of workloads PodTransitionRules are generated for
+type WorkloadKind string
+
+const (
+	WorkloadKindCollaSet   WorkloadKind = "CollaSet"
+	WorkloadKindDeployment WorkloadKind = "Deployment"
+)
+
+// PodTransitionRuleTemplateSpec defines the desired state of PodTransitionRuleTemplate
+type PodTransitionRuleTemplateSpec struct {
+	// WorkloadKind is the kind of workloads in the namespace of template, default is CollaSet.
+	// +optional
+	// +kubebuilder:validation:Enum=CollaSet;Deployment
+	WorkloadKind WorkloadKind `json:"workloadKind,omitempty"`
+
+	// WorkloadSelector selects workloads to generate PodTransitionRules for. Nil means all workloads.
+	// +optional
+	WorkloadSelector *metav1.LabelSelector `json:"workloadSelector,omitempty"`
+
+	// Template is the spec of generated PodTransitionRules. Selector is filled with the selector of each workload.
+	Template PodTransitionRuleSpec `json:"template"`
+}
+
+// PodTransitionRuleTemplateStatus defines the observed state of PodTransitionRuleTemplate
+type PodTransitionRuleTemplateStatus struct {
+	// ObservedGeneration is the most recent generation observed for PodTransitionRuleTemplate
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// PodTransitionRules are names of generated PodTransitionRules
+	// +optional
+	PodTransitionRules []string `json:"podTransitionRules,omitempty"`
+}
+
+// +genclient
+// +k8s:openapi-gen=true
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=ptrt
+
+// PodTransitionRuleTemplate is the Schema for the podtransitionruletemplates API, which generates a
+// PodTransitionRule for each selected workload
+type PodTransitionRuleTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodTransitionRuleTemplateSpec   `json:"spec,omitempty"`
+	Status PodTransitionRuleTemplateStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PodTransitionRuleTemplateList contains a list of PodTransitionRuleTemplate
+type PodTransitionRuleTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PodTransitionRuleTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PodTransitionRuleTemplate{}, &PodTransitionRuleTemplateList{})
+}
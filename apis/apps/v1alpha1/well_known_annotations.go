@@ -26,6 +26,23 @@ const (
 const (
 	AnnotationPodSkipRuleConditions         = "podtransitionrule.kusionstack.io/skip-rule-conditions"
 	AnnotationPodTransitionRuleDetailPrefix = "detail.podtransitionrule.kusionstack.io"
+	// AnnotationPodTransitionRuleApprovePrefix is the prefix of annotations approving pods for ManualApprovalRule
+	AnnotationPodTransitionRuleApprovePrefix = "approve.podtransitionrule.kusionstack.io"
+	// AnnotationPodDisruptionCost is the integer cost of disrupting a pod, pods with lower cost are let pass first
+	// when AvailablePolicy limits concurrent transitions. Pods without it cost 0.
+	AnnotationPodDisruptionCost = "podtransitionrule.kusionstack.io/disruption-cost"
+	// AnnotationPodExplain requests PodTransitionRules to explain verdicts of their rules on a pod with a pod event.
+	// The value is true for all PodTransitionRules of the pod, or comma separated PodTransitionRule names.
+	AnnotationPodExplain = "podtransitionrule.kusionstack.io/explain"
+	// AnnotationPodExclude exempts a pod from PodTransitionRules, e.g. a pod evacuated manually. The value is true for
+	// all PodTransitionRules selecting the pod, or comma separated PodTransitionRule names.
+	AnnotationPodExclude = "podtransitionrule.kusionstack.io/exclude"
+	// AnnotationDryRun on a PodTransitionRule makes its reconciles non-mutating when true. What would change on its
+	// status and pods is only logged and reported with an event.
+	AnnotationDryRun = "podtransitionrule.kusionstack.io/dry-run"
+	// AnnotationGovernProtectedPods on a PodTransitionRule opts in to govern protected pods when true, e.g. pods of
+	// the controller itself, which are excluded from its targets by default.
+	AnnotationGovernProtectedPods = "podtransitionrule.kusionstack.io/govern-protected-pods"
 )
 
 // PodDecoration Annotation
@@ -52,6 +52,11 @@ const (
 	CollaSetUpdateIndicateLabelKey = "collaset.kusionstack.io/update-included"
 )
 
+const (
+	PodTransitionRuleTemplateLabelKey  = "podtransitionrule.kusionstack.io/template"  // used to indicate the PodTransitionRuleTemplate generating a PodTransitionRule
+	PodTransitionRuleProtectedLabelKey = "podtransitionrule.kusionstack.io/protected" // used to indicate pods of critical operators which PodTransitionRules exclude by default
+)
+
 var (
 	WellKnownLabelPrefixesWithID = []string{PodOperatingLabelPrefix, PodOperationTypeLabelPrefix, PodPreCheckLabelPrefix, PodPreCheckedLabelPrefix,
 		PodPreparingLabelPrefix, PodDoneOperationTypeLabelPrefix, PodUndoOperationTypeLabelPrefix, PodOperateLabelPrefix, PodOperatedLabelPrefix, PodPostCheckLabelPrefix,
@@ -27,8 +27,26 @@ const (
 	ProtectFinalizer                      = "finalizer.operating.kusionstack.io/protected"
 )
 
+// well known pod condition
+const (
+	// PodTransitionRuleConditionTypePrefix is the prefix of pod condition type managed by PodTransitionRule
+	PodTransitionRuleConditionTypePrefix = "podtransitionrule.kusionstack.io"
+)
+
 // well known variables
 const (
 	PodOpsLifecyclePreCheckStage  = "PreCheck"
 	PodOpsLifecyclePostCheckStage = "PostCheck"
 )
+
+// well known ConfigMap
+const (
+	// PodTransitionRuleDefaultsConfigMap holds the defaults inherited by all PodTransitionRules in its namespace
+	PodTransitionRuleDefaultsConfigMap = "podtransitionrule-defaults"
+
+	// PodTransitionRuleKillSwitchConfigMap is the cluster-wide switch to make all PodTransitionRules observe-only
+	PodTransitionRuleKillSwitchConfigMap = "podtransitionrule-kill-switch"
+
+	// DefaultRulesConfigMapKey is the key of shared rules in ConfigMaps referred by rulesFrom without key
+	DefaultRulesConfigMapKey = "rules"
+)
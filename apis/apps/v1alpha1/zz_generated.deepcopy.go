@@ -28,6 +28,108 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertCheckRule) DeepCopyInto(out *AlertCheckRule) {
+	*out = *in
+	if in.Matchers != nil {
+		in, out := &in.Matchers, &out.Matchers
+		*out = make([]Parameter, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IntervalSeconds != nil {
+		in, out := &in.IntervalSeconds, &out.IntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertCheckRule.
+func (in *AlertCheckRule) DeepCopy() *AlertCheckRule {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertCheckRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnnotationCheckRule) DeepCopyInto(out *AnnotationCheckRule) {
+	*out = *in
+	if in.Requirements != nil {
+		in, out := &in.Requirements, &out.Requirements
+		*out = make([]AnnotationRequirement, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnnotationCheckRule.
+func (in *AnnotationCheckRule) DeepCopy() *AnnotationCheckRule {
+	if in == nil {
+		return nil
+	}
+	out := new(AnnotationCheckRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AnnotationRequirement) DeepCopyInto(out *AnnotationRequirement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AnnotationRequirement.
+func (in *AnnotationRequirement) DeepCopy() *AnnotationRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(AnnotationRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalStatus) DeepCopyInto(out *ApprovalStatus) {
+	*out = *in
+	if in.Pending != nil {
+		in, out := &in.Pending, &out.Pending
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Approved != nil {
+		in, out := &in.Approved, &out.Approved
+		*out = make([]ApprovedPod, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalStatus.
+func (in *ApprovalStatus) DeepCopy() *ApprovalStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovedPod) DeepCopyInto(out *ApprovedPod) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovedPod.
+func (in *ApprovedPod) DeepCopy() *ApprovedPod {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovedPod)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AvailableRule) DeepCopyInto(out *AvailableRule) {
 	*out = *in
@@ -41,6 +143,11 @@ func (in *AvailableRule) DeepCopyInto(out *AvailableRule) {
 		*out = new(intstr.IntOrString)
 		**out = **in
 	}
+	if in.ContainerReadiness != nil {
+		in, out := &in.ContainerReadiness, &out.ContainerReadiness
+		*out = new(ContainerReadiness)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AvailableRule.
@@ -53,6 +160,28 @@ func (in *AvailableRule) DeepCopy() *AvailableRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AvailableStatus) DeepCopyInto(out *AvailableStatus) {
+	*out = *in
+	if in.UnavailableReasons != nil {
+		in, out := &in.UnavailableReasons, &out.UnavailableReasons
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AvailableStatus.
+func (in *AvailableStatus) DeepCopy() *AvailableStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AvailableStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ByLabel) DeepCopyInto(out *ByLabel) {
 	*out = *in
@@ -88,6 +217,46 @@ func (in *ByPartition) DeepCopy() *ByPartition {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryRule) DeepCopyInto(out *CanaryRule) {
+	*out = *in
+	out.Count = in.Count
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRule.
+func (in *CanaryRule) DeepCopy() *CanaryRule {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
+	*out = *in
+	if in.Released != nil {
+		in, out := &in.Released, &out.Released
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HealthySince != nil {
+		in, out := &in.HealthySince, &out.HealthySince
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStatus.
+func (in *CanaryStatus) DeepCopy() *CanaryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CanaryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClientConfigBeta1) DeepCopyInto(out *ClientConfigBeta1) {
 	*out = *in
@@ -96,6 +265,16 @@ func (in *ClientConfigBeta1) DeepCopyInto(out *ClientConfigBeta1) {
 		*out = new(Poll)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.ConnectionPool != nil {
+		in, out := &in.ConnectionPool, &out.ConnectionPool
+		*out = new(ConnectionPool)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClientCertSecretRef != nil {
+		in, out := &in.ClientCertSecretRef, &out.ClientCertSecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClientConfigBeta1.
@@ -235,50 +414,320 @@ func (in *CollaSetStatus) DeepCopyInto(out *CollaSetStatus) {
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollaSetStatus.
-func (in *CollaSetStatus) DeepCopy() *CollaSetStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CollaSetStatus.
+func (in *CollaSetStatus) DeepCopy() *CollaSetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CollaSetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CompressedStatusReference) DeepCopyInto(out *CompressedStatusReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CompressedStatusReference.
+func (in *CompressedStatusReference) DeepCopy() *CompressedStatusReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CompressedStatusReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMapRulesRef) DeepCopyInto(out *ConfigMapRulesRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMapRulesRef.
+func (in *ConfigMapRulesRef) DeepCopy() *ConfigMapRulesRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMapRulesRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionDrainRule) DeepCopyInto(out *ConnectionDrainRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionDrainRule.
+func (in *ConnectionDrainRule) DeepCopy() *ConnectionDrainRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionDrainRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConnectionPool) DeepCopyInto(out *ConnectionPool) {
+	*out = *in
+	if in.MaxIdleConnsPerHost != nil {
+		in, out := &in.MaxIdleConnsPerHost, &out.MaxIdleConnsPerHost
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IdleTimeoutSeconds != nil {
+		in, out := &in.IdleTimeoutSeconds, &out.IdleTimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.KeepAliveSeconds != nil {
+		in, out := &in.KeepAliveSeconds, &out.KeepAliveSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConnectionPool.
+func (in *ConnectionPool) DeepCopy() *ConnectionPool {
+	if in == nil {
+		return nil
+	}
+	out := new(ConnectionPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerPatch) DeepCopyInto(out *ContainerPatch) {
+	*out = *in
+	in.Container.DeepCopyInto(&out.Container)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerPatch.
+func (in *ContainerPatch) DeepCopy() *ContainerPatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerPatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContainerReadiness) DeepCopyInto(out *ContainerReadiness) {
+	*out = *in
+	if in.ContainerNames != nil {
+		in, out := &in.ContainerNames, &out.ContainerNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerReadiness.
+func (in *ContainerReadiness) DeepCopy() *ContainerReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(ContainerReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ContextDetail) DeepCopyInto(out *ContextDetail) {
+	*out = *in
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContextDetail.
+func (in *ContextDetail) DeepCopy() *ContextDetail {
+	if in == nil {
+		return nil
+	}
+	out := new(ContextDetail)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CooldownStatus) DeepCopyInto(out *CooldownStatus) {
+	*out = *in
+	if in.LastPassTime != nil {
+		in, out := &in.LastPassTime, &out.LastPassTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CooldownStatus.
+func (in *CooldownStatus) DeepCopy() *CooldownStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CooldownStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CustomRule) DeepCopyInto(out *CustomRule) {
+	*out = *in
+	if in.Params != nil {
+		in, out := &in.Params, &out.Params
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CustomRule.
+func (in *CustomRule) DeepCopy() *CustomRule {
+	if in == nil {
+		return nil
+	}
+	out := new(CustomRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DailyWindow) DeepCopyInto(out *DailyWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DailyWindow.
+func (in *DailyWindow) DeepCopy() *DailyWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(DailyWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DelayInfo) DeepCopyInto(out *DelayInfo) {
+	*out = *in
+	in.DelayUntil.DeepCopyInto(&out.DelayUntil)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DelayInfo.
+func (in *DelayInfo) DeepCopy() *DelayInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(DelayInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeletionProtection) DeepCopyInto(out *DeletionProtection) {
+	*out = *in
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeletionProtection.
+func (in *DeletionProtection) DeepCopy() *DeletionProtection {
+	if in == nil {
+		return nil
+	}
+	out := new(DeletionProtection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainStatus) DeepCopyInto(out *DrainStatus) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]DrainingPod, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainStatus.
+func (in *DrainStatus) DeepCopy() *DrainStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainingPod) DeepCopyInto(out *DrainingPod) {
+	*out = *in
+	in.BeginTime.DeepCopyInto(&out.BeginTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainingPod.
+func (in *DrainingPod) DeepCopy() *DrainingPod {
 	if in == nil {
 		return nil
 	}
-	out := new(CollaSetStatus)
+	out := new(DrainingPod)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContainerPatch) DeepCopyInto(out *ContainerPatch) {
+func (in *ExpressionRule) DeepCopyInto(out *ExpressionRule) {
 	*out = *in
-	in.Container.DeepCopyInto(&out.Container)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContainerPatch.
-func (in *ContainerPatch) DeepCopy() *ContainerPatch {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExpressionRule.
+func (in *ExpressionRule) DeepCopy() *ExpressionRule {
 	if in == nil {
 		return nil
 	}
-	out := new(ContainerPatch)
+	out := new(ExpressionRule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ContextDetail) DeepCopyInto(out *ContextDetail) {
+func (in *InProgressStatus) DeepCopyInto(out *InProgressStatus) {
 	*out = *in
-	if in.Data != nil {
-		in, out := &in.Data, &out.Data
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ContextDetail.
-func (in *ContextDetail) DeepCopy() *ContextDetail {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InProgressStatus.
+func (in *InProgressStatus) DeepCopy() *InProgressStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ContextDetail)
+	out := new(InProgressStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -303,6 +752,41 @@ func (in *LabelCheckRule) DeepCopy() *LabelCheckRule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManualApprovalRule) DeepCopyInto(out *ManualApprovalRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManualApprovalRule.
+func (in *ManualApprovalRule) DeepCopy() *ManualApprovalRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ManualApprovalRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PDBRefRule) DeepCopyInto(out *PDBRefRule) {
+	*out = *in
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(FailurePolicyType)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PDBRefRule.
+func (in *PDBRefRule) DeepCopy() *PDBRefRule {
+	if in == nil {
+		return nil
+	}
+	out := new(PDBRefRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Parameter) DeepCopyInto(out *Parameter) {
 	*out = *in
@@ -343,6 +827,41 @@ func (in *ParameterSource) DeepCopy() *ParameterSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PayloadField) DeepCopyInto(out *PayloadField) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PayloadField.
+func (in *PayloadField) DeepCopy() *PayloadField {
+	if in == nil {
+		return nil
+	}
+	out := new(PayloadField)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PayloadTemplate) DeepCopyInto(out *PayloadTemplate) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]PayloadField, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PayloadTemplate.
+func (in *PayloadTemplate) DeepCopy() *PayloadTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PayloadTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PersistentVolumeClaimRetentionPolicy) DeepCopyInto(out *PersistentVolumeClaimRetentionPolicy) {
 	*out = *in
@@ -745,6 +1264,26 @@ func (in *PodDecorationWorkloadDetail) DeepCopy() *PodDecorationWorkloadDetail {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodStabilityRule) DeepCopyInto(out *PodStabilityRule) {
+	*out = *in
+	if in.BlockingReasons != nil {
+		in, out := &in.BlockingReasons, &out.BlockingReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodStabilityRule.
+func (in *PodStabilityRule) DeepCopy() *PodStabilityRule {
+	if in == nil {
+		return nil
+	}
+	out := new(PodStabilityRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodTransitionDetail) DeepCopyInto(out *PodTransitionDetail) {
 	*out = *in
@@ -753,11 +1292,35 @@ func (in *PodTransitionDetail) DeepCopyInto(out *PodTransitionDetail) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RejectedRules != nil {
+		in, out := &in.RejectedRules, &out.RejectedRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingRules != nil {
+		in, out := &in.PendingRules, &out.PendingRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.RejectInfo != nil {
 		in, out := &in.RejectInfo, &out.RejectInfo
 		*out = make([]RejectInfo, len(*in))
 		copy(*out, *in)
 	}
+	if in.DelayInfo != nil {
+		in, out := &in.DelayInfo, &out.DelayInfo
+		*out = make([]DelayInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RejectHistory != nil {
+		in, out := &in.RejectHistory, &out.RejectHistory
+		*out = make([]RejectEvent, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionDetail.
@@ -797,6 +1360,22 @@ func (in *PodTransitionRule) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTransitionRuleCondition) DeepCopyInto(out *PodTransitionRuleCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleCondition.
+func (in *PodTransitionRuleCondition) DeepCopy() *PodTransitionRuleCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTransitionRuleCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodTransitionRuleList) DeepCopyInto(out *PodTransitionRuleList) {
 	*out = *in
@@ -829,6 +1408,21 @@ func (in *PodTransitionRuleList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTransitionRuleReadinessGate) DeepCopyInto(out *PodTransitionRuleReadinessGate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleReadinessGate.
+func (in *PodTransitionRuleReadinessGate) DeepCopy() *PodTransitionRuleReadinessGate {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTransitionRuleReadinessGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodTransitionRuleSpec) DeepCopyInto(out *PodTransitionRuleSpec) {
 	*out = *in
@@ -837,6 +1431,18 @@ func (in *PodTransitionRuleSpec) DeepCopyInto(out *PodTransitionRuleSpec) {
 		*out = new(v1.LabelSelector)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.SelectorTerms != nil {
+		in, out := &in.SelectorTerms, &out.SelectorTerms
+		*out = make([]v1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Rules != nil {
 		in, out := &in.Rules, &out.Rules
 		*out = make([]TransitionRule, len(*in))
@@ -844,6 +1450,21 @@ func (in *PodTransitionRuleSpec) DeepCopyInto(out *PodTransitionRuleSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RulesFrom != nil {
+		in, out := &in.RulesFrom, &out.RulesFrom
+		*out = make([]ConfigMapRulesRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.ReadinessGate != nil {
+		in, out := &in.ReadinessGate, &out.ReadinessGate
+		*out = new(PodTransitionRuleReadinessGate)
+		**out = **in
+	}
+	if in.DeletionProtection != nil {
+		in, out := &in.DeletionProtection, &out.DeletionProtection
+		*out = new(DeletionProtection)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleSpec.
@@ -863,41 +1484,166 @@ func (in *PodTransitionRuleStatus) DeepCopyInto(out *PodTransitionRuleStatus) {
 		in, out := &in.UpdateTime, &out.UpdateTime
 		*out = (*in).DeepCopy()
 	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
 	if in.Targets != nil {
 		in, out := &in.Targets, &out.Targets
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.RuleStates != nil {
-		in, out := &in.RuleStates, &out.RuleStates
-		*out = make([]*RuleState, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(RuleState)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
-	if in.Details != nil {
-		in, out := &in.Details, &out.Details
-		*out = make([]*PodTransitionDetail, len(*in))
-		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = new(PodTransitionDetail)
-				(*in).DeepCopyInto(*out)
-			}
-		}
-	}
+	if in.RuleStates != nil {
+		in, out := &in.RuleStates, &out.RuleStates
+		*out = make([]*RuleState, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(RuleState)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.RuleStatesRef != nil {
+		in, out := &in.RuleStatesRef, &out.RuleStatesRef
+		*out = new(RuleStatesReference)
+		**out = **in
+	}
+	if in.Details != nil {
+		in, out := &in.Details, &out.Details
+		*out = make([]*PodTransitionDetail, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(PodTransitionDetail)
+				(*in).DeepCopyInto(*out)
+			}
+		}
+	}
+	if in.CompressedStatusRef != nil {
+		in, out := &in.CompressedStatusRef, &out.CompressedStatusRef
+		*out = new(CompressedStatusReference)
+		**out = **in
+	}
+	if in.DeletionBlockedSince != nil {
+		in, out := &in.DeletionBlockedSince, &out.DeletionBlockedSince
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]PodTransitionRuleCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleStatus.
+func (in *PodTransitionRuleStatus) DeepCopy() *PodTransitionRuleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTransitionRuleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTransitionRuleTemplate) DeepCopyInto(out *PodTransitionRuleTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleTemplate.
+func (in *PodTransitionRuleTemplate) DeepCopy() *PodTransitionRuleTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTransitionRuleTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PodTransitionRuleTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTransitionRuleTemplateList) DeepCopyInto(out *PodTransitionRuleTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PodTransitionRuleTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleTemplateList.
+func (in *PodTransitionRuleTemplateList) DeepCopy() *PodTransitionRuleTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTransitionRuleTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PodTransitionRuleTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTransitionRuleTemplateSpec) DeepCopyInto(out *PodTransitionRuleTemplateSpec) {
+	*out = *in
+	if in.WorkloadSelector != nil {
+		in, out := &in.WorkloadSelector, &out.WorkloadSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleTemplateSpec.
+func (in *PodTransitionRuleTemplateSpec) DeepCopy() *PodTransitionRuleTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PodTransitionRuleTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodTransitionRuleTemplateStatus) DeepCopyInto(out *PodTransitionRuleTemplateStatus) {
+	*out = *in
+	if in.PodTransitionRules != nil {
+		in, out := &in.PodTransitionRules, &out.PodTransitionRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleStatus.
-func (in *PodTransitionRuleStatus) DeepCopy() *PodTransitionRuleStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodTransitionRuleTemplateStatus.
+func (in *PodTransitionRuleTemplateStatus) DeepCopy() *PodTransitionRuleTemplateStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PodTransitionRuleStatus)
+	out := new(PodTransitionRuleTemplateStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -935,6 +1681,10 @@ func (in *PollResponse) DeepCopyInto(out *PollResponse) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DelayUntil != nil {
+		in, out := &in.DelayUntil, &out.DelayUntil
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PollResponse.
@@ -963,6 +1713,22 @@ func (in *PrimaryContainerPatch) DeepCopy() *PrimaryContainerPatch {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RejectEvent) DeepCopyInto(out *RejectEvent) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RejectEvent.
+func (in *RejectEvent) DeepCopy() *RejectEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(RejectEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RejectInfo) DeepCopyInto(out *RejectInfo) {
 	*out = *in
@@ -1080,6 +1846,31 @@ func (in *ResourceParameter) DeepCopy() *ResourceParameter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceStateRule) DeepCopyInto(out *ResourceStateRule) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlockingValues != nil {
+		in, out := &in.BlockingValues, &out.BlockingValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStateRule.
+func (in *ResourceStateRule) DeepCopy() *ResourceStateRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceStateRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RollingUpdateCollaSetStrategy) DeepCopyInto(out *RollingUpdateCollaSetStrategy) {
 	*out = *in
@@ -1113,6 +1904,48 @@ func (in *RuleState) DeepCopyInto(out *RuleState) {
 		*out = new(WebhookStatus)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.WebhookStates != nil {
+		in, out := &in.WebhookStates, &out.WebhookStates
+		*out = make([]WebhookState, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DrainStatus != nil {
+		in, out := &in.DrainStatus, &out.DrainStatus
+		*out = new(DrainStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ApprovalStatus != nil {
+		in, out := &in.ApprovalStatus, &out.ApprovalStatus
+		*out = new(ApprovalStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CooldownStatus != nil {
+		in, out := &in.CooldownStatus, &out.CooldownStatus
+		*out = new(CooldownStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InProgressStatus != nil {
+		in, out := &in.InProgressStatus, &out.InProgressStatus
+		*out = new(InProgressStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CanaryStatus != nil {
+		in, out := &in.CanaryStatus, &out.CanaryStatus
+		*out = new(CanaryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AvailableStatus != nil {
+		in, out := &in.AvailableStatus, &out.AvailableStatus
+		*out = new(AvailableStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(RuleStateSummary)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleState.
@@ -1125,6 +1958,36 @@ func (in *RuleState) DeepCopy() *RuleState {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleStateSummary) DeepCopyInto(out *RuleStateSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleStateSummary.
+func (in *RuleStateSummary) DeepCopy() *RuleStateSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleStateSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleStatesReference) DeepCopyInto(out *RuleStatesReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleStatesReference.
+func (in *RuleStatesReference) DeepCopy() *RuleStatesReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleStatesReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ScaleStrategy) DeepCopyInto(out *ScaleStrategy) {
 	*out = *in
@@ -1160,6 +2023,21 @@ func (in *ScaleStrategy) DeepCopy() *ScaleStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SidecarDrainRule) DeepCopyInto(out *SidecarDrainRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SidecarDrainRule.
+func (in *SidecarDrainRule) DeepCopy() *SidecarDrainRule {
+	if in == nil {
+		return nil
+	}
+	out := new(SidecarDrainRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TaskInfo) DeepCopyInto(out *TaskInfo) {
 	*out = *in
@@ -1193,6 +2071,48 @@ func (in *TaskInfo) DeepCopy() *TaskInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeWindowRule) DeepCopyInto(out *TimeWindowRule) {
+	*out = *in
+	if in.Daily != nil {
+		in, out := &in.Daily, &out.Daily
+		*out = make([]DailyWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeWindowRule.
+func (in *TimeWindowRule) DeepCopy() *TimeWindowRule {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeWindowRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TopologySpreadRule) DeepCopyInto(out *TopologySpreadRule) {
+	*out = *in
+	if in.MinAvailablePerDomain != nil {
+		in, out := &in.MinAvailablePerDomain, &out.MinAvailablePerDomain
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TopologySpreadRule.
+func (in *TopologySpreadRule) DeepCopy() *TopologySpreadRule {
+	if in == nil {
+		return nil
+	}
+	out := new(TopologySpreadRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TransitionRule) DeepCopyInto(out *TransitionRule) {
 	*out = *in
@@ -1242,6 +2162,81 @@ func (in *TransitionRuleDefinition) DeepCopyInto(out *TransitionRuleDefinition)
 		*out = new(TransitionRuleWebhook)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TopologySpread != nil {
+		in, out := &in.TopologySpread, &out.TopologySpread
+		*out = new(TopologySpreadRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkloadRollout != nil {
+		in, out := &in.WorkloadRollout, &out.WorkloadRollout
+		*out = new(WorkloadRolloutRule)
+		**out = **in
+	}
+	if in.ResourceState != nil {
+		in, out := &in.ResourceState, &out.ResourceState
+		*out = new(ResourceStateRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConnectionDrain != nil {
+		in, out := &in.ConnectionDrain, &out.ConnectionDrain
+		*out = new(ConnectionDrainRule)
+		**out = **in
+	}
+	if in.SidecarDrain != nil {
+		in, out := &in.SidecarDrain, &out.SidecarDrain
+		*out = new(SidecarDrainRule)
+		**out = **in
+	}
+	if in.ManualApproval != nil {
+		in, out := &in.ManualApproval, &out.ManualApproval
+		*out = new(ManualApprovalRule)
+		**out = **in
+	}
+	if in.PodStability != nil {
+		in, out := &in.PodStability, &out.PodStability
+		*out = new(PodStabilityRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AnnotationCheck != nil {
+		in, out := &in.AnnotationCheck, &out.AnnotationCheck
+		*out = new(AnnotationCheckRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VolumeCheck != nil {
+		in, out := &in.VolumeCheck, &out.VolumeCheck
+		*out = new(VolumeCheckRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AlertCheck != nil {
+		in, out := &in.AlertCheck, &out.AlertCheck
+		*out = new(AlertCheckRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TimeWindow != nil {
+		in, out := &in.TimeWindow, &out.TimeWindow
+		*out = new(TimeWindowRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Expression != nil {
+		in, out := &in.Expression, &out.Expression
+		*out = new(ExpressionRule)
+		**out = **in
+	}
+	if in.PDBRef != nil {
+		in, out := &in.PDBRef, &out.PDBRef
+		*out = new(PDBRefRule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(CanaryRule)
+		**out = **in
+	}
+	if in.Custom != nil {
+		in, out := &in.Custom, &out.Custom
+		*out = new(CustomRule)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransitionRuleDefinition.
@@ -1283,6 +2278,16 @@ func (in *TransitionRuleWebhook) DeepCopyInto(out *TransitionRuleWebhook) {
 		*out = new(FailurePolicyType)
 		**out = **in
 	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(WebhookRetry)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Parameters != nil {
 		in, out := &in.Parameters, &out.Parameters
 		*out = make([]Parameter, len(*in))
@@ -1290,6 +2295,16 @@ func (in *TransitionRuleWebhook) DeepCopyInto(out *TransitionRuleWebhook) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RedactHeaders != nil {
+		in, out := &in.RedactHeaders, &out.RedactHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PayloadTemplate != nil {
+		in, out := &in.PayloadTemplate, &out.PayloadTemplate
+		*out = new(PayloadTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransitionRuleWebhook.
@@ -1327,6 +2342,26 @@ func (in *UpdateStrategy) DeepCopy() *UpdateStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeCheckRule) DeepCopyInto(out *VolumeCheckRule) {
+	*out = *in
+	if in.SafePhases != nil {
+		in, out := &in.SafePhases, &out.SafePhases
+		*out = make([]corev1.PersistentVolumePhase, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeCheckRule.
+func (in *VolumeCheckRule) DeepCopy() *VolumeCheckRule {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeCheckRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebhookRequest) DeepCopyInto(out *WebhookRequest) {
 	*out = *in
@@ -1362,6 +2397,10 @@ func (in *WebhookResponse) DeepCopyInto(out *WebhookResponse) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.DelayUntil != nil {
+		in, out := &in.DelayUntil, &out.DelayUntil
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookResponse.
@@ -1374,6 +2413,78 @@ func (in *WebhookResponse) DeepCopy() *WebhookResponse {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookRetry) DeepCopyInto(out *WebhookRetry) {
+	*out = *in
+	if in.BackoffSeconds != nil {
+		in, out := &in.BackoffSeconds, &out.BackoffSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxBackoffSeconds != nil {
+		in, out := &in.MaxBackoffSeconds, &out.MaxBackoffSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookRetry.
+func (in *WebhookRetry) DeepCopy() *WebhookRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookRetryStatus) DeepCopyInto(out *WebhookRetryStatus) {
+	*out = *in
+	if in.NextAttemptTime != nil {
+		in, out := &in.NextAttemptTime, &out.NextAttemptTime
+		*out = (*in).DeepCopy()
+	}
+	if in.IgnoredPods != nil {
+		in, out := &in.IgnoredPods, &out.IgnoredPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookRetryStatus.
+func (in *WebhookRetryStatus) DeepCopy() *WebhookRetryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookRetryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookState) DeepCopyInto(out *WebhookState) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.LastCallTime.DeepCopyInto(&out.LastCallTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookState.
+func (in *WebhookState) DeepCopy() *WebhookState {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookState)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebhookStatus) DeepCopyInto(out *WebhookStatus) {
 	*out = *in
@@ -1391,6 +2502,11 @@ func (in *WebhookStatus) DeepCopyInto(out *WebhookStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RetryStatus != nil {
+		in, out := &in.RetryStatus, &out.RetryStatus
+		*out = new(WebhookRetryStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookStatus.
@@ -1402,3 +2518,18 @@ func (in *WebhookStatus) DeepCopy() *WebhookStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadRolloutRule) DeepCopyInto(out *WorkloadRolloutRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadRolloutRule.
+func (in *WorkloadRolloutRule) DeepCopy() *WorkloadRolloutRule {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadRolloutRule)
+	in.DeepCopyInto(out)
+	return out
+}
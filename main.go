@@ -34,9 +34,11 @@ import (
 	"kusionstack.io/operating/apis"
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	"kusionstack.io/operating/pkg/controllers"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule"
 	"kusionstack.io/operating/pkg/utils/feature"
 	"kusionstack.io/operating/pkg/utils/inject"
 	"kusionstack.io/operating/pkg/webhook"
+	podtransitionrulewebhook "kusionstack.io/operating/pkg/webhook/server/generic/podtransitionrule"
 
 	_ "kusionstack.io/operating/pkg/features"
 	//+kubebuilder:scaffold:imports
@@ -67,6 +69,9 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&certDir, "cert-dir", webhookTempCertDir(), "The directory that contains the server key and certificate. If not set, webhook server would look up the server key and certificate in {TempDir}/k8s-webhook-server/serving-certs")
 	flag.StringVar(&dnsName, "dns-name", "kusionstack-controller-manager.kusionstack-system.svc", "The DNS name of the webhook server.")
+	podTransitionRuleOptions := podtransitionrule.NewOptions()
+	podTransitionRuleOptions.AddFlags(flag.CommandLine)
+	podtransitionrulewebhook.AddFlags(flag.CommandLine)
 
 	klog.InitFlags(nil)
 	defer klog.Flush()
@@ -111,6 +116,7 @@ func main() {
 		os.Exit(1)
 	}
 
+	podtransitionrule.SetOptions(podtransitionrule.WithOptions(podTransitionRuleOptions))
 	if err = controllers.AddToManager(mgr); err != nil {
 		setupLog.Error(err, "unable to add controller")
 		os.Exit(1)
@@ -0,0 +1,163 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	commonutils "kusionstack.io/operating/pkg/utils"
+)
+
+// recordApprovalEvents emits events for pods newly pending manual approval and pods newly approved
+func (r *PodTransitionRuleReconciler) recordApprovalEvents(podTransitionRule *appsv1alpha1.PodTransitionRule, oldStates, newStates []*appsv1alpha1.RuleState) {
+	if r.Recorder == nil {
+		return
+	}
+	oldPending, oldApproved := map[string]sets.String{}, map[string]sets.String{}
+	for _, state := range oldStates {
+		if state == nil || state.ApprovalStatus == nil {
+			continue
+		}
+		oldPending[state.Name] = sets.NewString(state.ApprovalStatus.Pending...)
+		oldApproved[state.Name] = sets.NewString()
+		for _, pod := range state.ApprovalStatus.Approved {
+			oldApproved[state.Name].Insert(pod.Name)
+		}
+	}
+	for _, state := range newStates {
+		if state == nil || state.ApprovalStatus == nil {
+			continue
+		}
+		var pending []string
+		for _, podName := range state.ApprovalStatus.Pending {
+			if !oldPending[state.Name].Has(podName) {
+				pending = append(pending, podName)
+			}
+		}
+		if label := approvalLabels(podTransitionRule)[state.Name]; len(pending) > 0 && label != "" {
+			r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "AwaitingApproval", "[%s] pods awaiting approval by label %s=true: %s", state.Name, label, strings.Join(pending, ", "))
+		} else if len(pending) > 0 {
+			r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "PendingApproval", "[%s] pods pending manual approval: %s", state.Name, strings.Join(pending, ", "))
+		}
+		for _, pod := range state.ApprovalStatus.Approved {
+			if !oldApproved[state.Name].Has(pod.Name) {
+				r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "Approved", "[%s] pod %s approved by %s", state.Name, pod.Name, pod.Approver)
+			}
+		}
+	}
+}
+
+// approveAnnotationsChanged returns true if annotations approving pods on podTransitionRule are changed
+func approveAnnotationsChanged(oldPodTransitionRule, newPodTransitionRule *appsv1alpha1.PodTransitionRule) bool {
+	prefix := appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix + "/"
+	for key, val := range newPodTransitionRule.Annotations {
+		if strings.HasPrefix(key, prefix) && oldPodTransitionRule.Annotations[key] != val {
+			return true
+		}
+	}
+	for key := range oldPodTransitionRule.Annotations {
+		if _, ok := newPodTransitionRule.Annotations[key]; strings.HasPrefix(key, prefix) && !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// approvalLabels returns approval labels of manual approval rules keyed by rule name
+func approvalLabels(podTransitionRule *appsv1alpha1.PodTransitionRule) map[string]string {
+	labels := map[string]string{}
+	for _, rule := range podTransitionRule.Spec.Rules {
+		if rule.ManualApproval != nil && rule.ManualApproval.ApprovalLabel != "" {
+			labels[rule.Name] = rule.ManualApproval.ApprovalLabel
+		}
+	}
+	return labels
+}
+
+// approvalLabelsChanged returns true if approval labels of podTransitionRule are changed
+func approvalLabelsChanged(oldPodTransitionRule, newPodTransitionRule *appsv1alpha1.PodTransitionRule) bool {
+	for _, label := range approvalLabels(newPodTransitionRule) {
+		if oldPodTransitionRule.Labels[label] != newPodTransitionRule.Labels[label] {
+			return true
+		}
+	}
+	return false
+}
+
+// consumedApprovalLabels returns approval labels of one-shot rules which newly approved pods, they are removed
+// from podTransitionRule once the approval is persisted
+func consumedApprovalLabels(podTransitionRule *appsv1alpha1.PodTransitionRule, oldStates, newStates []*appsv1alpha1.RuleState) []string {
+	oneShot := map[string]string{}
+	for _, rule := range podTransitionRule.Spec.Rules {
+		if rule.ManualApproval != nil && rule.ManualApproval.OneShot && rule.ManualApproval.ApprovalLabel != "" &&
+			podTransitionRule.Labels[rule.ManualApproval.ApprovalLabel] == "true" {
+			oneShot[rule.Name] = rule.ManualApproval.ApprovalLabel
+		}
+	}
+	if len(oneShot) == 0 {
+		return nil
+	}
+	oldApproved := map[string]sets.String{}
+	for _, state := range oldStates {
+		if state == nil || state.ApprovalStatus == nil {
+			continue
+		}
+		oldApproved[state.Name] = sets.NewString()
+		for _, pod := range state.ApprovalStatus.Approved {
+			oldApproved[state.Name].Insert(pod.Name)
+		}
+	}
+	consumed := sets.NewString()
+	for _, state := range newStates {
+		label, ok := oneShot[state.Name]
+		if !ok || state.ApprovalStatus == nil {
+			continue
+		}
+		for _, pod := range state.ApprovalStatus.Approved {
+			if !oldApproved[state.Name].Has(pod.Name) {
+				consumed.Insert(label)
+				break
+			}
+		}
+	}
+	return consumed.List()
+}
+
+// removeApprovalLabels removes consumed approval labels from podTransitionRule
+func (r *PodTransitionRuleReconciler) removeApprovalLabels(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	original := podTransitionRule.DeepCopy()
+	for _, label := range labels {
+		delete(podTransitionRule.Labels, label)
+	}
+	if err := r.Client.Patch(ctx, podTransitionRule, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("fail to remove approval labels %v from PodTransitionRule %s: %v", labels, commonutils.ObjectKeyString(podTransitionRule), err)
+	}
+	if r.Recorder != nil {
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "ApprovalConsumed", "one-shot approval labels are removed: %s", strings.Join(labels, ", "))
+	}
+	return nil
+}
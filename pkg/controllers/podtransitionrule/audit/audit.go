@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"k8s.io/klog/v2"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	utilshttp "kusionstack.io/operating/pkg/utils/http"
+)
+
+type Format string
+
+const (
+	// FormatOPA is compatible with OPA decision log format
+	FormatOPA Format = "opa"
+	// FormatJSON posts Decisions as they are
+	FormatJSON Format = "json"
+)
+
+const (
+	bufferSize  = 1 << 10
+	maxRetries  = 3
+	retryPeriod = time.Second
+	keysTimeout = 10 * time.Second
+)
+
+// Decision is the block or unblock decision of PodTransitionRule on a pod
+type Decision struct {
+	Namespace         string                    `json:"namespace"`
+	PodTransitionRule string                    `json:"podTransitionRule"`
+	Pod               string                    `json:"pod"`
+	Stage             string                    `json:"stage"`
+	Passed            bool                      `json:"passed"`
+	PassedRules       []string                  `json:"passedRules,omitempty"`
+	RejectInfo        []appsv1alpha1.RejectInfo `json:"rejectInfo,omitempty"`
+	Timestamp         time.Time                 `json:"timestamp"`
+}
+
+// OPADecisionLog is the event of OPA decision log
+type OPADecisionLog struct {
+	DecisionId string            `json:"decision_id"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Path       string            `json:"path"`
+	Input      interface{}       `json:"input"`
+	Result     interface{}       `json:"result"`
+	Timestamp  string            `json:"timestamp"`
+}
+
+// Sink posts decisions to endpoint asynchronously, it never blocks the caller.
+type Sink struct {
+	endpoint string
+	format   Format
+	// keys signs payloads in SignatureHeader, nil means payloads are not signed
+	keys KeySource
+	ch   chan []Decision
+}
+
+// NewSink returns nil if endpoint is empty, payloads are signed with keys if it is not nil
+func NewSink(endpoint string, format Format, keys KeySource) (*Sink, error) {
+	if endpoint == "" {
+		return nil, nil
+	}
+	if format != FormatOPA && format != FormatJSON {
+		return nil, fmt.Errorf("unsupported audit format %q", format)
+	}
+	s := &Sink{
+		endpoint: endpoint,
+		format:   format,
+		keys:     keys,
+		ch:       make(chan []Decision, bufferSize),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Send enqueues decisions, decisions are dropped if the buffer is full
+func (s *Sink) Send(decisions []Decision) {
+	if s == nil || len(decisions) == 0 {
+		return
+	}
+	select {
+	case s.ch <- decisions:
+	default:
+		klog.Warningf("podtransitionrule audit buffer is full, drop %d decisions", len(decisions))
+	}
+}
+
+func (s *Sink) run() {
+	for decisions := range s.ch {
+		body, err := json.Marshal(s.payload(decisions))
+		if err != nil {
+			klog.Errorf("failed to marshal %d podtransitionrule decisions: %v", len(decisions), err)
+			continue
+		}
+		for i := 0; i < maxRetries; i++ {
+			if err = s.post(body); err == nil {
+				break
+			}
+			time.Sleep(retryPeriod * time.Duration(i+1))
+		}
+		if err != nil {
+			klog.Errorf("failed to post %d podtransitionrule decisions to %s: %v", len(decisions), s.endpoint, err)
+		}
+	}
+}
+
+func (s *Sink) post(body []byte) error {
+	var header map[string]string
+	if s.keys != nil {
+		// keys are fetched on each post, so that rotated keys take effect without restart
+		ctx, cancel := context.WithTimeout(context.Background(), keysTimeout)
+		keys, err := s.keys(ctx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to get signing keys, %v", err)
+		}
+		header = map[string]string{SignatureHeader: Sign(keys, time.Now(), body)}
+	}
+	resp, err := utilshttp.DoHttpAndHttpsRequestWithCa(http.MethodPost, s.endpoint, body, header, "")
+	if err != nil {
+		return err
+	}
+	return utilshttp.ParseResponse(resp, nil)
+}
+
+func (s *Sink) payload(decisions []Decision) interface{} {
+	if s.format == FormatJSON {
+		return decisions
+	}
+	logs := make([]OPADecisionLog, 0, len(decisions))
+	for i := range decisions {
+		logs = append(logs, ToOPADecisionLog(&decisions[i]))
+	}
+	return logs
+}
+
+func ToOPADecisionLog(d *Decision) OPADecisionLog {
+	return OPADecisionLog{
+		DecisionId: uuid.New().String(),
+		Labels: map[string]string{
+			"app": "kusionstack-operating",
+		},
+		Path: "podtransitionrule/" + d.Stage,
+		Input: map[string]string{
+			"namespace":         d.Namespace,
+			"podTransitionRule": d.PodTransitionRule,
+			"pod":               d.Pod,
+			"stage":             d.Stage,
+		},
+		Result: map[string]interface{}{
+			"allow":       d.Passed,
+			"passedRules": d.PassedRules,
+			"rejectInfo":  d.RejectInfo,
+		},
+		Timestamp: d.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// ChangedDecisions returns decisions of pods whose passed state is new or changed
+func ChangedDecisions(rs *appsv1alpha1.PodTransitionRule, old, new []*appsv1alpha1.PodTransitionDetail) []Decision {
+	oldDetails := map[string]*appsv1alpha1.PodTransitionDetail{}
+	for _, detail := range old {
+		oldDetails[detail.Name] = detail
+	}
+	now := time.Now()
+	var decisions []Decision
+	for _, detail := range new {
+		if oldDetail, ok := oldDetails[detail.Name]; ok && oldDetail.Stage == detail.Stage && oldDetail.Passed == detail.Passed {
+			continue
+		}
+		decisions = append(decisions, Decision{
+			Namespace:         rs.Namespace,
+			PodTransitionRule: rs.Name,
+			Pod:               detail.Name,
+			Stage:             detail.Stage,
+			Passed:            detail.Passed,
+			PassedRules:       detail.PassedRules,
+			RejectInfo:        detail.RejectInfo,
+			Timestamp:         now,
+		})
+	}
+	return decisions
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestChangedDecisions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rs"}}
+	old := []*appsv1alpha1.PodTransitionDetail{
+		{Name: "pod-a", Stage: "PreTrafficOff", Passed: false},
+		{Name: "pod-b", Stage: "PreTrafficOff", Passed: true},
+	}
+	new := []*appsv1alpha1.PodTransitionDetail{
+		{Name: "pod-a", Stage: "PreTrafficOff", Passed: true},
+		{Name: "pod-b", Stage: "PreTrafficOff", Passed: true},
+		{Name: "pod-c", Stage: "PreTrafficOff", Passed: false},
+	}
+	decisions := ChangedDecisions(rs, old, new)
+	g.Expect(len(decisions)).Should(gomega.BeEquivalentTo(2))
+	g.Expect(decisions[0].Pod).Should(gomega.BeEquivalentTo("pod-a"))
+	g.Expect(decisions[0].Passed).Should(gomega.BeTrue())
+	g.Expect(decisions[1].Pod).Should(gomega.BeEquivalentTo("pod-c"))
+	g.Expect(decisions[1].Passed).Should(gomega.BeFalse())
+}
+
+func TestSinkOPAFormatWithRetry(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var count int32
+	received := make(chan []OPADecisionLog, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		// fail the first request
+		if atomic.AddInt32(&count, 1) == 1 {
+			http.Error(resp, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var logs []OPADecisionLog
+		if err := json.NewDecoder(req.Body).Decode(&logs); err != nil {
+			http.Error(resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- logs
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(server.URL, FormatOPA, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	sink.Send([]Decision{{Namespace: "default", PodTransitionRule: "rs", Pod: "pod-a", Stage: "PreTrafficOff", Passed: true, Timestamp: time.Now()}})
+
+	var logs []OPADecisionLog
+	g.Eventually(received, 5*time.Second).Should(gomega.Receive(&logs))
+	g.Expect(len(logs)).Should(gomega.BeEquivalentTo(1))
+	g.Expect(logs[0].DecisionId).ShouldNot(gomega.BeEmpty())
+	g.Expect(logs[0].Path).Should(gomega.BeEquivalentTo("podtransitionrule/PreTrafficOff"))
+	g.Expect(logs[0].Result.(map[string]interface{})["allow"]).Should(gomega.BeTrue())
+}
+
+func TestNewSink(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	sink, err := NewSink("", FormatOPA, nil)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(sink).Should(gomega.BeNil())
+	// nil sink is a no-op
+	sink.Send([]Decision{{Pod: "pod-a"}})
+
+	_, err = NewSink("http://127.0.0.1", Format("xml"), nil)
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
+
+func TestSinkSigning(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kusionstack-system", Name: "audit"},
+		Data:       map[string][]byte{"key-1": []byte("old")},
+	}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+	received := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		// receiver has switched to the new key
+		received <- Verify([]byte("new"), req.Header.Get(SignatureHeader), body, time.Now(), time.Minute)
+	}))
+	defer server.Close()
+
+	sink, err := NewSink(server.URL, FormatJSON, SecretKeySource(c, "kusionstack-system", "audit"))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	decisions := []Decision{{Namespace: "default", PodTransitionRule: "rs", Pod: "pod-a", Stage: "PreTrafficOff", Passed: true, Timestamp: time.Now()}}
+	sink.Send(decisions)
+	var verifyErr error
+	g.Eventually(received, 5*time.Second).Should(gomega.Receive(&verifyErr))
+	g.Expect(verifyErr).Should(gomega.HaveOccurred())
+
+	// rotate: new key is added, payloads are signed with both keys
+	secret.Data["key-2"] = []byte("new")
+	g.Expect(c.Update(context.TODO(), secret)).ShouldNot(gomega.HaveOccurred())
+	sink.Send(decisions)
+	g.Eventually(received, 5*time.Second).Should(gomega.Receive(&verifyErr))
+	g.Expect(verifyErr).ShouldNot(gomega.HaveOccurred())
+}
+
+func TestVerify(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	body := []byte(`[{"pod":"pod-a"}]`)
+	now := time.Now()
+	header := Sign([][]byte{[]byte("old"), []byte("new")}, now, body)
+	g.Expect(Verify([]byte("old"), header, body, now, time.Minute)).ShouldNot(gomega.HaveOccurred())
+	g.Expect(Verify([]byte("new"), header, body, now, time.Minute)).ShouldNot(gomega.HaveOccurred())
+	g.Expect(Verify([]byte("other"), header, body, now, time.Minute)).Should(gomega.HaveOccurred())
+	g.Expect(Verify([]byte("new"), header, []byte(`[{"pod":"pod-b"}]`), now, time.Minute)).Should(gomega.HaveOccurred())
+	// replayed request
+	g.Expect(Verify([]byte("new"), header, body, now.Add(10*time.Minute), time.Minute)).Should(gomega.HaveOccurred())
+}
@@ -0,0 +1,125 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SignatureHeader carries the HMAC signatures of an audit payload.
+//
+// The value is "t=<timestamp>,v1=<signature>[,v1=<signature>...]", where timestamp is the unix
+// seconds the request is sent at, and each signature is the lowercase hex of
+// HMAC-SHA256(key, "<timestamp>." + body) signed with one of the keys, body being the raw request body.
+//
+// Receivers verify a request by computing the signature with their key over the timestamp and the
+// raw body, comparing it with each v1 in constant time, and rejecting requests whose timestamp is
+// too far from now to prevent replays.
+//
+// Keys are rotated without downtime by adding the new key to the signing Secret, switching
+// receivers to it, then removing the old key. Payloads are signed with every key in the Secret.
+const SignatureHeader = "X-PodTransitionRule-Signature"
+
+const signatureVersion = "v1"
+
+// KeySource returns the keys payloads are signed with, it is called on each post so that rotated keys take effect
+type KeySource func(ctx context.Context) ([][]byte, error)
+
+// SecretKeySource returns a KeySource of all values of the Secret, in the order of their keys
+func SecretKeySource(reader client.Reader, namespace, name string) KeySource {
+	return func(ctx context.Context) ([][]byte, error) {
+		secret := &corev1.Secret{}
+		if err := reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(secret.Data))
+		for k, v := range secret.Data {
+			if len(v) > 0 {
+				names = append(names, k)
+			}
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no signing key in secret %s/%s", namespace, name)
+		}
+		sort.Strings(names)
+		keys := make([][]byte, 0, len(names))
+		for _, k := range names {
+			keys = append(keys, secret.Data[k])
+		}
+		return keys, nil
+	}
+}
+
+// Sign returns the SignatureHeader value of body sent at t
+func Sign(keys [][]byte, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	parts := make([]string, 0, len(keys)+1)
+	parts = append(parts, "t="+timestamp)
+	for _, key := range keys {
+		parts = append(parts, signatureVersion+"="+signature(key, timestamp, body))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Verify checks header is signed by key over body, and is sent within tolerance before now
+func Verify(key []byte, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case signatureVersion:
+			signatures = append(signatures, v)
+		}
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp %q", timestamp)
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("signature timestamp %s is out of tolerance %s", time.Unix(sec, 0).UTC().Format(time.RFC3339), tolerance)
+	}
+	expected := []byte(signature(key, timestamp, body))
+	for _, s := range signatures {
+		if hmac.Equal(expected, []byte(s)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no matched signature")
+}
+
+func signature(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
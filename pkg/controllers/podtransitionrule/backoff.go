@@ -0,0 +1,75 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// errorBackoffBase is the backoff of the first failed reconcile
+const errorBackoffBase = time.Second
+
+// reconcileFailures counts consecutive failed reconciles of each PodTransitionRule
+type reconcileFailures struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// observe records the result of a reconcile, and returns the number of consecutive failures
+func (f *reconcileFailures) observe(key string, err error) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.counts, key)
+		return 0
+	}
+	if f.counts == nil {
+		f.counts = map[string]int{}
+	}
+	f.counts[key]++
+	return f.counts[key]
+}
+
+// errorBackoff returns the backoff after failures in a row, which doubles from errorBackoffBase until ceiling
+func errorBackoff(failures int, ceiling time.Duration) time.Duration {
+	backoff := errorBackoffBase
+	for i := 1; i < failures && backoff < ceiling; i++ {
+		backoff *= 2
+	}
+	if backoff > ceiling {
+		return ceiling
+	}
+	return backoff
+}
+
+// backoffOnError requeues podTransitionRule failing in a row after a capped exponential backoff instead of returning
+// the error, so that a persistently failing PodTransitionRule does not hammer API server and webhooks.
+func (r *PodTransitionRuleReconciler) backoffOnError(request reconcile.Request, result reconcile.Result, err error) (reconcile.Result, error) {
+	if r.errorBackoffCeiling <= 0 {
+		return result, err
+	}
+	failures := r.reconcileFailures.observe(request.String(), err)
+	if err == nil {
+		return result, nil
+	}
+	backoff := errorBackoff(failures, r.errorBackoffCeiling)
+	r.Logger.Error(err, "failed to reconcile podtransitionrule, requeue after backoff", "podTransitionRule", request.String(), "failures", failures, "backoff", backoff)
+	return reconcile.Result{RequeueAfter: backoff}, nil
+}
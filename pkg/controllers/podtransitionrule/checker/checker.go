@@ -25,6 +25,7 @@ import (
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 	"kusionstack.io/operating/pkg/utils/inject"
 )
 
@@ -53,18 +54,26 @@ func (c *checker) GetState(ctx context.Context, cl client.Client, item client.Ob
 	}
 	for i := range podTransitionRuleList.Items {
 		rs := &podTransitionRuleList.Items[i]
+		// verdicts of dry run PodTransitionRules are not enforced
+		if rs.Spec.DryRun {
+			continue
+		}
+		details, err := utils.GetDetails(ctx, cl, rs)
+		if err != nil {
+			return result, err
+		}
 		findStatus := false
-		for j, detail := range rs.Status.Details {
-			if detail.Name != item.GetName() {
+		for j, detail := range details {
+			if detail.Name != item.GetName() && detail.Name != item.GetNamespace()+"/"+item.GetName() {
 				continue
 			}
 			findStatus = true
 			if !detail.Passed {
-				result.Message += CollectInfo(rs.Name, rs.Status.Details[j])
+				result.Message += CollectInfo(rs.Name, details[j])
 			}
 			result.States = append(result.States, State{
 				PodTransitionRuleName: rs.Name,
-				Detail:                rs.Status.Details[j],
+				Detail:                details[j],
 			})
 			break
 		}
@@ -117,6 +126,9 @@ type State struct {
 func CollectInfo(podtransitionrule string, detail *appsv1alpha1.PodTransitionDetail) string {
 	res := ""
 	for _, rej := range detail.RejectInfo {
+		if rej.Audited {
+			continue
+		}
 		if res != "" {
 			res = res + fmt.Sprintf(", %s:%s", rej.RuleName, rej.Reason)
 		} else {
@@ -0,0 +1,65 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+func compressedStatusConfigMapName(podTransitionRuleName string) string {
+	return podTransitionRuleName + "-status"
+}
+
+// loadCompressedStatus replaces Details and RuleStates in status with those decoded from companion ConfigMap
+func (r *PodTransitionRuleReconciler) loadCompressedStatus(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule) error {
+	if podTransitionRule.Status.CompressedStatusRef == nil {
+		return nil
+	}
+	details, ruleStates, err := podtransitionruleutils.GetCompressedStatus(ctx, r.Client, podTransitionRule)
+	if err != nil {
+		return err
+	}
+	podTransitionRule.Status.Details = details
+	podTransitionRule.Status.RuleStates = ruleStates
+	return nil
+}
+
+// compressStatus moves Details and RuleStates into companion ConfigMap in compressed form if there are more target pods
+// than compressStatusPodThreshold, and returns Details and RuleStates to keep in status. The companion ConfigMap is
+// deleted once target pods are few enough.
+func (r *PodTransitionRuleReconciler) compressStatus(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, targets int, details []*appsv1alpha1.PodTransitionDetail, ruleStates []*appsv1alpha1.RuleState) ([]*appsv1alpha1.PodTransitionDetail, []*appsv1alpha1.RuleState, *appsv1alpha1.CompressedStatusReference, error) {
+	name := compressedStatusConfigMapName(podTransitionRule.Name)
+	if r.compressStatusPodThreshold <= 0 || targets <= r.compressStatusPodThreshold {
+		if podTransitionRule.Status.CompressedStatusRef != nil {
+			if err := r.deleteCompanionConfigMap(ctx, podTransitionRule, name); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		return details, ruleStates, nil, nil
+	}
+	encoded, err := podtransitionruleutils.EncodeStatus(details, ruleStates)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := r.applyCompanionConfigMap(ctx, podTransitionRule, name, podtransitionruleutils.CompressedStatusKey, encoded); err != nil {
+		return nil, nil, nil, err
+	}
+	return nil, summarizeRuleStates(ruleStates), &appsv1alpha1.CompressedStatusReference{ConfigMapName: name}, nil
+}
@@ -0,0 +1,257 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
+)
+
+var (
+	reconcileSecondsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podtransitionrule_reconcile_seconds_total",
+		Help: "Total time spent reconciling a PodTransitionRule, which approximates its CPU cost.",
+	}, []string{"namespace", "name"})
+
+	webhookCallsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podtransitionrule_webhook_calls_total",
+		Help: "Total webhook calls of a PodTransitionRule, including polling.",
+	}, []string{"namespace", "name"})
+
+	writesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podtransitionrule_writes_total",
+		Help: "Total write requests to apiserver in reconciles of a PodTransitionRule.",
+	}, []string{"namespace", "name"})
+
+	overBudget = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podtransitionrule_over_budget",
+		Help: "Whether a PodTransitionRule exceeds its cost budget in current budget window, and is deprioritized.",
+	}, []string{"namespace", "name"})
+
+	reconcileCosts = &costTracker{windows: map[reconcile.Request]*costWindow{}}
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileSecondsTotal, webhookCallsTotal, writesTotal, overBudget)
+}
+
+// costBudget is the soft budget of each PodTransitionRule in a window, zero values mean no limit
+type costBudget struct {
+	window       time.Duration
+	seconds      float64
+	webhookCalls int
+	writes       int
+}
+
+func (b costBudget) enabled() bool {
+	return b.window > 0 && (b.seconds > 0 || b.webhookCalls > 0 || b.writes > 0)
+}
+
+// costWindow is the cost of a PodTransitionRule in current budget window
+type costWindow struct {
+	start        time.Time
+	seconds      float64
+	webhookCalls int
+	writes       int
+	exceeded     bool
+}
+
+// costTracker accumulates costs of PodTransitionRules in budget windows
+type costTracker struct {
+	mu      sync.Mutex
+	budget  costBudget
+	windows map[reconcile.Request]*costWindow
+}
+
+// observe adds cost of a reconcile, and returns the exceeded budgets if they are first exceeded in current window
+func (t *costTracker) observe(req reconcile.Request, seconds float64, webhookCalls, writes int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.budget.enabled() {
+		return ""
+	}
+	now := time.Now()
+	w, ok := t.windows[req]
+	if !ok || now.Sub(w.start) >= t.budget.window {
+		w = &costWindow{start: now}
+		t.windows[req] = w
+	}
+	w.seconds += seconds
+	w.webhookCalls += webhookCalls
+	w.writes += writes
+	if w.exceeded {
+		return ""
+	}
+	var exceeded []string
+	if t.budget.seconds > 0 && w.seconds > t.budget.seconds {
+		exceeded = append(exceeded, fmt.Sprintf("[reconcile seconds]=%.2f/%.2f", w.seconds, t.budget.seconds))
+	}
+	if t.budget.webhookCalls > 0 && w.webhookCalls > t.budget.webhookCalls {
+		exceeded = append(exceeded, fmt.Sprintf("[webhook calls]=%d/%d", w.webhookCalls, t.budget.webhookCalls))
+	}
+	if t.budget.writes > 0 && w.writes > t.budget.writes {
+		exceeded = append(exceeded, fmt.Sprintf("[writes]=%d/%d", w.writes, t.budget.writes))
+	}
+	w.exceeded = len(exceeded) > 0
+	return strings.Join(exceeded, ", ")
+}
+
+// penalty returns how long req is deprioritized, which is the rest of its window if it is over budget
+func (t *costTracker) penalty(req reconcile.Request) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[req]
+	if !ok || !w.exceeded {
+		return 0
+	}
+	left := t.budget.window - time.Since(w.start)
+	if left <= 0 {
+		return 0
+	}
+	return left
+}
+
+func (t *costTracker) forget(req reconcile.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.windows, req)
+	reconcileSecondsTotal.DeleteLabelValues(req.Namespace, req.Name)
+	webhookCallsTotal.DeleteLabelValues(req.Namespace, req.Name)
+	writesTotal.DeleteLabelValues(req.Namespace, req.Name)
+	overBudget.DeleteLabelValues(req.Namespace, req.Name)
+}
+
+type reconcileCostKey struct{}
+
+// reconcileCost is the cost of a reconcile, writes are counted by costClient
+type reconcileCost struct {
+	writes int64
+
+	// start is the time the reconcile starts
+	start time.Time
+
+	// podTransitionRule is the reconciled object which budget warnings are emitted on
+	podTransitionRule *appsv1alpha1.PodTransitionRule
+}
+
+func withReconcileCost(ctx context.Context) (context.Context, *reconcileCost) {
+	cost := &reconcileCost{start: time.Now()}
+	return context.WithValue(ctx, reconcileCostKey{}, cost), cost
+}
+
+func reconcileCostFrom(ctx context.Context) *reconcileCost {
+	cost, _ := ctx.Value(reconcileCostKey{}).(*reconcileCost)
+	return cost
+}
+
+// reconcileStartTime returns the time the reconcile of ctx starts, now if ctx carries no reconcile cost
+func reconcileStartTime(ctx context.Context) time.Time {
+	if cost := reconcileCostFrom(ctx); cost != nil {
+		return cost.start
+	}
+	return time.Now()
+}
+
+func countWrite(ctx context.Context) {
+	if cost := reconcileCostFrom(ctx); cost != nil {
+		atomic.AddInt64(&cost.writes, 1)
+	}
+}
+
+// accountCost exports cost of a reconcile, and deprioritizes requeue of PodTransitionRule over budget
+func (r *PodTransitionRuleReconciler) accountCost(req reconcile.Request, cost *reconcileCost, elapsed time.Duration, result reconcile.Result) reconcile.Result {
+	webhookCalls := rules.TakeWebhookCalls(req.Namespace + "/" + req.Name)
+	writes := int(atomic.LoadInt64(&cost.writes))
+	reconcileSecondsTotal.WithLabelValues(req.Namespace, req.Name).Add(elapsed.Seconds())
+	webhookCallsTotal.WithLabelValues(req.Namespace, req.Name).Add(float64(webhookCalls))
+	writesTotal.WithLabelValues(req.Namespace, req.Name).Add(float64(writes))
+
+	if exceeded := reconcileCosts.observe(req, elapsed.Seconds(), webhookCalls, writes); exceeded != "" && cost.podTransitionRule != nil {
+		r.Recorder.Eventf(cost.podTransitionRule, corev1.EventTypeWarning, "OverBudget", "cost budget in %s is exceeded, reconciles are deprioritized: %s", reconcileCosts.budget.window, exceeded)
+	}
+	penalty := reconcileCosts.penalty(req)
+	if penalty == 0 {
+		overBudget.WithLabelValues(req.Namespace, req.Name).Set(0)
+		return result
+	}
+	overBudget.WithLabelValues(req.Namespace, req.Name).Set(1)
+	if (result.Requeue || result.RequeueAfter > 0) && result.RequeueAfter < penalty {
+		result.RequeueAfter = penalty
+	}
+	return result
+}
+
+// costClient counts write requests of reconciles
+type costClient struct {
+	client.Client
+}
+
+func (c *costClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	countWrite(ctx)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *costClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	countWrite(ctx)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *costClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	countWrite(ctx)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *costClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	countWrite(ctx)
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *costClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	countWrite(ctx)
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *costClient) Status() client.StatusWriter {
+	return &costStatusWriter{StatusWriter: c.Client.Status()}
+}
+
+type costStatusWriter struct {
+	client.StatusWriter
+}
+
+func (w *costStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	countWrite(ctx)
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *costStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	countWrite(ctx)
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,86 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+var reconcileDeadlineExceededTotal = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "podtransitionrule_reconcile_deadline_exceeded_total",
+	Help: "Reconciles of PodTransitionRules cut short by max reconcile duration, the remainder is continued on requeue.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(reconcileDeadlineExceededTotal)
+}
+
+type reconcileDeadlineKey struct{}
+
+// withReconcileDeadline sets a deadline of the reconcile. It is only checked between phases, and writes of the
+// reconcile are not bound to it, so that progress made before the deadline is always persisted.
+func withReconcileDeadline(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	deadline, cancel := context.WithTimeout(ctx, timeout)
+	return context.WithValue(ctx, reconcileDeadlineKey{}, deadline), cancel
+}
+
+// reconcileDeadlineExceeded returns true if the deadline of the reconcile in ctx is exceeded
+func reconcileDeadlineExceeded(ctx context.Context) bool {
+	deadline, ok := ctx.Value(reconcileDeadlineKey{}).(context.Context)
+	return ok && deadline.Err() != nil
+}
+
+// keepSkippedStages keeps details of pods in stages skipped by deadline and rule states not refreshed as they were,
+// so that status stays consistent until they are processed on requeue
+func (r *PodTransitionRuleReconciler) keepSkippedStages(
+	skipped sets.String,
+	targetPods map[string]*corev1.Pod,
+	oldDetails []*appsv1alpha1.PodTransitionDetail,
+	details map[string]*appsv1alpha1.PodTransitionDetail,
+	oldRuleStates, ruleStates []*appsv1alpha1.RuleState,
+) []*appsv1alpha1.RuleState {
+	for _, oldDetail := range oldDetails {
+		pod, ok := targetPods[oldDetail.Name]
+		if _, processed := details[oldDetail.Name]; !ok || processed {
+			continue
+		}
+		for stage := range skipped {
+			if r.InStage(pod, stage) {
+				details[oldDetail.Name] = oldDetail.DeepCopy()
+				break
+			}
+		}
+	}
+	refreshed := sets.NewString()
+	for _, state := range ruleStates {
+		refreshed.Insert(state.Name)
+	}
+	for _, state := range oldRuleStates {
+		if !refreshed.Has(state.Name) {
+			ruleStates = append(ruleStates, state.DeepCopy())
+		}
+	}
+	return ruleStates
+}
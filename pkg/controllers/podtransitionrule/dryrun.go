@@ -0,0 +1,140 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	"kusionstack.io/operating/pkg/utils"
+)
+
+// dryRunDiff is what a reconcile would change on a PodTransitionRule and its pods. It is only reported, never applied.
+type dryRunDiff struct {
+	// DryRun is always true, marking the diff as non-mutating
+	DryRun         bool             `json:"dryRun"`
+	AddedTargets   []string         `json:"addedTargets,omitempty"`
+	RemovedTargets []string         `json:"removedTargets,omitempty"`
+	Details        []detailDiff     `json:"details,omitempty"`
+	Annotations    []annotationDiff `json:"annotations,omitempty"`
+}
+
+// detailDiff is a changed verdict on a pod, Old is nil for new pods and New is nil for dropped pods
+type detailDiff struct {
+	Pod string   `json:"pod"`
+	Old *verdict `json:"old,omitempty"`
+	New *verdict `json:"new,omitempty"`
+}
+
+type verdict struct {
+	Stage      string                    `json:"stage,omitempty"`
+	Passed     bool                      `json:"passed"`
+	RejectInfo []appsv1alpha1.RejectInfo `json:"rejectInfo,omitempty"`
+}
+
+type annotationDiff struct {
+	Pod     string `json:"pod"`
+	Key     string `json:"key"`
+	Old     string `json:"old,omitempty"`
+	New     string `json:"new,omitempty"`
+	Removed bool   `json:"removed,omitempty"`
+}
+
+func isDryRun(podTransitionRule *appsv1alpha1.PodTransitionRule) bool {
+	return podTransitionRule.Annotations[appsv1alpha1.AnnotationDryRun] == "true"
+}
+
+// computeDryRunDiff compares status details and pod annotations of podTransitionRule with what is computed in reconcile
+func computeDryRunDiff(
+	podTransitionRule *appsv1alpha1.PodTransitionRule,
+	selectedPodNames sets.String,
+	targetPods map[string]*corev1.Pod,
+	oldDetails, newDetails []*appsv1alpha1.PodTransitionDetail,
+	details map[string]*appsv1alpha1.PodTransitionDetail,
+) *dryRunDiff {
+	diff := &dryRunDiff{DryRun: true}
+	oldTargets := sets.NewString(podTransitionRule.Status.Targets...)
+	diff.AddedTargets = selectedPodNames.Difference(oldTargets).List()
+	diff.RemovedTargets = oldTargets.Difference(selectedPodNames).List()
+
+	oldDetailMap := map[string]*appsv1alpha1.PodTransitionDetail{}
+	for _, detail := range oldDetails {
+		oldDetailMap[detail.Name] = detail
+	}
+	newNames := sets.NewString()
+	for _, detail := range newDetails {
+		newNames.Insert(detail.Name)
+		oldDetail, ok := oldDetailMap[detail.Name]
+		if ok && oldDetail.Stage == detail.Stage && oldDetail.Passed == detail.Passed {
+			continue
+		}
+		diff.Details = append(diff.Details, detailDiff{Pod: detail.Name, Old: verdictOf(oldDetail), New: verdictOf(detail)})
+	}
+	for _, detail := range oldDetails {
+		if !newNames.Has(detail.Name) {
+			diff.Details = append(diff.Details, detailDiff{Pod: detail.Name, Old: verdictOf(detail)})
+		}
+	}
+
+	for _, pod := range podsToSyncDetail(podTransitionRule.Name, targetPods, details) {
+		key, val := podDetailAnno(podTransitionRule.Name, details[podtransitionruleutils.TargetKey(podTransitionRule, pod)])
+		diff.Annotations = append(diff.Annotations, annotationDiff{Pod: pod.Name, Key: key, Old: pod.Annotations[key], New: val})
+	}
+	for _, name := range diff.RemovedTargets {
+		diff.Annotations = append(diff.Annotations, annotationDiff{
+			Pod:     name,
+			Key:     appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + podTransitionRule.Name,
+			Removed: true,
+		})
+	}
+	return diff
+}
+
+func verdictOf(detail *appsv1alpha1.PodTransitionDetail) *verdict {
+	if detail == nil {
+		return nil
+	}
+	return &verdict{Stage: detail.Stage, Passed: detail.Passed, RejectInfo: detail.RejectInfo}
+}
+
+// reportDryRun logs diff and summarizes it with an event on podTransitionRule
+func (r *PodTransitionRuleReconciler) reportDryRun(logger logr.Logger, podTransitionRule *appsv1alpha1.PodTransitionRule, diff *dryRunDiff) {
+	logger.Info("dry run, changes are not applied", "diff", utils.DumpJSON(diff))
+	if r.Recorder != nil {
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "DryRun",
+			"dry run, nothing is applied: %d targets would be added, %d removed, %d pod verdicts and %d pod annotations would change",
+			len(diff.AddedTargets), len(diff.RemovedTargets), len(diff.Details), len(diff.Annotations))
+	}
+}
+
+// reportEvaluated emits an event summarizing verdicts of a PodTransitionRule in dry run by spec
+func (r *PodTransitionRuleReconciler) reportEvaluated(podTransitionRule *appsv1alpha1.PodTransitionRule, details map[string]*appsv1alpha1.PodTransitionDetail) {
+	if r.Recorder == nil {
+		return
+	}
+	var passed int
+	for _, detail := range details {
+		if detail.Passed {
+			passed++
+		}
+	}
+	r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "DryRunEvaluated",
+		"dry run evaluated, verdicts are not enforced: %d pods would pass, %d would be rejected", passed, len(details)-passed)
+}
@@ -18,21 +18,27 @@ package podtransitionrule
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	processorrules "kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 	commonutils "kusionstack.io/operating/pkg/utils"
+	utilsinject "kusionstack.io/operating/pkg/utils/inject"
 )
 
 var _ inject.Client = &EventHandler{}
@@ -62,7 +68,7 @@ func (p *EventHandler) InjectLogger(l logr.Logger) error {
 
 func (p *EventHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
 	obj := e.Object
-	podTransitionRules, err := involvedPodTransitionRules(p.client, obj)
+	podTransitionRules, err := involvedPodTransitionRules(p.client, obj, p.logger)
 	if err != nil {
 		p.logger.Error(err, "failed to get involved podtransitionrules for objects", "obj", commonutils.ObjectKeyString(obj))
 		return
@@ -77,7 +83,7 @@ func (p *EventHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInter
 
 func (p *EventHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
 	obj := e.ObjectNew
-	podTransitionRules, err := involvedPodTransitionRules(p.client, obj)
+	podTransitionRules, err := involvedPodTransitionRules(p.client, obj, p.logger)
 	if err != nil {
 		p.logger.Error(err, "failed to get involved podtransitionrules for objects", "obj", commonutils.ObjectKeyString(obj))
 		return
@@ -92,7 +98,7 @@ func (p *EventHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInter
 
 func (p *EventHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
 	obj := e.Object
-	podTransitionRules, err := involvedPodTransitionRules(p.client, obj)
+	podTransitionRules, err := involvedPodTransitionRules(p.client, obj, p.logger)
 	if err != nil {
 		p.logger.Error(err, "failed to get involved podtransitionrules for objects", "obj", commonutils.ObjectKeyString(obj))
 		return
@@ -108,30 +114,287 @@ func (p *EventHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInter
 func (p *EventHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
 }
 
-func involvedPodTransitionRules(c client.Client, obj client.Object) ([]*appsv1alpha1.PodTransitionRule, error) {
-	podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
-	var podTransitionRules []*appsv1alpha1.PodTransitionRule
-	if err := c.List(context.TODO(), podTransitionRuleList, client.InNamespace(obj.GetNamespace())); err != nil {
-		return podTransitionRules, err
+// involvedPodTransitionRules returns PodTransitionRules which select obj or have it in targets. Only PodTransitionRules
+// in the namespace of obj and PodTransitionRules with namespace selector are listed, and PodTransitionRules with
+// invalid selectors are skipped, since they are rejected by reconcile anyway.
+func involvedPodTransitionRules(c client.Client, obj client.Object, logger logr.Logger) ([]*appsv1alpha1.PodTransitionRule, error) {
+	inNamespace := &appsv1alpha1.PodTransitionRuleList{}
+	if err := c.List(context.TODO(), inNamespace, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil, err
+	}
+	// podTransitionRules with namespace selector select pods in other namespaces
+	withNamespaceSelector := &appsv1alpha1.PodTransitionRuleList{}
+	if err := c.List(context.TODO(), withNamespaceSelector, &client.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector(utilsinject.FieldIndexNamespaceSelector, "true"),
+	}); err != nil {
+		return nil, err
 	}
-	for i, rs := range podTransitionRuleList.Items {
-		selector, err := metav1.LabelSelectorAsSelector(rs.Spec.Selector)
+	candidates := inNamespace.Items
+	for _, rs := range withNamespaceSelector.Items {
+		if rs.Namespace != obj.GetNamespace() && rs.Spec.NamespaceSelector != nil {
+			candidates = append(candidates, rs)
+		}
+	}
+
+	// labels of namespace are got at most once per event
+	var namespaceLabels map[string]string
+	namespaceResolved := false
+	var podTransitionRules []*appsv1alpha1.PodTransitionRule
+	for i := range candidates {
+		rs := &candidates[i]
+		selected, err := podtransitionruleutils.SelectsLabels(rs, obj.GetLabels())
 		if err != nil {
-			return podTransitionRules, err
+			logger.Error(err, "failed to parse selector of podtransitionrule", "podtransitionrule", commonutils.ObjectKeyString(rs))
+			continue
+		}
+		if selected && rs.Spec.NamespaceSelector != nil {
+			if !namespaceResolved {
+				ns := &corev1.Namespace{}
+				if err := c.Get(context.TODO(), types.NamespacedName{Name: obj.GetNamespace()}, ns); client.IgnoreNotFound(err) != nil {
+					return nil, err
+				}
+				namespaceLabels, namespaceResolved = ns.Labels, true
+			}
+			if selected, err = podtransitionruleutils.SelectsNamespaceLabels(rs, obj.GetNamespace(), namespaceLabels); err != nil {
+				logger.Error(err, "failed to parse namespace selector of podtransitionrule", "podtransitionrule", commonutils.ObjectKeyString(rs))
+				continue
+			}
 		}
-		if selector.Matches(labels.Set(obj.GetLabels())) {
-			podTransitionRules = append(podTransitionRules, &podTransitionRuleList.Items[i])
+		if selected {
+			podTransitionRules = append(podTransitionRules, rs)
 			continue
 		}
+		key := obj.GetName()
+		if rs.Spec.NamespaceSelector != nil {
+			key = obj.GetNamespace() + "/" + obj.GetName()
+		}
 		for _, item := range rs.Status.Targets {
-			if item == obj.GetName() {
-				podTransitionRules = append(podTransitionRules, &podTransitionRuleList.Items[i])
+			if item == key {
+				podTransitionRules = append(podTransitionRules, rs)
+				break
 			}
 		}
 	}
 	return podTransitionRules, nil
 }
 
+// enqueueNamespacePodTransitionRules enqueues all PodTransitionRules in the namespace of obj
+func enqueueNamespacePodTransitionRules(c client.Client) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
+		if err := c.List(context.TODO(), podTransitionRuleList, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(podTransitionRuleList.Items))
+		for _, rs := range podTransitionRuleList.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      rs.Name,
+				Namespace: rs.Namespace,
+			}})
+		}
+		return requests
+	}
+}
+
+// enqueueRulesFromPodTransitionRules enqueues PodTransitionRules in the namespace of ConfigMap which refer to it by rulesFrom
+func enqueueRulesFromPodTransitionRules(c client.Reader) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		podTransitionRules, err := rulesFromPodTransitionRules(c, obj)
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(podTransitionRules))
+		for _, rs := range podTransitionRules {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      rs.Name,
+				Namespace: rs.Namespace,
+			}})
+		}
+		return requests
+	}
+}
+
+// enqueueAllPodTransitionRules enqueues PodTransitionRules in all namespaces
+func enqueueAllPodTransitionRules(c client.Client) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
+		if err := c.List(context.TODO(), podTransitionRuleList); err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(podTransitionRuleList.Items))
+		for _, rs := range podTransitionRuleList.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+				Name:      rs.Name,
+				Namespace: rs.Namespace,
+			}})
+		}
+		return requests
+	}
+}
+
+// enqueueWorkloadRolloutPodTransitionRules enqueues PodTransitionRules with workload rollout rule in the namespace of obj
+func enqueueWorkloadRolloutPodTransitionRules(c client.Client) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
+		if err := c.List(context.TODO(), podTransitionRuleList, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, rs := range podTransitionRuleList.Items {
+			for _, rule := range rs.Spec.Rules {
+				if rule.WorkloadRollout != nil {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+						Name:      rs.Name,
+						Namespace: rs.Namespace,
+					}})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
+// enqueuePDBPodTransitionRules enqueues PodTransitionRules in the namespace of PodDisruptionBudget which refer to it
+func enqueuePDBPodTransitionRules(c client.Client) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
+		if err := c.List(context.TODO(), podTransitionRuleList, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, rs := range podTransitionRuleList.Items {
+			for _, rule := range rs.Spec.Rules {
+				if rule.PDBRef != nil && rule.PDBRef.Name == obj.GetName() {
+					requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+						Name:      rs.Name,
+						Namespace: rs.Namespace,
+					}})
+					break
+				}
+			}
+		}
+		return requests
+	}
+}
+
+var _ inject.Client = &ResourceEventHandler{}
+var _ inject.Logger = &ResourceEventHandler{}
+
+// ResourceEventHandler caches objects of registered resources in Store, and enqueues PodTransitionRules
+// which have rules on the resources
+type ResourceEventHandler struct {
+	Store *resources.Store
+
+	// client and logger will be injected
+	client client.Client
+	logger logr.Logger
+}
+
+func (p *ResourceEventHandler) InjectClient(c client.Client) error {
+	p.client = c
+	return nil
+}
+
+func (p *ResourceEventHandler) InjectLogger(l logr.Logger) error {
+	p.logger = l.WithName("podtransitionrule").WithName("resourceEventHandler")
+	return nil
+}
+
+func (p *ResourceEventHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	p.update(e.Object, q)
+}
+
+func (p *ResourceEventHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	p.update(e.ObjectNew, q)
+}
+
+func (p *ResourceEventHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	obj, ok := e.Object.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	p.Store.Delete(obj)
+	p.enqueue(obj, q)
+}
+
+func (p *ResourceEventHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+}
+
+func (p *ResourceEventHandler) update(o client.Object, q workqueue.RateLimitingInterface) {
+	obj, ok := o.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	if err := p.Store.Update(obj); err != nil {
+		p.logger.Error(err, "failed to cache resource", "gvk", obj.GroupVersionKind().String(), "obj", commonutils.ObjectKeyString(obj))
+	}
+	p.enqueue(obj, q)
+}
+
+func (p *ResourceEventHandler) enqueue(obj *unstructured.Unstructured, q workqueue.RateLimitingInterface) {
+	podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
+	// cluster-scoped resources affect PodTransitionRules in all namespaces
+	if err := p.client.List(context.TODO(), podTransitionRuleList, client.InNamespace(obj.GetNamespace())); err != nil {
+		p.logger.Error(err, "failed to list podtransitionrules", "obj", commonutils.ObjectKeyString(obj))
+		return
+	}
+	apiVersion, kind := obj.GroupVersionKind().ToAPIVersionAndKind()
+	for _, rs := range podTransitionRuleList.Items {
+		for _, rule := range rs.Spec.Rules {
+			if rule.ResourceState != nil && rule.ResourceState.APIVersion == apiVersion && rule.ResourceState.Kind == kind {
+				q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
+					Name:      rs.Name,
+					Namespace: rs.Namespace,
+				}})
+				break
+			}
+		}
+	}
+}
+
+var _ inject.Injector = &DebounceEventHandler{}
+
+// DebounceEventHandler delays the requests enqueued by the wrapped EventHandler, so that
+// requests of the same PodTransitionRule from different sources in quick succession are
+// coalesced into a single reconcile by the workqueue.
+type DebounceEventHandler struct {
+	handler.EventHandler
+	Delay time.Duration
+}
+
+// InjectFunc passes dependencies injection through to the wrapped EventHandler
+func (h *DebounceEventHandler) InjectFunc(f inject.Func) error {
+	return f(h.EventHandler)
+}
+
+func (h *DebounceEventHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Create(e, &debounceQueue{RateLimitingInterface: q, delay: h.Delay})
+}
+
+func (h *DebounceEventHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Update(e, &debounceQueue{RateLimitingInterface: q, delay: h.Delay})
+}
+
+func (h *DebounceEventHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Delete(e, &debounceQueue{RateLimitingInterface: q, delay: h.Delay})
+}
+
+func (h *DebounceEventHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Generic(e, &debounceQueue{RateLimitingInterface: q, delay: h.Delay})
+}
+
+// debounceQueue turns Add into AddAfter. The delaying queue keeps only the earliest ready time
+// of a waiting item, and an item is never lost since it is added to queue when ready.
+type debounceQueue struct {
+	workqueue.RateLimitingInterface
+	delay time.Duration
+}
+
+func (q *debounceQueue) Add(item interface{}) {
+	q.AddAfter(item, q.delay)
+}
+
 type PodTransitionRuleEventHandler struct {
 }
 
@@ -145,7 +408,12 @@ func (p *PodTransitionRuleEventHandler) Create(e event.CreateEvent, q workqueue.
 func (p *PodTransitionRuleEventHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
 	oldPodTransitionRule := e.ObjectOld.(*appsv1alpha1.PodTransitionRule)
 	newPodTransitionRule := e.ObjectNew.(*appsv1alpha1.PodTransitionRule)
-	if equality.Semantic.DeepEqual(oldPodTransitionRule.Spec, newPodTransitionRule.Spec) && newPodTransitionRule.DeletionTimestamp == nil {
+	if equality.Semantic.DeepEqual(oldPodTransitionRule.Spec, newPodTransitionRule.Spec) && newPodTransitionRule.DeletionTimestamp == nil &&
+		!statusMutatedOutOfBand(&oldPodTransitionRule.Status, &newPodTransitionRule.Status) &&
+		!approveAnnotationsChanged(oldPodTransitionRule, newPodTransitionRule) &&
+		!approvalLabelsChanged(oldPodTransitionRule, newPodTransitionRule) &&
+		oldPodTransitionRule.Annotations[appsv1alpha1.AnnotationDryRun] == newPodTransitionRule.Annotations[appsv1alpha1.AnnotationDryRun] &&
+		oldPodTransitionRule.Annotations[appsv1alpha1.AnnotationGovernProtectedPods] == newPodTransitionRule.Annotations[appsv1alpha1.AnnotationGovernProtectedPods] {
 		return
 	}
 	q.Add(reconcile.Request{NamespacedName: types.NamespacedName{
@@ -154,6 +422,11 @@ func (p *PodTransitionRuleEventHandler) Update(e event.UpdateEvent, q workqueue.
 	}})
 }
 
+// statusMutatedOutOfBand returns true if status is changed by others, controller always refreshes UpdateTime on its writes
+func statusMutatedOutOfBand(oldStatus, newStatus *appsv1alpha1.PodTransitionRuleStatus) bool {
+	return equality.Semantic.DeepEqual(oldStatus.UpdateTime, newStatus.UpdateTime) && !equality.Semantic.DeepEqual(oldStatus, newStatus)
+}
+
 func (p *PodTransitionRuleEventHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
 	if e.Object == nil {
 		return
@@ -0,0 +1,127 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	commonutils "kusionstack.io/operating/pkg/utils"
+)
+
+// explainLimiter limits explains of each PodTransitionRule on a pod to one per interval
+type explainLimiter struct {
+	mu        sync.Mutex
+	explained map[string]time.Time
+}
+
+// allow returns zero if key is allowed to explain now, otherwise how long to wait
+func (l *explainLimiter) allow(key string, interval time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for k, t := range l.explained {
+		if now.Sub(t) >= interval {
+			delete(l.explained, k)
+		}
+	}
+	if t, ok := l.explained[key]; ok {
+		return interval - now.Sub(t)
+	}
+	if l.explained == nil {
+		l.explained = map[string]time.Time{}
+	}
+	l.explained[key] = now
+	return 0
+}
+
+// explainPods emits events on target pods requesting explain with verdicts of every rule, and removes
+// podTransitionRule from their explain annotations
+func (r *PodTransitionRuleReconciler) explainPods(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, targetPods map[string]*corev1.Pod, details map[string]*appsv1alpha1.PodTransitionDetail) error {
+	for _, name := range sets.StringKeySet(targetPods).List() {
+		pod := targetPods[name]
+		if !podtransitionruleutils.ExplainRequested(pod, podTransitionRule.Name) {
+			continue
+		}
+		if wait := r.explainLimiter.allow(commonutils.ObjectKeyString(podTransitionRule)+"/"+name, r.explainInterval); wait > 0 {
+			r.Recorder.Eventf(pod, corev1.EventTypeWarning, "ExplainRateLimited", "[%s] explain is rate limited, retry after %s", podTransitionRule.Name, wait.Round(time.Second))
+		} else {
+			r.Recorder.Event(pod, corev1.EventTypeNormal, "Explain", explain(podTransitionRule, details[name]))
+		}
+		_, err := r.updatePodTransitionRuleOnPod(ctx, podTransitionRule.Name, pod.Name, pod.Namespace, podtransitionruleutils.MoveExplainAnno)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		r.observePodWrite(podTransitionRule, name, err)
+		if err != nil {
+			return fmt.Errorf("fail to remove explain annotation on pod %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// explain describes the verdict of every rule of podTransitionRule in detail
+func explain(podTransitionRule *appsv1alpha1.PodTransitionRule, detail *appsv1alpha1.PodTransitionDetail) string {
+	if detail == nil {
+		return fmt.Sprintf("[%s] no rule is evaluated, pod is not in any stage", podTransitionRule.Name)
+	}
+	passed := sets.NewString(detail.PassedRules...)
+	rejected := map[string]string{}
+	audited := map[string]string{}
+	for _, info := range detail.RejectInfo {
+		if info.Audited {
+			audited[info.RuleName] = info.Reason
+			continue
+		}
+		rejected[info.RuleName] = info.Reason
+	}
+	delayed := map[string]time.Time{}
+	for _, info := range detail.DelayInfo {
+		delayed[info.RuleName] = info.DelayUntil.Time
+	}
+	verdicts := []string{fmt.Sprintf("[%s] [stage]=%s, [passed]=%t", podTransitionRule.Name, detail.Stage, detail.Passed)}
+	for _, rule := range podTransitionRule.Spec.Rules {
+		var verdict string
+		switch {
+		case rule.Disabled:
+			verdict = "disabled"
+		case rule.Stage != nil && *rule.Stage != detail.Stage:
+			verdict = fmt.Sprintf("not in stage %s", *rule.Stage)
+		case rejected[rule.Name] != "":
+			verdict = "rejected, " + rejected[rule.Name]
+		case !delayed[rule.Name].IsZero():
+			verdict = "delayed until " + delayed[rule.Name].Format(time.RFC3339)
+		case audited[rule.Name] != "":
+			verdict = "passed, audited rejection " + audited[rule.Name]
+		case passed.Has(rule.Name):
+			verdict = "passed"
+		default:
+			verdict = "not evaluated"
+		}
+		verdicts = append(verdicts, fmt.Sprintf("%s: %s", rule.Name, verdict))
+	}
+	return strings.Join(verdicts, "; ")
+}
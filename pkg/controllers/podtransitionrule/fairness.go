@@ -0,0 +1,196 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+)
+
+const (
+	// requeue budget of each PodTransitionRule
+	perItemRequeueQPS   = 10
+	perItemRequeueBurst = 20
+)
+
+var (
+	queueWaitSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podtransitionrule_queue_wait_seconds",
+		Help:    "How long a PodTransitionRule waits in workqueue from being enqueued by events to being reconciled.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 15),
+	}, []string{"namespace", "name"})
+
+	reconcileDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podtransitionrule_reconcile_duration_seconds",
+		Help:    "Duration of reconciles of a PodTransitionRule.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"namespace", "name"})
+
+	queueWaits = &queueWaitTracker{enqueued: map[reconcile.Request]time.Time{}}
+)
+
+func init() {
+	metrics.Registry.MustRegister(queueWaitSeconds, reconcileDurationSeconds)
+}
+
+// newFairRateLimiter returns the default controller rate limiter, which also limits requeues of each PodTransitionRule
+// with its own token bucket, so that a PodTransitionRule requeuing frequently runs out of its own budget long before
+// it drains the overall bucket shared by others.
+func newFairRateLimiter() workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		// 10 qps, 100 bucket size, same as the default controller rate limiter
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+		&perItemBucketRateLimiter{qps: perItemRequeueQPS, burst: perItemRequeueBurst, limiters: map[interface{}]*rate.Limiter{}},
+	)
+}
+
+type perItemBucketRateLimiter struct {
+	qps   rate.Limit
+	burst int
+
+	limiters map[interface{}]*rate.Limiter
+	mu       sync.Mutex
+}
+
+func (r *perItemBucketRateLimiter) When(item interface{}) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limiter, ok := r.limiters[item]
+	if !ok {
+		limiter = rate.NewLimiter(r.qps, r.burst)
+		r.limiters[item] = limiter
+	}
+	return limiter.Reserve().Delay()
+}
+
+func (r *perItemBucketRateLimiter) NumRequeues(item interface{}) int {
+	return 0
+}
+
+func (r *perItemBucketRateLimiter) Forget(item interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.limiters, item)
+}
+
+// queueWaitTracker records the time PodTransitionRules are first enqueued since last reconcile
+type queueWaitTracker struct {
+	enqueued map[reconcile.Request]time.Time
+	mu       sync.Mutex
+}
+
+func (t *queueWaitTracker) add(item interface{}, delay time.Duration) {
+	req, ok := item.(reconcile.Request)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ready := time.Now().Add(delay)
+	if enqueued, exist := t.enqueued[req]; !exist || ready.Before(enqueued) {
+		t.enqueued[req] = ready
+	}
+}
+
+// observe observes the queue wait of req when it is reconciled
+func (t *queueWaitTracker) observe(req reconcile.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	enqueued, ok := t.enqueued[req]
+	if !ok {
+		return
+	}
+	delete(t.enqueued, req)
+	if wait := time.Since(enqueued); wait > 0 {
+		queueWaitSeconds.WithLabelValues(req.Namespace, req.Name).Observe(wait.Seconds())
+	}
+}
+
+func (t *queueWaitTracker) forget(req reconcile.Request) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.enqueued, req)
+	queueWaitSeconds.DeleteLabelValues(req.Namespace, req.Name)
+	reconcileDurationSeconds.DeleteLabelValues(req.Namespace, req.Name)
+}
+
+var _ inject.Injector = &QueueWaitEventHandler{}
+
+// QueueWaitEventHandler records the time and trigger source of requests enqueued by the wrapped EventHandler
+type QueueWaitEventHandler struct {
+	handler.EventHandler
+
+	// Trigger is the kind of watched objects
+	Trigger triggerObject
+}
+
+// InjectFunc passes dependencies injection through to the wrapped EventHandler
+func (h *QueueWaitEventHandler) InjectFunc(f inject.Func) error {
+	return f(h.EventHandler)
+}
+
+func (h *QueueWaitEventHandler) Create(e event.CreateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Create(e, &queueWaitQueue{RateLimitingInterface: q, source: h.Trigger.triggerSource("create", nil, e.Object)})
+}
+
+func (h *QueueWaitEventHandler) Update(e event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Update(e, &queueWaitQueue{RateLimitingInterface: q, source: h.Trigger.triggerSource("update", e.ObjectOld, e.ObjectNew)})
+}
+
+func (h *QueueWaitEventHandler) Delete(e event.DeleteEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Delete(e, &queueWaitQueue{RateLimitingInterface: q, source: h.Trigger.triggerSource("delete", nil, e.Object)})
+}
+
+func (h *QueueWaitEventHandler) Generic(e event.GenericEvent, q workqueue.RateLimitingInterface) {
+	h.EventHandler.Generic(e, &queueWaitQueue{RateLimitingInterface: q, source: h.Trigger.triggerSource("generic", nil, e.Object)})
+}
+
+type queueWaitQueue struct {
+	workqueue.RateLimitingInterface
+
+	// source is the trigger source of requests enqueued
+	source string
+}
+
+func (q *queueWaitQueue) Add(item interface{}) {
+	// PodTransitionRules over cost budget are deprioritized
+	if req, ok := item.(reconcile.Request); ok {
+		if penalty := reconcileCosts.penalty(req); penalty > 0 {
+			q.AddAfter(item, penalty)
+			return
+		}
+	}
+	queueWaits.add(item, 0)
+	reconcileTriggers.add(item, q.source)
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *queueWaitQueue) AddAfter(item interface{}, duration time.Duration) {
+	queueWaits.add(item, duration)
+	reconcileTriggers.add(item, q.source)
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
@@ -0,0 +1,87 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+var (
+	killSwitchActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podtransitionrule_kill_switch_active",
+		Help: "Whether the kill switch is active, all PodTransitionRules are observe-only while it is 1.",
+	})
+
+	// lastKillSwitchState is 1 if kill switch was active in last reconcile
+	lastKillSwitchState int32
+)
+
+func init() {
+	metrics.Registry.MustRegister(killSwitchActive)
+}
+
+// isKillSwitchActive returns whether PodTransitionRules should be observe-only. It falls back to the option
+// if the kill switch ConfigMap fails to be read.
+func (r *PodTransitionRuleReconciler) isKillSwitchActive(ctx context.Context) bool {
+	active := r.killSwitch
+	if !active {
+		var err error
+		if active, err = podtransitionruleutils.IsKillSwitchActive(ctx, r.Client, r.killSwitchNamespace); err != nil {
+			r.Logger.Error(err, "failed to get kill switch")
+		}
+	}
+	state := int32(0)
+	if active {
+		state = 1
+	}
+	if atomic.SwapInt32(&lastKillSwitchState, state) != state {
+		if active {
+			r.Logger.Info("WARNING: PodTransitionRule kill switch is active, all PodTransitionRules are observe-only and never block pods")
+		} else {
+			r.Logger.Info("PodTransitionRule kill switch is cleared, PodTransitionRules block pods again")
+		}
+	}
+	killSwitchActive.Set(float64(state))
+	return active
+}
+
+// observeOnly lets blocked pods pass. Reject info is kept, so that what would be blocked is still observable.
+func (r *PodTransitionRuleReconciler) observeOnly(logger logr.Logger, podTransitionRule *appsv1alpha1.PodTransitionRule, details map[string]*appsv1alpha1.PodTransitionDetail) {
+	var overridden int
+	for _, detail := range details {
+		if !detail.Passed {
+			detail.Passed = true
+			overridden++
+		}
+	}
+	if overridden == 0 {
+		return
+	}
+	logger.Info("kill switch is active, let blocked pods pass", "pods", overridden)
+	if r.Recorder != nil {
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeWarning, "KillSwitchActive", "kill switch is active, %d blocked pods are let pass", overridden)
+	}
+}
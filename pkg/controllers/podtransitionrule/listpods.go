@@ -0,0 +1,122 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+// ResolveTargets returns pods currently targeted by podTransitionRule, which match its selectors and are neither
+// protected nor excluded by annotation.
+// It resolves targets the same way as reconcile, so that controllers embedding the reconciler do not duplicate it.
+func (r *PodTransitionRuleReconciler) ResolveTargets(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule) ([]*corev1.Pod, error) {
+	if err := selectorError(podTransitionRule); err != nil {
+		return nil, err
+	}
+	pods, _, _, err := r.resolveTargets(ctx, podTransitionRule)
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*corev1.Pod, len(pods))
+	for i := range pods {
+		targets[i] = &pods[i]
+	}
+	return targets, nil
+}
+
+// resolveTargets lists pods selected by podTransitionRule and excludes protected pods and pods annotated to be excluded,
+// names of the protected pods and target keys of the annotated pods are returned
+func (r *PodTransitionRuleReconciler) resolveTargets(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule) ([]corev1.Pod, []string, []string, error) {
+	// the informer cache ignores Limit and Continue, so pods are listed in pages only from API server
+	var reader client.Reader = r.Client
+	pageSize := 0
+	if r.podListPageSize > 0 && r.APIReader != nil {
+		reader, pageSize = r.APIReader, r.podListPageSize
+	}
+	pods, err := r.listTargetPods(ctx, reader, pageSize, podTransitionRule)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pods, protected := r.excludeProtectedPods(podTransitionRule, pods)
+	pods, annotated := excludeAnnotatedPods(podTransitionRule, pods)
+	return pods, protected, annotated, nil
+}
+
+// listTargetPods lists pods selected by podTransitionRule from reader in pages of pageSize, and matches field selector
+// page by page, so that unmatched pods of a page are released before the next page is listed. Non-positive pageSize
+// lists all pods at once, which is required by the informer cache, since it stops at Limit and never sets Continue.
+// Pods are listed once per selector term in every selected namespace, and pods matching multiple terms are only
+// returned once.
+func (r *PodTransitionRuleReconciler) listTargetPods(ctx context.Context, reader client.Reader, pageSize int, podTransitionRule *appsv1alpha1.PodTransitionRule) ([]corev1.Pod, error) {
+	selectors, err := podtransitionruleutils.PodSelectors(podTransitionRule)
+	if err != nil {
+		return nil, err
+	}
+	// field selector is matched in memory, since the cache only supports field selectors on indexed fields
+	var matches func(pod *corev1.Pod) bool
+	if podTransitionRule.Spec.FieldSelector != "" {
+		fieldSelector, err := podtransitionruleutils.ParsePodFieldSelector(podTransitionRule.Spec.FieldSelector)
+		if err != nil {
+			return nil, err
+		}
+		matches = func(pod *corev1.Pod) bool {
+			return fieldSelector.Matches(podtransitionruleutils.PodFields(pod))
+		}
+	}
+
+	namespaces, err := podtransitionruleutils.SelectedNamespaces(ctx, r.Client, podTransitionRule)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []corev1.Pod
+	listed := sets.NewString()
+	for _, namespace := range namespaces {
+		for _, selector := range selectors {
+			opts := &client.ListOptions{Namespace: namespace, LabelSelector: selector}
+			if pageSize > 0 {
+				opts.Limit = int64(pageSize)
+			}
+			for {
+				page := &corev1.PodList{}
+				if err := reader.List(ctx, page, opts); err != nil {
+					return nil, err
+				}
+				for i := range page.Items {
+					key := podtransitionruleutils.TargetKey(podTransitionRule, &page.Items[i])
+					if listed.Has(key) || (matches != nil && !matches(&page.Items[i])) {
+						continue
+					}
+					listed.Insert(key)
+					pods = append(pods, page.Items[i])
+				}
+				if page.Continue == "" {
+					break
+				}
+				opts.Continue = page.Continue
+			}
+		}
+	}
+	return pods, nil
+}
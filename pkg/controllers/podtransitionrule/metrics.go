@@ -0,0 +1,39 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	stageProcessDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "podtransitionrule_stage_process_duration_seconds",
+		Help:    "Duration of processing rules of a stage in a PodTransitionRule reconcile, by stage and namespace of PodTransitionRule.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	}, []string{"stage", "namespace"})
+
+	ruleRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podtransitionrule_rule_rejections_total",
+		Help: "Pods rejected by PodTransitionRule rules by rule name, a pod rejected in several reconciles is counted once for each.",
+	}, []string{"rule"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(stageProcessDuration, ruleRejectionsTotal)
+}
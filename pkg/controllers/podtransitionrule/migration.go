@@ -0,0 +1,107 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	commonutils "kusionstack.io/operating/pkg/utils"
+)
+
+// statusSchemaVersion is bumped when fields are added to status, so that status of existing PodTransitionRules
+// is migrated on startup instead of waiting for changes of them
+const statusSchemaVersion = 1
+
+// statusMigrations are PodTransitionRules enqueued by statusMigrator, whose status is written on next reconcile
+// even if nothing but schema version is changed
+var statusMigrations = &pendingMigrations{pending: sets.NewString()}
+
+type pendingMigrations struct {
+	mu      sync.Mutex
+	pending sets.String
+}
+
+func (p *pendingMigrations) add(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending.Insert(key)
+}
+
+// take returns true and forgets key if key is pending migration
+func (p *pendingMigrations) take(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.pending.Has(key) {
+		return false
+	}
+	p.pending.Delete(key)
+	return true
+}
+
+// statusMigrator enqueues PodTransitionRules with status of older schema version once on startup. It is rate limited
+// to avoid a storm of status writes after upgrade, and PodTransitionRules already migrated are skipped.
+type statusMigrator struct {
+	cache  cache.Cache
+	client client.Client
+	logger logr.Logger
+	qps    float64
+	events chan event.GenericEvent
+
+	// sharding runs statusMigrator on all replicas
+	sharding bool
+}
+
+// NeedLeaderElection runs statusMigrator on all replicas if sharding, PodTransitionRules not owned are skipped in reconcile
+func (m *statusMigrator) NeedLeaderElection() bool {
+	return !m.sharding
+}
+
+func (m *statusMigrator) Start(ctx context.Context) error {
+	if !m.cache.WaitForCacheSync(ctx) {
+		return nil
+	}
+	podTransitionRules := &appsv1alpha1.PodTransitionRuleList{}
+	if err := m.client.List(ctx, podTransitionRules); err != nil {
+		m.logger.Error(err, "failed to list podtransitionrules to migrate status")
+		return nil
+	}
+	limiter := rate.NewLimiter(rate.Limit(m.qps), 1)
+	var migrated int
+	for i := range podTransitionRules.Items {
+		podTransitionRule := &podTransitionRules.Items[i]
+		if podTransitionRule.DeletionTimestamp != nil || podTransitionRule.Status.SchemaVersion >= statusSchemaVersion {
+			continue
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return nil
+		}
+		statusMigrations.add(commonutils.ObjectKeyString(podTransitionRule))
+		m.events <- event.GenericEvent{Object: podTransitionRule}
+		migrated++
+	}
+	m.logger.Info("finish enqueuing podtransitionrules to migrate status", "migrated", migrated, "total", len(podTransitionRules.Items), "schemaVersion", statusSchemaVersion)
+	return nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"flag"
+	"strings"
+	"time"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/audit"
+)
+
+// Options are the settings of PodTransitionRule controller
+type Options struct {
+	MaxConcurrentReconciles    int
+	MaxPodWritesPerReconcile   int
+	PodListPageSize            int
+	RejectHistoryLimit         int
+	AuditEndpoint              string
+	AuditFormat                string
+	AuditSigningSecret         string
+	ProtectedPodSelector       string
+	EnablePprofLabels          bool
+	DeletionEscalation         string
+	KillSwitch                 bool
+	KillSwitchNamespace        string
+	MaxRuleStatesBytes         int
+	CompressStatusPodThreshold int
+	PodUpdateStrategy          string
+	PodWriteConcurrency        int
+	PodWriteFailureThreshold   int
+	ExplainInterval            time.Duration
+	CostBudgetWindow           time.Duration
+	ReconcileSecondsBudget     float64
+	WebhookCallsBudget         int
+	WritesBudget               int
+	StatusMigration            bool
+	StatusMigrationQPS         float64
+	UnknownStageVerdict        string
+	Sharding                   bool
+	ShardNamespace             string
+	ShardLeaseDuration         time.Duration
+	MaxReconcileDuration       time.Duration
+	RequeueJitter              float64
+	Finalizer                  string
+	SerializePodWrites         bool
+	ErrorBackoffCeiling        time.Duration
+	ReconcileStatusInterval    time.Duration
+	PodChangeFields            string
+	ResourceCacheSize          int
+	WebhookBreakerFailures     int
+	WebhookBreakerCooldown     time.Duration
+	PodEventInterval           time.Duration
+}
+
+// NewOptions returns Options with default values
+func NewOptions() *Options {
+	return &Options{
+		MaxConcurrentReconciles:  10,
+		MaxPodWritesPerReconcile: 500,
+		PodListPageSize:          0,
+		RejectHistoryLimit:       10,
+		AuditFormat:              string(audit.FormatOPA),
+		DeletionEscalation:       "10m,30m",
+		KillSwitchNamespace:      "kusionstack-system",
+		MaxRuleStatesBytes:       64 * 1024,
+		PodUpdateStrategy:        PodUpdateStrategyPatch,
+		PodWriteConcurrency:      10,
+		PodWriteFailureThreshold: 3,
+		ExplainInterval:          time.Minute,
+		CostBudgetWindow:         time.Minute,
+		StatusMigration:          true,
+		StatusMigrationQPS:       5,
+		UnknownStageVerdict:      UnknownStageVerdictPass,
+		ShardNamespace:           "kusionstack-system",
+		ShardLeaseDuration:       15 * time.Second,
+		RequeueJitter:            0.1,
+		Finalizer:                appsv1alpha1.ProtectFinalizer,
+		ErrorBackoffCeiling:      0,
+		ReconcileStatusInterval:  time.Minute,
+		PodChangeFields:          strings.Join(defaultPodChangeFields, ","),
+		ResourceCacheSize:        1000,
+		WebhookBreakerFailures:   5,
+		WebhookBreakerCooldown:   30 * time.Second,
+		PodEventInterval:         time.Second,
+	}
+}
+
+// AddFlags binds o to podtransitionrule-* flags of fs, current values of o are the defaults of flags
+func (o *Options) AddFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.MaxPodWritesPerReconcile, "podtransitionrule-max-pod-writes", o.MaxPodWritesPerReconcile, "The max number of pods whose detail annotation is written in a single PodTransitionRule reconcile, the remainder is written on subsequent requeues. Non-positive means no limit.")
+	fs.IntVar(&o.PodListPageSize, "podtransitionrule-pod-list-page-size", o.PodListPageSize, "The page size of listing pods selected by a PodTransitionRule from API server instead of the informer cache, so that huge selectors are listed in chunks. Non-positive means pods are listed from the informer cache at once.")
+	fs.IntVar(&o.RejectHistoryLimit, "podtransitionrule-reject-history-limit", o.RejectHistoryLimit, "The max number of latest rejections recorded in status detail of each pod. Non-positive means reject history is not recorded.")
+	fs.StringVar(&o.AuditEndpoint, "podtransitionrule-audit-endpoint", o.AuditEndpoint, "The endpoint PodTransitionRule block/unblock decisions are posted to. Empty means audit is disabled.")
+	fs.StringVar(&o.AuditFormat, "podtransitionrule-audit-format", o.AuditFormat, "The payload format of PodTransitionRule audit, opa or json.")
+	fs.IntVar(&o.MaxConcurrentReconciles, "podtransitionrule-max-concurrent-reconciles", o.MaxConcurrentReconciles, "The max number of PodTransitionRules reconciled concurrently, which must be at least 1. "+
+		"Increasing it helps to keep up with many large PodTransitionRules, but raises load on API server since each reconcile lists and writes pods.")
+	fs.StringVar(&o.ProtectedPodSelector, "podtransitionrule-protected-pod-selector", o.ProtectedPodSelector, "The label selector of protected pods, e.g. pods of the controller and other critical operators. "+
+		"Protected pods, pods labeled "+appsv1alpha1.PodTransitionRuleProtectedLabelKey+"=true and the pod of the controller itself are excluded from targets of PodTransitionRules, "+
+		"unless PodTransitionRules are annotated with "+appsv1alpha1.AnnotationGovernProtectedPods+"=true. Empty means only the labeled pods and the pod of the controller itself are protected.")
+	fs.StringVar(&o.AuditSigningSecret, "podtransitionrule-audit-signing-secret", o.AuditSigningSecret, "The namespace/name of Secret whose values are HMAC keys signing PodTransitionRule audit payloads in header "+audit.SignatureHeader+". "+
+		"Payloads are signed with every key, so keys can be rotated by adding the new key before removing the old one. Empty means payloads are not signed.")
+	fs.StringVar(&o.DeletionEscalation, "podtransitionrule-deletion-escalation-thresholds", o.DeletionEscalation, "Comma separated durations of blocked PodTransitionRule deletion, at which escalating warning events are emitted. The last one is critical.")
+	fs.BoolVar(&o.KillSwitch, "podtransitionrule-kill-switch", o.KillSwitch, "Make all PodTransitionRules observe-only, so that they never block pods. Deletion protection is not affected.")
+	fs.StringVar(&o.KillSwitchNamespace, "podtransitionrule-kill-switch-namespace", o.KillSwitchNamespace, "The namespace of kill switch ConfigMap podtransitionrule-kill-switch. All PodTransitionRules are observe-only while its data active is true.")
+	fs.IntVar(&o.MaxRuleStatesBytes, "podtransitionrule-max-rule-states-bytes", o.MaxRuleStatesBytes, "The max size of RuleStates kept in PodTransitionRule status, larger RuleStates are moved into a companion ConfigMap and summarized in status. Non-positive means no limit.")
+	fs.IntVar(&o.CompressStatusPodThreshold, "podtransitionrule-compress-status-pod-threshold", o.CompressStatusPodThreshold, "PodTransitionRules with more target pods than the threshold keep Details and RuleStates compressed in a companion ConfigMap instead of status. Non-positive means never compress.")
+	fs.IntVar(&o.PodWriteConcurrency, "podtransitionrule-pod-write-concurrency", o.PodWriteConcurrency, "The max number of pods written concurrently when PodTransitionRule annotations are removed from unselected pods or on deletion. "+
+		"Each pod is written by a single merge patch only containing changed annotations, retried on conflict. Non-positive means pods are written one by one.")
+	fs.StringVar(&o.PodUpdateStrategy, "podtransitionrule-pod-update-strategy", o.PodUpdateStrategy, "How PodTransitionRule controller writes pods when removing its annotations, patch or update. Patch only sends changed keys and conflicts less with concurrent writers.")
+	fs.IntVar(&o.PodWriteFailureThreshold, "podtransitionrule-pod-write-failure-threshold", o.PodWriteFailureThreshold, "The number of consecutive failed annotation writes on a pod, at which a warning event is emitted and the pod is named in PodWriteConflict condition of PodTransitionRule. Non-positive means disabled.")
+	fs.DurationVar(&o.ExplainInterval, "podtransitionrule-explain-interval", o.ExplainInterval, "The min interval between explains of a PodTransitionRule on a pod requested by annotation podtransitionrule.kusionstack.io/explain.")
+	fs.DurationVar(&o.CostBudgetWindow, "podtransitionrule-cost-budget-window", o.CostBudgetWindow, "The window of PodTransitionRule cost budgets. A PodTransitionRule exceeding any budget in a window is deprioritized in workqueue until the window ends.")
+	fs.Float64Var(&o.ReconcileSecondsBudget, "podtransitionrule-reconcile-seconds-budget", o.ReconcileSecondsBudget, "The soft budget of time spent reconciling a PodTransitionRule in a budget window. Non-positive means no limit.")
+	fs.IntVar(&o.WebhookCallsBudget, "podtransitionrule-webhook-calls-budget", o.WebhookCallsBudget, "The soft budget of webhook calls of a PodTransitionRule in a budget window. Non-positive means no limit.")
+	fs.IntVar(&o.WritesBudget, "podtransitionrule-writes-budget", o.WritesBudget, "The soft budget of write requests in reconciles of a PodTransitionRule in a budget window. Non-positive means no limit.")
+	fs.BoolVar(&o.StatusMigration, "podtransitionrule-status-migration", o.StatusMigration, "Migrate status of PodTransitionRules written with older schema version on startup, so that new status fields are populated without waiting for changes.")
+	fs.Float64Var(&o.StatusMigrationQPS, "podtransitionrule-status-migration-qps", o.StatusMigrationQPS, "The rate of PodTransitionRules enqueued by status migration on startup.")
+	fs.BoolVar(&o.SerializePodWrites, "podtransitionrule-serialize-pod-writes", o.SerializePodWrites, "Serialize annotation writes of PodTransitionRules on the same pod, so that PodTransitionRules selecting overlapping pods do not conflict on pod writes. "+
+		"It reduces throughput of pod writes, but prevents annotations of overlapping PodTransitionRules flapping.")
+	fs.StringVar(&o.UnknownStageVerdict, "podtransitionrule-unknown-stage-verdict", o.UnknownStageVerdict, "The verdict of pods whose stage is removed from policy, pass or block. Blocked pods are in stage Unknown until they enter a stage of current policy.")
+	fs.BoolVar(&o.Sharding, "podtransitionrule-sharding", o.Sharding, "Run PodTransitionRule controller on all replicas without leader election, each replica reconciles a hash shard of PodTransitionRules. "+
+		"Replicas are discovered by Leases in podtransitionrule-shard-namespace, and PodTransitionRules moved to a replica are reconciled by it only after a handoff delay, so that no PodTransitionRule is owned by two replicas at the same time.")
+	fs.StringVar(&o.ShardNamespace, "podtransitionrule-shard-namespace", o.ShardNamespace, "The namespace of Leases held by replicas sharing PodTransitionRules.")
+	fs.DurationVar(&o.ShardLeaseDuration, "podtransitionrule-shard-lease-duration", o.ShardLeaseDuration, "The duration of Leases held by replicas sharing PodTransitionRules. Leases are renewed every third of it, "+
+		"and the handoff delay of PodTransitionRules moved on rebalancing is the lease duration plus a renew interval.")
+	fs.DurationVar(&o.MaxReconcileDuration, "podtransitionrule-max-reconcile-duration", o.MaxReconcileDuration, "The max duration of a PodTransitionRule reconcile. Stages and pod writes not started before it are skipped, "+
+		"progress made is persisted and the reconcile is requeued to continue. Non-positive means no limit.")
+	fs.Float64Var(&o.RequeueJitter, "podtransitionrule-requeue-jitter", o.RequeueJitter, "The max fraction PodTransitionRule requeue intervals are shortened or lengthened by, so that PodTransitionRules computing the same interval "+
+		"are not requeued at the same instant. The jitter of a PodTransitionRule is stable across reconciles. Non-positive means no jitter.")
+	fs.DurationVar(&o.ErrorBackoffCeiling, "podtransitionrule-error-backoff-ceiling", o.ErrorBackoffCeiling, "The max backoff of requeuing a PodTransitionRule whose reconciles fail in a row. The backoff starts from "+errorBackoffBase.String()+
+		" and doubles on each failure until the ceiling, and is reset on success. Errors are logged instead of returned to workqueue. Non-positive, the default, means errors are returned to workqueue and retried by its rate limiter.")
+	fs.DurationVar(&o.ReconcileStatusInterval, "podtransitionrule-reconcile-status-interval", o.ReconcileStatusInterval, "The min interval of refreshing lastReconcileTime and lastReconcileDurationMillis in status of a PodTransitionRule "+
+		"whose status is not changed otherwise, so that reconciles do not cause a storm of status writes. Non-positive means they are only written with other changes of status.")
+	fs.StringVar(&o.Finalizer, "podtransitionrule-finalizer", o.Finalizer, "The finalizer added on PodTransitionRules, so that pods are cleaned up before PodTransitionRules are deleted. "+
+		"Use distinct finalizers to run multiple controllers on the same PodTransitionRules.")
+	fs.BoolVar(&o.EnablePprofLabels, "podtransitionrule-pprof-labels", o.EnablePprofLabels, "Set pprof labels of PodTransitionRule and stage on reconcile, so that CPU profiles can be grouped by PodTransitionRule.")
+	fs.StringVar(&o.PodChangeFields, "podtransitionrule-pod-change-fields", o.PodChangeFields, "Comma separated pod fields whose changes trigger PodTransitionRule reconcile, other pod updates are ignored. "+
+		"Supported fields are metadata.labels, metadata.annotations, metadata.annotations[<key>], metadata.deletionTimestamp, metadata.finalizers, spec.nodeName, status.phase, status.conditions, status.podIP and status.containerStatuses.")
+	fs.IntVar(&o.ResourceCacheSize, "podtransitionrule-resource-cache-size", o.ResourceCacheSize, "The max number of objects cached for each resource registered to PodTransitionRule. Rules on a resource with more objects fail.")
+	fs.IntVar(&o.WebhookBreakerFailures, "podtransitionrule-webhook-breaker-failures", o.WebhookBreakerFailures, "The number of consecutive failures of a PodTransitionRule webhook endpoint to open its circuit breaker. Non-positive means circuit breaker is disabled.")
+	fs.DurationVar(&o.WebhookBreakerCooldown, "podtransitionrule-webhook-breaker-cooldown", o.WebhookBreakerCooldown, "The duration a PodTransitionRule webhook circuit breaker keeps open before trying to recover.")
+	fs.DurationVar(&o.PodEventInterval, "podtransitionrule-pod-event-interval", o.PodEventInterval, "The min interval between notifications of the same pod whose PodTransitionRule detail is changed, "+
+		"changes within the interval are notified once at its end. Non-positive means every change is notified immediately.")
+}
+
+// Option configures PodTransitionRule controller on setup
+type Option func(*Options)
+
+// WithOptions replaces all options by o, e.g. options bound to command line flags. Options following it override o.
+func WithOptions(o *Options) Option {
+	return func(opts *Options) {
+		*opts = *o
+	}
+}
+
+// WithMaxConcurrentReconciles sets the max number of PodTransitionRules reconciled concurrently, which must be at
+// least 1. Increasing it helps to keep up with many large PodTransitionRules, but raises load on API server since
+// each reconcile lists and writes pods.
+func WithMaxConcurrentReconciles(n int) Option {
+	return func(opts *Options) {
+		opts.MaxConcurrentReconciles = n
+	}
+}
+
+// newOptions applies opts on default options
+func newOptions(opts ...Option) *Options {
+	o := NewOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
@@ -0,0 +1,89 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// pausedDetails lets all target pods in stages pass without evaluating rules
+func (r *PodTransitionRuleReconciler) pausedDetails(targets map[string]*corev1.Pod) map[string]*appsv1alpha1.PodTransitionDetail {
+	details := map[string]*appsv1alpha1.PodTransitionDetail{}
+	for name, pod := range targets {
+		stage := r.Stage(pod)
+		if stage == "" {
+			continue
+		}
+		details[name] = &appsv1alpha1.PodTransitionDetail{
+			Name:   name,
+			Stage:  stage,
+			Passed: true,
+		}
+	}
+	return details
+}
+
+// pausedConditions sets Paused condition in conditions if podTransitionRule is paused, or else removes it
+func pausedConditions(podTransitionRule *appsv1alpha1.PodTransitionRule, conditions []appsv1alpha1.PodTransitionRuleCondition) []appsv1alpha1.PodTransitionRuleCondition {
+	var res []appsv1alpha1.PodTransitionRuleCondition
+	var current *appsv1alpha1.PodTransitionRuleCondition
+	for i, cond := range conditions {
+		if cond.Type == appsv1alpha1.PodTransitionRulePaused {
+			current = &conditions[i]
+			continue
+		}
+		res = append(res, cond)
+	}
+	if !podTransitionRule.Spec.Paused {
+		return res
+	}
+	cond := appsv1alpha1.PodTransitionRuleCondition{
+		Type:               appsv1alpha1.PodTransitionRulePaused,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Reason:             "Paused",
+		Message:            "rule evaluation is paused, all target pods pass",
+	}
+	if current != nil && current.Status == cond.Status {
+		cond.LastTransitionTime = current.LastTransitionTime
+	}
+	return append(res, cond)
+}
+
+// recordPauseEvents emits an event when podTransitionRule is paused or resumed, compared with last Paused condition
+func (r *PodTransitionRuleReconciler) recordPauseEvents(podTransitionRule *appsv1alpha1.PodTransitionRule, lastConditions []appsv1alpha1.PodTransitionRuleCondition) {
+	if r.Recorder == nil {
+		return
+	}
+	var wasPaused bool
+	for _, cond := range lastConditions {
+		if cond.Type == appsv1alpha1.PodTransitionRulePaused && cond.Status == corev1.ConditionTrue {
+			wasPaused = true
+		}
+	}
+	switch {
+	case podTransitionRule.Spec.Paused && !wasPaused:
+		r.Recorder.Event(podTransitionRule, corev1.EventTypeWarning, "Paused", "rule evaluation is paused, all target pods pass")
+	case !podTransitionRule.Spec.Paused && wasPaused:
+		r.Recorder.Event(podTransitionRule, corev1.EventTypeNormal, "Resumed", "rule evaluation is resumed")
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"sync"
+)
+
+// keyedMutex is a set of mutexes by key, a mutex is released once nobody holds or waits for it
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the mutex of key, and returns the function releasing it
+func (m *keyedMutex) lock(key string) func() {
+	m.mu.Lock()
+	if m.locks == nil {
+		m.locks = map[string]*keyedLock{}
+	}
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		m.locks[key] = l
+	}
+	l.refs++
+	m.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(m.locks, key)
+		}
+	}
+}
+
+// lockPod serializes writes on pod by PodTransitionRules if serializePodWrites is enabled. It returns the function
+// releasing the lock.
+func (r *PodTransitionRuleReconciler) lockPod(namespace, name string) func() {
+	if !r.serializePodWrites {
+		return func() {}
+	}
+	return r.podLocks.lock(namespace + "/" + name)
+}
@@ -19,28 +19,41 @@ package podtransitionrule
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"runtime/pprof"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/audit"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/tracing"
 	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 	controllerutils "kusionstack.io/operating/pkg/controllers/utils"
 	"kusionstack.io/operating/pkg/utils"
@@ -51,41 +64,224 @@ import (
 const (
 	controllerName = "podtransitionrule-controller"
 	resourceName   = "PodTransitionRule"
+
+	// debounceDelay coalesces requests triggered by pods and referenced resources
+	debounceDelay = 500 * time.Millisecond
 )
 
 // NewReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, opts ...Option) reconcile.Reconciler {
+	o := newOptions(opts...)
 	mixin := mixin.NewReconcilerMixin(controllerName, mgr)
+	auditEndpoint := o.AuditEndpoint
+	var auditKeys audit.KeySource
+	if o.AuditSigningSecret != "" {
+		namespace, name, err := cache.SplitMetaNamespaceKey(o.AuditSigningSecret)
+		if err != nil || namespace == "" || name == "" {
+			mixin.Logger.Error(err, "invalid audit signing secret, audit is disabled", "secret", o.AuditSigningSecret)
+			auditEndpoint = ""
+		} else {
+			auditKeys = audit.SecretKeySource(mgr.GetAPIReader(), namespace, name)
+		}
+	}
+	auditSink, err := audit.NewSink(auditEndpoint, audit.Format(o.AuditFormat), auditKeys)
+	if err != nil {
+		mixin.Logger.Error(err, "failed to init audit sink, audit is disabled")
+	}
+	escalationThresholds, err := parseEscalationThresholds(o.DeletionEscalation)
+	if err != nil {
+		mixin.Logger.Error(err, "failed to parse deletion escalation thresholds, escalation is disabled")
+	}
+	updateStrategy, err := parsePodUpdateStrategy(o.PodUpdateStrategy)
+	if err != nil {
+		mixin.Logger.Error(err, "failed to parse pod update strategy")
+	}
+	stageVerdict, err := parseUnknownStageVerdict(o.UnknownStageVerdict)
+	if err != nil {
+		mixin.Logger.Error(err, "failed to parse verdict of unknown stages")
+	}
+	protection, err := newPodProtection(o.ProtectedPodSelector)
+	if err != nil {
+		mixin.Logger.Error(err, "failed to parse protected pod selector, only labeled pods and the pod of controller are protected")
+	}
+	mixin.Client = &costClient{Client: mixin.Client}
+	reconcileCosts.budget = costBudget{
+		window:       o.CostBudgetWindow,
+		seconds:      o.ReconcileSecondsBudget,
+		webhookCalls: o.WebhookCallsBudget,
+		writes:       o.WritesBudget,
+	}
+	resources.SetMaxObjectsPerResource(o.ResourceCacheSize)
+	rules.SetBreakerOptions(o.WebhookBreakerFailures, o.WebhookBreakerCooldown)
+	PodEventQueues.SetMinInterval(o.PodEventInterval)
 	return &PodTransitionRuleReconciler{
-		ReconcilerMixin: mixin,
-		Policy:          register.DefaultPolicy(),
+		ReconcilerMixin:            mixin,
+		Policy:                     register.DefaultPolicy(),
+		maxPodWritesPerReconcile:   o.MaxPodWritesPerReconcile,
+		podListPageSize:            o.PodListPageSize,
+		rejectHistoryLimit:         o.RejectHistoryLimit,
+		auditSink:                  auditSink,
+		podProtection:              protection,
+		escalationThresholds:       escalationThresholds,
+		maxRuleStatesBytes:         o.MaxRuleStatesBytes,
+		compressStatusPodThreshold: o.CompressStatusPodThreshold,
+		podUpdateStrategy:          updateStrategy,
+		podWriteConcurrency:        o.PodWriteConcurrency,
+		podWriteFailureThreshold:   o.PodWriteFailureThreshold,
+		explainInterval:            o.ExplainInterval,
+		unknownStageVerdict:        stageVerdict,
+		maxReconcileDuration:       o.MaxReconcileDuration,
+		requeueJitter:              o.RequeueJitter,
+		finalizer:                  o.Finalizer,
+		serializePodWrites:         o.SerializePodWrites,
+		errorBackoffCeiling:        o.ErrorBackoffCeiling,
+		reconcileStatusInterval:    o.ReconcileStatusInterval,
+		killSwitch:                 o.KillSwitch,
+		killSwitchNamespace:        o.KillSwitchNamespace,
+		pprofLabels:                o.EnablePprofLabels,
+	}
+}
+
+// jitterInterval shortens or lengthens interval by at most fraction of it. The jitter is derived from key, so that
+// it is stable for the same object while objects computing the same interval are requeued at different instants.
+func jitterInterval(key string, interval time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || interval <= 0 {
+		return interval
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	// uniform in [-1, 1)
+	unit := float64(mix64(h.Sum64())>>11)/float64(1<<53)*2 - 1
+	return interval + time.Duration(float64(interval)*fraction*unit)
+}
+
+// parseEscalationThresholds parses comma separated durations in ascending order
+func parseEscalationThresholds(thresholds string) ([]time.Duration, error) {
+	var res []time.Duration
+	for _, threshold := range strings.Split(thresholds, ",") {
+		threshold = strings.TrimSpace(threshold)
+		if threshold == "" {
+			continue
+		}
+		d, err := time.ParseDuration(threshold)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, d)
 	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] < res[j]
+	})
+	return res, nil
 }
 
-func addToMgr(mgr manager.Manager, r reconcile.Reconciler) (controller.Controller, error) {
-	// Create a new controller
-	c, err := controller.New(controllerName, mgr, controller.Options{
-		MaxConcurrentReconciles: 5,
+func addToMgr(mgr manager.Manager, r reconcile.Reconciler, opts ...Option) (controller.Controller, error) {
+	o := newOptions(opts...)
+	// Create a new controller, sharded controllers are started on all replicas
+	newController := controller.New
+	if o.Sharding {
+		newController = controller.NewUnmanaged
+	}
+	if o.MaxConcurrentReconciles < 1 {
+		return nil, fmt.Errorf("invalid max concurrent reconciles %d, it must be at least 1", o.MaxConcurrentReconciles)
+	}
+	c, err := newController(controllerName, mgr, controller.Options{
+		MaxConcurrentReconciles: o.MaxConcurrentReconciles,
 		Reconciler:              r,
+		RateLimiter:             newFairRateLimiter(),
 	})
 	if err != nil {
 		return nil, err
 	}
+	if o.Sharding {
+		if err := addShardsToMgr(mgr, c, o.ShardNamespace, o.ShardLeaseDuration); err != nil {
+			return c, err
+		}
+	}
 	// Watch for changes to PodTransitionRule
-	err = c.Watch(&source.Kind{Type: &appsv1alpha1.PodTransitionRule{}}, &PodTransitionRuleEventHandler{})
+	err = c.Watch(&source.Kind{Type: &appsv1alpha1.PodTransitionRule{}}, &QueueWaitEventHandler{EventHandler: &PodTransitionRuleEventHandler{}, Trigger: triggerObjectPodTransitionRule})
+	if err != nil {
+		return c, err
+	}
+
+	podChangePredicate, err := NewPodChangePredicate(o.PodChangeFields)
+	if err != nil {
+		return c, err
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &QueueWaitEventHandler{EventHandler: &DebounceEventHandler{EventHandler: &EventHandler{}, Delay: debounceDelay}, Trigger: triggerObjectPod}, podChangePredicate)
+	if err != nil {
+		return c, err
+	}
+
+	err = c.Watch(&source.Channel{Source: NewWebhookGenericEventChannel()}, &QueueWaitEventHandler{EventHandler: &handler.EnqueueRequestForObject{}})
+	if err != nil {
+		return c, err
+	}
+
+	// Migrate status of older schema version
+	if o.StatusMigration {
+		migrations := make(chan event.GenericEvent, 1<<10)
+		err = c.Watch(&source.Channel{Source: migrations}, &QueueWaitEventHandler{EventHandler: &handler.EnqueueRequestForObject{}})
+		if err != nil {
+			return c, err
+		}
+		err = mgr.Add(&statusMigrator{
+			cache:    mgr.GetCache(),
+			client:   mgr.GetClient(),
+			logger:   mgr.GetLogger().WithName(controllerName).WithName("statusMigrator"),
+			qps:      o.StatusMigrationQPS,
+			events:   migrations,
+			sharding: o.Sharding,
+		})
+		if err != nil {
+			return c, err
+		}
+	}
+
+	// Watch for changes to namespace defaults
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &QueueWaitEventHandler{EventHandler: &DebounceEventHandler{EventHandler: handler.EnqueueRequestsFromMapFunc(enqueueNamespacePodTransitionRules(mgr.GetClient())), Delay: debounceDelay}}, &NamespaceDefaultsPredicate{})
 	if err != nil {
 		return c, err
 	}
 
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &EventHandler{})
+	// Watch for changes to ConfigMaps of shared rules
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &QueueWaitEventHandler{EventHandler: &DebounceEventHandler{EventHandler: handler.EnqueueRequestsFromMapFunc(enqueueRulesFromPodTransitionRules(mgr.GetClient())), Delay: debounceDelay}}, &RulesFromPredicate{Reader: mgr.GetClient()})
 	if err != nil {
 		return c, err
 	}
 
-	err = c.Watch(&source.Channel{Source: NewWebhookGenericEventChannel()}, &handler.EnqueueRequestForObject{})
+	// Watch for changes to kill switch
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &QueueWaitEventHandler{EventHandler: handler.EnqueueRequestsFromMapFunc(enqueueAllPodTransitionRules(mgr.GetClient()))}, &KillSwitchPredicate{Namespace: o.KillSwitchNamespace})
 	if err != nil {
 		return c, err
 	}
+
+	// Watch for rollout progress of workloads
+	for _, workload := range []client.Object{&appsv1.Deployment{}, &appsv1alpha1.CollaSet{}} {
+		err = c.Watch(&source.Kind{Type: workload}, &QueueWaitEventHandler{EventHandler: &DebounceEventHandler{EventHandler: handler.EnqueueRequestsFromMapFunc(enqueueWorkloadRolloutPodTransitionRules(mgr.GetClient())), Delay: debounceDelay}})
+		if err != nil {
+			return c, err
+		}
+	}
+
+	// Watch for disruptions allowed of PodDisruptionBudgets referred by rules
+	err = c.Watch(&source.Kind{Type: &policyv1.PodDisruptionBudget{}}, &QueueWaitEventHandler{EventHandler: handler.EnqueueRequestsFromMapFunc(enqueuePDBPodTransitionRules(mgr.GetClient()))}, &PDBDisruptionsAllowedPredicate{})
+	if err != nil {
+		return c, err
+	}
+
+	// Watch for changes to resources registered, rules are able to check their state
+	for _, gvk := range register.GetResources(register.DefaultPolicy()) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		err = c.Watch(&source.Kind{Type: obj}, &QueueWaitEventHandler{EventHandler: &DebounceEventHandler{EventHandler: &ResourceEventHandler{Store: resources.DefaultStore}, Delay: debounceDelay}})
+		if err != nil {
+			return c, err
+		}
+	}
 	return c, nil
 }
 
@@ -93,88 +289,315 @@ func addToMgr(mgr manager.Manager, r reconcile.Reconciler) (controller.Controlle
 type PodTransitionRuleReconciler struct {
 	*mixin.ReconcilerMixin
 	register.Policy
+
+	// maxPodWritesPerReconcile limits the write burst on pods when a PodTransitionRule selects lots of new pods
+	maxPodWritesPerReconcile int
+
+	// podListPageSize is the page size of listing selected pods from API server, non-positive means listing them from
+	// the informer cache at once
+	podListPageSize int
+
+	// rejectHistoryLimit is the max number of rejections kept in reject history of each pod, non-positive means
+	// reject history is not recorded
+	rejectHistoryLimit int
+
+	// auditSink exports decisions of pods, nil if audit is disabled
+	auditSink *audit.Sink
+
+	// escalationThresholds are durations of blocked deletion to emit escalating events
+	escalationThresholds []time.Duration
+
+	// maxRuleStatesBytes is the max size of RuleStates kept in status
+	maxRuleStatesBytes int
+
+	// compressStatusPodThreshold is the number of target pods above which status is compressed
+	compressStatusPodThreshold int
+
+	// podUpdateStrategy is how pods are written, patch or update
+	podUpdateStrategy string
+
+	// podWriteConcurrency is the max number of pods written concurrently when annotations are removed from pods
+	podWriteConcurrency int
+
+	// podWriteFailureThreshold is the number of consecutive failed annotation writes on a pod to be reported
+	podWriteFailureThreshold int
+	podWriteFailures         podWriteFailures
+
+	// serializePodWrites serializes annotation writes on the same pod by podLocks
+	serializePodWrites bool
+	podLocks           keyedMutex
+
+	// explainInterval is the min interval between explains of a PodTransitionRule on a pod
+	explainInterval time.Duration
+	explainLimiter  explainLimiter
+
+	// unknownStageVerdict is the verdict of pods whose stage is removed from policy, pass or block
+	unknownStageVerdict string
+
+	// maxReconcileDuration is the deadline of a reconcile, after which the remainder is continued on requeue
+	maxReconcileDuration time.Duration
+
+	// requeueJitter is the max fraction requeue intervals are jittered by
+	requeueJitter float64
+
+	// errorBackoffCeiling is the max backoff of podTransitionRules failing in a row, non-positive means disabled
+	errorBackoffCeiling time.Duration
+	reconcileFailures   reconcileFailures
+
+	// reconcileStatusInterval is the min interval of refreshing last reconcile of unchanged status, non-positive means never
+	reconcileStatusInterval time.Duration
+
+	// finalizer is added on podTransitionRules to clean up pods before deletion, empty means ProtectFinalizer
+	finalizer string
+
+	// podProtection identifies protected pods excluded from targets, nil means no pod is protected
+	podProtection *podProtection
+
+	// killSwitch makes all podTransitionRules observe-only, as well as the kill switch ConfigMap in killSwitchNamespace
+	killSwitch          bool
+	killSwitchNamespace string
+
+	// pprofLabels sets pprof labels of podTransitionRule and stage on reconcile
+	pprofLabels bool
 }
 
 // +kubebuilder:rbac:groups=apps.kusionstack.io,resources=podtransitionrules,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=apps.kusionstack.io,resources=podtransitionrules/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=apps.kusionstack.io,resources=podtransitionrules/finalizers,verbs=update
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;update;patch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims;persistentvolumes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments;replicasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps.kusionstack.io,resources=collasets,verbs=get;list;watch
+
+// InjectClient wraps the injected client to count writes of reconciles
+func (r *PodTransitionRuleReconciler) InjectClient(c client.Client) error {
+	return r.ReconcilerMixin.InjectClient(&costClient{Client: c})
+}
 
 func (r *PodTransitionRuleReconciler) Reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, reconcileErr error) {
+	if shardMembers != nil && !shardMembers.owns(request.String()) {
+		return reconcile.Result{}, nil
+	}
+	queueWaits.observe(request)
+	reconcileTriggers.observe(request)
+	ctx, cost := withReconcileCost(ctx)
+	ctx, span := tracing.Start(ctx, "PodTransitionRule.Reconcile", tracing.String("podtransitionrule", request.String()))
+	defer span.End()
+	if r.maxReconcileDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = withReconcileDeadline(ctx, r.maxReconcileDuration)
+		defer cancel()
+	}
+	defer func(start time.Time) {
+		reconcileDurationSeconds.WithLabelValues(request.Namespace, request.Name).Observe(time.Since(start).Seconds())
+		result = r.accountCost(request, cost, time.Since(start), result)
+	}(time.Now())
+	if !r.pprofLabels {
+		result, reconcileErr = r.reconcile(ctx, request)
+	} else {
+		pprof.Do(ctx, pprof.Labels("podtransitionrule", request.Name, "namespace", request.Namespace), func(ctx context.Context) {
+			result, reconcileErr = r.reconcile(ctx, request)
+		})
+	}
+	return r.backoffOnError(request, result, reconcileErr)
+}
+
+func (r *PodTransitionRuleReconciler) reconcile(ctx context.Context, request reconcile.Request) (result reconcile.Result, reconcileErr error) {
 	logger := r.Logger.WithValues("podTransitionRule", request.String())
+	startTime := reconcileStartTime(ctx)
 	result = reconcile.Result{}
 	podTransitionRule := &appsv1alpha1.PodTransitionRule{}
-	if err := r.Client.Get(context.TODO(), request.NamespacedName, podTransitionRule); err != nil {
+	if err := r.Client.Get(ctx, request.NamespacedName, podTransitionRule); err != nil {
 		if errors.IsNotFound(err) {
+			podtransitionruleutils.PodTransitionRuleVersionExpectation.DeleteExpectations(request.String())
+			queueWaits.forget(request)
+			reconcileCosts.forget(request)
+			processor.ForgetVerdicts(request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{}, err
 	}
+	if cost := reconcileCostFrom(ctx); cost != nil {
+		cost.podTransitionRule = podTransitionRule
+	}
 
 	if !podtransitionruleutils.PodTransitionRuleVersionExpectation.SatisfiedExpectations(commonutils.ObjectKeyString(podTransitionRule), podTransitionRule.ResourceVersion) {
 		logger.Info("podTransitionRule's resourceVersion is too old, retry later", "resourceVersion.now", podTransitionRule.ResourceVersion)
 		return reconcile.Result{}, nil
 	}
 
-	selector, _ := metav1.LabelSelectorAsSelector(podTransitionRule.Spec.Selector)
-	selectedPods := &corev1.PodList{}
-	if err := r.Client.List(context.TODO(), selectedPods, &client.ListOptions{Namespace: podTransitionRule.Namespace, LabelSelector: selector}); err != nil {
-		logger.Error(err, "failed to list pod by podtransitionrule")
-		return reconcile.Result{}, err
+	// podTransitionRule with invalid selectors selects no pod, and is only reconciled on deletion
+	var pods []corev1.Pod
+	selectorErr := selectorError(podTransitionRule)
+	if selectorErr != nil && podTransitionRule.DeletionTimestamp == nil {
+		logger.Error(selectorErr, "invalid selector of podtransitionrule")
+		return reconcile.Result{}, r.reportInvalidSelector(ctx, podTransitionRule, selectorErr)
+	} else if selectorErr == nil {
+		var excluded, annotated []string
+		var err error
+		if pods, excluded, annotated, err = r.resolveTargets(ctx, podTransitionRule); err != nil {
+			logger.Error(err, "failed to list pod by podtransitionrule")
+			return reconcile.Result{}, err
+		}
+		if len(excluded) > 0 {
+			logger.Info("WARNING: protected pods are excluded from targets", "pods", excluded)
+			r.recordExcludedPods(podTransitionRule, excluded)
+		}
+		r.recordAnnotatedExcludedPods(podTransitionRule, annotated)
 	}
+	selectedPods := &corev1.PodList{Items: pods}
+
+	// dry run reconciles never write podTransitionRule and pods
+	dryRun := isDryRun(podTransitionRule)
+	// evaluate only reconciles write status but never write pods
+	evaluateOnly := podTransitionRule.Spec.DryRun
 
 	// Delete
 	if podTransitionRule.DeletionTimestamp != nil {
 		if err := r.cleanUpPodTransitionRulePods(ctx, podTransitionRule); err != nil {
-			return reconcile.Result{}, err
+			if !deletionGracePeriodExpired(podTransitionRule, time.Now()) {
+				return reconcile.Result{}, r.escalateBlockedDeletion(ctx, podTransitionRule, err)
+			}
+			logger.Info("WARNING: deletion grace period expired, remove finalizer with pods not cleaned up", "error", err.Error())
+			if r.Recorder != nil {
+				r.Recorder.Eventf(podTransitionRule, corev1.EventTypeWarning, "ForcedCleanup", "deletion grace period %ds expired, remove finalizer with pods not cleaned up: %v",
+					*podTransitionRule.Spec.DeletionProtection.GracePeriodSeconds, err)
+			}
 		}
-		if !controllerutil.ContainsFinalizer(podTransitionRule, appsv1alpha1.ProtectFinalizer) {
-			return reconcile.Result{}, nil
+		r.podWriteFailures.forget(commonutils.ObjectKeyString(podTransitionRule))
+		if err := r.removeFinalizer(ctx, podTransitionRule); err != nil {
+			return reconcile.Result{}, err
 		}
-		return reconcile.Result{}, controllerutils.RemoveFinalizer(ctx, r.Client, podTransitionRule, appsv1alpha1.ProtectFinalizer)
-	} else if !controllerutil.ContainsFinalizer(podTransitionRule, appsv1alpha1.ProtectFinalizer) {
-		if err := controllerutils.AddFinalizer(ctx, r.Client, podTransitionRule, appsv1alpha1.ProtectFinalizer); err != nil {
+		// expectation is not fulfilled by a recreated podTransitionRule of the same key, whose resourceVersion may be lower
+		podtransitionruleutils.PodTransitionRuleVersionExpectation.DeleteExpectations(commonutils.ObjectKeyString(podTransitionRule))
+		return reconcile.Result{}, nil
+	} else if !dryRun && !evaluateOnly && !controllerutil.ContainsFinalizer(podTransitionRule, r.cleanUpFinalizer()) {
+		if err := controllerutils.AddFinalizer(ctx, r.Client, podTransitionRule, r.cleanUpFinalizer()); err != nil {
 			return result, fmt.Errorf("fail to add finalizer on PodTransitionRule %s: %s", request, err)
 		}
 	}
 
+	// pods are keyed by name, or by namespace/name if podTransitionRule selects pods across namespaces
 	selectedPodNames := sets.String{}
 	for _, pod := range selectedPods.Items {
 		if !podtransitionruleutils.PodVersionExpectation.SatisfiedExpectations(commonutils.ObjectKeyString(&pod), pod.ResourceVersion) {
 			logger.Info("pod's resourceVersion is too old, retry later", "pod", commonutils.ObjectKeyString(&pod), "pod.resourceVersion", pod.ResourceVersion)
 			return reconcile.Result{}, nil
 		}
-		selectedPodNames.Insert(pod.Name)
+		selectedPodNames.Insert(podtransitionruleutils.TargetKey(podTransitionRule, &pod))
 	}
 	targetPods := map[string]*corev1.Pod{}
 	for i, pod := range selectedPods.Items {
-		targetPods[pod.Name] = &selectedPods.Items[i]
+		targetPods[podtransitionruleutils.TargetKey(podTransitionRule, &pod)] = &selectedPods.Items[i]
 	}
+	tracing.SpanFromContext(ctx).SetAttributes(tracing.Int("targets", len(targetPods)))
 
 	// remove unselected pods
+	var unselected []string
 	for _, name := range podTransitionRule.Status.Targets {
-		if selectedPodNames.Has(name) {
-			continue
+		if !dryRun && !evaluateOnly && !selectedPodNames.Has(name) {
+			unselected = append(unselected, name)
 		}
-
-		if _, err := r.updatePodTransitionRuleOnPod(ctx, podTransitionRule.Name, name, podTransitionRule.Namespace, podtransitionruleutils.MoveAllPodTransitionRuleInfo); err != nil {
+	}
+	if err := r.writePods(len(unselected), func(i int) error {
+		name := unselected[i]
+		namespace, podName := podtransitionruleutils.SplitTargetKey(podTransitionRule, name)
+		_, err := r.updatePodTransitionRuleOnPod(ctx, podTransitionRule.Name, podName, namespace, podtransitionruleutils.MoveAllPodTransitionRuleInfo)
+		if errors.IsNotFound(err) {
+			// pod is deleted, it is dropped from targets since targets are rebuilt from selected pods
+			r.observePodWrite(podTransitionRule, name, nil)
+			logger.V(1).Info("unselected pod is deleted, drop it from targets", "pod", name)
+			return nil
+		}
+		r.observePodWrite(podTransitionRule, name, err)
+		if err != nil {
 			logger.Error(err, "failed to remote podtransitionrule on pod", "pod", name)
-			return result, err
+			return err
+		}
+		if err := r.removePodCondition(ctx, podTransitionRule, podName, namespace); err != nil {
+			logger.Error(err, "failed to remove podtransitionrule condition on pod", "pod", name)
+			return err
 		}
+		return nil
+	}); err != nil {
+		return result, err
+	}
+
+	// rules are merged with shared rules of ConfigMaps, rules in spec win
+	effective := podTransitionRule.DeepCopy()
+	if err := podtransitionruleutils.MergeRulesFrom(ctx, r.Client, effective); err != nil {
+		logger.Error(err, "failed to merge rules from ConfigMaps")
+		return reconcile.Result{}, err
+	}
+	// rules inherit namespace defaults, fields set in spec always win
+	defaults, err := podtransitionruleutils.GetNamespaceDefaults(ctx, r.Client, podTransitionRule.Namespace)
+	if err != nil {
+		logger.Error(err, "failed to get namespace defaults, use built-in defaults")
+	}
+	podtransitionruleutils.ApplyDefaults(effective, defaults)
+	// rules need detailed status
+	if err := r.loadCompressedStatus(ctx, effective); err != nil {
+		logger.Error(err, "failed to load compressed status")
+		return reconcile.Result{}, err
+	}
+	if err := r.loadRuleStates(ctx, effective); err != nil {
+		logger.Error(err, "failed to load rule states")
+		return reconcile.Result{}, err
 	}
 
-	// process rules
-	shouldRetry, interval, details, ruleStates := r.process(podTransitionRule, targetPods)
+	// process rules, paused podTransitionRule lets all pods pass and keeps rule states
+	var (
+		shouldRetry bool
+		interval    *time.Duration
+		details     map[string]*appsv1alpha1.PodTransitionDetail
+		ruleStates  []*appsv1alpha1.RuleState
+		// executedStages are stages processed in this reconcile
+		executedStages []string
+		// progressing is the reason of a partial reconcile
+		progressing, progressingMessage string
+	)
+	stages := sets.NewString(r.GetStages()...)
+	if podTransitionRule.Spec.Paused {
+		// cached verdicts are dropped, so that rules are fully evaluated once resumed
+		processor.ForgetVerdicts(podTransitionRule.Namespace, podTransitionRule.Name)
+		details, ruleStates = r.pausedDetails(targetPods), effective.Status.RuleStates
+	} else {
+		var skippedStages sets.String
+		shouldRetry, interval, details, ruleStates, skippedStages = r.process(ctx, effective, targetPods)
+		executedStages = r.executedStages(skippedStages)
+		if skippedStages.Len() > 0 {
+			logger.Info("reconcile deadline exceeded, skipped stages are processed on requeue", "stages", skippedStages.List())
+			progressing, progressingMessage = "StagesSkipped", fmt.Sprintf("stages skipped by reconcile deadline: %s", strings.Join(skippedStages.List(), ", "))
+			ruleStates = r.keepSkippedStages(skippedStages, targetPods, effective.Status.Details, details, effective.Status.RuleStates, ruleStates)
+			shouldRetry = true
+		}
+		applyUnknownStageVerdict(r.unknownStageVerdict, stages, targetPods, effective.Status.Details, details)
+	}
+	if r.isKillSwitchActive(ctx) {
+		r.observeOnly(logger, podTransitionRule, details)
+	}
+	r.recordApprovalEvents(podTransitionRule, effective.Status.RuleStates, ruleStates)
+	consumedLabels := consumedApprovalLabels(effective, effective.Status.RuleStates, ruleStates)
+	defer func() {
+		r.logSummary(logger, podTransitionRule.Generation, executedStages, targetPods, details, startTime, result, reconcileErr)
+	}()
 
 	res := reconcile.Result{
 		Requeue: shouldRetry,
 	}
 	if interval != nil {
-		res.RequeueAfter = *interval
+		res.RequeueAfter = jitterInterval(commonutils.ObjectKeyString(podTransitionRule), *interval, r.requeueJitter)
 	}
 
-	// TODO: Sync WebhookStates in Details
+	// webhook calls are kept in RuleStates, reject reasons in details refer to them by trace id or task id
 
 	detailList := make([]*appsv1alpha1.PodTransitionDetail, 0, len(details))
 	keys := make([]string, 0, len(details))
@@ -186,57 +609,316 @@ func (r *PodTransitionRuleReconciler) Reconcile(ctx context.Context, request rec
 	for _, key := range keys {
 		detailList = append(detailList, details[key])
 	}
+	// gc status entries of pods which are not targets any more
+	detailList, ruleStates = gcStatus(targetPods, detailList, ruleStates)
+	// and entries of stages removed from policy
+	detailList, ruleStates = pruneUnknownStages(stages, effective, detailList, ruleStates)
+	if dryRun {
+		r.reportDryRun(logger, podTransitionRule, computeDryRunDiff(podTransitionRule, selectedPodNames, targetPods, effective.Status.Details, detailList, details))
+		return res, nil
+	}
+
+	// pods whose detail annotation is out of date, only part of them are written in this reconcile
+	pendingPods := podsToSyncDetail(podTransitionRule.Name, targetPods, details)
+	syncPods := pendingPods
+	var syncProgress string
+	deadlineExceeded := reconcileDeadlineExceeded(ctx)
+	if evaluateOnly {
+		pendingPods, syncPods = nil, nil
+	} else if deadlineExceeded {
+		syncPods = nil
+	} else if r.maxPodWritesPerReconcile > 0 && len(pendingPods) > r.maxPodWritesPerReconcile {
+		syncPods = pendingPods[:r.maxPodWritesPerReconcile]
+	}
+	if len(syncPods) < len(pendingPods) {
+		syncProgress = fmt.Sprintf("%d/%d", len(targetPods)-len(pendingPods)+len(syncPods), len(targetPods))
+		logger.Info("too many pods to sync detail, the remainder will be synced on requeue", "progress", syncProgress, "deadlineExceeded", deadlineExceeded)
+		if progressing == "" {
+			progressing, progressingMessage = "PartialSync", fmt.Sprintf("pod details are partially synced: %s", syncProgress)
+		}
+		res.Requeue = true
+		res.RequeueAfter = 0
+	}
+
+	decisions := audit.ChangedDecisions(podTransitionRule, effective.Status.Details, detailList)
+	detailList, ruleStates, compressedStatusRef, err := r.compressStatus(ctx, podTransitionRule, len(targetPods), detailList, ruleStates)
+	if err != nil {
+		logger.Error(err, "failed to compress status")
+		return reconcile.Result{}, err
+	}
+	ruleStates, ruleStatesRef, err := r.compactRuleStates(ctx, podTransitionRule, ruleStates)
+	if err != nil {
+		logger.Error(err, "failed to compact rule states")
+		return reconcile.Result{}, err
+	}
+
 	// update podtransitionrule status
 	tm := metav1.NewTime(time.Now())
 	newStatus := &appsv1alpha1.PodTransitionRuleStatus{
-		Targets:            selectedPodNames.List(),
-		ObservedGeneration: podTransitionRule.Generation,
-		Details:            detailList,
-		RuleStates:         ruleStates,
-		UpdateTime:         &tm,
+		Targets:                     selectedPodNames.List(),
+		ObservedGeneration:          podTransitionRule.Generation,
+		SchemaVersion:               statusSchemaVersion,
+		Details:                     detailList,
+		CompressedStatusRef:         compressedStatusRef,
+		RuleStates:                  ruleStates,
+		RuleStatesRef:               ruleStatesRef,
+		SyncProgress:                syncProgress,
+		Conditions:                  readinessConditions(pausedConditions(podTransitionRule, r.podWriteConditions(podTransitionRule)), nil, progressing, progressingMessage),
+		UpdateTime:                  &tm,
+		LastReconcileTime:           &tm,
+		LastReconcileDurationMillis: time.Since(startTime).Milliseconds(),
 	}
 
-	if !equalStatus(newStatus, &podTransitionRule.Status) {
-		podtransitionruleutils.PodTransitionRuleVersionExpectation.ExpectUpdate(commonutils.ObjectKeyString(podTransitionRule), podTransitionRule.ResourceVersion)
-		podTransitionRule.Status = *newStatus
-		if err := r.Client.Status().Update(ctx, podTransitionRule); err != nil {
+	// status of older schema version is only written alone when migrated, others are written on changes
+	migrating := podTransitionRule.Status.SchemaVersion < statusSchemaVersion && statusMigrations.take(commonutils.ObjectKeyString(podTransitionRule))
+	changed := !equalStatus(newStatus, &podTransitionRule.Status)
+	// last reconcile of unchanged status is refreshed once it is stale, update time is kept
+	refreshing := !changed && r.lastReconcileStale(podTransitionRule, tm.Time)
+	if refreshing {
+		newStatus.UpdateTime = podTransitionRule.Status.UpdateTime
+	}
+	if migrating || changed || refreshing {
+		lastConditions := podTransitionRule.Status.Conditions
+		if err := r.updateStatus(ctx, podTransitionRule, newStatus); err != nil {
 			podtransitionruleutils.PodTransitionRuleVersionExpectation.DeleteExpectations(commonutils.ObjectKeyString(podTransitionRule))
 			logger.Error(err, "failed to update podtransitionrule status")
 			return reconcile.Result{}, err
 		}
+		r.recordPauseEvents(podTransitionRule, lastConditions)
+		if evaluateOnly {
+			r.reportEvaluated(podTransitionRule, details)
+		}
+	}
+	if evaluateOnly {
+		return res, r.explainPods(ctx, effective, targetPods, details)
+	}
+	// decisions are sent even if status is not changed, since compressed details are not in status
+	r.auditSink.Send(decisions)
+	r.recordRejectionEvents(podTransitionRule, targetPods, effective.Status.Details, details)
+	// approvals are persisted in status, one-shot approval labels are consumed
+	if err := r.removeApprovalLabels(ctx, podTransitionRule, consumedLabels); err != nil {
+		return res, err
+	}
+	deleted, err := r.syncPodsDetail(ctx, podTransitionRule, syncPods, details)
+	if err != nil {
+		return res, err
+	}
+	if deleted.Len() > 0 {
+		// pods deleted after listed are not listed again on requeue, so that their status entries are pruned
+		logger.Info("pods are deleted during reconcile, prune them on requeue", "pods", deleted.List())
+		res.Requeue = true
+	}
+	if reconcileDeadlineExceeded(ctx) {
+		reconcileDeadlineExceededTotal.Inc()
+		res.Requeue = true
+		res.RequeueAfter = 0
+		return res, nil
+	}
+	if err := r.syncPodsCondition(ctx, podTransitionRule, targetPods, details); err != nil {
+		return res, err
+	}
+	return res, r.explainPods(ctx, effective, targetPods, details)
+}
+
+// updateStatus updates status of podTransitionRule to newStatus. On conflict, the latest podTransitionRule is fetched
+// and newStatus is applied again, as long as spec is not changed since newStatus is computed.
+func (r *PodTransitionRuleReconciler) updateStatus(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, newStatus *appsv1alpha1.PodTransitionRuleStatus) error {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	first := true
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if !first {
+			latest := &appsv1alpha1.PodTransitionRule{}
+			if err := reader.Get(ctx, types.NamespacedName{Namespace: podTransitionRule.Namespace, Name: podTransitionRule.Name}, latest); err != nil {
+				return err
+			}
+			// spec is changed, newStatus is stale and should be recomputed in a new reconcile
+			if latest.Generation != newStatus.ObservedGeneration {
+				return fmt.Errorf("podtransitionrule %s/%s generation changed from %d to %d during reconcile",
+					latest.Namespace, latest.Name, newStatus.ObservedGeneration, latest.Generation)
+			}
+			latest.DeepCopyInto(podTransitionRule)
+			if equalStatus(newStatus, &podTransitionRule.Status) {
+				return nil
+			}
+		}
+		first = false
+		podtransitionruleutils.PodTransitionRuleVersionExpectation.ExpectUpdate(commonutils.ObjectKeyString(podTransitionRule), podTransitionRule.ResourceVersion)
+		podTransitionRule.Status = *newStatus
+		return r.Client.Status().Update(ctx, podTransitionRule)
+	})
+}
+
+// lastReconcileStale tells whether last reconcile in status of podTransitionRule is older than reconcileStatusInterval
+func (r *PodTransitionRuleReconciler) lastReconcileStale(podTransitionRule *appsv1alpha1.PodTransitionRule, now time.Time) bool {
+	if r.reconcileStatusInterval <= 0 {
+		return false
 	}
+	last := podTransitionRule.Status.LastReconcileTime
+	return last == nil || now.Sub(last.Time) >= r.reconcileStatusInterval
+}
+
+// logSummary logs the outcome of a reconcile in a single line at info level, so that it is visible by default
+func (r *PodTransitionRuleReconciler) logSummary(
+	logger logr.Logger,
+	generation int64,
+	stages []string,
+	targetPods map[string]*corev1.Pod,
+	details map[string]*appsv1alpha1.PodTransitionDetail,
+	startTime time.Time,
+	result reconcile.Result,
+	err error,
+) {
+	// blocked pods are not passed yet, of which rejected pods are rejected by rules rather than pending on them
+	passed, blocked, rejected := 0, 0, 0
+	for _, detail := range details {
+		if detail.Passed {
+			passed++
+			continue
+		}
+		blocked++
+		if len(detail.RejectInfo) > 0 {
+			rejected++
+		}
+	}
+	logger.Info("reconcile summary",
+		"generation", generation,
+		"targets", len(targetPods),
+		"passed", passed,
+		"blocked", blocked,
+		"rejected", rejected,
+		"stages", stages,
+		"duration", time.Since(startTime).String(),
+		"requeued", result.Requeue || result.RequeueAfter > 0,
+		"requeueInterval", result.RequeueAfter.String(),
+		"error", err != nil,
+	)
+}
+
+// podsToSyncDetail returns pods whose detail annotation differs from details, sorted by name
+func podsToSyncDetail(podTransitionRuleName string, targetPods map[string]*corev1.Pod, details map[string]*appsv1alpha1.PodTransitionDetail) []*corev1.Pod {
 	pods := make([]*corev1.Pod, 0, len(targetPods))
-	for _, pod := range targetPods {
+	for name, pod := range targetPods {
+		key, val := podDetailAnno(podTransitionRuleName, details[name])
+		if pod.Annotations != nil && pod.Annotations[key] == val {
+			continue
+		}
 		pods = append(pods, pod)
 	}
-	return res, r.syncPodsDetail(ctx, podTransitionRule.Name, pods, details)
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods
 }
 
-func (r *PodTransitionRuleReconciler) syncPodsDetail(ctx context.Context, podTransitionRuleName string, pods []*corev1.Pod, details map[string]*appsv1alpha1.PodTransitionDetail) error {
+// syncPodsDetail writes details to annotations of pods, and returns target keys of pods which are NotFound
+func (r *PodTransitionRuleReconciler) syncPodsDetail(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, pods []*corev1.Pod, details map[string]*appsv1alpha1.PodTransitionDetail) (sets.String, error) {
+	mu := sync.Mutex{}
+	deleted := sets.NewString()
 	_, err := controllerutils.SlowStartBatch(len(pods), 1, false, func(i int, _ error) error {
-		return r.updatePodDetail(ctx, pods[i], podTransitionRuleName, details[pods[i].Name])
+		key := podtransitionruleutils.TargetKey(podTransitionRule, pods[i])
+		err := r.updatePodDetail(ctx, pods[i], podTransitionRule.Name, details[key])
+		if errors.IsNotFound(err) {
+			mu.Lock()
+			deleted.Insert(key)
+			mu.Unlock()
+			err = nil
+		}
+		r.observePodWrite(podTransitionRule, pods[i].Name, err)
+		return err
 	})
-	return err
+	return deleted, err
 }
 
 func (r *PodTransitionRuleReconciler) updatePodDetail(ctx context.Context, pod *corev1.Pod, podTransitionRuleName string, detail *appsv1alpha1.PodTransitionDetail) error {
-	detailAnno := appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + podTransitionRuleName
-	var newDetail string
-	if detail != nil {
-		newDetail = utils.DumpJSON(&appsv1alpha1.PodTransitionDetail{Stage: detail.Stage, Passed: detail.Passed})
-	} else {
-		newDetail = utils.DumpJSON(&appsv1alpha1.PodTransitionDetail{Stage: "Unknown", Passed: true})
-	}
+	detailAnno, newDetail := podDetailAnno(podTransitionRuleName, detail)
 	if pod.Annotations != nil && pod.Annotations[detailAnno] == newDetail {
 		return nil
 	}
 	patch := client.RawPatch(types.MergePatchType, controllerutils.GetLabelAnnoPatchBytes(nil, nil, nil, map[string]string{detailAnno: newDetail}))
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+	defer r.lockPod(pod.Namespace, pod.Name)()
+	if err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		return r.Client.Patch(ctx, pod, patch)
+	}); err != nil {
+		return err
+	}
+	PodEventQueues.Broadcast(types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+	return nil
+}
+
+// syncPodsCondition sets conditions on target pods if ManagePodCondition is enabled, otherwise removes them.
+// The readiness gate condition is set if configured.
+func (r *PodTransitionRuleReconciler) syncPodsCondition(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, targetPods map[string]*corev1.Pod, details map[string]*appsv1alpha1.PodTransitionDetail) error {
+	var pods, newPods []*corev1.Pod
+	for _, name := range sets.StringKeySet(targetPods).List() {
+		newPod := targetPods[name].DeepCopy()
+		var changed bool
+		if podTransitionRule.Spec.ManagePodCondition {
+			changed = podtransitionruleutils.SetPodCondition(newPod, podtransitionruleutils.NewPodCondition(podTransitionRule.Name, details[name]))
+		} else {
+			changed = podtransitionruleutils.RemovePodCondition(newPod, podTransitionRule.Name)
+		}
+		if gate := podTransitionRule.Spec.ReadinessGate; gate != nil {
+			if podtransitionruleutils.SetPodCondition(newPod, podtransitionruleutils.NewReadinessGateCondition(gate, details[name])) {
+				changed = true
+			}
+		}
+		if changed {
+			pods = append(pods, targetPods[name])
+			newPods = append(newPods, newPod)
+		}
+	}
+	_, err := controllerutils.SlowStartBatch(len(pods), 1, false, func(i int, _ error) error {
+		// strategic merge patch merges conditions by type, and never conflicts with others
+		return client.IgnoreNotFound(r.Client.Status().Patch(ctx, newPods[i], client.StrategicMergeFrom(pods[i])))
 	})
+	return err
+}
+
+// removePodCondition removes the condition of podTransitionRule from pod, and releases its readiness gate condition
+func (r *PodTransitionRuleReconciler) removePodCondition(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, name, namespace string) error {
+	pod := &corev1.Pod{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	newPod := pod.DeepCopy()
+	changed := podtransitionruleutils.RemovePodCondition(newPod, podTransitionRule.Name)
+	if gate := podTransitionRule.Spec.ReadinessGate; gate != nil {
+		if podtransitionruleutils.SetPodCondition(newPod, podtransitionruleutils.ReleasedReadinessGateCondition(gate)) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Client.Status().Patch(ctx, newPod, client.StrategicMergeFrom(pod)))
+}
+
+func podDetailAnno(podTransitionRuleName string, detail *appsv1alpha1.PodTransitionDetail) (string, string) {
+	detailAnno := appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + podTransitionRuleName
+	if detail != nil {
+		return detailAnno, utils.DumpJSON(&appsv1alpha1.PodTransitionDetail{Stage: detail.Stage, Passed: detail.Passed})
+	}
+	return detailAnno, utils.DumpJSON(&appsv1alpha1.PodTransitionDetail{Stage: UnknownStage, Passed: true})
+}
+
+// executedStages returns stages of policy in processing order, except stages skipped by reconcile deadline
+func (r *PodTransitionRuleReconciler) executedStages(skippedStages sets.String) []string {
+	var stages []string
+	for _, group := range register.GetStageGroups(r.Policy) {
+		for _, stage := range group.Stages {
+			if !skippedStages.Has(stage) {
+				stages = append(stages, stage)
+			}
+		}
+	}
+	return stages
 }
 
 func (r *PodTransitionRuleReconciler) process(
+	ctx context.Context,
 	rs *appsv1alpha1.PodTransitionRule,
 	pods map[string]*corev1.Pod,
 ) (
@@ -244,91 +926,320 @@ func (r *PodTransitionRuleReconciler) process(
 	interval *time.Duration,
 	details map[string]*appsv1alpha1.PodTransitionDetail,
 	ruleStates []*appsv1alpha1.RuleState,
+	skippedStages sets.String,
 ) {
-	stages := r.GetStages()
-	wg := sync.WaitGroup{}
-	wg.Add(len(stages))
 	mu := sync.RWMutex{}
+	skippedStages = sets.NewString()
 	details = map[string]*appsv1alpha1.PodTransitionDetail{}
-	for _, stage := range stages {
-		currentStage := stage
-		go func() {
-			defer wg.Done()
-			res := processor.NewRuleProcessor(r.Client, currentStage, rs, r.Logger).Process(pods)
-			mu.Lock()
-			defer mu.Unlock()
-			if res.Interval != nil {
-				if interval == nil || *interval > *res.Interval {
-					interval = res.Interval
-				}
-			}
-			if res.RuleStates != nil {
-				ruleStates = append(ruleStates, res.RuleStates...)
+	lastDetails := map[string]*appsv1alpha1.PodTransitionDetail{}
+	for _, detail := range rs.Status.Details {
+		if detail != nil {
+			lastDetails[detail.Name] = detail
+		}
+	}
+	processStage := func(stage string) {
+		start := time.Now()
+		stageCtx, span := tracing.Start(ctx, "PodTransitionRule.Stage", tracing.String("stage", stage))
+		defer span.End()
+		var res *processor.ProcessResult
+		if r.pprofLabels {
+			pprof.Do(stageCtx, pprof.Labels("stage", stage), func(stageCtx context.Context) {
+				res = processor.NewRuleProcessor(r.Client, stage, rs, r.Logger).WithContext(stageCtx).WithSecretReader(r.APIReader).Process(pods)
+			})
+		} else {
+			res = processor.NewRuleProcessor(r.Client, stage, rs, r.Logger).WithContext(stageCtx).WithSecretReader(r.APIReader).Process(pods)
+		}
+		stageProcessDuration.WithLabelValues(stage, rs.Namespace).Observe(time.Since(start).Seconds())
+		span.SetAttributes(tracing.Int("passed", len(res.PassRules)-len(res.Rejected)), tracing.Int("rejected", len(res.Rejected)))
+		mu.Lock()
+		defer mu.Unlock()
+		if res.Interval != nil {
+			if interval == nil || *interval > *res.Interval {
+				interval = res.Interval
 			}
-			if res.Retry {
-				shouldRetry = true
+		}
+		if res.RuleStates != nil {
+			ruleStates = append(ruleStates, res.RuleStates...)
+		}
+		if res.Retry {
+			shouldRetry = true
+		}
+		updateDetail(details, res, stage, lastDetails, r.rejectHistoryLimit)
+	}
+	// stage groups are processed in order, stages in a group are processed in parallel unless serial
+	for _, group := range register.GetStageGroups(r.Policy) {
+		// stage groups not started before deadline are skipped
+		if reconcileDeadlineExceeded(ctx) {
+			skippedStages.Insert(group.Stages...)
+			continue
+		}
+		if group.Serial {
+			for _, stage := range group.Stages {
+				processStage(stage)
 			}
-			updateDetail(details, res, currentStage)
-		}()
+			continue
+		}
+		wg := sync.WaitGroup{}
+		wg.Add(len(group.Stages))
+		for _, stage := range group.Stages {
+			currentStage := stage
+			go func() {
+				defer wg.Done()
+				processStage(currentStage)
+			}()
+		}
+		wg.Wait()
 	}
-	wg.Wait()
-	return shouldRetry, interval, details, ruleStates
+	// stages in parallel append rule states in random order, sort them so that equal status is not updated again
+	sort.SliceStable(ruleStates, func(i, j int) bool {
+		return ruleStates[i].Name < ruleStates[j].Name
+	})
+	return shouldRetry, interval, details, ruleStates, skippedStages
 }
 
 func (r *PodTransitionRuleReconciler) cleanUpPodTransitionRulePods(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule) error {
-	for _, name := range podTransitionRule.Status.Targets {
-		if _, err := r.updatePodTransitionRuleOnPod(ctx, podTransitionRule.Name, name, podTransitionRule.Namespace, podtransitionruleutils.MoveAllPodTransitionRuleInfo); err != nil && !errors.IsNotFound(err) {
+	targets := podTransitionRule.Status.Targets
+	return r.writePods(len(targets), func(i int) error {
+		name := targets[i]
+		namespace, podName := podtransitionruleutils.SplitTargetKey(podTransitionRule, name)
+		_, err := r.updatePodTransitionRuleOnPod(ctx, podTransitionRule.Name, podName, namespace, podtransitionruleutils.MoveAllPodTransitionRuleInfo)
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		r.observePodWrite(podTransitionRule, name, err)
+		if err != nil {
 			return fmt.Errorf("fail to remove PodTransitionRule %s on pod %s: %v", commonutils.ObjectKeyString(podTransitionRule), name, err)
 		}
+		if err := r.removePodCondition(ctx, podTransitionRule, podName, namespace); err != nil {
+			return fmt.Errorf("fail to remove PodTransitionRule %s condition on pod %s: %v", commonutils.ObjectKeyString(podTransitionRule), name, err)
+		}
+		return nil
+	})
+}
+
+// deletionGracePeriodExpired returns true if the deletion grace period of podTransitionRule is set and expired at now
+func deletionGracePeriodExpired(podTransitionRule *appsv1alpha1.PodTransitionRule, now time.Time) bool {
+	protection := podTransitionRule.Spec.DeletionProtection
+	if protection == nil || protection.GracePeriodSeconds == nil || podTransitionRule.DeletionTimestamp == nil {
+		return false
 	}
-	return nil
+	gracePeriod := time.Duration(*protection.GracePeriodSeconds) * time.Second
+	return !now.Before(podTransitionRule.DeletionTimestamp.Add(gracePeriod))
+}
+
+// cleanUpFinalizer returns the finalizer this reconciler adds on podTransitionRules
+func (r *PodTransitionRuleReconciler) cleanUpFinalizer() string {
+	if r.finalizer == "" {
+		return appsv1alpha1.ProtectFinalizer
+	}
+	return r.finalizer
+}
+
+// removeFinalizer removes the finalizer of this reconciler only, finalizers of other controllers on the same
+// podTransitionRule are left to be removed by their owners.
+func (r *PodTransitionRuleReconciler) removeFinalizer(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule) error {
+	if !controllerutil.ContainsFinalizer(podTransitionRule, r.cleanUpFinalizer()) {
+		return nil
+	}
+	return controllerutils.RemoveFinalizer(ctx, r.Client, podTransitionRule, r.cleanUpFinalizer())
+}
+
+// escalateBlockedDeletion records the time deletion is first blocked, and emits warning events once the blocked
+// duration reaches escalation thresholds. It returns the error blocking deletion.
+func (r *PodTransitionRuleReconciler) escalateBlockedDeletion(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, blockErr error) error {
+	if podTransitionRule.Status.DeletionBlockedSince == nil {
+		now := metav1.Now()
+		podTransitionRule.Status.DeletionBlockedSince = &now
+		if err := r.Client.Status().Update(ctx, podTransitionRule); err != nil {
+			r.Logger.Error(err, "failed to update deletion blocked time", "podTransitionRule", commonutils.ObjectKeyString(podTransitionRule))
+		}
+	}
+	blocked := time.Since(podTransitionRule.Status.DeletionBlockedSince.Time)
+	level := 0
+	for _, threshold := range r.escalationThresholds {
+		if blocked >= threshold {
+			level++
+		}
+	}
+	if level > 0 && r.Recorder != nil {
+		reason := "DeletionBlocked"
+		if level == len(r.escalationThresholds) {
+			reason = "DeletionBlockedCritical"
+		}
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeWarning, reason, "deletion is blocked for %s, escalation level %d/%d: %v",
+			blocked.Truncate(time.Second), level, len(r.escalationThresholds), blockErr)
+	}
+	return blockErr
 }
 
+// updatePodTransitionRuleOnPod writes pod mutated by fn, it returns NotFound error if pod is deleted
 func (r *PodTransitionRuleReconciler) updatePodTransitionRuleOnPod(ctx context.Context, podTransitionRule, name, namespace string, fn func(*corev1.Pod, string) bool) (*corev1.Pod, error) {
+	defer r.lockPod(namespace, name)()
 	pod := &corev1.Pod{}
 	return pod, retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil {
-			if errors.IsNotFound(err) {
-				return nil
-			}
 			return err
 		}
+		original := pod.DeepCopy()
 		if fn(pod, podTransitionRule) {
-			return r.Client.Update(ctx, pod)
+			return r.writePod(ctx, original, pod)
 		}
 		return nil
 	})
 }
 
-func updateDetail(details map[string]*appsv1alpha1.PodTransitionDetail, passRules *processor.ProcessResult, stage string) {
+// updateDetail merges results of stage into details. It is idempotent per pod and rule, so that results of the
+// same rule merged more than once, e.g. by stages returning overlapping pods, are only recorded once. Reject history
+// is inherited from lastDetails, and rejections not in lastDetails are appended to it, at most historyLimit
+// rejections are kept.
+func updateDetail(
+	details map[string]*appsv1alpha1.PodTransitionDetail,
+	passRules *processor.ProcessResult,
+	stage string,
+	lastDetails map[string]*appsv1alpha1.PodTransitionDetail,
+	historyLimit int,
+) {
 	for po, rules := range passRules.PassRules {
-		var rejectInfo *appsv1alpha1.RejectInfo
-		if rej, ok := passRules.Rejected[po]; ok {
-			rejectInfo = &appsv1alpha1.RejectInfo{
-				RuleName: rej.RuleName,
-				Reason:   rej.Reason,
-			}
-		}
 		detail, ok := details[po]
 		if !ok {
 			detail = &appsv1alpha1.PodTransitionDetail{
 				Name:  po,
 				Stage: stage,
 			}
+			if last := lastDetails[po]; last != nil && historyLimit > 0 {
+				detail.RejectHistory = append([]appsv1alpha1.RejectEvent(nil), last.RejectHistory...)
+			}
+		}
+		detail.PassedRules = appendMissing(detail.PassedRules, rules.List()...)
+		for _, delay := range passRules.Delays[po] {
+			if !hasDelayInfo(detail.DelayInfo, delay.RuleName) {
+				detail.DelayInfo = append(detail.DelayInfo, delay)
+			}
+		}
+		detail.PendingRules = appendMissing(detail.PendingRules, passRules.Pending[po].List()...)
+		if rej, ok := passRules.Rejected[po]; ok && !hasRejectInfo(detail.RejectInfo, rej.RuleName) {
+			detail.RejectInfo = append(detail.RejectInfo, appsv1alpha1.RejectInfo{
+				RuleName: rej.RuleName,
+				Reason:   rej.Reason,
+			})
+			ruleRejectionsTotal.WithLabelValues(rej.RuleName).Inc()
+			if last := lastDetails[po]; historyLimit > 0 && (last == nil || !hasRejection(last.RejectInfo, rej.RuleName, rej.Reason)) {
+				detail.RejectHistory = append(detail.RejectHistory, appsv1alpha1.RejectEvent{
+					RuleName: rej.RuleName,
+					Reason:   rej.Reason,
+					Time:     metav1.Now(),
+				})
+				if len(detail.RejectHistory) > historyLimit {
+					detail.RejectHistory = detail.RejectHistory[len(detail.RejectHistory)-historyLimit:]
+				}
+			}
+			if !passRules.Pending[po].Has(rej.RuleName) {
+				detail.RejectedRules = appendMissing(detail.RejectedRules, rej.RuleName)
+			}
 		}
-		detail.PassedRules = append(detail.PassedRules, rules.List()...)
-		if rejectInfo != nil {
-			detail.RejectInfo = append(detail.RejectInfo, *rejectInfo)
+		for _, rej := range passRules.Audited[po] {
+			if !hasRejectInfo(detail.RejectInfo, rej.RuleName) {
+				detail.RejectInfo = append(detail.RejectInfo, appsv1alpha1.RejectInfo{
+					RuleName: rej.RuleName,
+					Reason:   rej.Reason,
+					Audited:  true,
+				})
+			}
 		}
-		detail.Passed = detail.RejectInfo == nil || len(detail.RejectInfo) == 0
+		detail.Passed = !hasBlockingRejectInfo(detail.RejectInfo)
 		details[po] = detail
 	}
 }
 
+// appendMissing appends items not in list to list
+func appendMissing(list []string, items ...string) []string {
+	for _, item := range items {
+		if !sets.NewString(list...).Has(item) {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func hasRejectInfo(infos []appsv1alpha1.RejectInfo, ruleName string) bool {
+	for _, info := range infos {
+		if info.RuleName == ruleName {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBlockingRejectInfo returns true if any of infos is not audited
+func hasBlockingRejectInfo(infos []appsv1alpha1.RejectInfo) bool {
+	for _, info := range infos {
+		if !info.Audited {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRejection returns true if infos contain the rejection by ruleName with reason
+func hasRejection(infos []appsv1alpha1.RejectInfo, ruleName, reason string) bool {
+	for _, info := range infos {
+		if info.RuleName == ruleName && info.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDelayInfo(infos []appsv1alpha1.DelayInfo, ruleName string) bool {
+	for _, info := range infos {
+		if info.RuleName == ruleName {
+			return true
+		}
+	}
+	return false
+}
+
+// gcStatus prunes Details and RuleStates entries which refer to pods not in targets,
+// so that status does not accumulate entries of deleted or unselected pods.
+func gcStatus(targets map[string]*corev1.Pod, details []*appsv1alpha1.PodTransitionDetail, ruleStates []*appsv1alpha1.RuleState) ([]*appsv1alpha1.PodTransitionDetail, []*appsv1alpha1.RuleState) {
+	prunedDetails := make([]*appsv1alpha1.PodTransitionDetail, 0, len(details))
+	for _, detail := range details {
+		if _, ok := targets[detail.Name]; ok {
+			prunedDetails = append(prunedDetails, detail)
+		}
+	}
+	for _, state := range ruleStates {
+		if state == nil || state.WebhookStatus == nil {
+			continue
+		}
+		// tasks with no processing pods left will be moved into history by the webhook rule
+		for i := range state.WebhookStatus.TaskStates {
+			task := &state.WebhookStatus.TaskStates[i]
+			task.Processing = filterTargets(targets, task.Processing)
+			task.Approved = filterTargets(targets, task.Approved)
+		}
+	}
+	return prunedDetails, ruleStates
+}
+
+func filterTargets(targets map[string]*corev1.Pod, names []string) []string {
+	var res []string
+	for _, name := range names {
+		if _, ok := targets[name]; ok {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
 func equalStatus(updated *appsv1alpha1.PodTransitionRuleStatus, current *appsv1alpha1.PodTransitionRuleStatus) bool {
 	deepEqual := equality.Semantic.DeepEqual(updated.Targets, current.Targets) &&
 		equality.Semantic.DeepEqual(updated.Details, current.Details) &&
 		equality.Semantic.DeepEqual(updated.RuleStates, current.RuleStates) &&
+		equality.Semantic.DeepEqual(updated.RuleStatesRef, current.RuleStatesRef) &&
+		equality.Semantic.DeepEqual(updated.CompressedStatusRef, current.CompressedStatusRef) &&
+		equalConditions(updated.Conditions, current.Conditions) &&
+		updated.SyncProgress == current.SyncProgress &&
 		updated.ObservedGeneration == current.ObservedGeneration
 	if !deepEqual {
 		return utils.DumpJSON(updated) == utils.DumpJSON(current)
@@ -18,10 +18,12 @@ package podtransitionrule
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/checker"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
 )
 
@@ -48,7 +50,27 @@ func AddUnAvailableFunc(f func(pod *corev1.Pod) (bool, *int64)) {
 	register.UnAvailableFuncList = append(register.UnAvailableFuncList, f)
 }
 
-func newPodTransitionRuleManager() ManagerInterface {
+// RegisterResource registers a resource whose state is checked by ResourceState rules, it must be called before
+// setting up the controller. The controller watches registered resources, so it must be granted to get, list and watch them.
+func RegisterResource(gvk schema.GroupVersionKind) {
+	if r, ok := register.DefaultRegister().(register.ResourceRegister); ok {
+		r.RegisterResource(gvk)
+	}
+}
+
+// RegisterRuler registers factory of custom rules with ruleType, rules whose custom.type is ruleType are checked by
+// rulers created by factory. Pods are rejected by custom rules of unregistered types.
+func RegisterRuler(ruleType string, factory rules.RulerFactory) {
+	rules.RegisterCustomRuler(ruleType, factory)
+}
+
+// SetOptions sets options of the controller set up by PodTransitionRuleManager, it must be called before setting up
+// the controller. Options not set are defaults of NewOptions.
+func SetOptions(opts ...Option) {
+	defaultManager.options = opts
+}
+
+func newPodTransitionRuleManager() *rsManager {
 	return &rsManager{
 		Register: register.DefaultRegister(),
 		Checker:  checker.NewCheck(),
@@ -59,9 +81,10 @@ type rsManager struct {
 	register.Register
 	checker.Checker
 	controller controller.Controller
+	options    []Option
 }
 
 func (m *rsManager) SetupPodTransitionRuleController(mgr manager.Manager) (err error) {
-	m.controller, err = addToMgr(mgr, newReconciler(mgr))
+	m.controller, err = addToMgr(mgr, newReconciler(mgr, m.options...), m.options...)
 	return err
 }
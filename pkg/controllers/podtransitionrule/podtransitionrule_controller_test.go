@@ -17,27 +17,52 @@ limitations under the License.
 package podtransitionrule
 
 import (
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/google/uuid"
 	"github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 	"kusionstack.io/operating/pkg/utils/inject"
+	"kusionstack.io/operating/pkg/utils/mixin"
 )
 
 func TestPodTransitionRule(t *testing.T) {
@@ -167,28 +192,22 @@ func TestPodTransitionRule(t *testing.T) {
 	}, 5*time.Second, 1*time.Second).Should(gomega.HaveOccurred())
 }
 
-func TestWebhookRule(t *testing.T) {
+func TestPodTransitionRuleStatusGC(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
-	stop, finish := RunHttpServer(handleHttpAlwaysSuccess, "8899")
-	defer func() {
-		stop <- struct{}{}
-		<-finish
-	}()
-	<-time.After(1 * time.Second)
 	var pods []*corev1.Pod
 	pods = append(pods,
 		genDefaultPod("default", "pod-test-1"),
 		genDefaultPod("default", "pod-test-2"),
-		genDefaultPod("default", "pod-test-3"),
-		genDefaultPod("default", "pod-test-4"))
+		genDefaultPod("default", "pod-test-3"))
 	for _, po := range pods {
+		po.Labels[StageLabel] = PreTrafficOffStage
 		g.Expect(c.Create(ctx, po)).NotTo(gomega.HaveOccurred())
 	}
+	istr := intstr.FromString("50%")
 	stage := PreTrafficOffStage
-	policy := appsv1alpha1.Fail
 	rs := &appsv1alpha1.PodTransitionRule{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "podtransitionrule-webhook",
+			Name:      "podtransitionrule-gc",
 			Namespace: "default",
 		},
 		Spec: appsv1alpha1.PodTransitionRuleSpec{
@@ -200,33 +219,10 @@ func TestWebhookRule(t *testing.T) {
 			Rules: []appsv1alpha1.TransitionRule{
 				{
 					Stage: &stage,
-					Name:  "webhook",
+					Name:  "serviceAvailable",
 					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
-						Webhook: &appsv1alpha1.TransitionRuleWebhook{
-							ClientConfig: appsv1alpha1.ClientConfigBeta1{
-								URL: "http://127.0.0.1:8899",
-							},
-							FailurePolicy: &policy,
-							Parameters: []appsv1alpha1.Parameter{
-								{
-									Key: "podIP",
-									ValueFrom: &appsv1alpha1.ParameterSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											APIVersion: "v1",
-											FieldPath:  "status.podIP",
-										},
-									},
-								},
-								{
-									Key: "context",
-									ValueFrom: &appsv1alpha1.ParameterSource{
-										FieldRef: &corev1.ObjectFieldSelector{
-											APIVersion: "v1",
-											FieldPath:  "metadata.annotations['test.io/context']",
-										},
-									},
-								},
-							},
+						AvailablePolicy: &appsv1alpha1.AvailableRule{
+							MaxUnavailableValue: &istr,
 						},
 					},
 				},
@@ -242,84 +238,199 @@ func TestWebhookRule(t *testing.T) {
 		}
 	}()
 	g.Eventually(func() int {
-		g.Expect(c.List(ctx, podList, &client.ListOptions{
-			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
-		})).ShouldNot(gomega.HaveOccurred())
-		return len(podList.Items)
-	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
-	for i := range podList.Items {
-		if podList.Items[i].Name == "pod-test-1" {
-			g.Expect(setPodOnStage(&podList.Items[i], PreTrafficOffStage)).NotTo(gomega.HaveOccurred())
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-gc"}, rs)).Should(gomega.BeNil())
+		return len(rs.Status.Details)
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(3))
+
+	po := &corev1.Pod{}
+	g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(c.Delete(ctx, po)).NotTo(gomega.HaveOccurred())
+
+	g.Eventually(func() []string {
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-gc"}, rs)).Should(gomega.BeNil())
+		var names []string
+		for _, detail := range rs.Status.Details {
+			names = append(names, detail.Name)
 		}
+		return names
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal([]string{"pod-test-2", "pod-test-3"}))
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-2", "pod-test-3"}))
+
+	g.Expect(c.Delete(ctx, rs)).NotTo(gomega.HaveOccurred())
+	g.Eventually(func() error {
+		return c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-gc"}, rs)
+	}, 5*time.Second, 1*time.Second).Should(gomega.HaveOccurred())
+}
+
+// deletedAfterListClient deletes pods right after they are listed
+type deletedAfterListClient struct {
+	client.Client
+	deleted sets.String
+}
+
+func (c *deletedAfterListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
 	}
-	g.Eventually(func() int {
-		g.Expect(c.List(ctx, podList, &client.ListOptions{
-			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
-		})).NotTo(gomega.HaveOccurred())
-		// LifeCycle 2. 2 pod passed
-		passedCount := 0
+	if podList, ok := list.(*corev1.PodList); ok {
 		for i := range podList.Items {
-			state, err := PodTransitionRuleManager().GetState(ctx, c, &podList.Items[i])
-			g.Expect(err).NotTo(gomega.HaveOccurred())
-			if state.InStageAndPassed() && state.Stage == PreTrafficOffStage {
-				passedCount++
-				g.Expect(podList.Items[i].Name).Should(gomega.Equal("pod-test-1"))
+			if c.deleted.Has(podList.Items[i].Name) {
+				if err := c.Client.Delete(ctx, podList.Items[i].DeepCopy()); err != nil {
+					return err
+				}
 			}
 		}
-		return passedCount
-	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(1))
-
-	g.Eventually(func() int {
-		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-webhook"}, rs)).Should(gomega.BeNil())
-		return len(rs.Status.Details)
-	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(1))
+	}
+	return nil
+}
 
-	// LifeCycle 1. set all pod on Stage
-	for i := range podList.Items {
-		g.Expect(setPodOnStage(&podList.Items[i], PreTrafficOffStage)).NotTo(gomega.HaveOccurred())
+func TestPodDeletedDuringReconcile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	istr := intstr.FromString("50%")
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-deleted",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "serviceAvailable",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						AvailablePolicy: &appsv1alpha1.AvailableRule{
+							MaxUnavailableValue: &istr,
+						},
+					},
+				},
+			},
+		},
+		Status: appsv1alpha1.PodTransitionRuleStatus{
+			Targets: []string{"pod-test-1", "pod-test-2"},
+			Details: []*appsv1alpha1.PodTransitionDetail{
+				{Name: "pod-test-1", Stage: PreTrafficOffStage, Passed: true},
+				{Name: "pod-test-2", Stage: PreTrafficOffStage, Passed: true},
+			},
+		},
+	}
+	objs := []client.Object{rs}
+	for _, name := range []string{"pod-test-1", "pod-test-2"} {
+		po := genDefaultPod("default", name)
+		po.Labels[StageLabel] = PreTrafficOffStage
+		objs = append(objs, po)
 	}
+	fc := &deletedAfterListClient{
+		Client:  fake.NewClientBuilder().WithObjects(objs...).Build(),
+		deleted: sets.NewString("pod-test-1"),
+	}
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-deleted"}
+	res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(res.Requeue).Should(gomega.BeTrue())
 
-	g.Eventually(func() int {
-		g.Expect(c.List(ctx, podList, &client.ListOptions{
-			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
-		})).NotTo(gomega.HaveOccurred())
-		passedCount := 0
-		for i := range podList.Items {
-			state, err := PodTransitionRuleManager().GetState(ctx, c, &podList.Items[i])
-			g.Expect(err).NotTo(gomega.HaveOccurred())
-			if state.InStageAndPassed() && state.Stage == PreTrafficOffStage {
-				passedCount++
+	// the deleted pod is not listed on requeue, and its status entries are pruned
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-2"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.Details[0].Name).Should(gomega.Equal("pod-test-2"))
+}
+
+func TestManagePodCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-condition",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+			ManagePodCondition: true,
+		},
+	}
+	passed := genDefaultPod("default", "pod-test-1")
+	passed.Labels[StageLabel] = PreTrafficOffStage
+	passed.Labels["ready"] = "true"
+	blocked := genDefaultPod("default", "pod-test-2")
+	blocked.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, passed, blocked).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-condition"}
+	getCondition := func(name string) *corev1.PodCondition {
+		po := &corev1.Pod{}
+		g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, po)).NotTo(gomega.HaveOccurred())
+		for i := range po.Status.Conditions {
+			if po.Status.Conditions[i].Type == podtransitionruleutils.PodConditionType(rs.Name) {
+				return &po.Status.Conditions[i]
 			}
 		}
-		return passedCount
-	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
+		return nil
+	}
 
-	g.Eventually(func() int {
-		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-webhook"}, rs)).Should(gomega.BeNil())
-		return len(rs.Status.Details)
-	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	condition := getCondition("pod-test-1")
+	g.Expect(condition).NotTo(gomega.BeNil())
+	g.Expect(condition.Status).Should(gomega.Equal(corev1.ConditionTrue))
+	g.Expect(condition.Reason).Should(gomega.Equal(podtransitionruleutils.PodConditionReasonPassed))
+	condition = getCondition("pod-test-2")
+	g.Expect(condition).NotTo(gomega.BeNil())
+	g.Expect(condition.Status).Should(gomega.Equal(corev1.ConditionFalse))
+	g.Expect(condition.Reason).Should(gomega.Equal(podtransitionruleutils.PodConditionReasonBlocked))
+	g.Expect(condition.LastTransitionTime.IsZero()).Should(gomega.BeFalse())
 
-	g.Expect(c.Delete(ctx, rs)).NotTo(gomega.HaveOccurred())
-	g.Eventually(func() error {
-		err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-webhook"}, rs)
-		return err
-	}, 5*time.Second, 1*time.Second).Should(gomega.HaveOccurred())
+	// conditions are removed once disabled
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	rs.Spec.ManagePodCondition = false
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getCondition("pod-test-1")).Should(gomega.BeNil())
+	g.Expect(getCondition("pod-test-2")).Should(gomega.BeNil())
 }
 
-func TestWebhookPoll(t *testing.T) {
+func TestReadinessGate(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
-	stop, finish, taskStartTime, taskFinishTime := RunPollingServer()
-	defer func() {
-		stop <- struct{}{}
-		<-finish
-	}()
 	stage := PreTrafficOffStage
-	policy := appsv1alpha1.Fail
-	interval := int64(3)
-	timeout := int64(60)
+	gateType := corev1.PodConditionType("example.com/traffic-ready")
 	rs := &appsv1alpha1.PodTransitionRule{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "podtransitionrule-poll-test",
+			Name:      "podtransitionrule-readiness-gate",
 			Namespace: "default",
 		},
 		Spec: appsv1alpha1.PodTransitionRuleSpec{
@@ -331,315 +442,3899 @@ func TestWebhookPoll(t *testing.T) {
 			Rules: []appsv1alpha1.TransitionRule{
 				{
 					Stage: &stage,
-					Name:  "webhook",
+					Name:  "labelCheck",
 					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
-						Webhook: &appsv1alpha1.TransitionRuleWebhook{
-							ClientConfig: appsv1alpha1.ClientConfigBeta1{
-								URL: "http://127.0.0.1:8999/first",
-								Poll: &appsv1alpha1.Poll{
-									URL:             "http://127.0.0.1:8999/poll",
-									IntervalSeconds: &interval,
-									TimeoutSeconds:  &timeout,
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
 								},
 							},
-							FailurePolicy: &policy,
 						},
 					},
 				},
 			},
+			ReadinessGate: &appsv1alpha1.PodTransitionRuleReadinessGate{ConditionType: gateType},
 		},
 	}
-	var pods []*corev1.Pod
-	pods = append(pods,
-		genDefaultPod("default", "pod-test-1"),
-		genDefaultPod("default", "pod-test-2"),
-		genDefaultPod("default", "pod-test-3"),
-		genDefaultPod("default", "pod-test-4"))
-	pods[0].Labels[StageLabel] = PreTrafficOffStage
-	pods[1].Labels[StageLabel] = PreTrafficOffStage
-	pods[2].Labels[StageLabel] = PreTrafficOffStage
-	pods[3].Labels[StageLabel] = PreTrafficOffStage
-	for _, po := range pods {
-		g.Expect(c.Create(ctx, po)).NotTo(gomega.HaveOccurred())
+	passed := genDefaultPod("default", "pod-test-1")
+	passed.Labels[StageLabel] = PreTrafficOffStage
+	passed.Labels["ready"] = "true"
+	blocked := genDefaultPod("default", "pod-test-2")
+	blocked.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, passed, blocked).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+		Policy:          register.DefaultPolicy(),
 	}
-
-	podList := &corev1.PodList{}
-	defer func() {
-		c.List(ctx, podList, client.InNamespace("default"))
-		for _, po := range podList.Items {
-			g.Expect(c.Delete(ctx, &po)).NotTo(gomega.HaveOccurred())
-		}
-	}()
-	g.Eventually(func() int {
-		g.Expect(c.List(ctx, podList, &client.ListOptions{
-			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
-		})).ShouldNot(gomega.HaveOccurred())
-		return len(podList.Items)
-	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
-	g.Expect(c.Create(ctx, rs)).NotTo(gomega.HaveOccurred())
-	g.Eventually(func() bool {
-		if err := c.Get(ctx, types.NamespacedName{
-			Name:      rs.Name,
-			Namespace: rs.Namespace,
-		}, rs); err == nil {
-			return len(rs.Status.Details) == 4
-		}
-		return false
-	}, 5*time.Second, 500*time.Millisecond).Should(gomega.BeTrue())
-	passCaseMap := map[int]bool{}
-	g.Eventually(func() int {
-		g.Expect(c.List(ctx, podList, &client.ListOptions{
-			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
-		})).NotTo(gomega.HaveOccurred())
-		// LifeCycle 2. 2 pod passed
-		passedCount := 0
-		for i := range podList.Items {
-			state, err := PodTransitionRuleManager().GetState(ctx, c, &podList.Items[i])
-			g.Expect(err).NotTo(gomega.HaveOccurred())
-			if state.InStageAndPassed() && state.Stage == PreTrafficOffStage {
-				passedCount++
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-readiness-gate"}
+	getPod := func(name string) *corev1.Pod {
+		po := &corev1.Pod{}
+		g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, po)).NotTo(gomega.HaveOccurred())
+		return po
+	}
+	getCondition := func(name string) *corev1.PodCondition {
+		po := getPod(name)
+		for i := range po.Status.Conditions {
+			if po.Status.Conditions[i].Type == gateType {
+				return &po.Status.Conditions[i]
 			}
 		}
-		passCaseMap[passedCount] = true
-		return passedCount
-	}, 15*time.Second, 1*time.Second).Should(gomega.Equal(4))
-	g.Expect(passCaseMap[0]).Should(gomega.BeTrue())
-	g.Expect(passCaseMap[1]).Should(gomega.BeTrue())
-	g.Expect(passCaseMap[2]).Should(gomega.BeTrue())
-	g.Expect(passCaseMap[3]).Should(gomega.BeTrue())
-	g.Expect(passCaseMap[4]).Should(gomega.BeTrue())
-	for taskId, tm := range taskStartTime {
-		cost := taskFinishTime[taskId].Sub(tm)
-		fmt.Printf("task %s, cost %d seconds\n", taskId, cost/time.Second)
-		g.Expect(cost >= 12*time.Second).Should(gomega.BeTrue())
+		return nil
 	}
-}
 
-const (
-	StageLabel       = "test.kafe.io/stage"
-	ConditionLabel   = "test.kafe.io/condition"
-	UnavailableLabel = "test.kafe.io/unavailable"
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getCondition("pod-test-1").Status).Should(gomega.Equal(corev1.ConditionTrue))
+	g.Expect(getCondition("pod-test-1").Reason).Should(gomega.Equal(podtransitionruleutils.PodConditionReasonPassed))
+	g.Expect(getCondition("pod-test-2").Status).Should(gomega.Equal(corev1.ConditionFalse))
+	g.Expect(getCondition("pod-test-2").Reason).Should(gomega.Equal(podtransitionruleutils.PodConditionReasonBlocked))
+	g.Expect(getCondition("pod-test-2").Message).Should(gomega.ContainSubstring("labels not match ready=true"))
 
-	PreTrafficOffStage = "PreTrafficOff"
+	// blocked pod passes once labeled
+	po := getPod("pod-test-2")
+	po.Labels["ready"] = "true"
+	g.Expect(fc.Update(ctx, po)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getCondition("pod-test-2").Status).Should(gomega.Equal(corev1.ConditionTrue))
+	g.Expect(getCondition("pod-test-2").Reason).Should(gomega.Equal(podtransitionruleutils.PodConditionReasonPassed))
 
-	DeletePodCondition = "DeletePod"
-)
+	// unselected pod is released
+	po = getPod("pod-test-1")
+	delete(po.Labels, "test")
+	g.Expect(fc.Update(ctx, po)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getCondition("pod-test-1").Status).Should(gomega.Equal(corev1.ConditionTrue))
+	g.Expect(getCondition("pod-test-1").Reason).Should(gomega.Equal(podtransitionruleutils.PodConditionReasonReleased))
+}
+
+// podUpdateFailClient fails updates of pods
+type podUpdateFailClient struct {
+	client.Client
+}
+
+func (c *podUpdateFailClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if _, ok := obj.(*corev1.Pod); ok {
+		return fmt.Errorf("update pod failed")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *podUpdateFailClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if _, ok := obj.(*corev1.Pod); ok {
+		return fmt.Errorf("patch pod failed")
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestDeletionEscalation(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	now := metav1.Now()
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "podtransitionrule-escalation",
+			Namespace:         "default",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{appsv1alpha1.ProtectFinalizer},
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+		Status: appsv1alpha1.PodTransitionRuleStatus{
+			Targets: []string{"pod-test-1"},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Annotations = map[string]string{appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + rs.Name: "{}"}
+	fc := &podUpdateFailClient{Client: fake.NewClientBuilder().WithObjects(rs, po).Build()}
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:      &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:               register.DefaultPolicy(),
+		escalationThresholds: []time.Duration{0, time.Hour},
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-escalation"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.DeletionBlockedSince).NotTo(gomega.BeNil())
+	blockedSince := rs.Status.DeletionBlockedSince.DeepCopy()
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning DeletionBlocked deletion is blocked for 0s, escalation level 1/2"))
+
+	// blocked time is kept, and escalated to critical
+	r.escalationThresholds = []time.Duration{0}
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.DeletionBlockedSince.Equal(blockedSince)).Should(gomega.BeTrue())
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning DeletionBlockedCritical"))
+}
+
+func TestDeletionGracePeriod(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	deletedAt := metav1.NewTime(time.Now().Add(-time.Minute))
+	gracePeriod := int64(3600)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "podtransitionrule-grace-period",
+			Namespace:         "default",
+			DeletionTimestamp: &deletedAt,
+			Finalizers:        []string{appsv1alpha1.ProtectFinalizer},
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			DeletionProtection: &appsv1alpha1.DeletionProtection{GracePeriodSeconds: &gracePeriod},
+		},
+		Status: appsv1alpha1.PodTransitionRuleStatus{
+			Targets: []string{"pod-test-1"},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Annotations = map[string]string{appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + rs.Name: "{}"}
+	fc := &podUpdateFailClient{Client: fake.NewClientBuilder().WithObjects(rs, po).Build()}
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-grace-period"}
+
+	// within grace period, deletion is blocked
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).Should(gomega.ContainElement(appsv1alpha1.ProtectFinalizer))
+	g.Expect(recorder.Events).ShouldNot(gomega.Receive())
+
+	// grace period expired, finalizer is removed and podTransitionRule is deleted
+	gracePeriod = 30
+	rs.Spec.DeletionProtection.GracePeriodSeconds = &gracePeriod
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning ForcedCleanup deletion grace period 30s expired"))
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, key, rs))).Should(gomega.BeTrue())
+}
+
+func TestConfigurableFinalizer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-finalizer",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	fc := fake.NewClientBuilder().WithObjects(rs).Build()
+	reconcilerWith := func(finalizer string) *PodTransitionRuleReconciler {
+		return &PodTransitionRuleReconciler{
+			ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+			Policy:          register.DefaultPolicy(),
+			finalizer:       finalizer,
+		}
+	}
+	ra := reconcilerWith("a.operating.kusionstack.io/protected")
+	rb := reconcilerWith("b.operating.kusionstack.io/protected")
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-finalizer"}
+
+	// each reconciler adds its own finalizer
+	_, err := ra.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = rb.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).Should(gomega.ConsistOf("a.operating.kusionstack.io/protected", "b.operating.kusionstack.io/protected"))
+
+	// each reconciler removes its own finalizer only, ProtectFinalizer is left to the reconciler using it
+	rs.Finalizers = append(rs.Finalizers, appsv1alpha1.ProtectFinalizer)
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Delete(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = ra.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).Should(gomega.ConsistOf("b.operating.kusionstack.io/protected", appsv1alpha1.ProtectFinalizer))
+
+	_, err = rb.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).Should(gomega.ConsistOf(appsv1alpha1.ProtectFinalizer))
+
+	_, err = reconcilerWith("").Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, key, rs))).Should(gomega.BeTrue())
+}
+
+func TestRecreateAfterDeletion(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	newRule := func() *appsv1alpha1.PodTransitionRule {
+		return &appsv1alpha1.PodTransitionRule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "podtransitionrule-recreate",
+				Namespace: "default",
+			},
+			Spec: appsv1alpha1.PodTransitionRuleSpec{
+				Selector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"test": "gen",
+					},
+				},
+			},
+		}
+	}
+	fc := fake.NewClientBuilder().WithObjects(newRule()).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-recreate"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	rs := &appsv1alpha1.PodTransitionRule{}
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).ShouldNot(gomega.BeEmpty())
+
+	// status is updated and expected, then podTransitionRule is deleted
+	g.Expect(fc.Delete(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, key, rs))).Should(gomega.BeTrue())
+	_, exists, _ := podtransitionruleutils.PodTransitionRuleVersionExpectation.GetExpectations(key.String())
+	g.Expect(exists).Should(gomega.BeFalse())
+
+	// recreated podTransitionRule with lower resourceVersion is reconciled
+	g.Expect(fc.Create(ctx, newRule())).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).ShouldNot(gomega.BeEmpty())
+}
+
+func TestPodWriteConflict(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-write-conflict",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := &podUpdateFailClient{Client: fake.NewClientBuilder().WithObjects(rs, po).Build()}
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:          &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:                   register.DefaultPolicy(),
+		podWriteFailureThreshold: 2,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-write-conflict"}
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		g.Expect(err).To(gomega.HaveOccurred())
+	}
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning PodWriteConflict annotation writes on pod pod-test-1 failed 2 times in a row"))
+
+	// failing pod is named in condition
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	cond := findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRulePodWriteConflict)
+	g.Expect(cond).NotTo(gomega.BeNil())
+	g.Expect(cond.Message).Should(gomega.ContainSubstring("pod-test-1"))
+	// event is emitted once per streak of failures
+	g.Expect(recorder.Events).Should(gomega.BeEmpty())
+
+	// condition is removed once writes succeed
+	r.Client = fc.Client
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRulePodWriteConflict)).Should(gomega.BeNil())
+}
+
+func TestExplain(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-explain",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name: "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name:     "disabled",
+					Disabled: true,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						AvailablePolicy: &appsv1alpha1.AvailableRule{},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	po.Annotations = map[string]string{appsv1alpha1.AnnotationPodExplain: "true"}
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+		explainInterval: time.Minute,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-explain"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning RuleRejected [podtransitionrule-explain] rejected by rule labelCheck"))
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning RuleRejected pods newly rejected by rules: "))
+	event := <-recorder.Events
+	g.Expect(event).Should(gomega.HavePrefix("Normal Explain [podtransitionrule-explain] [stage]=PreTrafficOff, [passed]=false; labelCheck: rejected, "))
+	g.Expect(event).Should(gomega.HaveSuffix("; disabled: disabled"))
+	// explain annotation is cleared
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(appsv1alpha1.AnnotationPodExplain))
+
+	// explain again is rate limited
+	po.Annotations[appsv1alpha1.AnnotationPodExplain] = "other, podtransitionrule-explain"
+	g.Expect(fc.Update(ctx, po)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning ExplainRateLimited [podtransitionrule-explain] explain is rate limited"))
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations[appsv1alpha1.AnnotationPodExplain]).Should(gomega.Equal("other"))
+}
+
+func TestDryRun(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "podtransitionrule-dry-run",
+			Namespace:   "default",
+			Annotations: map[string]string{appsv1alpha1.AnnotationDryRun: "true"},
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name: "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		Status: appsv1alpha1.PodTransitionRuleStatus{
+			Targets: []string{"pod-test-0"},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-dry-run"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Normal DryRun dry run, nothing is applied: 1 targets would be added, 1 removed, 1 pod verdicts and 2 pod annotations would change"))
+
+	// nothing is written
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).Should(gomega.BeEmpty())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-0"}))
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/podtransitionrule-dry-run"))
+
+	diff := computeDryRunDiff(rs, sets.NewString("pod-test-1"), map[string]*corev1.Pod{"pod-test-1": po}, nil,
+		[]*appsv1alpha1.PodTransitionDetail{{Name: "pod-test-1", Stage: PreTrafficOffStage}},
+		map[string]*appsv1alpha1.PodTransitionDetail{"pod-test-1": {Name: "pod-test-1", Stage: PreTrafficOffStage}})
+	g.Expect(diff.DryRun).Should(gomega.BeTrue())
+	g.Expect(diff.AddedTargets).Should(gomega.Equal([]string{"pod-test-1"}))
+	g.Expect(diff.RemovedTargets).Should(gomega.Equal([]string{"pod-test-0"}))
+	g.Expect(diff.Details).Should(gomega.HaveLen(1))
+	g.Expect(diff.Details[0].Old).Should(gomega.BeNil())
+	g.Expect(diff.Details[0].New.Passed).Should(gomega.BeFalse())
+	g.Expect(diff.Annotations[1]).Should(gomega.Equal(annotationDiff{Pod: "pod-test-0", Key: appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/podtransitionrule-dry-run", Removed: true}))
+}
+
+func TestReconcileDeadline(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-deadline",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name: "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-deadline"}
+	detailAnno := appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/podtransitionrule-deadline"
+
+	// stages and pod writes are skipped after deadline, status is persisted and the reconcile is requeued
+	deadlineCtx, cancel := withReconcileDeadline(ctx, -time.Second)
+	defer cancel()
+	res, err := r.reconcile(deadlineCtx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(res.Requeue).Should(gomega.BeTrue())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1"}))
+	g.Expect(rs.Status.Details).Should(gomega.BeEmpty())
+	g.Expect(rs.Status.SyncProgress).Should(gomega.Equal("0/1"))
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(detailAnno))
+
+	// requeue resumes
+	_, err = r.reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.SyncProgress).Should(gomega.BeEmpty())
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).Should(gomega.HaveKey(detailAnno))
+
+	// details of skipped stages are kept as they were
+	deadlineCtx, cancel = withReconcileDeadline(ctx, -time.Second)
+	defer cancel()
+	_, err = r.reconcile(deadlineCtx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+}
+
+func TestExecutedStages(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	r := &PodTransitionRuleReconciler{Policy: register.DefaultPolicy()}
+	var all []string
+	for _, group := range register.GetStageGroups(r.Policy) {
+		all = append(all, group.Stages...)
+	}
+	g.Expect(all).Should(gomega.ContainElement(PreTrafficOffStage))
+	g.Expect(r.executedStages(sets.NewString())).Should(gomega.Equal(all))
+
+	// stages skipped by reconcile deadline are not reported
+	executed := r.executedStages(sets.NewString(PreTrafficOffStage))
+	g.Expect(executed).Should(gomega.HaveLen(len(all) - 1))
+	g.Expect(executed).ShouldNot(gomega.ContainElement(PreTrafficOffStage))
+	g.Expect(r.executedStages(sets.NewString(all...))).Should(gomega.BeEmpty())
+}
+
+// recordingLogger records key values of info logs
+type recordingLogger struct {
+	logr.Logger
+	infos map[string]map[string]interface{}
+}
+
+func (l *recordingLogger) Info(msg string, keysAndValues ...interface{}) {
+	values := map[string]interface{}{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		values[fmt.Sprint(keysAndValues[i])] = keysAndValues[i+1]
+	}
+	l.infos[msg] = values
+}
+
+func TestLogSummary(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	logger := &recordingLogger{Logger: logr.Discard(), infos: map[string]map[string]interface{}{}}
+	r := &PodTransitionRuleReconciler{}
+	targets := map[string]*corev1.Pod{"pod-a": nil, "pod-b": nil, "pod-c": nil}
+	details := map[string]*appsv1alpha1.PodTransitionDetail{
+		"pod-a": {Name: "pod-a", Passed: true},
+		"pod-b": {Name: "pod-b", RejectInfo: []appsv1alpha1.RejectInfo{{RuleName: "labelCheck"}}},
+		"pod-c": {Name: "pod-c", PendingRules: []string{"webhook"}},
+	}
+	r.logSummary(logger, 2, []string{PreTrafficOffStage}, targets, details, time.Now(), reconcile.Result{RequeueAfter: time.Minute}, nil)
+	g.Expect(logger.infos).Should(gomega.HaveKey("reconcile summary"))
+	summary := logger.infos["reconcile summary"]
+	g.Expect(summary).Should(gomega.HaveKeyWithValue("targets", 3))
+	g.Expect(summary).Should(gomega.HaveKeyWithValue("passed", 1))
+	g.Expect(summary).Should(gomega.HaveKeyWithValue("blocked", 2))
+	g.Expect(summary).Should(gomega.HaveKeyWithValue("rejected", 1))
+	g.Expect(summary).Should(gomega.HaveKeyWithValue("requeued", true))
+	g.Expect(summary).Should(gomega.HaveKeyWithValue("requeueInterval", "1m0s"))
+}
+
+func TestKillSwitch(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-kill-switch",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      appsv1alpha1.PodTransitionRuleKillSwitchConfigMap,
+			Namespace: "kusionstack-system",
+		},
+		Data: map[string]string{podtransitionruleutils.KillSwitchKeyActive: "true"},
+	}
+	fc := fake.NewClientBuilder().WithObjects(rs, po, cm).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:     &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:              register.DefaultPolicy(),
+		killSwitchNamespace: "kusionstack-system",
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-kill-switch"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	// pod passes, and what would be blocked is still observable
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+	g.Expect(rs.Status.Details[0].RejectInfo).Should(gomega.HaveLen(1))
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning KillSwitchActive"))
+
+	// kill switch cleared
+	cm.Data[podtransitionruleutils.KillSwitchKeyActive] = "false"
+	g.Expect(fc.Update(ctx, cm)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+}
+
+func TestWebhookRule(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stop, finish := RunHttpServer(handleHttpAlwaysSuccess, "8899")
+	defer func() {
+		stop <- struct{}{}
+		<-finish
+	}()
+	<-time.After(1 * time.Second)
+	var pods []*corev1.Pod
+	pods = append(pods,
+		genDefaultPod("default", "pod-test-1"),
+		genDefaultPod("default", "pod-test-2"),
+		genDefaultPod("default", "pod-test-3"),
+		genDefaultPod("default", "pod-test-4"))
+	for _, po := range pods {
+		g.Expect(c.Create(ctx, po)).NotTo(gomega.HaveOccurred())
+	}
+	stage := PreTrafficOffStage
+	policy := appsv1alpha1.Fail
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-webhook",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "webhook",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						Webhook: &appsv1alpha1.TransitionRuleWebhook{
+							ClientConfig: appsv1alpha1.ClientConfigBeta1{
+								URL: "http://127.0.0.1:8899",
+							},
+							FailurePolicy: &policy,
+							Parameters: []appsv1alpha1.Parameter{
+								{
+									Key: "podIP",
+									ValueFrom: &appsv1alpha1.ParameterSource{
+										FieldRef: &corev1.ObjectFieldSelector{
+											APIVersion: "v1",
+											FieldPath:  "status.podIP",
+										},
+									},
+								},
+								{
+									Key: "context",
+									ValueFrom: &appsv1alpha1.ParameterSource{
+										FieldRef: &corev1.ObjectFieldSelector{
+											APIVersion: "v1",
+											FieldPath:  "metadata.annotations['test.io/context']",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	g.Expect(c.Create(ctx, rs)).NotTo(gomega.HaveOccurred())
+	podList := &corev1.PodList{}
+	defer func() {
+		c.List(ctx, podList, client.InNamespace("default"))
+		for _, po := range podList.Items {
+			g.Expect(c.Delete(ctx, &po)).NotTo(gomega.HaveOccurred())
+		}
+	}()
+	g.Eventually(func() int {
+		g.Expect(c.List(ctx, podList, &client.ListOptions{
+			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
+		})).ShouldNot(gomega.HaveOccurred())
+		return len(podList.Items)
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
+	for i := range podList.Items {
+		if podList.Items[i].Name == "pod-test-1" {
+			g.Expect(setPodOnStage(&podList.Items[i], PreTrafficOffStage)).NotTo(gomega.HaveOccurred())
+		}
+	}
+	g.Eventually(func() int {
+		g.Expect(c.List(ctx, podList, &client.ListOptions{
+			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
+		})).NotTo(gomega.HaveOccurred())
+		// LifeCycle 2. 2 pod passed
+		passedCount := 0
+		for i := range podList.Items {
+			state, err := PodTransitionRuleManager().GetState(ctx, c, &podList.Items[i])
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			if state.InStageAndPassed() && state.Stage == PreTrafficOffStage {
+				passedCount++
+				g.Expect(podList.Items[i].Name).Should(gomega.Equal("pod-test-1"))
+			}
+		}
+		return passedCount
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(1))
+
+	g.Eventually(func() int {
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-webhook"}, rs)).Should(gomega.BeNil())
+		return len(rs.Status.Details)
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(1))
+
+	// LifeCycle 1. set all pod on Stage
+	for i := range podList.Items {
+		g.Expect(setPodOnStage(&podList.Items[i], PreTrafficOffStage)).NotTo(gomega.HaveOccurred())
+	}
+
+	g.Eventually(func() int {
+		g.Expect(c.List(ctx, podList, &client.ListOptions{
+			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
+		})).NotTo(gomega.HaveOccurred())
+		passedCount := 0
+		for i := range podList.Items {
+			state, err := PodTransitionRuleManager().GetState(ctx, c, &podList.Items[i])
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			if state.InStageAndPassed() && state.Stage == PreTrafficOffStage {
+				passedCount++
+			}
+		}
+		return passedCount
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
+
+	g.Eventually(func() int {
+		g.Expect(c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-webhook"}, rs)).Should(gomega.BeNil())
+		return len(rs.Status.Details)
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
+
+	g.Expect(c.Delete(ctx, rs)).NotTo(gomega.HaveOccurred())
+	g.Eventually(func() error {
+		err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "podtransitionrule-webhook"}, rs)
+		return err
+	}, 5*time.Second, 1*time.Second).Should(gomega.HaveOccurred())
+}
+
+func TestWebhookPoll(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stop, finish, taskStartTime, taskFinishTime := RunPollingServer()
+	defer func() {
+		stop <- struct{}{}
+		<-finish
+	}()
+	stage := PreTrafficOffStage
+	policy := appsv1alpha1.Fail
+	interval := int64(3)
+	timeout := int64(60)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-poll-test",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "webhook",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						Webhook: &appsv1alpha1.TransitionRuleWebhook{
+							ClientConfig: appsv1alpha1.ClientConfigBeta1{
+								URL: "http://127.0.0.1:8999/first",
+								Poll: &appsv1alpha1.Poll{
+									URL:             "http://127.0.0.1:8999/poll",
+									IntervalSeconds: &interval,
+									TimeoutSeconds:  &timeout,
+								},
+							},
+							FailurePolicy: &policy,
+						},
+					},
+				},
+			},
+		},
+	}
+	var pods []*corev1.Pod
+	pods = append(pods,
+		genDefaultPod("default", "pod-test-1"),
+		genDefaultPod("default", "pod-test-2"),
+		genDefaultPod("default", "pod-test-3"),
+		genDefaultPod("default", "pod-test-4"))
+	pods[0].Labels[StageLabel] = PreTrafficOffStage
+	pods[1].Labels[StageLabel] = PreTrafficOffStage
+	pods[2].Labels[StageLabel] = PreTrafficOffStage
+	pods[3].Labels[StageLabel] = PreTrafficOffStage
+	for _, po := range pods {
+		g.Expect(c.Create(ctx, po)).NotTo(gomega.HaveOccurred())
+	}
+
+	podList := &corev1.PodList{}
+	defer func() {
+		c.List(ctx, podList, client.InNamespace("default"))
+		for _, po := range podList.Items {
+			g.Expect(c.Delete(ctx, &po)).NotTo(gomega.HaveOccurred())
+		}
+	}()
+	g.Eventually(func() int {
+		g.Expect(c.List(ctx, podList, &client.ListOptions{
+			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
+		})).ShouldNot(gomega.HaveOccurred())
+		return len(podList.Items)
+	}, 5*time.Second, 1*time.Second).Should(gomega.Equal(4))
+	g.Expect(c.Create(ctx, rs)).NotTo(gomega.HaveOccurred())
+	g.Eventually(func() bool {
+		if err := c.Get(ctx, types.NamespacedName{
+			Name:      rs.Name,
+			Namespace: rs.Namespace,
+		}, rs); err == nil {
+			return len(rs.Status.Details) == 4
+		}
+		return false
+	}, 5*time.Second, 500*time.Millisecond).Should(gomega.BeTrue())
+	passCaseMap := map[int]bool{}
+	g.Eventually(func() int {
+		g.Expect(c.List(ctx, podList, &client.ListOptions{
+			LabelSelector: labels.SelectorFromSet(rs.Spec.Selector.MatchLabels),
+		})).NotTo(gomega.HaveOccurred())
+		// LifeCycle 2. 2 pod passed
+		passedCount := 0
+		for i := range podList.Items {
+			state, err := PodTransitionRuleManager().GetState(ctx, c, &podList.Items[i])
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			if state.InStageAndPassed() && state.Stage == PreTrafficOffStage {
+				passedCount++
+			}
+		}
+		passCaseMap[passedCount] = true
+		return passedCount
+	}, 15*time.Second, 1*time.Second).Should(gomega.Equal(4))
+	g.Expect(passCaseMap[0]).Should(gomega.BeTrue())
+	g.Expect(passCaseMap[1]).Should(gomega.BeTrue())
+	g.Expect(passCaseMap[2]).Should(gomega.BeTrue())
+	g.Expect(passCaseMap[3]).Should(gomega.BeTrue())
+	g.Expect(passCaseMap[4]).Should(gomega.BeTrue())
+	for taskId, tm := range taskStartTime {
+		cost := taskFinishTime[taskId].Sub(tm)
+		fmt.Printf("task %s, cost %d seconds\n", taskId, cost/time.Second)
+		g.Expect(cost >= 12*time.Second).Should(gomega.BeTrue())
+	}
+}
+
+func TestPodsToSyncDetail(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	details := map[string]*appsv1alpha1.PodTransitionDetail{
+		"pod-c": {Name: "pod-c", Stage: PreTrafficOffStage, Passed: true},
+		"pod-a": {Name: "pod-a", Stage: PreTrafficOffStage, Passed: false},
+		"pod-b": {Name: "pod-b", Stage: PreTrafficOffStage, Passed: true},
+	}
+	targets := map[string]*corev1.Pod{}
+	for name := range details {
+		targets[name] = genDefaultPod("default", name)
+	}
+	// pod-b is already synced
+	key, val := podDetailAnno("rs", details["pod-b"])
+	targets["pod-b"].Annotations = map[string]string{key: val}
+
+	pods := podsToSyncDetail("rs", targets, details)
+	g.Expect(len(pods)).Should(gomega.BeEquivalentTo(2))
+	g.Expect(pods[0].Name).Should(gomega.BeEquivalentTo("pod-a"))
+	g.Expect(pods[1].Name).Should(gomega.BeEquivalentTo("pod-c"))
+}
+
+func TestUpdateDetailIdempotent(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	details := map[string]*appsv1alpha1.PodTransitionDetail{}
+	// two stages return overlapping pods, rejected by the same rule
+	for _, pods := range [][]string{{"pod-a", "pod-b"}, {"pod-b", "pod-c"}} {
+		res := &processor.ProcessResult{
+			Rejected:  map[string]processor.RejectInfo{},
+			PassRules: map[string]sets.String{},
+			Delays:    map[string][]appsv1alpha1.DelayInfo{},
+			Pending:   map[string]sets.String{},
+		}
+		for _, po := range pods {
+			res.PassRules[po] = sets.NewString("rule-pass")
+			res.Rejected[po] = processor.RejectInfo{RuleName: "rule-reject", Reason: "rejected"}
+			res.Delays[po] = []appsv1alpha1.DelayInfo{{RuleName: "rule-delay"}}
+			res.Pending[po] = sets.NewString("rule-pending")
+		}
+		updateDetail(details, res, PreTrafficOffStage, nil, 0)
+	}
+	g.Expect(details).Should(gomega.HaveLen(3))
+	for _, detail := range details {
+		g.Expect(detail.RejectInfo).Should(gomega.Equal([]appsv1alpha1.RejectInfo{{RuleName: "rule-reject", Reason: "rejected"}}))
+		g.Expect(detail.RejectedRules).Should(gomega.Equal([]string{"rule-reject"}))
+		g.Expect(detail.PassedRules).Should(gomega.Equal([]string{"rule-pass"}))
+		g.Expect(detail.PendingRules).Should(gomega.Equal([]string{"rule-pending"}))
+		g.Expect(detail.DelayInfo).Should(gomega.HaveLen(1))
+		g.Expect(detail.Passed).Should(gomega.BeFalse())
+	}
+}
+
+func TestDebounceEventHandler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	h := &DebounceEventHandler{EventHandler: &handler.EnqueueRequestForObject{}, Delay: 200 * time.Millisecond}
+	pod := genDefaultPod("default", "pod-debounce")
+	// events from different sources in quick succession
+	h.Create(event.CreateEvent{Object: pod}, q)
+	h.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: pod}, q)
+	h.Generic(event.GenericEvent{Object: pod}, q)
+	g.Expect(q.Len()).Should(gomega.BeEquivalentTo(0))
+	// coalesced into a single request, and not lost
+	g.Eventually(q.Len, 2*time.Second, 50*time.Millisecond).Should(gomega.BeEquivalentTo(1))
+	g.Consistently(q.Len, 500*time.Millisecond, 50*time.Millisecond).Should(gomega.BeEquivalentTo(1))
+}
+
+func TestQueueRegistry(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	registry := NewQueueRegistry()
+	q1, q2, stopped := workqueue.NewDelayingQueue(), workqueue.NewDelayingQueue(), workqueue.NewDelayingQueue()
+	defer q1.ShutDown()
+	defer q2.ShutDown()
+	unregister := registry.Register(q1)
+	registry.Register(q2)
+	registry.Register(stopped)
+	stopped.ShutDown()
+
+	key := types.NamespacedName{Namespace: "default", Name: "pod-test"}
+	registry.Broadcast(key)
+	g.Expect(q1.Len()).Should(gomega.Equal(1))
+	g.Expect(q2.Len()).Should(gomega.Equal(1))
+	g.Expect(stopped.Len()).Should(gomega.BeZero())
+
+	// unregistered queues are not notified any more
+	item, _ := q1.Get()
+	q1.Done(item)
+	unregister()
+	registry.Broadcast(types.NamespacedName{Namespace: "default", Name: "pod-other"})
+	g.Expect(q1.Len()).Should(gomega.BeZero())
+	g.Expect(q2.Len()).Should(gomega.Equal(2))
+}
+
+func TestPodEventQueues(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-pod-events",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	q := workqueue.NewDelayingQueue()
+	defer q.ShutDown()
+	defer PodEventQueues.Register(q)()
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-pod-events"}
+
+	// pods whose details are changed are broadcast
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(q.Len()).Should(gomega.Equal(1))
+	item, _ := q.Get()
+	q.Done(item)
+	g.Expect(item).Should(gomega.Equal(types.NamespacedName{Namespace: "default", Name: "pod-test"}))
+
+	// unchanged details are not broadcast
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(q.Len()).Should(gomega.BeZero())
+}
+
+// countingQueue counts keys added to it
+type countingQueue struct {
+	workqueue.DelayingInterface
+	adds, addAfters int
+	mu              sync.Mutex
+}
+
+func (q *countingQueue) Add(item interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.adds++
+}
+
+func (q *countingQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.addAfters++
+}
+
+func (q *countingQueue) counts() (int, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.adds, q.addAfters
+}
+
+func TestQueueRegistryMinInterval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	registry := NewQueueRegistry()
+	registry.SetMinInterval(time.Second)
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+	q := &countingQueue{DelayingInterface: workqueue.NewDelayingQueue()}
+	defer q.ShutDown()
+	registry.Register(q)
+	key := types.NamespacedName{Namespace: "default", Name: "pod-test"}
+
+	// the first broadcast is immediate, the following ones in the interval are merged into a delayed one
+	for i := 0; i < 100; i++ {
+		registry.Broadcast(key)
+	}
+	adds, addAfters := q.counts()
+	g.Expect(adds).Should(gomega.Equal(1))
+	g.Expect(addAfters).Should(gomega.Equal(1))
+
+	// other keys are not limited
+	registry.Broadcast(types.NamespacedName{Namespace: "default", Name: "pod-other"})
+	adds, _ = q.counts()
+	g.Expect(adds).Should(gomega.Equal(2))
+
+	// broadcasts after the delayed one are delayed to the end of next interval
+	now = now.Add(time.Second)
+	for i := 0; i < 100; i++ {
+		registry.Broadcast(key)
+	}
+	adds, addAfters = q.counts()
+	g.Expect(adds).Should(gomega.Equal(2))
+	g.Expect(addAfters).Should(gomega.Equal(2))
+
+	// key is broadcast immediately once quiet for an interval, and forgotten by sweep
+	now = now.Add(2 * time.Second)
+	registry.Broadcast(key)
+	adds, _ = q.counts()
+	g.Expect(adds).Should(gomega.Equal(3))
+	g.Expect(registry.broadcasts).Should(gomega.HaveLen(1))
+
+	// min interval is disabled by non-positive value
+	registry.SetMinInterval(0)
+	for i := 0; i < 10; i++ {
+		registry.Broadcast(key)
+	}
+	adds, _ = q.counts()
+	g.Expect(adds).Should(gomega.Equal(13))
+}
+
+func TestPodEventQueuesMinInterval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-pod-event-interval",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-flapping")
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(100)},
+		Policy:          register.DefaultPolicy(),
+	}
+	q := &countingQueue{DelayingInterface: workqueue.NewDelayingQueue()}
+	defer q.ShutDown()
+	defer PodEventQueues.Register(q)()
+	PodEventQueues.SetMinInterval(time.Hour)
+	defer PodEventQueues.SetMinInterval(0)
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-pod-event-interval"}
+
+	// stage of pod flaps, so that its detail is changed on every reconcile
+	for i := 0; i < 10; i++ {
+		g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-flapping"}, po)).NotTo(gomega.HaveOccurred())
+		if i%2 == 0 {
+			po.Labels[StageLabel] = PreTrafficOffStage
+		} else {
+			delete(po.Labels, StageLabel)
+		}
+		g.Expect(fc.Update(ctx, po)).NotTo(gomega.HaveOccurred())
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+	adds, addAfters := q.counts()
+	g.Expect(adds).Should(gomega.Equal(1))
+	g.Expect(addAfters).Should(gomega.Equal(1))
+}
+
+func TestFairRateLimiter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	limiter := newFairRateLimiter()
+	hot := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "hot"}}
+	quiet := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "quiet"}}
+	for i := 0; i < perItemRequeueBurst; i++ {
+		limiter.When(hot)
+		limiter.Forget(hot)
+	}
+	// requeues of a hot PodTransitionRule never consume budget of others
+	for i := 0; i < 2*perItemRequeueBurst; i++ {
+		limiter.When(hot)
+	}
+	g.Expect(limiter.When(hot) > time.Second).Should(gomega.BeTrue())
+	g.Expect(limiter.When(quiet) < 10*time.Millisecond).Should(gomega.BeTrue())
+
+	// requeues of all PodTransitionRules are still limited by the overall bucket
+	for i := 0; i < 100; i++ {
+		limiter.When(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: fmt.Sprintf("rs-%d", i)}})
+	}
+	g.Expect(limiter.When(quiet) > time.Second).Should(gomega.BeTrue())
+}
+
+func TestQueueWaitEventHandler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	h := &QueueWaitEventHandler{EventHandler: &handler.EnqueueRequestForObject{}}
+	pod := genDefaultPod("default", "pod-queue-wait")
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "pod-queue-wait"}}
+	h.Create(event.CreateEvent{Object: pod}, q)
+	h.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: pod}, q)
+	g.Expect(q.Len()).Should(gomega.BeEquivalentTo(1))
+
+	queueWaits.mu.Lock()
+	_, ok := queueWaits.enqueued[req]
+	queueWaits.mu.Unlock()
+	g.Expect(ok).Should(gomega.BeTrue())
+	queueWaits.observe(req)
+	queueWaits.mu.Lock()
+	_, ok = queueWaits.enqueued[req]
+	queueWaits.mu.Unlock()
+	g.Expect(ok).Should(gomega.BeFalse())
+}
+
+func TestCostBudget(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	reconcileCosts.budget = costBudget{window: time.Minute, writes: 1}
+	defer func() {
+		reconcileCosts.budget = costBudget{}
+	}()
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-cost",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: &costClient{Client: fc}, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "podtransitionrule-cost"}}
+	defer reconcileCosts.forget(req)
+	// finalizer, status and pod detail are written
+	_, err := r.Reconcile(ctx, req)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning OverBudget cost budget in 1m0s is exceeded, reconciles are deprioritized: [writes]=3/1"))
+	g.Expect(reconcileCosts.penalty(req) > 50*time.Second).Should(gomega.BeTrue())
+
+	// requeues and events are deprioritized
+	res := r.accountCost(req, &reconcileCost{}, 0, reconcile.Result{Requeue: true})
+	g.Expect(res.RequeueAfter > 50*time.Second).Should(gomega.BeTrue())
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	(&queueWaitQueue{RateLimitingInterface: q}).Add(req)
+	g.Expect(q.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(recorder.Events).Should(gomega.BeEmpty())
+}
+
+func TestStatusMigration(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-migration",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-migration"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.SchemaVersion).Should(gomega.BeEquivalentTo(statusSchemaVersion))
+
+	// status of older schema version is not written alone without migration
+	rs.Status.SchemaVersion = 0
+	g.Expect(fc.Status().Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.SchemaVersion).Should(gomega.BeEquivalentTo(0))
+
+	events := make(chan event.GenericEvent, 1)
+	m := &statusMigrator{cache: &informertest.FakeInformers{}, client: fc, logger: logr.Discard(), qps: 100, events: events}
+	g.Expect(m.Start(ctx)).NotTo(gomega.HaveOccurred())
+	g.Expect((<-events).Object.GetName()).Should(gomega.Equal("podtransitionrule-migration"))
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.SchemaVersion).Should(gomega.BeEquivalentTo(statusSchemaVersion))
+
+	// migrated PodTransitionRules are skipped
+	g.Expect(m.Start(ctx)).NotTo(gomega.HaveOccurred())
+	g.Expect(events).Should(gomega.BeEmpty())
+}
+
+// stagesPolicy is a policy with some stages removed
+type stagesPolicy struct {
+	register.Policy
+	stages []string
+}
+
+func (p *stagesPolicy) GetStages() []string {
+	return p.stages
+}
+
+func TestUnknownStageVerdict(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-unknown-stage",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "labelCheck",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:     &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:              register.DefaultPolicy(),
+		unknownStageVerdict: UnknownStageVerdictBlock,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-unknown-stage"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.Details[0].Stage).Should(gomega.Equal(PreTrafficOffStage))
+
+	// stage is removed from policy, and pod is blocked by default verdict
+	var stages []string
+	for _, stage := range register.DefaultPolicy().GetStages() {
+		if stage != PreTrafficOffStage {
+			stages = append(stages, stage)
+		}
+	}
+	r.Policy = &stagesPolicy{Policy: register.DefaultPolicy(), stages: stages}
+	for i := 0; i < 2; i++ {
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+		g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+		g.Expect(rs.Status.Details[0].Stage).Should(gomega.Equal(UnknownStage))
+		g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+		g.Expect(rs.Status.Details[0].RejectInfo[0].Reason).Should(gomega.ContainSubstring("stage " + PreTrafficOffStage + " is not in current policy"))
+	}
+
+	// stale detail is pruned, and pod passes
+	r.unknownStageVerdict = UnknownStageVerdictPass
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.BeEmpty())
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations[appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix+"/"+rs.Name]).Should(gomega.ContainSubstring(`"passed":true`))
+}
+
+func TestReconcileTrigger(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	q := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	defer q.ShutDown()
+	h := &QueueWaitEventHandler{EventHandler: &DebounceEventHandler{EventHandler: &handler.EnqueueRequestForObject{}}, Trigger: triggerObjectPod}
+	pod := genDefaultPod("default", "pod-trigger")
+	pod.ResourceVersion = "1"
+	newPod := pod.DeepCopy()
+	newPod.ResourceVersion = "2"
+	req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "pod-trigger"}}
+	h.Create(event.CreateEvent{Object: pod}, q)
+	h.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: newPod}, q)
+	h.Update(event.UpdateEvent{ObjectOld: newPod, ObjectNew: newPod}, q)
+	h.Generic(event.GenericEvent{Object: pod}, q)
+
+	reconcileTriggers.mu.Lock()
+	sources := reconcileTriggers.sources[req].List()
+	reconcileTriggers.mu.Unlock()
+	g.Expect(sources).Should(gomega.Equal([]string{triggerManual, triggerPodAdd, triggerPodUpdate, triggerResync}))
+	reconcileTriggers.observe(req)
+	reconcileTriggers.mu.Lock()
+	_, ok := reconcileTriggers.sources[req]
+	reconcileTriggers.mu.Unlock()
+	g.Expect(ok).Should(gomega.BeFalse())
+
+	// other objects
+	g.Expect(triggerObjectPodTransitionRule.triggerSource("update", pod, newPod)).Should(gomega.Equal(triggerRuleChange))
+	g.Expect(triggerObjectDependency.triggerSource("delete", nil, pod)).Should(gomega.Equal(triggerDependencyChange))
+}
+
+func TestShardMembership(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	fc := fake.NewClientBuilder().Build()
+	now := time.Now()
+	newMembership := func(identity string) *shardMembership {
+		return &shardMembership{
+			client:        fc,
+			reader:        fc,
+			logger:        logr.Discard(),
+			namespace:     "kusionstack-system",
+			identity:      identity,
+			leaseDuration: 15 * time.Second,
+			events:        make(chan event.GenericEvent, 10),
+			now:           func() time.Time { return now },
+		}
+	}
+	// tick advances clock by d in renew intervals, replicas sync on each interval
+	tick := func(d time.Duration, replicas ...*shardMembership) {
+		for elapsed := time.Duration(0); elapsed < d; elapsed += 5 * time.Second {
+			now = now.Add(5 * time.Second)
+			for _, m := range replicas {
+				g.Expect(m.sync(ctx)).Should(gomega.Succeed())
+				m.settle()
+			}
+		}
+	}
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("default/podtransitionrule-%d", i)
+	}
+	owned := func(m *shardMembership) sets.String {
+		res := sets.NewString()
+		for _, key := range keys {
+			if m.owns(key) {
+				res.Insert(key)
+			}
+		}
+		return res
+	}
+
+	// a new replica acquires nothing before handoff delay
+	a := newMembership("replica-a")
+	g.Expect(a.sync(ctx)).Should(gomega.Succeed())
+	g.Expect(a.settle()).Should(gomega.BeFalse())
+	g.Expect(owned(a).Len()).Should(gomega.Equal(0))
+	tick(a.handoffDelay(), a)
+	g.Expect(a.settled).Should(gomega.Equal([]string{"replica-a"}))
+	g.Expect(owned(a).Len()).Should(gomega.Equal(len(keys)))
+
+	// keys moved to a joining replica are released at once, and acquired after handoff delay
+	b := newMembership("replica-b")
+	tick(time.Second, b, a)
+	ownedA := owned(a)
+	g.Expect(ownedA.Len()).Should(gomega.BeNumerically(">", 0))
+	g.Expect(ownedA.Len()).Should(gomega.BeNumerically("<", len(keys)))
+	g.Expect(owned(b).Len()).Should(gomega.Equal(0))
+	tick(b.handoffDelay(), b, a)
+	ownedB := owned(b)
+	g.Expect(owned(a)).Should(gomega.Equal(ownedA))
+	g.Expect(ownedA.Intersection(ownedB).Len()).Should(gomega.Equal(0))
+	g.Expect(ownedA.Union(ownedB).Len()).Should(gomega.Equal(len(keys)))
+
+	// a replica failing to renew its lease owns nothing, and its keys move after its lease expires
+	tick(b.leaseDuration+time.Second, b)
+	g.Expect(owned(a).Len()).Should(gomega.Equal(0))
+	g.Expect(owned(b)).Should(gomega.Equal(ownedB))
+	tick(b.handoffDelay(), b)
+	g.Expect(owned(b).Len()).Should(gomega.Equal(len(keys)))
+
+	// a stopped replica releases its lease
+	b.release()
+	leases := &coordinationv1.LeaseList{}
+	g.Expect(fc.List(ctx, leases)).Should(gomega.Succeed())
+	g.Expect(leases.Items).Should(gomega.HaveLen(1))
+	g.Expect(leases.Items[0].Name).Should(gomega.Equal(shardLeasePrefix + "replica-a"))
+}
+
+func TestPodChangePredicate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	p, err := NewPodChangePredicate(strings.Join(defaultPodChangeFields, ","))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	oldPod := genDefaultPod("default", "pod-predicate")
+	update := func(mutate func(*corev1.Pod)) bool {
+		newPod := oldPod.DeepCopy()
+		mutate(newPod)
+		return p.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: newPod})
+	}
+	g.Expect(update(func(po *corev1.Pod) { po.ResourceVersion = "2" })).Should(gomega.BeFalse())
+	g.Expect(update(func(po *corev1.Pod) {
+		po.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "nginx", RestartCount: 1}}
+	})).Should(gomega.BeFalse())
+	g.Expect(update(func(po *corev1.Pod) {
+		po.Annotations = map[string]string{appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/rs": "{}"}
+	})).Should(gomega.BeFalse())
+	g.Expect(update(func(po *corev1.Pod) { po.Labels[StageLabel] = PreTrafficOffStage })).Should(gomega.BeTrue())
+	g.Expect(update(func(po *corev1.Pod) { po.Status.Phase = corev1.PodFailed })).Should(gomega.BeTrue())
+
+	p, err = NewPodChangePredicate("metadata.annotations[test.io/ops]")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(update(func(po *corev1.Pod) { po.Labels[StageLabel] = PreTrafficOffStage })).Should(gomega.BeFalse())
+	g.Expect(update(func(po *corev1.Pod) { po.Annotations = map[string]string{"test.io/ops": "true"} })).Should(gomega.BeTrue())
+
+	_, err = NewPodChangePredicate("spec.containers")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+const (
+	StageLabel       = "test.kafe.io/stage"
+	ConditionLabel   = "test.kafe.io/condition"
+	UnavailableLabel = "test.kafe.io/unavailable"
+
+	PreTrafficOffStage = "PreTrafficOff"
+
+	DeletePodCondition = "DeletePod"
+)
+
+func initPodTransitionRuleManager() {
+
+	register.UnAvailableFuncList = []register.UnAvailableFunc{func(pod *corev1.Pod) (bool, *int64) {
+		if pod.GetLabels() == nil {
+			return false, nil
+		}
+		_, ok := pod.GetLabels()[UnavailableLabel]
+		return ok, nil
+	}}
+	PodTransitionRuleManager().RegisterStage(PreTrafficOffStage, func(po client.Object) bool {
+		if po.GetLabels() == nil {
+			return false
+		}
+		val, ok := po.GetLabels()[StageLabel]
+		return ok && val == PreTrafficOffStage
+	})
+
+	PodTransitionRuleManager().RegisterCondition(DeletePodCondition, func(po client.Object) bool {
+		if po.GetLabels() == nil {
+			return false
+		}
+		val, ok := po.GetLabels()[ConditionLabel]
+		return ok && val == DeletePodCondition
+	})
+}
+
+func setPodOnStage(po *corev1.Pod, stage string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, types.NamespacedName{Namespace: po.Namespace, Name: po.Name}, po); err != nil {
+			return err
+		}
+		po.Labels[StageLabel] = stage
+		return c.Update(ctx, po)
+	})
+}
+
+func setPodUnavailable(po *corev1.Pod) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := c.Get(ctx, types.NamespacedName{Namespace: po.Namespace, Name: po.Name}, po); err != nil {
+			return err
+		}
+		po.Labels[UnavailableLabel] = "true"
+		return c.Update(ctx, po)
+	})
+}
+
+func genDefaultPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"test": "gen",
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "nginx",
+					Image: "nginx:latest",
+				},
+			},
+		},
+	}
+}
+
+func printJson(obj any) {
+	byt, _ := json.MarshalIndent(obj, "", "  ")
+	fmt.Printf("%s\n", string(byt))
+}
+
+func handleHttpAlwaysSuccess(resp http.ResponseWriter, req *http.Request) {
+	fmt.Println(req.URL)
+	all, err := io.ReadAll(req.Body)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("read body err: %s", err))
+	}
+	webReq := &appsv1alpha1.WebhookRequest{}
+	fmt.Printf("handle http req: %s", string(all))
+	err = json.Unmarshal(all, webReq)
+	if err != nil {
+		fmt.Printf("fail to unmarshal webhook request: %v", err)
+		http.Error(resp, fmt.Sprintf("fail to unmarshal webhook request %s", string(all)), http.StatusInternalServerError)
+		return
+	}
+	webhookResp := &appsv1alpha1.WebhookResponse{
+		Success: true,
+		Message: "test success",
+	}
+	byt, _ := json.Marshal(webhookResp)
+	resp.Write(byt)
+}
+
+type Server struct {
+	server *http.Server
+}
+
+func (s *Server) Run(stop <-chan struct{}) chan struct{} {
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	<-time.After(5 * time.Second)
+	finish := make(chan struct{})
+	fmt.Println("run server")
+	go func() {
+		select {
+		case <-stop:
+			s.server.Close()
+			finish <- struct{}{}
+		}
+	}()
+	return finish
+}
+
+func RunHttpServer(f func(http.ResponseWriter, *http.Request), port string) (chan struct{}, chan struct{}) {
+	fmt.Println("try run http server")
+	defer fmt.Println("running")
+	server := &Server{
+		server: &http.Server{
+			Addr:    fmt.Sprintf("127.0.0.1:%s", port),
+			Handler: http.HandlerFunc(f),
+		},
+	}
+	stopped := make(chan struct{})
+	finish := server.Run(stopped)
+	return stopped, finish
+}
+
+func RunPollingServer() (chan<- struct{}, <-chan struct{}, map[string]time.Time, map[string]time.Time) {
+	taskMap := map[string][]string{}
+	taskIdx := map[string]int{}
+	taskStartTime := map[string]time.Time{}
+	taskFinishTime := map[string]time.Time{}
+	serverMux := http.NewServeMux()
+	serverMux.HandleFunc("/first", func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Println(req.URL)
+		all, err := io.ReadAll(req.Body)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("read body err: %s", err))
+		}
+		webReq := &appsv1alpha1.WebhookRequest{}
+		fmt.Printf("handle http req. time %s: %s\n", time.Now().String(), string(all))
+		err = json.Unmarshal(all, webReq)
+		if err != nil {
+			fmt.Printf("fail to unmarshal webhook request: %v", err)
+			http.Error(resp, fmt.Sprintf("fail to unmarshal webhook request %s", string(all)), http.StatusInternalServerError)
+			return
+		}
+		var names []string
+		for _, resource := range webReq.Resources {
+			names = append(names, resource.Name)
+		}
+		taskID := uuid.New().String()
+		fmt.Printf("handle pods %v\n, task %s\n", names, taskID)
+		taskMap[taskID] = names
+		taskStartTime[taskID] = time.Now()
+		webhookResp := &appsv1alpha1.WebhookResponse{
+			Success: true,
+			Message: fmt.Sprintf("init task %s", taskID),
+			Poll:    true,
+			TaskId:  taskID,
+		}
+		byt, _ := json.Marshal(webhookResp)
+		resp.Write(byt)
+	})
+	serverMux.HandleFunc("/poll", func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Println(req.URL)
+		taskId := req.URL.Query().Get("task-id")
+
+		pods, ok := taskMap[taskId]
+		if !ok {
+			panic(fmt.Sprintf("taskId %s not found", taskId))
+		}
+		idx := taskIdx[taskId]
+		webhookResp := &appsv1alpha1.PollResponse{}
+		if idx == len(pods)-1 {
+			taskFinishTime[taskId] = time.Now()
+			webhookResp = &appsv1alpha1.PollResponse{
+				Success:  true,
+				Message:  "success",
+				Finished: true,
+			}
+		} else {
+			taskIdx[taskId] = idx + 1
+			webhookResp = &appsv1alpha1.PollResponse{
+				Success:       true,
+				Message:       fmt.Sprintf("test passed pods %v", pods[:idx+1]),
+				Finished:      false,
+				FinishedNames: pods[:idx+1],
+			}
+		}
+		byt, _ := json.Marshal(webhookResp)
+		resp.Write(byt)
+	})
+
+	sv := &http.Server{Addr: ":8999", Handler: serverMux}
+	stop := make(chan struct{})
+	finish := make(chan struct{})
+	go func() {
+		if err := sv.ListenAndServe(); err != nil {
+			fmt.Println(err)
+		}
+		finish <- struct{}{}
+	}()
+	<-time.After(5 * time.Second)
+
+	fmt.Println("run server")
+	go func() {
+		select {
+		case <-stop:
+			sv.Close()
+		}
+	}()
+	return stop, finish, taskStartTime, taskFinishTime
+}
+
+func TestCompactRuleStates(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-rule-states",
+			Namespace: "default",
+		},
+	}
+	ruleStates := []*appsv1alpha1.RuleState{
+		{
+			Name: "webhook",
+			WebhookStatus: &appsv1alpha1.WebhookStatus{
+				TaskStates: []appsv1alpha1.TaskInfo{
+					{TaskId: "task-1", Processing: []string{"pod-1", "pod-2"}},
+					{TaskId: "task-2", Processing: []string{"pod-2", "pod-3"}},
+				},
+			},
+		},
+	}
+	fc := fake.NewClientBuilder().WithObjects(rs).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:    &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+		maxRuleStatesBytes: 1,
+	}
+
+	// large rule states are moved into companion ConfigMap
+	summaries, ref, err := r.compactRuleStates(ctx, rs, ruleStates)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ref).NotTo(gomega.BeNil())
+	g.Expect(summaries).Should(gomega.HaveLen(1))
+	g.Expect(summaries[0].WebhookStatus).Should(gomega.BeNil())
+	g.Expect(*summaries[0].Summary).Should(gomega.Equal(appsv1alpha1.RuleStateSummary{Tasks: 2, Pods: 3}))
+	cm := &corev1.ConfigMap{}
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: ref.ConfigMapName}, cm)).NotTo(gomega.HaveOccurred())
+	g.Expect(metav1.IsControlledBy(cm, rs)).Should(gomega.BeTrue())
+
+	// detailed rule states are loaded back
+	effective := rs.DeepCopy()
+	effective.Status.RuleStates = summaries
+	effective.Status.RuleStatesRef = ref
+	g.Expect(r.loadRuleStates(ctx, effective)).NotTo(gomega.HaveOccurred())
+	g.Expect(effective.Status.RuleStates).Should(gomega.Equal(ruleStates))
+
+	// companion ConfigMap is deleted once rule states fit in status
+	r.maxRuleStatesBytes = 0
+	rs.Status.RuleStatesRef = ref
+	summaries, ref, err = r.compactRuleStates(ctx, rs, ruleStates)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(ref).Should(gomega.BeNil())
+	g.Expect(summaries).Should(gomega.Equal(ruleStates))
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: ruleStatesConfigMapName(rs.Name)}, cm))).Should(gomega.BeTrue())
+}
+
+// concurrentWriteClient updates the object with mutate right before the first status update, to make it conflict
+type concurrentWriteClient struct {
+	client.Client
+	mutate func(obj client.Object)
+}
+
+func (c *concurrentWriteClient) Status() client.StatusWriter {
+	return &concurrentWriteStatusWriter{StatusWriter: c.Client.Status(), c: c}
+}
+
+type concurrentWriteStatusWriter struct {
+	client.StatusWriter
+	c *concurrentWriteClient
+}
+
+func (w *concurrentWriteStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if w.c.mutate != nil {
+		latest := obj.DeepCopyObject().(client.Object)
+		if err := w.c.Client.Get(ctx, client.ObjectKeyFromObject(obj), latest); err != nil {
+			return err
+		}
+		w.c.mutate(latest)
+		w.c.mutate = nil
+		if err := w.c.Client.Update(ctx, latest); err != nil {
+			return err
+		}
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func TestUpdateStatusOnConflict(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "podtransitionrule-conflict",
+			Namespace:  "default",
+			Generation: 1,
+		},
+	}
+	fc := &concurrentWriteClient{Client: fake.NewClientBuilder().WithObjects(rs).Build()}
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-conflict"}
+
+	// metadata changed concurrently, status is applied on the latest object
+	fc.mutate = func(obj client.Object) {
+		obj.SetLabels(map[string]string{"foo": "bar"})
+	}
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(r.updateStatus(ctx, rs, &appsv1alpha1.PodTransitionRuleStatus{ObservedGeneration: 1, Targets: []string{"pod-a"}})).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Labels).Should(gomega.HaveKeyWithValue("foo", "bar"))
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-a"}))
+
+	// spec changed concurrently, status is not applied
+	fc.mutate = func(obj client.Object) {
+		obj.SetGeneration(2)
+	}
+	err := r.updateStatus(ctx, rs, &appsv1alpha1.PodTransitionRuleStatus{ObservedGeneration: 1, Targets: []string{"pod-b"}})
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-a"}))
+}
+
+func TestCompressStatus(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-compress",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	po1 := genDefaultPod("default", "pod-test-1")
+	po1.Labels[StageLabel] = PreTrafficOffStage
+	po2 := genDefaultPod("default", "pod-test-2")
+	po2.Labels[StageLabel] = PreTrafficOffStage
+	po2.Labels["ready"] = "true"
+	fc := fake.NewClientBuilder().WithObjects(rs, po1, po2).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:            &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+		Policy:                     register.DefaultPolicy(),
+		compressStatusPodThreshold: 1,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-compress"}
+
+	// more target pods than threshold, details are compressed
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+	}
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.BeEmpty())
+	g.Expect(rs.Status.CompressedStatusRef).NotTo(gomega.BeNil())
+	details, err := podtransitionruleutils.GetDetails(ctx, fc, rs)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(details).Should(gomega.HaveLen(2))
+	g.Expect(details[0].Name).Should(gomega.Equal("pod-test-1"))
+	g.Expect(details[0].RejectInfo).Should(gomega.HaveLen(1))
+
+	// compression disabled, details are back in status
+	r.compressStatusPodThreshold = 0
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.CompressedStatusRef).Should(gomega.BeNil())
+	g.Expect(rs.Status.Details).Should(gomega.Equal(details))
+	cm := &corev1.ConfigMap{}
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: compressedStatusConfigMapName(rs.Name)}, cm))).Should(gomega.BeTrue())
+}
+
+func TestManualApprovalEvents(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-approval",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "approval",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						ManualApproval: &appsv1alpha1.ManualApprovalRule{},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-approval"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+	g.Expect(rs.Status.RuleStates[0].ApprovalStatus.Pending).Should(gomega.Equal([]string{"pod-test-1"}))
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Normal PendingApproval [approval] pods pending manual approval: pod-test-1"))
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning RuleRejected [podtransitionrule-approval] rejected by rule approval"))
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Warning RuleRejected pods newly rejected by rules: approval=1"))
+
+	// pending pods are not reported again
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(recorder.Events).Should(gomega.BeEmpty())
+
+	// approved on podtransitionrule
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	rs.Annotations = map[string]string{appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix + "/pod-test-1": "alice"}
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Normal Approved [approval] pod pod-test-1 approved by alice"))
+}
+
+func TestDisabledRule(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-disabled",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "approval",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						ManualApproval: &appsv1alpha1.ManualApprovalRule{},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-disabled"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+
+	// disabled rule does not gate pods, and its last known state is kept
+	rs.Spec.Rules[0].Disabled = true
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+	g.Expect(rs.Status.RuleStates).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.RuleStates[0].Disabled).Should(gomega.BeTrue())
+	g.Expect(rs.Status.RuleStates[0].ApprovalStatus.Pending).Should(gomega.Equal([]string{"pod-test-1"}))
+
+	// re-enabled rule gates pods again
+	rs.Spec.Rules[0].Disabled = false
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+	g.Expect(rs.Status.RuleStates[0].Disabled).Should(gomega.BeFalse())
+}
+
+func TestOneShotApprovalLabel(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-approval-label",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "approval",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						ManualApproval: &appsv1alpha1.ManualApprovalRule{ApprovalLabel: "break-glass", OneShot: true},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-approval-label"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Normal AwaitingApproval [approval] pods awaiting approval by label break-glass=true: pod-test-1"))
+
+	// label approves pods, and is removed once approval is persisted
+	rs.Labels = map[string]string{"break-glass": "true"}
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+	g.Expect(rs.Labels).ShouldNot(gomega.HaveKey("break-glass"))
+
+	// pods passed stay passed
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+}
+
+// concurrentPodWriteClient labels pod right before the first write, and counts writes
+type concurrentPodWriteClient struct {
+	client.Client
+	writes int
+}
+
+func (c *concurrentPodWriteClient) mutate(ctx context.Context, obj client.Object) error {
+	c.writes++
+	if c.writes > 1 {
+		return nil
+	}
+	latest := &corev1.Pod{}
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), latest); err != nil {
+		return err
+	}
+	latest.Labels[fmt.Sprintf("concurrent-%d", c.writes)] = "true"
+	return c.Client.Update(ctx, latest)
+}
+
+func (c *concurrentPodWriteClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.mutate(ctx, obj); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *concurrentPodWriteClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.mutate(ctx, obj); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestPodUpdateStrategy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	for strategy, expectedWrites := range map[string]int{
+		PodUpdateStrategyPatch:  1,
+		PodUpdateStrategyUpdate: 2,
+	} {
+		po := genDefaultPod("default", "pod-test-1")
+		po.Annotations = map[string]string{
+			appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/podtransitionrule-update": "{}",
+		}
+		fc := &concurrentPodWriteClient{Client: fake.NewClientBuilder().WithObjects(po).Build()}
+		r := &PodTransitionRuleReconciler{
+			ReconcilerMixin:   &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+			podUpdateStrategy: strategy,
+		}
+		_, err := r.updatePodTransitionRuleOnPod(ctx, "podtransitionrule-update", "pod-test-1", "default", podtransitionruleutils.MoveAllPodTransitionRuleInfo)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		// update conflicts with the concurrent writer and retries, while patch does not
+		g.Expect(fc.writes).Should(gomega.Equal(expectedWrites), strategy)
+		g.Expect(fc.Client.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+		g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/podtransitionrule-update"))
+		g.Expect(po.Labels).Should(gomega.HaveKey("concurrent-1"))
+	}
+}
+
+// countingWriteClient counts pod writes, and the max number of writes in flight
+type countingWriteClient struct {
+	client.Client
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	patches     int
+	updates     int
+}
+
+func (c *countingWriteClient) write(count *int) func() {
+	c.mu.Lock()
+	*count++
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	return func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}
+}
+
+func (c *countingWriteClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	defer c.write(&c.updates)()
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *countingWriteClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	defer c.write(&c.patches)()
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func TestPodWriteConcurrency(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-writes",
+			Namespace: "default",
+		},
+	}
+	var objs []client.Object
+	for i := 0; i < 20; i++ {
+		po := genDefaultPod("default", fmt.Sprintf("pod-test-%d", i))
+		po.Annotations = map[string]string{
+			appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + rs.Name: "{}",
+		}
+		objs = append(objs, po)
+		rs.Status.Targets = append(rs.Status.Targets, po.Name)
+	}
+	fc := &countingWriteClient{Client: fake.NewClientBuilder().WithObjects(objs...).Build()}
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:     &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+		podWriteConcurrency: 4,
+	}
+	g.Expect(r.cleanUpPodTransitionRulePods(ctx, rs)).NotTo(gomega.HaveOccurred())
+	// every pod is written by a single patch, by at most 4 workers
+	g.Expect(fc.patches).Should(gomega.Equal(20))
+	g.Expect(fc.updates).Should(gomega.Equal(0))
+	g.Expect(fc.maxInFlight).Should(gomega.BeNumerically(">", 1))
+	g.Expect(fc.maxInFlight).Should(gomega.BeNumerically("<=", 4))
+	for _, obj := range objs {
+		po := &corev1.Pod{}
+		g.Expect(fc.Get(ctx, client.ObjectKeyFromObject(obj), po)).NotTo(gomega.HaveOccurred())
+		g.Expect(po.Annotations).Should(gomega.BeEmpty())
+	}
+}
+
+func TestProtectedPods(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-protected",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	labeled := genDefaultPod("default", "pod-test-2")
+	labeled.Labels[appsv1alpha1.PodTransitionRuleProtectedLabelKey] = "true"
+	operator := genDefaultPod("default", "pod-test-3")
+	operator.Labels["control-plane"] = "controller-manager"
+	self := genDefaultPod("default", "controller-0")
+	fc := fake.NewClientBuilder().WithObjects(rs, po, labeled, operator, self).Build()
+	recorder := record.NewFakeRecorder(10)
+	protection, err := newPodProtection("control-plane=controller-manager")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	protection.namespace, protection.name = "default", "controller-0"
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+		podProtection:   protection,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-protected"}
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Warning ProtectedPodsExcluded 3 protected pods are excluded from targets, annotate " +
+		appsv1alpha1.AnnotationGovernProtectedPods + "=true to govern them: controller-0, pod-test-2, pod-test-3"))
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1"}))
+
+	// opt in
+	rs.Annotations = map[string]string{appsv1alpha1.AnnotationGovernProtectedPods: "true"}
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"controller-0", "pod-test-1", "pod-test-2", "pod-test-3"}))
+
+	// pods of other operators are not protected by default
+	protection, err = newPodProtection(NewOptions().ProtectedPodSelector)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(protection.isProtected(operator)).Should(gomega.BeFalse())
+	g.Expect(protection.isProtected(labeled)).Should(gomega.BeTrue())
+}
+
+func TestPendingRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-pending",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name: "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+				{
+					Name:  "annotationCheck",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						AnnotationCheck: &appsv1alpha1.AnnotationCheckRule{
+							Requirements: []appsv1alpha1.AnnotationRequirement{{Key: "checked", Operator: appsv1alpha1.AnnotationOpExists}},
+						},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-pending"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.Details[0].RejectedRules).Should(gomega.Equal([]string{"labelCheck"}))
+	g.Expect(rs.Status.Details[0].PendingRules).Should(gomega.Equal([]string{"annotationCheck"}))
+
+	// pod passes labelCheck, and is rejected by annotationCheck
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	po.Labels["ready"] = "true"
+	g.Expect(fc.Update(ctx, po)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].PassedRules).Should(gomega.Equal([]string{"labelCheck"}))
+	g.Expect(rs.Status.Details[0].RejectedRules).Should(gomega.Equal([]string{"annotationCheck"}))
+	g.Expect(rs.Status.Details[0].PendingRules).Should(gomega.BeEmpty())
+}
+
+func TestRulePriority(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var requested [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		webhookReq := &appsv1alpha1.WebhookRequest{}
+		g.Expect(json.NewDecoder(req.Body).Decode(webhookReq)).NotTo(gomega.HaveOccurred())
+		var names []string
+		for _, resource := range webhookReq.Resources {
+			names = append(names, resource.Name)
+		}
+		requested = append(requested, names)
+		http.Error(resp, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-priority",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "webhook",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						Webhook: &appsv1alpha1.TransitionRuleWebhook{
+							ClientConfig: appsv1alpha1.ClientConfigBeta1{URL: server.URL},
+						},
+					},
+				},
+				{
+					Name:     "annotationCheck",
+					Stage:    &stage,
+					Priority: -1,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						AnnotationCheck: &appsv1alpha1.AnnotationCheckRule{
+							Requirements: []appsv1alpha1.AnnotationRequirement{{Key: "checked", Operator: appsv1alpha1.AnnotationOpExists}},
+						},
+					},
+				},
+			},
+		},
+	}
+	checked := genDefaultPod("default", "pod-test-1")
+	checked.Labels[StageLabel] = PreTrafficOffStage
+	checked.Annotations = map[string]string{"checked": "true"}
+	unchecked := genDefaultPod("default", "pod-test-2")
+	unchecked.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, checked, unchecked).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-priority"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// annotationCheck of lower priority is evaluated first, and the webhook is only called with pods passing it
+	g.Expect(requested).Should(gomega.Equal([][]string{{"pod-test-1"}}))
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	var detail *appsv1alpha1.PodTransitionDetail
+	for _, d := range rs.Status.Details {
+		if d.Name == "pod-test-2" {
+			detail = d
+		}
+	}
+	g.Expect(detail).NotTo(gomega.BeNil())
+	g.Expect(detail.RejectedRules).Should(gomega.Equal([]string{"annotationCheck"}))
+	g.Expect(detail.PendingRules).Should(gomega.Equal([]string{"webhook"}))
+}
+
+func TestAuditMode(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-audit",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "annotationCheck",
+					Stage: &stage,
+					Mode:  appsv1alpha1.TransitionRuleModeAudit,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						AnnotationCheck: &appsv1alpha1.AnnotationCheckRule{
+							Requirements: []appsv1alpha1.AnnotationRequirement{{Key: "checked", Operator: appsv1alpha1.AnnotationOpExists}},
+						},
+					},
+				},
+			},
+		},
+	}
+	checked := genDefaultPod("default", "pod-test-1")
+	checked.Labels[StageLabel] = PreTrafficOffStage
+	checked.Annotations = map[string]string{"checked": "true"}
+	unchecked := genDefaultPod("default", "pod-test-2")
+	unchecked.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, checked, unchecked).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-audit"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	// the audited rejection is recorded, but does not block the pod
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	var detail *appsv1alpha1.PodTransitionDetail
+	for _, d := range rs.Status.Details {
+		if d.Name == "pod-test-2" {
+			detail = d
+		}
+	}
+	g.Expect(detail).NotTo(gomega.BeNil())
+	g.Expect(detail.Passed).Should(gomega.BeTrue())
+	g.Expect(detail.PassedRules).Should(gomega.Equal([]string{"annotationCheck"}))
+	g.Expect(detail.RejectedRules).Should(gomega.BeEmpty())
+	g.Expect(detail.RejectInfo).Should(gomega.HaveLen(1))
+	g.Expect(detail.RejectInfo[0].RuleName).Should(gomega.Equal("annotationCheck"))
+	g.Expect(detail.RejectInfo[0].Audited).Should(gomega.BeTrue())
+	g.Expect(explain(rs, detail)).Should(gomega.ContainSubstring("annotationCheck: passed, audited rejection"))
+
+	po := &corev1.Pod{}
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-2"}, po)).NotTo(gomega.HaveOccurred())
+	_, passedAnno := podDetailAnno(rs.Name, &appsv1alpha1.PodTransitionDetail{Stage: PreTrafficOffStage, Passed: true})
+	g.Expect(po.Annotations[appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix+"/"+rs.Name]).Should(gomega.Equal(passedAnno))
+	for len(recorder.Events) > 0 {
+		g.Expect(<-recorder.Events).ShouldNot(gomega.ContainSubstring(RuleRejectedReason))
+	}
+
+	// enforcing the rule blocks the pod
+	rs.Spec.Rules[0].Mode = appsv1alpha1.TransitionRuleModeEnforce
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	detail = nil
+	for _, d := range rs.Status.Details {
+		if d.Name == "pod-test-2" {
+			detail = d
+		}
+	}
+	g.Expect(detail).NotTo(gomega.BeNil())
+	g.Expect(detail.Passed).Should(gomega.BeFalse())
+	g.Expect(detail.RejectedRules).Should(gomega.Equal([]string{"annotationCheck"}))
+}
+
+// multiStagePolicy processes stages in parallel
+type multiStagePolicy struct {
+	register.Policy
+	stages []string
+}
+
+func (p *multiStagePolicy) GetStages() []string {
+	return p.stages
+}
+
+func TestProcessRuleStatesOrder(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var stages []string
+	var rules []appsv1alpha1.TransitionRule
+	for i := 0; i < 8; i++ {
+		stage := fmt.Sprintf("stage-%d", i)
+		stages = append(stages, stage)
+		rules = append(rules, appsv1alpha1.TransitionRule{
+			Name:     fmt.Sprintf("rule-%d", 7-i),
+			Stage:    &stage,
+			Disabled: true,
+			TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+				LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{}},
+			},
+		})
+	}
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "podtransitionrule-order", Namespace: "default"},
+		Spec:       appsv1alpha1.PodTransitionRuleSpec{Rules: rules},
+	}
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fake.NewClientBuilder().Build(), Logger: logr.Discard()},
+		Policy:          &multiStagePolicy{Policy: register.DefaultPolicy(), stages: stages},
+	}
+	_, _, _, ruleStates, _ := r.process(ctx, rs, map[string]*corev1.Pod{})
+	var names []string
+	for _, state := range ruleStates {
+		names = append(names, state.Name)
+	}
+	// rule states are sorted by name, regardless of the order stages finish
+	g.Expect(names).Should(gomega.Equal([]string{"rule-0", "rule-1", "rule-2", "rule-3", "rule-4", "rule-5", "rule-6", "rule-7"}))
+	current := &appsv1alpha1.PodTransitionRuleStatus{RuleStates: ruleStates}
+	for i := 0; i < 20; i++ {
+		_, _, _, ruleStates, _ = r.process(ctx, rs, map[string]*corev1.Pod{})
+		g.Expect(equalStatus(&appsv1alpha1.PodTransitionRuleStatus{RuleStates: ruleStates}, current)).Should(gomega.BeTrue())
+	}
+}
+
+func TestSerializePodWrites(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	var objs []client.Object
+	var keys []types.NamespacedName
+	for _, name := range []string{"podtransitionrule-overlap-a", "podtransitionrule-overlap-b"} {
+		objs = append(objs, &appsv1alpha1.PodTransitionRule{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: appsv1alpha1.PodTransitionRuleSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+				Rules: []appsv1alpha1.TransitionRule{
+					{
+						Name:  "labelCheck",
+						Stage: &stage,
+						TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+							LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{}},
+						},
+					},
+				},
+			},
+		})
+		keys = append(keys, types.NamespacedName{Namespace: "default", Name: name})
+	}
+	po := genDefaultPod("default", "pod-test")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	objs = append(objs, po)
+	fc := fake.NewClientBuilder().WithObjects(objs...).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:    &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:             register.DefaultPolicy(),
+		podUpdateStrategy:  PodUpdateStrategyUpdate,
+		serializePodWrites: true,
+	}
+
+	// overlapping PodTransitionRules reconciled concurrently both keep their annotations on pod
+	wg := sync.WaitGroup{}
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key types.NamespacedName) {
+			defer wg.Done()
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+		}(key)
+	}
+	wg.Wait()
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test"}, po)).NotTo(gomega.HaveOccurred())
+	for _, key := range keys {
+		g.Expect(po.Annotations).Should(gomega.HaveKey(appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + key.Name))
+	}
+	g.Expect(r.podLocks.locks).Should(gomega.BeEmpty())
+}
+
+func TestKeyedMutex(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	m := &keyedMutex{}
+	var holding, maxHolding int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := m.lock("default/pod-test")
+			defer unlock()
+			current := atomic.AddInt32(&holding, 1)
+			if current > atomic.LoadInt32(&maxHolding) {
+				atomic.StoreInt32(&maxHolding, current)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&holding, -1)
+		}()
+	}
+	wg.Wait()
+	g.Expect(maxHolding).Should(gomega.Equal(int32(1)))
+	g.Expect(m.locks).Should(gomega.BeEmpty())
+
+	// different keys do not block each other
+	unlockA := m.lock("default/pod-a")
+	unlockB := m.lock("default/pod-b")
+	g.Expect(m.locks).Should(gomega.HaveLen(2))
+	unlockA()
+	unlockB()
+	g.Expect(m.locks).Should(gomega.BeEmpty())
+}
+
+func TestExcludeAnnotation(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-exclude",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "labelCheck",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{}},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-exclude"}
+	podKey := types.NamespacedName{Namespace: "default", Name: "pod-test"}
+	detailAnno := appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + rs.Name
+	setExclude := func(val string) {
+		g.Expect(fc.Get(ctx, podKey, po)).NotTo(gomega.HaveOccurred())
+		if val == "" {
+			delete(po.Annotations, appsv1alpha1.AnnotationPodExclude)
+		} else {
+			po.Annotations[appsv1alpha1.AnnotationPodExclude] = val
+		}
+		g.Expect(fc.Update(ctx, po)).NotTo(gomega.HaveOccurred())
+	}
+
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test"}))
+	g.Expect(fc.Get(ctx, podKey, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).Should(gomega.HaveKey(detailAnno))
+
+	// pods excluded for other PodTransitionRules are still targets
+	setExclude("podtransitionrule-other")
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test"}))
+
+	// excluded pod is removed from targets and cleaned up
+	setExclude("podtransitionrule-other, podtransitionrule-exclude")
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.BeEmpty())
+	g.Expect(rs.Status.Details).Should(gomega.BeEmpty())
+	g.Expect(fc.Get(ctx, podKey, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(detailAnno))
+	var excludedEvents []string
+	for len(recorder.Events) > 0 {
+		if e := <-recorder.Events; strings.Contains(e, "PodsExcluded") {
+			excludedEvents = append(excludedEvents, e)
+		}
+	}
+	g.Expect(excludedEvents).Should(gomega.HaveLen(1))
+	g.Expect(excludedEvents[0]).Should(gomega.ContainSubstring("pod-test"))
+
+	// the event is not emitted again while the pod stays excluded
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	for len(recorder.Events) > 0 {
+		g.Expect(<-recorder.Events).ShouldNot(gomega.ContainSubstring("PodsExcluded"))
+	}
+
+	// removing the annotation brings the pod back
+	setExclude("")
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test"}))
+	g.Expect(fc.Get(ctx, podKey, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).Should(gomega.HaveKey(detailAnno))
+}
+
+func TestErrorBackoff(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:     &mixin.ReconcilerMixin{Logger: logr.Discard()},
+		errorBackoffCeiling: 10 * time.Second,
+	}
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "podtransitionrule-backoff"}}
+	other := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "podtransitionrule-other"}}
+	failed := fmt.Errorf("webhook unavailable")
+
+	// backoff doubles on each failure until the ceiling
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		result, err := r.backoffOnError(request, reconcile.Result{}, failed)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(result.RequeueAfter).Should(gomega.Equal(expected))
+	}
+	// failures are counted by object
+	result, _ := r.backoffOnError(other, reconcile.Result{}, failed)
+	g.Expect(result.RequeueAfter).Should(gomega.Equal(time.Second))
+
+	// success keeps result and resets the backoff
+	result, err := r.backoffOnError(request, reconcile.Result{RequeueAfter: time.Minute}, nil)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(result.RequeueAfter).Should(gomega.Equal(time.Minute))
+	result, _ = r.backoffOnError(request, reconcile.Result{}, failed)
+	g.Expect(result.RequeueAfter).Should(gomega.Equal(time.Second))
+
+	// errors are returned if backoff is disabled, which is the default
+	r.errorBackoffCeiling = NewOptions().ErrorBackoffCeiling
+	_, err = r.backoffOnError(request, reconcile.Result{}, failed)
+	g.Expect(err).Should(gomega.Equal(failed))
+
+	g.Expect(errorBackoff(1000, time.Hour)).Should(gomega.Equal(time.Hour))
+}
+
+// labelValueRuler is an example custom ruler, which passes pods whose label of key params has the value of value params
+type labelValueRuler struct {
+	key, value string
+}
+
+func (l *labelValueRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *rules.FilterResult {
+	passed := sets.NewString()
+	rejected := map[string]string{}
+	for podName := range subjects {
+		if targets[podName].Labels[l.key] == l.value {
+			passed.Insert(podName)
+		} else {
+			rejected[podName] = fmt.Sprintf("label %s is not %s", l.key, l.value)
+		}
+	}
+	return &rules.FilterResult{Passed: passed, Rejected: rejected}
+}
+
+func TestCustomRuler(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	RegisterRuler("labelValue", func(c client.Client, rule *appsv1alpha1.TransitionRule) rules.Ruler {
+		return &labelValueRuler{key: rule.Custom.Params["key"], value: rule.Custom.Params["value"]}
+	})
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-custom",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "custom",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						Custom: &appsv1alpha1.CustomRule{
+							Type:   "labelValue",
+							Params: map[string]string{"key": "traffic", "value": "off"},
+						},
+					},
+				},
+			},
+		},
+	}
+	passedPod := genDefaultPod("default", "pod-test-1")
+	passedPod.Labels[StageLabel] = PreTrafficOffStage
+	passedPod.Labels["traffic"] = "off"
+	rejectedPod := genDefaultPod("default", "pod-test-2")
+	rejectedPod.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, passedPod, rejectedPod).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-custom"}
+	getDetails := func() map[string]*appsv1alpha1.PodTransitionDetail {
+		g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+		details := map[string]*appsv1alpha1.PodTransitionDetail{}
+		for _, d := range rs.Status.Details {
+			details[d.Name] = d
+		}
+		return details
+	}
+
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	details := getDetails()
+	g.Expect(details).Should(gomega.HaveLen(2))
+	g.Expect(details["pod-test-1"].Passed).Should(gomega.BeTrue())
+	g.Expect(details["pod-test-1"].PassedRules).Should(gomega.Equal([]string{"custom"}))
+	g.Expect(details["pod-test-2"].Passed).Should(gomega.BeFalse())
+	g.Expect(details["pod-test-2"].RejectInfo).Should(gomega.HaveLen(1))
+	g.Expect(details["pod-test-2"].RejectInfo[0].Reason).Should(gomega.Equal("label traffic is not off"))
+
+	// pods are rejected by custom rules of unregistered types
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	rs.Spec.Rules[0].Custom.Type = "unregistered"
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	details = getDetails()
+	g.Expect(details["pod-test-1"].Passed).Should(gomega.BeFalse())
+	g.Expect(details["pod-test-1"].RejectInfo[0].Reason).Should(gomega.ContainSubstring("custom rule type unregistered is not registered"))
+	g.Expect(details["pod-test-2"].Passed).Should(gomega.BeFalse())
+}
+
+func TestLastReconcile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-last-reconcile",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "labelCheck",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{}},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:         &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:                  register.DefaultPolicy(),
+		reconcileStatusInterval: time.Hour,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-last-reconcile"}
+
+	// duration is measured from the start of reconcile
+	reconcileCtx, cost := withReconcileCost(ctx)
+	cost.start = time.Now().Add(-time.Second)
+	_, err := r.reconcile(reconcileCtx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.LastReconcileTime).NotTo(gomega.BeNil())
+	g.Expect(rs.Status.LastReconcileDurationMillis).Should(gomega.BeNumerically(">=", 1000))
+	lastReconcileTime, updateTime := rs.Status.LastReconcileTime.DeepCopy(), rs.Status.UpdateTime.DeepCopy()
+
+	// unchanged status is not written until last reconcile is stale
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.LastReconcileTime.Equal(lastReconcileTime)).Should(gomega.BeTrue())
+	g.Expect(rs.Status.LastReconcileDurationMillis).Should(gomega.BeNumerically(">=", 1000))
+
+	// stale last reconcile is refreshed, update time is kept
+	stale := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	rs.Status.LastReconcileTime = &stale
+	g.Expect(fc.Status().Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.LastReconcileTime.After(stale.Time)).Should(gomega.BeTrue())
+	g.Expect(rs.Status.LastReconcileDurationMillis).Should(gomega.BeNumerically("<", 1000))
+	g.Expect(rs.Status.UpdateTime.Equal(updateTime)).Should(gomega.BeTrue())
+}
+
+func TestRulesFrom(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared-rules", Namespace: "default"},
+		Data: map[string]string{appsv1alpha1.DefaultRulesConfigMapKey: `
+- name: labelCheck
+  stage: PreTrafficOff
+  labelCheck:
+    requires:
+      matchLabels:
+        shared: "true"
+- name: sharedCheck
+  stage: PreTrafficOff
+  labelCheck:
+    requires: {}
+`},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-rules-from",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "labelCheck",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{}},
+					},
+				},
+			},
+			RulesFrom: []appsv1alpha1.ConfigMapRulesRef{{Name: "shared-rules"}},
+		},
+	}
+	po := genDefaultPod("default", "pod-test")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, cm, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-rules-from"}
+	cmKey := types.NamespacedName{Namespace: "default", Name: "shared-rules"}
+	getDetail := func() *appsv1alpha1.PodTransitionDetail {
+		g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+		g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+		return rs.Status.Details[0]
+	}
+
+	// rules in spec win over shared rules of the same name
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	detail := getDetail()
+	g.Expect(detail.Passed).Should(gomega.BeTrue())
+	g.Expect(sets.NewString(detail.PassedRules...).List()).Should(gomega.Equal([]string{"labelCheck", "sharedCheck"}))
+
+	// changes of shared rules are reloaded, and PodTransitionRules referring to the ConfigMap are enqueued
+	g.Expect(fc.Get(ctx, cmKey, cm)).NotTo(gomega.HaveOccurred())
+	cm.Data[appsv1alpha1.DefaultRulesConfigMapKey] = `
+- name: sharedCheck
+  stage: PreTrafficOff
+  labelCheck:
+    requires:
+      matchLabels:
+        shared: "true"
+`
+	g.Expect(fc.Update(ctx, cm)).NotTo(gomega.HaveOccurred())
+	g.Expect(enqueueRulesFromPodTransitionRules(fc)(cm)).Should(gomega.Equal([]reconcile.Request{{NamespacedName: key}}))
+	predicate := &RulesFromPredicate{Reader: fc}
+	g.Expect(predicate.Update(event.UpdateEvent{ObjectOld: cm, ObjectNew: cm})).Should(gomega.BeTrue())
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}}
+	g.Expect(predicate.Create(event.CreateEvent{Object: other})).Should(gomega.BeFalse())
+	g.Expect(enqueueRulesFromPodTransitionRules(fc)(other)).Should(gomega.BeEmpty())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	detail = getDetail()
+	g.Expect(detail.Passed).Should(gomega.BeFalse())
+	g.Expect(detail.RejectInfo).Should(gomega.HaveLen(1))
+	g.Expect(detail.RejectInfo[0].RuleName).Should(gomega.Equal("sharedCheck"))
+
+	// unparsable shared rules fail the reconcile
+	cm.Data[appsv1alpha1.DefaultRulesConfigMapKey] = "- name: [sharedCheck"
+	g.Expect(fc.Update(ctx, cm)).NotTo(gomega.HaveOccurred())
+	_, err = r.reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).Should(gomega.MatchError(gomega.ContainSubstring("invalid rules in key rules of ConfigMap default/shared-rules")))
+}
+
+func TestRulesFromInvalidatesVerdicts(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	maintenance := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "test.kusionstack.io/v1",
+		"kind":       "MaintenanceMode",
+		"metadata": map[string]interface{}{
+			"name":            "maintenance",
+			"namespace":       "default",
+			"resourceVersion": "1",
+		},
+		"spec": map[string]interface{}{
+			"phase": "drain",
+		},
+	}}
+	g.Expect(resources.DefaultStore.Update(maintenance)).Should(gomega.Succeed())
+	defer resources.DefaultStore.Delete(maintenance)
+	sharedRules := func(blockingValue string) string {
+		return `
+- name: maintenance
+  stage: PreTrafficOff
+  resourceState:
+    apiVersion: test.kusionstack.io/v1
+    kind: MaintenanceMode
+    fieldPath: spec.phase
+    blockingValues: ["` + blockingValue + `"]
+`
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared-rules", Namespace: "default"},
+		Data:       map[string]string{appsv1alpha1.DefaultRulesConfigMapKey: sharedRules("drain")},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "podtransitionrule-rules-from-verdicts",
+			Namespace:  "default",
+			Generation: 1,
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+			RulesFrom: []appsv1alpha1.ConfigMapRulesRef{{Name: "shared-rules"}},
+		},
+	}
+	defer processor.ForgetVerdicts(rs.Namespace, rs.Name)
+	po := genDefaultPod("default", "pod-test")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, cm, po).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: rs.Name}
+
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+	g.Expect(rs.Status.Details[0].RejectInfo[0].RuleName).Should(gomega.Equal("maintenance"))
+
+	// editing the referenced ConfigMap does not bump generation, the cached verdict is dropped anyway
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "shared-rules"}, cm)).NotTo(gomega.HaveOccurred())
+	cm.Data[appsv1alpha1.DefaultRulesConfigMapKey] = sharedRules("upgrade")
+	g.Expect(fc.Update(ctx, cm)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Generation).Should(gomega.BeEquivalentTo(1))
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+}
+
+func TestNamespaceDefaultsCreatedLater(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	// window is open in UTC, the built-in default time zone, and closed in UTC+12
+	now := time.Now().UTC()
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-defaults",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:  "timeWindow",
+					Stage: &stage,
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						TimeWindow: &appsv1alpha1.TimeWindowRule{Daily: []appsv1alpha1.DailyWindow{{
+							Start: now.Add(-time.Hour).Format("15:04"),
+							End:   now.Add(time.Hour).Format("15:04"),
+						}}},
+					},
+				},
+			},
+		},
+	}
+	poA := genDefaultPod("default", "pod-test-a")
+	poA.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, poA).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-defaults"}
+	getDetails := func() map[string]*appsv1alpha1.PodTransitionDetail {
+		g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+		details := map[string]*appsv1alpha1.PodTransitionDetail{}
+		for _, detail := range rs.Status.Details {
+			details[detail.Name] = detail
+		}
+		return details
+	}
+
+	// built-in defaults are used without namespace defaults
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(getDetails()["pod-test-a"].Passed).Should(gomega.BeTrue())
+
+	// namespace defaults created after the PodTransitionRule apply to it, since spec is never defaulted
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: appsv1alpha1.PodTransitionRuleDefaultsConfigMap, Namespace: "default"},
+		Data:       map[string]string{podtransitionruleutils.DefaultsKeyTimeWindowTimeZone: "Etc/GMT-12"},
+	}
+	g.Expect(fc.Create(ctx, cm)).NotTo(gomega.HaveOccurred())
+	g.Expect(enqueueNamespacePodTransitionRules(fc)(cm)).Should(gomega.Equal([]reconcile.Request{{NamespacedName: key}}))
+	poB := genDefaultPod("default", "pod-test-b")
+	poB.Labels[StageLabel] = PreTrafficOffStage
+	g.Expect(fc.Create(ctx, poB)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	details := getDetails()
+	g.Expect(details["pod-test-a"].Passed).Should(gomega.BeTrue())
+	g.Expect(details["pod-test-b"].Passed).Should(gomega.BeFalse())
+	g.Expect(details["pod-test-b"].RejectInfo[0].Reason).Should(gomega.HavePrefix("[timeWindow] outside maintenance window"))
+	g.Expect(rs.Spec.Rules[0].TimeWindow.TimeZone).Should(gomega.BeEmpty())
+}
+
+func TestOptions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	g.Expect(newOptions().MaxConcurrentReconciles).Should(gomega.Equal(10))
+
+	// flags are only registered on the given flag set
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := NewOptions()
+	o.AddFlags(fs)
+	g.Expect(flag.CommandLine.Lookup("podtransitionrule-max-concurrent-reconciles")).Should(gomega.BeNil())
+	g.Expect(fs.Parse([]string{"--podtransitionrule-max-concurrent-reconciles=20", "--podtransitionrule-finalizer=test.kusionstack.io/protected"})).NotTo(gomega.HaveOccurred())
+	opts := newOptions(WithOptions(o))
+	g.Expect(opts.MaxConcurrentReconciles).Should(gomega.Equal(20))
+	g.Expect(opts.Finalizer).Should(gomega.Equal("test.kusionstack.io/protected"))
+
+	// options following override former ones
+	opts = newOptions(WithOptions(o), WithMaxConcurrentReconciles(3))
+	g.Expect(opts.MaxConcurrentReconciles).Should(gomega.Equal(3))
+	g.Expect(opts.Finalizer).Should(gomega.Equal("test.kusionstack.io/protected"))
+	g.Expect(o.MaxConcurrentReconciles).Should(gomega.Equal(20))
+
+	_, err := addToMgr(nil, nil, WithMaxConcurrentReconciles(0))
+	g.Expect(err).Should(gomega.MatchError("invalid max concurrent reconciles 0, it must be at least 1"))
+}
+
+func TestFieldSelector(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-field-selector",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			FieldSelector: "spec.nodeName=node-a,status.phase!=Failed",
+		},
+	}
+	onNode := genDefaultPod("default", "pod-test-1")
+	onNode.Spec.NodeName = "node-a"
+	failed := genDefaultPod("default", "pod-test-2")
+	failed.Spec.NodeName = "node-a"
+	failed.Status.Phase = corev1.PodFailed
+	otherNode := genDefaultPod("default", "pod-test-3")
+	otherNode.Spec.NodeName = "node-b"
+	fc := fake.NewClientBuilder().WithObjects(rs, onNode, failed, otherNode).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-field-selector"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1"}))
+
+	// unsupported field is reported in condition rather than an empty target list
+	rs.Spec.FieldSelector = "spec.hostname=node-a"
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1"}))
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleSelectorValid).Status).Should(gomega.Equal(corev1.ConditionFalse))
+}
+
+func TestSpecDryRun(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-spec-dry-run",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name: "labelCheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"ready": "true",
+								},
+							},
+						},
+					},
+				},
+			},
+			DryRun: true,
+		},
+	}
+	ready := genDefaultPod("default", "pod-test-1")
+	ready.Labels[StageLabel] = PreTrafficOffStage
+	ready.Labels["ready"] = "true"
+	notReady := genDefaultPod("default", "pod-test-2")
+	notReady.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, ready, notReady).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-spec-dry-run"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Normal DryRunEvaluated dry run evaluated, verdicts are not enforced: 1 pods would pass, 1 would be rejected"))
+
+	// verdicts are in status, but pods are not written
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Finalizers).Should(gomega.BeEmpty())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1", "pod-test-2"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(2))
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+	g.Expect(rs.Status.Details[1].Passed).Should(gomega.BeFalse())
+	for _, name := range []string{"pod-test-1", "pod-test-2"} {
+		po := &corev1.Pod{}
+		g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, po)).NotTo(gomega.HaveOccurred())
+		g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/podtransitionrule-spec-dry-run"))
+	}
+}
+
+func TestDeletedTargetPruned(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-deleted-target",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	deleted := genDefaultPod("default", "pod-test-2")
+	deleted.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po, deleted).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:          &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:                   register.DefaultPolicy(),
+		podWriteFailureThreshold: 1,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-deleted-target"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1", "pod-test-2"}))
+
+	g.Expect(fc.Delete(ctx, deleted)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1"}))
+	// deleted pod is not a write failure
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRulePodWriteConflict)).Should(gomega.BeNil())
+}
+
+func TestRuleRejectedEvents(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-rejected",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "labelcheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{MatchLabels: map[string]string{"ready": "true"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-rejected"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(recorder.Events).Should(gomega.HaveLen(2))
+	g.Expect(<-recorder.Events).Should(gomega.HavePrefix("Warning RuleRejected [podtransitionrule-rejected] rejected by rule labelcheck in stage " + PreTrafficOffStage + ": "))
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Warning RuleRejected pods newly rejected by rules: labelcheck=1"))
+
+	// identical rejections are not recorded again
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(recorder.Events).Should(gomega.BeEmpty())
+}
+
+// pagingClient lists pods in pages like API server, the continue token is the index of next pod
+type pagingClient struct {
+	client.Client
+	pages int
+}
+
+func (c *pagingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	podList, ok := list.(*corev1.PodList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	limit, cont := listOpts.Limit, listOpts.Continue
+	listOpts.Limit, listOpts.Continue = 0, ""
+	if err := c.Client.List(ctx, podList, listOpts); err != nil {
+		return err
+	}
+	sort.Slice(podList.Items, func(i, j int) bool {
+		return podList.Items[i].Name < podList.Items[j].Name
+	})
+	start := 0
+	if cont != "" {
+		start, _ = strconv.Atoi(cont)
+	}
+	end := len(podList.Items)
+	if limit > 0 && start+int(limit) < end {
+		end = start + int(limit)
+		podList.Continue = strconv.Itoa(end)
+	}
+	podList.Items = podList.Items[start:end]
+	c.pages++
+	return nil
+}
+
+// cacheClient lists pods like the informer cache, which stops at Limit and never sets Continue
+type cacheClient struct {
+	client.Client
+}
+
+func (c *cacheClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	podList, ok := list.(*corev1.PodList)
+	if !ok {
+		return c.Client.List(ctx, list, opts...)
+	}
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	limit := listOpts.Limit
+	listOpts.Limit, listOpts.Continue = 0, ""
+	if err := c.Client.List(ctx, podList, listOpts); err != nil {
+		return err
+	}
+	sort.Slice(podList.Items, func(i, j int) bool {
+		return podList.Items[i].Name < podList.Items[j].Name
+	})
+	if limit > 0 && int(limit) < len(podList.Items) {
+		podList.Items = podList.Items[:limit]
+	}
+	return nil
+}
+
+func TestPodListPaging(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-paging",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			FieldSelector: "metadata.name!=pod-test-3",
+		},
+	}
+	objs := []client.Object{rs}
+	for i := 1; i <= 5; i++ {
+		po := genDefaultPod("default", fmt.Sprintf("pod-test-%d", i))
+		po.Labels[StageLabel] = PreTrafficOffStage
+		objs = append(objs, po)
+	}
+	fc := &cacheClient{Client: fake.NewClientBuilder().WithObjects(objs...).Build()}
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+		podListPageSize: 2,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-paging"}
+
+	// pods are listed from the cache at once without API reader, otherwise pods past the page are lost
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1", "pod-test-2", "pod-test-4", "pod-test-5"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(4))
+
+	// pods are listed in pages from API reader
+	apiReader := &pagingClient{Client: fc.Client}
+	r.APIReader = apiReader
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(apiReader.pages).Should(gomega.Equal(3))
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1", "pod-test-2", "pod-test-4", "pod-test-5"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(4))
+}
+
+func TestResolveTargets(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-resolve",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			FieldSelector: "metadata.name!=pod-test-3",
+		},
+	}
+	objs := []client.Object{rs}
+	for i := 1; i <= 5; i++ {
+		objs = append(objs, genDefaultPod("default", fmt.Sprintf("pod-test-%d", i)))
+	}
+	protected := genDefaultPod("default", "pod-test-6")
+	protected.Labels[appsv1alpha1.PodTransitionRuleProtectedLabelKey] = "true"
+	objs = append(objs, protected)
+	fc := &pagingClient{Client: fake.NewClientBuilder().WithObjects(objs...).Build()}
+	protection, err := newPodProtection("")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, APIReader: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+		podListPageSize: 2,
+		podProtection:   protection,
+	}
+	pods, err := r.ResolveTargets(ctx, rs)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.pages).Should(gomega.Equal(3))
+	var names []string
+	for _, po := range pods {
+		names = append(names, po.Name)
+	}
+	g.Expect(names).Should(gomega.ConsistOf("pod-test-1", "pod-test-2", "pod-test-4", "pod-test-5"))
+	// resolving targets has no side effect
+	g.Expect(recorder.Events).ShouldNot(gomega.Receive())
+
+	rs.Spec.FieldSelector = "metadata.unknown=pod-test-3"
+	_, err = r.ResolveTargets(ctx, rs)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
+
+func TestPaused(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stage := PreTrafficOffStage
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-paused",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Stage: &stage,
+					Name:  "labelcheck",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{
+							Requires: &metav1.LabelSelector{MatchLabels: map[string]string{"ready": "true"}},
+						},
+					},
+				},
+			},
+			Paused: true,
+		},
+	}
+	po := genDefaultPod("default", "pod-test-1")
+	po.Labels[StageLabel] = PreTrafficOffStage
+	fc := fake.NewClientBuilder().WithObjects(rs, po).Build()
+	recorder := record.NewFakeRecorder(10)
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: recorder},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-paused"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Warning Paused rule evaluation is paused, all target pods pass"))
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(1))
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeTrue())
+	g.Expect(rs.Status.Details[0].RejectInfo).Should(gomega.BeEmpty())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRulePaused)).NotTo(gomega.BeNil())
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "pod-test-1"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).Should(gomega.HaveKey(appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + rs.Name))
 
-func initPodTransitionRuleManager() {
+	// resumed
+	rs.Spec.Paused = false
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Details[0].Passed).Should(gomega.BeFalse())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRulePaused)).Should(gomega.BeNil())
+	g.Expect(<-recorder.Events).Should(gomega.Equal("Normal Resumed rule evaluation is resumed"))
+}
 
-	register.UnAvailableFuncList = []register.UnAvailableFunc{func(pod *corev1.Pod) (bool, *int64) {
-		if pod.GetLabels() == nil {
-			return false, nil
-		}
-		_, ok := pod.GetLabels()[UnavailableLabel]
-		return ok, nil
-	}}
-	PodTransitionRuleManager().RegisterStage(PreTrafficOffStage, func(po client.Object) bool {
-		if po.GetLabels() == nil {
-			return false
+func TestSelectorTerms(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-terms",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			// ignored since selector terms are set
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			SelectorTerms: []metav1.LabelSelector{
+				{MatchLabels: map[string]string{"app": "a"}},
+				{MatchLabels: map[string]string{"app": "b"}},
+				{MatchLabels: map[string]string{"tier": "x"}},
+			},
+		},
+	}
+	objs := []client.Object{rs}
+	for i, podLabels := range []map[string]string{
+		{"app": "a"},
+		{"app": "b"},
+		{"app": "c"},
+		{"app": "a", "tier": "x"},
+	} {
+		po := genDefaultPod("default", fmt.Sprintf("pod-test-%d", i+1))
+		po.Labels[StageLabel] = PreTrafficOffStage
+		for k, v := range podLabels {
+			po.Labels[k] = v
 		}
-		val, ok := po.GetLabels()[StageLabel]
-		return ok && val == PreTrafficOffStage
-	})
+		objs = append(objs, po)
+	}
+	fc := &pagingClient{Client: fake.NewClientBuilder().WithObjects(objs...).Build()}
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, APIReader: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+		podListPageSize: 1,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-terms"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"pod-test-1", "pod-test-2", "pod-test-4"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(3))
 
-	PodTransitionRuleManager().RegisterCondition(DeletePodCondition, func(po client.Object) bool {
-		if po.GetLabels() == nil {
-			return false
-		}
-		val, ok := po.GetLabels()[ConditionLabel]
-		return ok && val == DeletePodCondition
-	})
+	// pods matching any term are involved
+	po := genDefaultPod("default", "pod-test-5")
+	po.Labels["tier"] = "x"
+	involved, err := involvedPodTransitionRules(fc, po, logr.Discard())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(involved).Should(gomega.HaveLen(1))
 }
 
-func setPodOnStage(po *corev1.Pod, stage string) error {
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		if err := c.Get(ctx, types.NamespacedName{Namespace: po.Namespace, Name: po.Name}, po); err != nil {
-			return err
+func TestRejectHistory(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	result := func(reason string) *processor.ProcessResult {
+		res := &processor.ProcessResult{
+			PassRules: map[string]sets.String{"pod-a": sets.NewString()},
+			Rejected:  map[string]processor.RejectInfo{},
 		}
-		po.Labels[StageLabel] = stage
-		return c.Update(ctx, po)
-	})
-}
-
-func setPodUnavailable(po *corev1.Pod) error {
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		if err := c.Get(ctx, types.NamespacedName{Namespace: po.Namespace, Name: po.Name}, po); err != nil {
-			return err
+		if reason != "" {
+			res.Rejected["pod-a"] = processor.RejectInfo{RuleName: "rule-a", Reason: reason}
 		}
-		po.Labels[UnavailableLabel] = "true"
-		return c.Update(ctx, po)
-	})
+		return res
+	}
+	var lastDetails map[string]*appsv1alpha1.PodTransitionDetail
+	reasons := func() (res []string) {
+		for _, event := range lastDetails["pod-a"].RejectHistory {
+			res = append(res, event.Reason)
+		}
+		return res
+	}
+	// rejections unchanged since last reconcile are not recorded again, rejections after passing are recorded
+	for _, reason := range []string{"r1", "r1", "r2", "", "r2", "r3", "r4"} {
+		details := map[string]*appsv1alpha1.PodTransitionDetail{}
+		updateDetail(details, result(reason), PreTrafficOffStage, lastDetails, 3)
+		lastDetails = details
+	}
+	g.Expect(reasons()).Should(gomega.Equal([]string{"r2", "r3", "r4"}))
+
+	// history is not recorded if limit is non-positive
+	details := map[string]*appsv1alpha1.PodTransitionDetail{}
+	updateDetail(details, result("r5"), PreTrafficOffStage, lastDetails, 0)
+	g.Expect(details["pod-a"].RejectHistory).Should(gomega.BeEmpty())
 }
 
-func genDefaultPod(namespace, name string) *corev1.Pod {
-	return &corev1.Pod{
+func TestNamespaceSelector(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-			Labels: map[string]string{
-				"test": "gen",
-			},
+			Name:      "podtransitionrule-namespaces",
+			Namespace: "default",
 		},
-		Spec: corev1.PodSpec{
-			Containers: []corev1.Container{
-				{
-					Name:  "nginx",
-					Image: "nginx:latest",
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"team": "a",
 				},
 			},
 		},
 	}
+	objs := []client.Object{rs}
+	for ns, team := range map[string]string{"ns-a": "a", "ns-b": "a", "ns-c": "b"} {
+		objs = append(objs, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns, Labels: map[string]string{"team": team}}})
+		po := genDefaultPod(ns, "pod-test")
+		po.Labels[StageLabel] = PreTrafficOffStage
+		objs = append(objs, po)
+	}
+	fc := fake.NewClientBuilder().WithObjects(objs...).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:          register.DefaultPolicy(),
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-namespaces"}
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"ns-a/pod-test", "ns-b/pod-test"}))
+	g.Expect(rs.Status.Details).Should(gomega.HaveLen(2))
+	detailAnno := appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix + "/" + rs.Name
+	po := &corev1.Pod{}
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "ns-b", Name: "pod-test"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).Should(gomega.HaveKey(detailAnno))
+
+	// pods in other namespaces are involved only if their namespace is selected
+	involved, err := involvedPodTransitionRules(fc, po, logr.Discard())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(involved).Should(gomega.HaveLen(1))
+	involved, err = involvedPodTransitionRules(fc, genDefaultPod("ns-c", "pod-test"), logr.Discard())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(involved).Should(gomega.BeEmpty())
+
+	// pods of unselected namespace are removed from targets
+	ns := &corev1.Namespace{}
+	g.Expect(fc.Get(ctx, types.NamespacedName{Name: "ns-b"}, ns)).NotTo(gomega.HaveOccurred())
+	ns.Labels["team"] = "b"
+	g.Expect(fc.Update(ctx, ns)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(rs.Status.Targets).Should(gomega.Equal([]string{"ns-a/pod-test"}))
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "ns-b", Name: "pod-test"}, po)).NotTo(gomega.HaveOccurred())
+	g.Expect(po.Annotations).ShouldNot(gomega.HaveKey(detailAnno))
 }
 
-func printJson(obj any) {
-	byt, _ := json.MarshalIndent(obj, "", "  ")
-	fmt.Printf("%s\n", string(byt))
+// namespaceGetCounter counts Gets of namespaces
+type namespaceGetCounter struct {
+	client.Client
+	gets int
 }
 
-func handleHttpAlwaysSuccess(resp http.ResponseWriter, req *http.Request) {
-	fmt.Println(req.URL)
-	all, err := io.ReadAll(req.Body)
-	if err != nil {
-		fmt.Println(fmt.Sprintf("read body err: %s", err))
+func (c *namespaceGetCounter) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if _, ok := obj.(*corev1.Namespace); ok {
+		c.gets++
 	}
-	webReq := &appsv1alpha1.WebhookRequest{}
-	fmt.Printf("handle http req: %s", string(all))
-	err = json.Unmarshal(all, webReq)
-	if err != nil {
-		fmt.Printf("fail to unmarshal webhook request: %v", err)
-		http.Error(resp, fmt.Sprintf("fail to unmarshal webhook request %s", string(all)), http.StatusInternalServerError)
-		return
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestInvolvedPodTransitionRules(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"test": "gen"}}
+	namespaceSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	invalid := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "test", Operator: "Unknown"}}}
+	rules := []*appsv1alpha1.PodTransitionRule{
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-a", Name: "local"},
+			Spec:       appsv1alpha1.PodTransitionRuleSpec{Selector: selector},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-a", Name: "invalid"},
+			Spec:       appsv1alpha1.PodTransitionRuleSpec{Selector: invalid},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-b", Name: "other-namespace"},
+			Spec:       appsv1alpha1.PodTransitionRuleSpec{Selector: selector},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-b", Name: "namespace-selector-1"},
+			Spec:       appsv1alpha1.PodTransitionRuleSpec{Selector: selector, NamespaceSelector: namespaceSelector},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns-c", Name: "namespace-selector-2"},
+			Spec:       appsv1alpha1.PodTransitionRuleSpec{Selector: selector, NamespaceSelector: namespaceSelector},
+		},
 	}
-	webhookResp := &appsv1alpha1.WebhookResponse{
-		Success: true,
-		Message: "test success",
+	objs := []client.Object{&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-a", Labels: map[string]string{"team": "a"}}}}
+	for _, rs := range rules {
+		objs = append(objs, rs)
 	}
-	byt, _ := json.Marshal(webhookResp)
-	resp.Write(byt)
-}
+	fc := &namespaceGetCounter{Client: fake.NewClientBuilder().WithObjects(objs...).Build()}
 
-type Server struct {
-	server *http.Server
+	// rules with invalid selector and rules in other namespaces without namespace selector are not involved
+	involved, err := involvedPodTransitionRules(fc, genDefaultPod("ns-a", "pod-test"), logr.Discard())
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	var names []string
+	for _, rs := range involved {
+		names = append(names, rs.Name)
+	}
+	g.Expect(names).Should(gomega.ConsistOf("local", "namespace-selector-1", "namespace-selector-2"))
+	// namespace is got once per event
+	g.Expect(fc.gets).Should(gomega.Equal(1))
 }
 
-func (s *Server) Run(stop <-chan struct{}) chan struct{} {
-	go func() {
-		if err := s.server.ListenAndServe(); err != nil {
-			fmt.Println(err)
-		}
-	}()
-
-	<-time.After(5 * time.Second)
-	finish := make(chan struct{})
-	fmt.Println("run server")
-	go func() {
-		select {
-		case <-stop:
-			s.server.Close()
-			finish <- struct{}{}
+func findCondition(conditions []appsv1alpha1.PodTransitionRuleCondition, conditionType appsv1alpha1.PodTransitionRuleConditionType) *appsv1alpha1.PodTransitionRuleCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
 		}
-	}()
-	return finish
+	}
+	return nil
 }
 
-func RunHttpServer(f func(http.ResponseWriter, *http.Request), port string) (chan struct{}, chan struct{}) {
-	fmt.Println("try run http server")
-	defer fmt.Println("running")
-	server := &Server{
-		server: &http.Server{
-			Addr:    fmt.Sprintf("127.0.0.1:%s", port),
-			Handler: http.HandlerFunc(f),
+func TestReadinessConditions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "podtransitionrule-readiness",
+			Namespace: "default",
+		},
+		Spec: appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"test": "gen",
+				},
+			},
 		},
 	}
-	stopped := make(chan struct{})
-	finish := server.Run(stopped)
-	return stopped, finish
-}
+	var objs []client.Object
+	objs = append(objs, rs)
+	for i := 1; i <= 3; i++ {
+		po := genDefaultPod("default", fmt.Sprintf("pod-test-%d", i))
+		po.Labels[StageLabel] = PreTrafficOffStage
+		objs = append(objs, po)
+	}
+	fc := fake.NewClientBuilder().WithObjects(objs...).Build()
+	r := &PodTransitionRuleReconciler{
+		ReconcilerMixin:          &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard(), Recorder: record.NewFakeRecorder(10)},
+		Policy:                   register.DefaultPolicy(),
+		maxPodWritesPerReconcile: 2,
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "podtransitionrule-readiness"}
 
-func RunPollingServer() (chan<- struct{}, <-chan struct{}, map[string]time.Time, map[string]time.Time) {
-	taskMap := map[string][]string{}
-	taskIdx := map[string]int{}
-	taskStartTime := map[string]time.Time{}
-	taskFinishTime := map[string]time.Time{}
-	serverMux := http.NewServeMux()
-	serverMux.HandleFunc("/first", func(resp http.ResponseWriter, req *http.Request) {
-		fmt.Println(req.URL)
-		all, err := io.ReadAll(req.Body)
-		if err != nil {
-			fmt.Println(fmt.Sprintf("read body err: %s", err))
-		}
-		webReq := &appsv1alpha1.WebhookRequest{}
-		fmt.Printf("handle http req. time %s: %s\n", time.Now().String(), string(all))
-		err = json.Unmarshal(all, webReq)
-		if err != nil {
-			fmt.Printf("fail to unmarshal webhook request: %v", err)
-			http.Error(resp, fmt.Sprintf("fail to unmarshal webhook request %s", string(all)), http.StatusInternalServerError)
-			return
-		}
-		var names []string
-		for _, resource := range webReq.Resources {
-			names = append(names, resource.Name)
-		}
-		taskID := uuid.New().String()
-		fmt.Printf("handle pods %v\n, task %s\n", names, taskID)
-		taskMap[taskID] = names
-		taskStartTime[taskID] = time.Now()
-		webhookResp := &appsv1alpha1.WebhookResponse{
-			Success: true,
-			Message: fmt.Sprintf("init task %s", taskID),
-			Poll:    true,
-			TaskId:  taskID,
-		}
-		byt, _ := json.Marshal(webhookResp)
-		resp.Write(byt)
-	})
-	serverMux.HandleFunc("/poll", func(resp http.ResponseWriter, req *http.Request) {
-		fmt.Println(req.URL)
-		taskId := req.URL.Query().Get("task-id")
+	// pods are partially synced
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleSelectorValid).Status).Should(gomega.Equal(corev1.ConditionTrue))
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleProgressing).Status).Should(gomega.Equal(corev1.ConditionTrue))
+	ready := findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleReady)
+	g.Expect(ready.Status).Should(gomega.Equal(corev1.ConditionFalse))
+	g.Expect(ready.Reason).Should(gomega.Equal("PartialSync"))
 
-		pods, ok := taskMap[taskId]
-		if !ok {
-			panic(fmt.Sprintf("taskId %s not found", taskId))
-		}
-		idx := taskIdx[taskId]
-		webhookResp := &appsv1alpha1.PollResponse{}
-		if idx == len(pods)-1 {
-			taskFinishTime[taskId] = time.Now()
-			webhookResp = &appsv1alpha1.PollResponse{
-				Success:  true,
-				Message:  "success",
-				Finished: true,
-			}
-		} else {
-			taskIdx[taskId] = idx + 1
-			webhookResp = &appsv1alpha1.PollResponse{
-				Success:       true,
-				Message:       fmt.Sprintf("test passed pods %v", pods[:idx+1]),
-				Finished:      false,
-				FinishedNames: pods[:idx+1],
-			}
-		}
-		byt, _ := json.Marshal(webhookResp)
-		resp.Write(byt)
-	})
+	// ready once all pods are synced
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleProgressing).Status).Should(gomega.Equal(corev1.ConditionFalse))
+	ready = findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleReady)
+	g.Expect(ready.Status).Should(gomega.Equal(corev1.ConditionTrue))
 
-	sv := &http.Server{Addr: ":8999", Handler: serverMux}
-	stop := make(chan struct{})
-	finish := make(chan struct{})
-	go func() {
-		if err := sv.ListenAndServe(); err != nil {
-			fmt.Println(err)
-		}
-		finish <- struct{}{}
-	}()
-	<-time.After(5 * time.Second)
+	// transition time is kept while status is unchanged
+	transitionTime := ready.LastTransitionTime
+	resourceVersion := rs.ResourceVersion
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleReady).LastTransitionTime).Should(gomega.Equal(transitionTime))
+	g.Expect(rs.ResourceVersion).Should(gomega.Equal(resourceVersion))
 
-	fmt.Println("run server")
-	go func() {
-		select {
-		case <-stop:
-			sv.Close()
-		}
-	}()
-	return stop, finish, taskStartTime, taskFinishTime
+	// invalid selector
+	rs.Spec.SelectorTerms = []metav1.LabelSelector{{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Bad"}}}}
+	g.Expect(fc.Update(ctx, rs)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, key, rs)).NotTo(gomega.HaveOccurred())
+	g.Expect(findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleSelectorValid).Status).Should(gomega.Equal(corev1.ConditionFalse))
+	ready = findCondition(rs.Status.Conditions, appsv1alpha1.PodTransitionRuleReady)
+	g.Expect(ready.Status).Should(gomega.Equal(corev1.ConditionFalse))
+	g.Expect(ready.Reason).Should(gomega.Equal("InvalidSelector"))
+	g.Expect(rs.Status.Targets).Should(gomega.HaveLen(3))
+}
+
+func TestRequeueJitter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	interval := 10 * time.Minute
+	jittered := sets.NewInt64()
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("default/podtransitionrule-%d", i)
+		d := jitterInterval(key, interval, 0.1)
+		g.Expect(d).Should(gomega.BeNumerically(">=", 9*time.Minute))
+		g.Expect(d).Should(gomega.BeNumerically("<=", 11*time.Minute))
+		// stable across reconciles
+		g.Expect(jitterInterval(key, interval, 0.1)).Should(gomega.Equal(d))
+		jittered.Insert(int64(d))
+	}
+	g.Expect(jittered.Len()).Should(gomega.BeNumerically(">", 90))
+
+	g.Expect(jitterInterval("default/podtransitionrule", interval, 0)).Should(gomega.Equal(interval))
+	g.Expect(jitterInterval("default/podtransitionrule", 0, 0.1)).Should(gomega.Equal(time.Duration(0)))
+	d := jitterInterval("default/podtransitionrule", interval, 5)
+	g.Expect(d).Should(gomega.BeNumerically(">=", 0))
+	g.Expect(d).Should(gomega.BeNumerically("<=", 2*interval))
 }
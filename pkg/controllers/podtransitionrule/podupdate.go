@@ -0,0 +1,114 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// PodUpdateStrategyPatch sends a merge patch only containing keys changed on pod
+	PodUpdateStrategyPatch = "patch"
+	// PodUpdateStrategyUpdate sends the whole pod, which conflicts with concurrent writers
+	PodUpdateStrategyUpdate = "update"
+)
+
+var podUpdateAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "podtransitionrule_pod_update_attempts_total",
+	Help: "Attempts of PodTransitionRule controller writing pods, retried attempts are counted with result conflict.",
+}, []string{"strategy", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(podUpdateAttempts)
+}
+
+func parsePodUpdateStrategy(strategy string) (string, error) {
+	switch strategy {
+	case PodUpdateStrategyPatch, PodUpdateStrategyUpdate:
+		return strategy, nil
+	}
+	return PodUpdateStrategyPatch, fmt.Errorf("unknown pod update strategy %q, use %s", strategy, PodUpdateStrategyPatch)
+}
+
+// writePod writes pod mutated from original with podUpdateStrategy
+func (r *PodTransitionRuleReconciler) writePod(ctx context.Context, original, pod *corev1.Pod) error {
+	strategy := r.podUpdateStrategy
+	var err error
+	if strategy == PodUpdateStrategyUpdate {
+		err = r.Client.Update(ctx, pod)
+	} else {
+		strategy = PodUpdateStrategyPatch
+		err = r.Client.Patch(ctx, pod, client.MergeFrom(original))
+	}
+	result := "success"
+	switch {
+	case errors.IsConflict(err):
+		result = "conflict"
+	case err != nil:
+		result = "error"
+	}
+	podUpdateAttempts.WithLabelValues(strategy, result).Inc()
+	return err
+}
+
+// writePods calls write for indexes in [0, count) by at most podWriteConcurrency workers, so that writes on lots of
+// pods are neither sequential nor bursting. All writes are called even if some fail, and the first error is returned.
+func (r *PodTransitionRuleReconciler) writePods(count int, write func(i int) error) error {
+	workers := r.podWriteConcurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > count {
+		workers = count
+	}
+	indexes := make(chan int, count)
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := write(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/fields"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/utils/inject"
+)
+
+// defaultPodChangeFields are pod fields relevant to the lifecycle of pods
+var defaultPodChangeFields = []string{
+	"metadata.labels",
+	"metadata.annotations",
+	"metadata.deletionTimestamp",
+	"spec.nodeName",
+	"status.phase",
+	"status.conditions",
+	"status.podIP",
+}
+
+type podFieldChanged func(oldPod, newPod *corev1.Pod) bool
+
+var podFieldChangedFuncs = map[string]podFieldChanged{
+	"metadata.labels": func(oldPod, newPod *corev1.Pod) bool {
+		return !equality.Semantic.DeepEqual(oldPod.Labels, newPod.Labels)
+	},
+	"metadata.annotations": func(oldPod, newPod *corev1.Pod) bool {
+		return !equality.Semantic.DeepEqual(withoutDetailAnnotations(oldPod.Annotations), withoutDetailAnnotations(newPod.Annotations))
+	},
+	"metadata.deletionTimestamp": func(oldPod, newPod *corev1.Pod) bool {
+		return (oldPod.DeletionTimestamp == nil) != (newPod.DeletionTimestamp == nil)
+	},
+	"metadata.finalizers": func(oldPod, newPod *corev1.Pod) bool {
+		return !equality.Semantic.DeepEqual(oldPod.Finalizers, newPod.Finalizers)
+	},
+	"spec.nodeName": func(oldPod, newPod *corev1.Pod) bool {
+		return oldPod.Spec.NodeName != newPod.Spec.NodeName
+	},
+	"status.phase": func(oldPod, newPod *corev1.Pod) bool {
+		return oldPod.Status.Phase != newPod.Status.Phase
+	},
+	"status.conditions": func(oldPod, newPod *corev1.Pod) bool {
+		return !equality.Semantic.DeepEqual(oldPod.Status.Conditions, newPod.Status.Conditions)
+	},
+	"status.podIP": func(oldPod, newPod *corev1.Pod) bool {
+		return oldPod.Status.PodIP != newPod.Status.PodIP
+	},
+	"status.containerStatuses": func(oldPod, newPod *corev1.Pod) bool {
+		return !equality.Semantic.DeepEqual(oldPod.Status.ContainerStatuses, newPod.Status.ContainerStatuses)
+	},
+}
+
+// withoutDetailAnnotations drops detail annotations written by PodTransitionRule controller itself
+func withoutDetailAnnotations(annotations map[string]string) map[string]string {
+	res := map[string]string{}
+	for key, val := range annotations {
+		if strings.HasPrefix(key, appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix+"/") {
+			continue
+		}
+		res[key] = val
+	}
+	return res
+}
+
+// PodChangePredicate only accepts pod updates which change any of the given fields
+type PodChangePredicate struct {
+	changed []podFieldChanged
+}
+
+// NewPodChangePredicate returns a PodChangePredicate of comma separated fields
+func NewPodChangePredicate(fields string) (*PodChangePredicate, error) {
+	p := &PodChangePredicate{}
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "metadata.annotations[") && strings.HasSuffix(field, "]") {
+			key := strings.TrimSuffix(strings.TrimPrefix(field, "metadata.annotations["), "]")
+			p.changed = append(p.changed, func(oldPod, newPod *corev1.Pod) bool {
+				return oldPod.Annotations[key] != newPod.Annotations[key]
+			})
+			continue
+		}
+		changed, ok := podFieldChangedFuncs[field]
+		if !ok {
+			return nil, fmt.Errorf("unsupported pod change field %s", field)
+		}
+		p.changed = append(p.changed, changed)
+	}
+	return p, nil
+}
+
+func (p *PodChangePredicate) Create(e event.CreateEvent) bool {
+	return true
+}
+
+func (p *PodChangePredicate) Delete(e event.DeleteEvent) bool {
+	return true
+}
+
+func (p *PodChangePredicate) Update(e event.UpdateEvent) bool {
+	oldPod, ok := e.ObjectOld.(*corev1.Pod)
+	if !ok {
+		return true
+	}
+	newPod, ok := e.ObjectNew.(*corev1.Pod)
+	if !ok {
+		return true
+	}
+	// explain and exclude requests are always accepted
+	if oldPod.Annotations[appsv1alpha1.AnnotationPodExplain] != newPod.Annotations[appsv1alpha1.AnnotationPodExplain] ||
+		oldPod.Annotations[appsv1alpha1.AnnotationPodExclude] != newPod.Annotations[appsv1alpha1.AnnotationPodExclude] {
+		return true
+	}
+	for _, changed := range p.changed {
+		if changed(oldPod, newPod) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PodChangePredicate) Generic(e event.GenericEvent) bool {
+	return true
+}
+
+// NamespaceDefaultsPredicate only accepts events of namespace defaults ConfigMap
+type NamespaceDefaultsPredicate struct {
+}
+
+func (p *NamespaceDefaultsPredicate) Create(e event.CreateEvent) bool {
+	return isNamespaceDefaults(e.Object)
+}
+
+func (p *NamespaceDefaultsPredicate) Delete(e event.DeleteEvent) bool {
+	return isNamespaceDefaults(e.Object)
+}
+
+func (p *NamespaceDefaultsPredicate) Update(e event.UpdateEvent) bool {
+	return isNamespaceDefaults(e.ObjectNew)
+}
+
+func (p *NamespaceDefaultsPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
+
+// KillSwitchPredicate only accepts events of kill switch ConfigMap
+type KillSwitchPredicate struct {
+	Namespace string
+}
+
+func (p *KillSwitchPredicate) Create(e event.CreateEvent) bool {
+	return p.isKillSwitch(e.Object)
+}
+
+func (p *KillSwitchPredicate) Delete(e event.DeleteEvent) bool {
+	return p.isKillSwitch(e.Object)
+}
+
+func (p *KillSwitchPredicate) Update(e event.UpdateEvent) bool {
+	return p.isKillSwitch(e.ObjectNew)
+}
+
+func (p *KillSwitchPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
+
+func (p *KillSwitchPredicate) isKillSwitch(obj client.Object) bool {
+	return obj != nil && obj.GetNamespace() == p.Namespace && obj.GetName() == appsv1alpha1.PodTransitionRuleKillSwitchConfigMap
+}
+
+// RulesFromPredicate only accepts events of ConfigMaps referred by rulesFrom of PodTransitionRules
+type RulesFromPredicate struct {
+	Reader client.Reader
+}
+
+func (p *RulesFromPredicate) Create(e event.CreateEvent) bool {
+	return p.isReferred(e.Object)
+}
+
+func (p *RulesFromPredicate) Delete(e event.DeleteEvent) bool {
+	return p.isReferred(e.Object)
+}
+
+func (p *RulesFromPredicate) Update(e event.UpdateEvent) bool {
+	return p.isReferred(e.ObjectNew)
+}
+
+func (p *RulesFromPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
+
+func (p *RulesFromPredicate) isReferred(obj client.Object) bool {
+	if obj == nil {
+		return false
+	}
+	podTransitionRules, err := rulesFromPodTransitionRules(p.Reader, obj)
+	// ConfigMap is accepted if referring PodTransitionRules are unknown
+	return err != nil || len(podTransitionRules) > 0
+}
+
+// rulesFromPodTransitionRules returns PodTransitionRules in the namespace of ConfigMap which refer to it by rulesFrom,
+// they are listed by index of referenced ConfigMaps.
+func rulesFromPodTransitionRules(c client.Reader, obj client.Object) ([]appsv1alpha1.PodTransitionRule, error) {
+	podTransitionRuleList := &appsv1alpha1.PodTransitionRuleList{}
+	if err := c.List(context.TODO(), podTransitionRuleList, &client.ListOptions{
+		Namespace:     obj.GetNamespace(),
+		FieldSelector: fields.OneTermEqualSelector(inject.FieldIndexRulesFromConfigMap, obj.GetName()),
+	}); err != nil {
+		return nil, err
+	}
+	var res []appsv1alpha1.PodTransitionRule
+	for _, rs := range podTransitionRuleList.Items {
+		for _, ref := range rs.Spec.RulesFrom {
+			if ref.Name == obj.GetName() {
+				res = append(res, rs)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func isNamespaceDefaults(obj client.Object) bool {
+	return obj != nil && obj.GetName() == appsv1alpha1.PodTransitionRuleDefaultsConfigMap
+}
+
+// PDBDisruptionsAllowedPredicate only accepts events changing disruptions allowed of PodDisruptionBudgets
+type PDBDisruptionsAllowedPredicate struct {
+}
+
+func (p *PDBDisruptionsAllowedPredicate) Create(e event.CreateEvent) bool {
+	return true
+}
+
+func (p *PDBDisruptionsAllowedPredicate) Delete(e event.DeleteEvent) bool {
+	return true
+}
+
+func (p *PDBDisruptionsAllowedPredicate) Update(e event.UpdateEvent) bool {
+	oldPDB, ok := e.ObjectOld.(*policyv1.PodDisruptionBudget)
+	if !ok {
+		return true
+	}
+	newPDB, ok := e.ObjectNew.(*policyv1.PodDisruptionBudget)
+	if !ok {
+		return true
+	}
+	return oldPDB.Status.DisruptionsAllowed != newPDB.Status.DisruptionsAllowed
+}
+
+func (p *PDBDisruptionsAllowedPredicate) Generic(e event.GenericEvent) bool {
+	return false
+}
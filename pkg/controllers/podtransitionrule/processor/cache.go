@@ -0,0 +1,168 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
+)
+
+var verdictCacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "podtransitionrule_rule_verdict_cache_total",
+	Help: "Lookups of cached verdicts of versioned PodTransitionRule rules by result, hit or miss.",
+}, []string{"result"})
+
+func init() {
+	metrics.Registry.MustRegister(verdictCacheLookups)
+}
+
+// verdicts caches verdicts of VersionedRulers
+var verdicts = &verdictCache{entries: map[verdictKey]map[string]*cachedVerdict{}}
+
+type verdictKey struct {
+	namespace         string
+	podTransitionRule string
+	stage             string
+	rule              string
+}
+
+type cachedVerdict struct {
+	// generation of PodTransitionRule the verdict is made on, so that spec changes invalidate it
+	generation int64
+	// ruleHash is the hash of the effective rule the verdict is made on, so that changes of rules merged from
+	// rulesFrom ConfigMaps and namespace defaults, which do not bump generation, invalidate it
+	ruleHash uint64
+	version  string
+	passed   bool
+	reason   string
+}
+
+type verdictCache struct {
+	entries map[verdictKey]map[string]*cachedVerdict
+	mu      sync.Mutex
+}
+
+// ForgetVerdicts drops cached verdicts of the PodTransitionRule
+func ForgetVerdicts(namespace, name string) {
+	verdicts.mu.Lock()
+	defer verdicts.mu.Unlock()
+	for key := range verdicts.entries {
+		if key.namespace == namespace && key.podTransitionRule == name {
+			delete(verdicts.entries, key)
+		}
+	}
+}
+
+// filter filters subjects with ruler. Subjects whose version token of VersionedRuler is unchanged get the cached
+// verdict, and only the rest are filtered by ruler. Verdicts of pods not in subjects are dropped from cache.
+func (p *Processor) filter(ruler rules.Ruler, rule *appsv1alpha1.TransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *rules.FilterResult {
+	versioned, ok := ruler.(rules.VersionedRuler)
+	if !ok {
+		return ruler.Filter(p.podTransitionRule, targets, subjects)
+	}
+	key := verdictKey{namespace: p.podTransitionRule.Namespace, podTransitionRule: p.podTransitionRule.Name, stage: p.stage, rule: rule.Name}
+	generation := p.podTransitionRule.Generation
+	ruleHash := hashRule(rule)
+	versions := versioned.Versions(p.podTransitionRule, targets, subjects)
+
+	verdicts.mu.Lock()
+	cached := verdicts.entries[key]
+	verdicts.mu.Unlock()
+
+	hits := map[string]*cachedVerdict{}
+	misses := sets.NewString()
+	for podName := range subjects {
+		entry := cached[podName]
+		if version := versions[podName]; version != "" && entry != nil && entry.version == version && entry.generation == generation && entry.ruleHash == ruleHash {
+			hits[podName] = entry
+			continue
+		}
+		misses.Insert(podName)
+	}
+	verdictCacheLookups.WithLabelValues("hit").Add(float64(len(hits)))
+	verdictCacheLookups.WithLabelValues("miss").Add(float64(misses.Len()))
+	p.V(1).Info("rule verdict cache", "podTransitionRule", p.podTransitionRule.Name, "stage", p.stage, "rule", rule.Name, "hits", len(hits), "misses", misses.Len())
+
+	var result *rules.FilterResult
+	if misses.Len() > 0 {
+		result = ruler.Filter(p.podTransitionRule, targets, misses)
+	} else {
+		result = &rules.FilterResult{Passed: sets.NewString(), Rejected: map[string]string{}, RuleState: p.lastRuleState(rule.Name)}
+	}
+	if result.Passed == nil {
+		result.Passed = sets.NewString()
+	}
+	if result.Rejected == nil {
+		result.Rejected = map[string]string{}
+	}
+
+	entries := make(map[string]*cachedVerdict, subjects.Len())
+	for podName, entry := range hits {
+		entries[podName] = entry
+		if entry.passed {
+			result.Passed.Insert(podName)
+		} else {
+			result.Rejected[podName] = entry.reason
+		}
+	}
+	// failed filters and delayed pods are not cached, they are retried anyway
+	if result.Err == nil {
+		for podName := range misses {
+			version := versions[podName]
+			if _, delayed := result.DelayUntil[podName]; version == "" || delayed {
+				continue
+			}
+			if result.Passed.Has(podName) {
+				entries[podName] = &cachedVerdict{generation: generation, ruleHash: ruleHash, version: version, passed: true}
+			} else if reason, ok := result.Rejected[podName]; ok {
+				entries[podName] = &cachedVerdict{generation: generation, ruleHash: ruleHash, version: version, reason: reason}
+			}
+		}
+	}
+
+	verdicts.mu.Lock()
+	verdicts.entries[key] = entries
+	verdicts.mu.Unlock()
+	return result
+}
+
+// hashRule returns the hash of rule
+func hashRule(rule *appsv1alpha1.TransitionRule) uint64 {
+	h := fnv.New64a()
+	data, _ := json.Marshal(rule)
+	h.Write(data)
+	return h.Sum64()
+}
+
+// lastRuleState returns RuleState of rule in status, which is kept when no pod is filtered by the rule
+func (p *Processor) lastRuleState(ruleName string) *appsv1alpha1.RuleState {
+	for _, state := range p.podTransitionRule.Status.RuleStates {
+		if state != nil && state.Name == ruleName {
+			return state.DeepCopy()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type versionedRuler struct {
+	version  string
+	pass     bool
+	filtered sets.String
+}
+
+func (r *versionedRuler) Filter(_ *appsv1alpha1.PodTransitionRule, _ map[string]*corev1.Pod, subjects sets.String) *rules.FilterResult {
+	r.filtered = sets.NewString(subjects.List()...)
+	res := &rules.FilterResult{Passed: sets.NewString(), Rejected: map[string]string{}}
+	for podName := range subjects {
+		if r.pass {
+			res.Passed.Insert(podName)
+		} else {
+			res.Rejected[podName] = "rejected"
+		}
+	}
+	return res
+}
+
+func (r *versionedRuler) Versions(_ *appsv1alpha1.PodTransitionRule, _ map[string]*corev1.Pod, subjects sets.String) map[string]string {
+	versions := map[string]string{}
+	for podName := range subjects {
+		if podName != "pod-uncached" {
+			versions[podName] = r.version
+		}
+	}
+	return versions
+}
+
+func TestVerdictCache(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rs", Generation: 1}}
+	rule := &appsv1alpha1.TransitionRule{Name: "versioned"}
+	p := NewRuleProcessor(nil, testStage, rs, logr.Discard())
+	defer ForgetVerdicts("default", "rs")
+	ruler := &versionedRuler{version: "1"}
+	subjects := sets.NewString("pod-a", "pod-uncached")
+
+	res := p.filter(ruler, rule, nil, subjects)
+	g.Expect(ruler.filtered.List()).Should(gomega.Equal([]string{"pod-a", "pod-uncached"}))
+	g.Expect(res.Rejected).Should(gomega.HaveLen(2))
+
+	// token is unchanged, cached verdict is reused even if rule would pass now
+	ruler.pass = true
+	res = p.filter(ruler, rule, nil, subjects)
+	g.Expect(ruler.filtered.List()).Should(gomega.Equal([]string{"pod-uncached"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKeyWithValue("pod-a", "rejected"))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"pod-uncached"}))
+
+	// token changes
+	ruler.version = "2"
+	res = p.filter(ruler, rule, nil, subjects)
+	g.Expect(ruler.filtered.List()).Should(gomega.Equal([]string{"pod-a", "pod-uncached"}))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"pod-a", "pod-uncached"}))
+
+	// spec changes
+	ruler.pass = false
+	rs.Generation = 2
+	res = p.filter(ruler, rule, nil, sets.NewString("pod-a"))
+	g.Expect(ruler.filtered.List()).Should(gomega.Equal([]string{"pod-a"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-a"))
+
+	// all subjects hit cache, ruler is not called
+	ruler.filtered = nil
+	res = p.filter(ruler, rule, nil, sets.NewString("pod-a"))
+	g.Expect(ruler.filtered).Should(gomega.BeNil())
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-a"))
+
+	// effective rule changes without generation change, e.g. merged from a referenced ConfigMap
+	ruler.pass = true
+	rule.LabelCheck = &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}}}
+	res = p.filter(ruler, rule, nil, sets.NewString("pod-a"))
+	g.Expect(ruler.filtered.List()).Should(gomega.Equal([]string{"pod-a"}))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"pod-a"}))
+}
+
+func TestRulesFromVerdictCache(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	maintenance := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "test.kusionstack.io/v1",
+		"kind":       "MaintenanceMode",
+		"metadata": map[string]interface{}{
+			"name":            "maintenance",
+			"namespace":       "default",
+			"resourceVersion": "1",
+		},
+		"spec": map[string]interface{}{
+			"phase": "drain",
+		},
+	}}
+	g.Expect(resources.DefaultStore.Update(maintenance)).Should(gomega.Succeed())
+	defer resources.DefaultStore.Delete(maintenance)
+	sharedRules := func(blockingValue string) string {
+		return `
+- name: maintenance
+  stage: ` + testStage + `
+  resourceState:
+    apiVersion: test.kusionstack.io/v1
+    kind: MaintenanceMode
+    fieldPath: spec.phase
+    blockingValues: ["` + blockingValue + `"]
+`
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared-rules", Namespace: "default"},
+		Data:       map[string]string{appsv1alpha1.DefaultRulesConfigMapKey: sharedRules("drain")},
+	}
+	c := fake.NewClientBuilder().WithObjects(cm).Build()
+	rs := newTestPodTransitionRule("rules-from")
+	rs.Spec.RulesFrom = []appsv1alpha1.ConfigMapRulesRef{{Name: cm.Name}}
+	targets := map[string]*corev1.Pod{"pod-a": newTestPod("pod-a", true)}
+	process := func() *ProcessResult {
+		effective := rs.DeepCopy()
+		g.Expect(utils.MergeRulesFrom(context.TODO(), c, effective)).Should(gomega.Succeed())
+		return newTestProcessor(t, effective).Process(targets)
+	}
+
+	res := process()
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-a"))
+
+	// rule of the ConfigMap changes while generation and resource are unchanged, the pod is evaluated again
+	cm.Data[appsv1alpha1.DefaultRulesConfigMapKey] = sharedRules("upgrade")
+	g.Expect(c.Update(context.TODO(), cm)).Should(gomega.Succeed())
+	res = process()
+	g.Expect(res.Rejected).Should(gomega.BeEmpty())
+	g.Expect(res.PassRules["pod-a"].List()).Should(gomega.Equal([]string{"maintenance"}))
+}
@@ -0,0 +1,71 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// cooldown admits at most one pod newly passing rule per cooldown, pods passed before are kept passed. It returns
+// pods rejected by the cooldown with reasons, the new cooldown status, and the wait until the next pod is admitted.
+func (p *Processor) cooldown(rule *appsv1alpha1.TransitionRule, passed sets.String, now time.Time) (map[string]string, *appsv1alpha1.CooldownStatus, *time.Duration) {
+	last := p.lastCooldownStatus(rule.Name)
+	status := &appsv1alpha1.CooldownStatus{LastPassTime: last.LastPassTime}
+	passedBefore := sets.NewString(last.Pods...)
+	admitted := sets.NewString()
+	var newlyPassed []string
+	for _, podName := range passed.List() {
+		if passedBefore.Has(podName) {
+			admitted.Insert(podName)
+		} else {
+			newlyPassed = append(newlyPassed, podName)
+		}
+	}
+
+	cooldown := time.Duration(rule.CooldownSeconds) * time.Second
+	rejected := map[string]string{}
+	var wait *time.Duration
+	for _, podName := range newlyPassed {
+		if status.LastPassTime == nil || !now.Before(status.LastPassTime.Add(cooldown)) {
+			admitted.Insert(podName)
+			passTime := metav1.NewTime(now)
+			status.LastPassTime = &passTime
+			continue
+		}
+		until := status.LastPassTime.Add(cooldown)
+		rejected[podName] = fmt.Sprintf("[%s] cooling down until %s", rule.Name, until.Format(time.RFC3339))
+		remaining := until.Sub(now)
+		wait = &remaining
+	}
+	status.Pods = admitted.List()
+	return rejected, status, wait
+}
+
+func (p *Processor) lastCooldownStatus(ruleName string) *appsv1alpha1.CooldownStatus {
+	for _, state := range p.podTransitionRule.Status.RuleStates {
+		if state != nil && state.Name == ruleName && state.CooldownStatus != nil {
+			return state.CooldownStatus
+		}
+	}
+	return &appsv1alpha1.CooldownStatus{}
+}
@@ -0,0 +1,60 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestCooldown(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rs"}}
+	rule := &appsv1alpha1.TransitionRule{Name: "cooldown", CooldownSeconds: 60}
+	p := NewRuleProcessor(nil, testStage, rs, logr.Discard())
+	now := time.Now()
+
+	// only the first pod is admitted
+	rejected, status, wait := p.cooldown(rule, sets.NewString("pod-a", "pod-b", "pod-c"), now)
+	g.Expect(rejected).Should(gomega.HaveLen(2))
+	g.Expect(rejected).Should(gomega.HaveKey("pod-b"))
+	g.Expect(rejected).Should(gomega.HaveKey("pod-c"))
+	g.Expect(status.Pods).Should(gomega.Equal([]string{"pod-a"}))
+	g.Expect(status.LastPassTime.Time.Equal(now)).Should(gomega.BeTrue())
+	g.Expect(*wait).Should(gomega.Equal(time.Minute))
+
+	// pods passed before are kept passed during cooldown
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{{Name: "cooldown", CooldownStatus: status}}
+	rejected, status, wait = p.cooldown(rule, sets.NewString("pod-a", "pod-b", "pod-c"), now.Add(30*time.Second))
+	g.Expect(rejected).Should(gomega.HaveLen(2))
+	g.Expect(status.Pods).Should(gomega.Equal([]string{"pod-a"}))
+	g.Expect(*wait).Should(gomega.Equal(30 * time.Second))
+
+	// the next pod is admitted once cooldown elapses, pods not passing the rule any more are dropped
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{{Name: "cooldown", CooldownStatus: status}}
+	rejected, status, _ = p.cooldown(rule, sets.NewString("pod-b", "pod-c"), now.Add(time.Minute))
+	g.Expect(rejected).Should(gomega.HaveLen(1))
+	g.Expect(rejected).Should(gomega.HaveKey("pod-c"))
+	g.Expect(status.Pods).Should(gomega.Equal([]string{"pod-b"}))
+}
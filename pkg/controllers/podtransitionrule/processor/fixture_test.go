@@ -0,0 +1,110 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
+)
+
+const (
+	testStage      = "PreTrafficOff"
+	testStageLabel = "test.kafe.io/stage"
+)
+
+// testPolicy puts pods labeled with testStageLabel in the stage of the label value
+type testPolicy struct {
+	register.Policy
+}
+
+func (p *testPolicy) Stage(obj client.Object) string {
+	return obj.GetLabels()[testStageLabel]
+}
+
+func (p *testPolicy) InStage(obj client.Object, key string) bool {
+	return p.Stage(obj) == key
+}
+
+// newTestPod returns a ready pod, which is in testStage if inStage
+func newTestPod(name string, inStage bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	if inStage {
+		pod.Labels[testStageLabel] = testStage
+	}
+	return pod
+}
+
+// withLabels adds labels to pod
+func withLabels(pod *corev1.Pod, labels map[string]string) *corev1.Pod {
+	for k, v := range labels {
+		pod.Labels[k] = v
+	}
+	return pod
+}
+
+// newTestPodTransitionRule returns a PodTransitionRule of rules in testStage
+func newTestPodTransitionRule(name string, transitionRules ...appsv1alpha1.TransitionRule) *appsv1alpha1.PodTransitionRule {
+	stage := testStage
+	for i := range transitionRules {
+		if transitionRules[i].Stage == nil {
+			transitionRules[i].Stage = &stage
+		}
+	}
+	return &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Generation: 1},
+		Spec:       appsv1alpha1.PodTransitionRuleSpec{Rules: transitionRules},
+	}
+}
+
+// newTestProcessor returns a processor of testStage, whose cached verdicts are dropped after the test
+func newTestProcessor(t *testing.T, rs *appsv1alpha1.PodTransitionRule) *Processor {
+	p := NewRuleProcessor(nil, testStage, rs, logr.Discard())
+	p.Policy = &testPolicy{Policy: register.DefaultPolicy()}
+	t.Cleanup(func() {
+		ForgetVerdicts(rs.Namespace, rs.Name)
+	})
+	return p
+}
+
+// labelCheck returns a rule requiring pods labeled key=value
+func labelCheck(name, key, value string) appsv1alpha1.TransitionRule {
+	return appsv1alpha1.TransitionRule{
+		Name: name,
+		TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+			LabelCheck: &appsv1alpha1.LabelCheckRule{
+				Requires: &metav1.LabelSelector{MatchLabels: map[string]string{key: value}},
+			},
+		},
+	}
+}
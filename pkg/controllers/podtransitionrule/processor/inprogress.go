@@ -0,0 +1,76 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	controllerutils "kusionstack.io/operating/pkg/controllers/utils"
+)
+
+// inProgress admits pods newly passing rule while pods in progress are fewer than rule.MaxInProgress, pods passed
+// before hold their slots until they complete. It returns pods rejected for exhausted slots with reasons, and the new
+// in progress status.
+func (p *Processor) inProgress(rule *appsv1alpha1.TransitionRule, targets map[string]*corev1.Pod, passed sets.String) (map[string]string, *appsv1alpha1.InProgressStatus) {
+	holding := p.holdingPods(rule.Name, targets)
+	rejected := map[string]string{}
+	for _, podName := range passed.List() {
+		if holding.Has(podName) {
+			continue
+		}
+		if holding.Len() < int(rule.MaxInProgress) {
+			holding.Insert(podName)
+			continue
+		}
+		rejected[podName] = fmt.Sprintf("[%s] operation slots exhausted, [inProgress]=%d, [maxInProgress]=%d", rule.Name, holding.Len(), rule.MaxInProgress)
+	}
+	return rejected, &appsv1alpha1.InProgressStatus{Pods: holding.List()}
+}
+
+// holdingPods returns pods holding slots of rule, which have not completed their operations
+func (p *Processor) holdingPods(ruleName string, targets map[string]*corev1.Pod) sets.String {
+	holding := sets.NewString()
+	for _, state := range p.podTransitionRule.Status.RuleStates {
+		if state == nil || state.Name != ruleName || state.InProgressStatus == nil {
+			continue
+		}
+		for _, podName := range state.InProgressStatus.Pods {
+			pod, ok := targets[podName]
+			if !ok || (p.Stage(pod) == "" && controllerutils.IsPodReady(pod)) {
+				continue
+			}
+			holding.Insert(podName)
+		}
+	}
+	return holding
+}
+
+// inProgressStates returns in progress states of rules, when no pod is in the stage to process
+func (p *Processor) inProgressStates(rules []*appsv1alpha1.TransitionRule, targets map[string]*corev1.Pod) []*appsv1alpha1.RuleState {
+	var states []*appsv1alpha1.RuleState
+	for _, rule := range rules {
+		if rule.MaxInProgress <= 0 {
+			continue
+		}
+		states = append(states, &appsv1alpha1.RuleState{Name: rule.Name, InProgressStatus: &appsv1alpha1.InProgressStatus{Pods: p.holdingPods(rule.Name, targets).List()}})
+	}
+	return states
+}
@@ -0,0 +1,78 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestInProgress(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	rs := &appsv1alpha1.PodTransitionRule{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "rs"}}
+	rule := &appsv1alpha1.TransitionRule{Name: "inProgress", MaxInProgress: 2}
+	p := NewRuleProcessor(nil, testStage, rs, logr.Discard())
+	newPod := func(name string, ready bool) *corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+		}
+	}
+	targets := map[string]*corev1.Pod{
+		"pod-a": newPod("pod-a", false),
+		"pod-b": newPod("pod-b", false),
+		"pod-c": newPod("pod-c", false),
+	}
+
+	// slots are acquired by the first pods
+	rejected, status := p.inProgress(rule, targets, sets.NewString("pod-a", "pod-b", "pod-c"))
+	g.Expect(rejected).Should(gomega.HaveLen(1))
+	g.Expect(rejected["pod-c"]).Should(gomega.ContainSubstring("operation slots exhausted"))
+	g.Expect(status.Pods).Should(gomega.Equal([]string{"pod-a", "pod-b"}))
+
+	// pods holding slots keep them until they complete, even if they do not pass the rule any more
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{{Name: "inProgress", InProgressStatus: status}}
+	rejected, status = p.inProgress(rule, targets, sets.NewString("pod-c"))
+	g.Expect(rejected).Should(gomega.HaveKey("pod-c"))
+	g.Expect(status.Pods).Should(gomega.Equal([]string{"pod-a", "pod-b"}))
+
+	// slot of completed pod is released
+	targets["pod-a"] = newPod("pod-a", true)
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{{Name: "inProgress", InProgressStatus: status}}
+	rejected, status = p.inProgress(rule, targets, sets.NewString("pod-c"))
+	g.Expect(rejected).Should(gomega.BeEmpty())
+	g.Expect(status.Pods).Should(gomega.Equal([]string{"pod-b", "pod-c"}))
+
+	// slots of pods no longer targets are released, also when no pod is in the stage
+	delete(targets, "pod-b")
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{{Name: "inProgress", InProgressStatus: status}}
+	states := p.inProgressStates([]*appsv1alpha1.TransitionRule{rule, {Name: "other"}}, targets)
+	g.Expect(states).Should(gomega.HaveLen(1))
+	g.Expect(states[0].Name).Should(gomega.Equal("inProgress"))
+	g.Expect(states[0].InProgressStatus.Pods).Should(gomega.Equal([]string{"pod-c"}))
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package processor
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"os"
 	"reflect"
@@ -34,6 +36,7 @@ import (
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/processor/rules"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/tracing"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 )
 
@@ -43,6 +46,7 @@ func NewRuleProcessor(client client.Client, stage string, podTransitionRule *app
 		stage:             stage,
 		podTransitionRule: podTransitionRule,
 		Logger:            log,
+		ctx:               context.TODO(),
 	}
 	processor.Policy = register.DefaultPolicy()
 	return processor
@@ -52,27 +56,51 @@ type Processor struct {
 	podTransitionRule *appsv1alpha1.PodTransitionRule
 	client            client.Client
 	stage             string
+	// ctx carries the trace context propagated to webhooks
+	ctx context.Context
+	// secretReader reads Secrets of webhook client certificates, nil means client is used
+	secretReader client.Reader
 	register.Policy
 	logr.Logger
 }
 
+// WithContext sets the context of processing, whose trace context is propagated to webhooks
+func (p *Processor) WithContext(ctx context.Context) *Processor {
+	p.ctx = ctx
+	return p
+}
+
+// WithSecretReader sets the reader of Secrets referred by rules, e.g. an uncached reader, so that Secrets are not cached
+func (p *Processor) WithSecretReader(reader client.Reader) *Processor {
+	p.secretReader = reader
+	return p
+}
+
 func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 	// some pods on check stage
 
 	var effectiveRules utils.Rules
+	// disabled rules are skipped, but their last known states are kept
+	var ruleStates []*appsv1alpha1.RuleState
 	for i := range p.podTransitionRule.Spec.Rules {
-		if p.podTransitionRule.Spec.Rules[i].Disabled || needSkip(&p.podTransitionRule.Spec.Rules[i]) {
+		rule := &p.podTransitionRule.Spec.Rules[i]
+		if needSkip(rule) {
 			continue
 		}
-		if p.podTransitionRule.Spec.Rules[i].Stage == nil && register.GetRuleStage(&p.podTransitionRule.Spec.Rules[i].TransitionRuleDefinition) == p.stage {
-			effectiveRules = append(effectiveRules, &p.podTransitionRule.Spec.Rules[i])
+		if rule.Stage == nil && register.GetRuleStage(&rule.TransitionRuleDefinition) != p.stage {
+			continue
 		}
-		if p.podTransitionRule.Spec.Rules[i].Stage != nil && *p.podTransitionRule.Spec.Rules[i].Stage == p.stage {
-			effectiveRules = append(effectiveRules, &p.podTransitionRule.Spec.Rules[i])
+		if rule.Stage != nil && *rule.Stage != p.stage {
+			continue
 		}
+		if rule.Disabled {
+			ruleStates = append(ruleStates, p.disabledRuleState(rule.Name))
+			continue
+		}
+		effectiveRules = append(effectiveRules, rule)
 	}
 
-	sort.Sort(effectiveRules)
+	sort.Stable(effectiveRules)
 
 	effectivePods := sets.NewString()
 	processingPods := sets.NewString()
@@ -85,18 +113,24 @@ func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 	}
 
 	if processingPods.Len() == 0 {
-		return &ProcessResult{}
+		// pods which left the stage hold slots of max in progress until they complete
+		return &ProcessResult{RuleStates: append(ruleStates, p.inProgressStates(effectiveRules, targets)...)}
 	}
 
 	passInfo := map[string]sets.String{}
+	pending := map[string]sets.String{}
 	rejected := map[string]RejectInfo{}
-	var ruleStates []*appsv1alpha1.RuleState
+	audited := map[string][]RejectInfo{}
+	delays := map[string][]appsv1alpha1.DelayInfo{}
+	lastDelays := p.lastDelays()
+	nowTime := time.Now()
 
 	minInterval := time.Duration(math.MaxInt32) * time.Second
 	retry := false
 
 	for po := range processingPods {
 		passInfo[po] = sets.NewString()
+		pending[po] = sets.NewString()
 	}
 
 	for _, rule := range effectiveRules {
@@ -105,37 +139,50 @@ func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 		if ruler == nil {
 			continue
 		}
-		// skip rule by pod anno
+		if webhook, ok := ruler.(*rules.WebhookRuler); ok {
+			webhook.Context = p.ctx
+			webhook.Headers = tracing.InjectHeaders(p.ctx, webhook.Headers)
+			if p.secretReader != nil {
+				webhook.SecretReader = p.secretReader
+			}
+		}
+		// rules after the rejecting rule are pending on rejected pods
+		for podName := range rejected {
+			if !processingPods.Has(podName) && !p.skipRule(rule, targets[podName]) {
+				pending[podName].Insert(rule.Name)
+			}
+		}
 		for _, podName := range processingPods.List() {
-			if ok, err := utils.HasSkipRule(targets[podName], rule.Name); ok {
+			if p.skipRule(rule, targets[podName]) {
 				skipPods.Insert(podName)
 				processingPods.Delete(podName)
-			} else if err != nil {
-				p.Error(err, "fail to get skip rule", "Pod", podName)
 			}
 		}
-		// filter pod with conditions
-		if len(rule.Conditions) > 0 {
-			for _, podName := range processingPods.List() {
-				if len(p.MatchConditions(targets[podName], rule.Conditions...)) == 0 {
-					skipPods.Insert(podName)
-					processingPods.Delete(podName)
-				}
+
+		// pods delayed by this rule before do not need to be checked again
+		delayPassed := sets.NewString()
+		for _, podName := range processingPods.List() {
+			delayUntil, ok := lastDelays[podName][rule.Name]
+			if !ok {
+				continue
 			}
-		}
-		// rule label match
-		if rule.Filter != nil && rule.Filter.LabelSelector != nil {
-			selector, _ := metav1.LabelSelectorAsSelector(rule.Filter.LabelSelector)
-			for _, podName := range processingPods.List() {
-				if !selector.Matches(labels.Set(targets[podName].Labels)) {
-					skipPods.Insert(podName)
-					processingPods.Delete(podName)
-				}
+			processingPods.Delete(podName)
+			delays[podName] = append(delays[podName], appsv1alpha1.DelayInfo{RuleName: rule.Name, DelayUntil: metav1.NewTime(delayUntil)})
+			if !nowTime.Before(delayUntil) {
+				delayPassed.Insert(podName)
+				passInfo[podName].Insert(rule.Name)
+				continue
+			}
+			rejected[podName] = RejectInfo{Reason: fmt.Sprintf("delayed until %s", delayUntil.Format(time.RFC3339)), RuleName: rule.Name}
+			pending[podName].Insert(rule.Name)
+			if wait := delayUntil.Sub(nowTime); wait < minInterval {
+				retry = true
+				minInterval = wait
 			}
 		}
 
 		// do rule processor
-		result := ruler.Filter(p.podTransitionRule, targets, processingPods)
+		result := p.filter(ruler, rule, targets, processingPods)
 
 		if result.RuleState != nil {
 			ruleStates = append(ruleStates, result.RuleState)
@@ -152,6 +199,68 @@ func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 			minInterval = *result.Interval
 		}
 
+		// pods rejected by rule in audit mode pass, the rejections are only recorded
+		if rule.Mode == appsv1alpha1.TransitionRuleModeAudit {
+			for podName, reason := range result.Rejected {
+				audited[podName] = append(audited[podName], RejectInfo{Reason: reason, RuleName: rule.Name})
+				result.Passed.Insert(podName)
+			}
+			result.Rejected = nil
+			result.Pending = nil
+		}
+
+		// passed pods with delay are blocked until the delay expires
+		for podName, delayUntil := range result.DelayUntil {
+			if !result.Passed.Has(podName) {
+				continue
+			}
+			delays[podName] = append(delays[podName], appsv1alpha1.DelayInfo{RuleName: rule.Name, DelayUntil: metav1.NewTime(delayUntil)})
+			if !nowTime.Before(delayUntil) {
+				continue
+			}
+			result.Passed.Delete(podName)
+			rejected[podName] = RejectInfo{Reason: fmt.Sprintf("delayed until %s", delayUntil.Format(time.RFC3339)), RuleName: rule.Name}
+			pending[podName].Insert(rule.Name)
+			if wait := delayUntil.Sub(nowTime); wait < minInterval {
+				retry = true
+				minInterval = wait
+			}
+		}
+
+		// pods passing rule with cooldown are admitted one by one
+		if rule.CooldownSeconds > 0 {
+			cooledDown, status, wait := p.cooldown(rule, result.Passed, nowTime)
+			for podName, reason := range cooledDown {
+				result.Passed.Delete(podName)
+				rejected[podName] = RejectInfo{Reason: reason, RuleName: rule.Name}
+				pending[podName].Insert(rule.Name)
+			}
+			if wait != nil && *wait < minInterval {
+				retry = true
+				minInterval = *wait
+			}
+			if result.RuleState == nil {
+				result.RuleState = &appsv1alpha1.RuleState{Name: rule.Name}
+				ruleStates = append(ruleStates, result.RuleState)
+			}
+			result.RuleState.CooldownStatus = status
+		}
+
+		// pods passing rule with max in progress are admitted while slots are free
+		if rule.MaxInProgress > 0 {
+			exhausted, status := p.inProgress(rule, targets, result.Passed)
+			for podName, reason := range exhausted {
+				result.Passed.Delete(podName)
+				rejected[podName] = RejectInfo{Reason: reason, RuleName: rule.Name}
+				pending[podName].Insert(rule.Name)
+			}
+			if result.RuleState == nil {
+				result.RuleState = &appsv1alpha1.RuleState{Name: rule.Name}
+				ruleStates = append(ruleStates, result.RuleState)
+			}
+			result.RuleState.InProgressStatus = status
+		}
+
 		for passPodName := range result.Passed {
 			passInfo[passPodName].Insert(rule.Name)
 		}
@@ -159,8 +268,13 @@ func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 		for podName, reason := range result.Rejected {
 			rejected[podName] = RejectInfo{Reason: reason, RuleName: rule.Name}
 		}
+		for podName := range result.Pending {
+			if _, ok := result.Rejected[podName]; ok {
+				pending[podName].Insert(rule.Name)
+			}
+		}
 
-		processingPods = result.Passed.Union(skipPods)
+		processingPods = result.Passed.Union(skipPods).Union(delayPassed)
 		// do not break: ensure update status
 		//if processingPods.Len() == 0 {
 		//	break
@@ -169,7 +283,10 @@ func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 
 	res := &ProcessResult{
 		Rejected:   rejected,
+		Audited:    audited,
 		PassRules:  passInfo,
+		Delays:     delays,
+		Pending:    pending,
 		Retry:      retry,
 		RuleStates: ruleStates,
 	}
@@ -182,10 +299,16 @@ func (p *Processor) Process(targets map[string]*corev1.Pod) *ProcessResult {
 
 type ProcessResult struct {
 	Rejected map[string]RejectInfo
+	// pod:rejections by rules in audit mode, which do not block the pod
+	Audited map[string][]RejectInfo
 	// pod:rules
 	PassRules map[string]sets.String
-	Retry     bool
-	Interval  *time.Duration
+	// pod:delays
+	Delays map[string][]appsv1alpha1.DelayInfo
+	// pod:rules which are not evaluated after the pod is rejected, or are awaiting external state
+	Pending  map[string]sets.String
+	Retry    bool
+	Interval *time.Duration
 
 	RuleStates []*appsv1alpha1.RuleState
 }
@@ -195,6 +318,55 @@ type RejectInfo struct {
 	Reason   string
 }
 
+// skipRule returns whether rule is skipped on pod, by pod annotation, rule conditions or rule filter
+func (p *Processor) skipRule(rule *appsv1alpha1.TransitionRule, pod *corev1.Pod) bool {
+	if ok, err := utils.HasSkipRule(pod, rule.Name); ok {
+		return true
+	} else if err != nil {
+		p.Error(err, "fail to get skip rule", "Pod", pod.Name)
+	}
+	if len(rule.Conditions) > 0 && len(p.MatchConditions(pod, rule.Conditions...)) == 0 {
+		return true
+	}
+	if rule.Filter != nil && rule.Filter.LabelSelector != nil {
+		selector, _ := metav1.LabelSelectorAsSelector(rule.Filter.LabelSelector)
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastDelays returns pod:rule:delayUntil recorded in PodTransitionRule status on current stage
+func (p *Processor) lastDelays() map[string]map[string]time.Time {
+	res := map[string]map[string]time.Time{}
+	for _, detail := range p.podTransitionRule.Status.Details {
+		if detail == nil || detail.Stage != p.stage {
+			continue
+		}
+		for _, delay := range detail.DelayInfo {
+			if res[detail.Name] == nil {
+				res[detail.Name] = map[string]time.Time{}
+			}
+			res[detail.Name][delay.RuleName] = delay.DelayUntil.Time
+		}
+	}
+	return res
+}
+
+// disabledRuleState returns the last known state of rule marked as disabled
+func (p *Processor) disabledRuleState(ruleName string) *appsv1alpha1.RuleState {
+	state := &appsv1alpha1.RuleState{Name: ruleName}
+	for _, last := range p.podTransitionRule.Status.RuleStates {
+		if last != nil && last.Name == ruleName {
+			state = last.DeepCopy()
+			break
+		}
+	}
+	state.Disabled = true
+	return state
+}
+
 const (
 	EnvSkipTransitionRules = "SKIP_POD_TRANSITION_RULES"
 )
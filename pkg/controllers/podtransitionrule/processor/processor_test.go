@@ -0,0 +1,245 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package processor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestProcess(t *testing.T) {
+	maxUnavailable := intstr.FromInt(1)
+	delayed := func(until time.Time) appsv1alpha1.PodTransitionRuleStatus {
+		return appsv1alpha1.PodTransitionRuleStatus{Details: []*appsv1alpha1.PodTransitionDetail{{
+			Name:      "pod-a",
+			Stage:     testStage,
+			DelayInfo: []appsv1alpha1.DelayInfo{{RuleName: "ready", DelayUntil: metav1.NewTime(until)}},
+		}}}
+	}
+	withPriority := func(rule appsv1alpha1.TransitionRule, priority int32) appsv1alpha1.TransitionRule {
+		rule.Priority = priority
+		return rule
+	}
+	withMode := func(rule appsv1alpha1.TransitionRule, mode appsv1alpha1.TransitionRuleMode) appsv1alpha1.TransitionRule {
+		rule.Mode = mode
+		return rule
+	}
+	disabled := func(rule appsv1alpha1.TransitionRule) appsv1alpha1.TransitionRule {
+		rule.Disabled = true
+		return rule
+	}
+	withMaxInProgress := func(rule appsv1alpha1.TransitionRule, max int32) appsv1alpha1.TransitionRule {
+		rule.MaxInProgress = max
+		return rule
+	}
+
+	cases := []struct {
+		name   string
+		rules  []appsv1alpha1.TransitionRule
+		pods   []*corev1.Pod
+		status appsv1alpha1.PodTransitionRuleStatus
+		// rejected is pod:rule rejecting the pod, pods not listed are not rejected
+		rejected map[string]string
+		// reasons is pod:substring of reject reason
+		reasons   map[string]string
+		passRules map[string][]string
+		pending   map[string][]string
+		check     func(g *gomega.WithT, res *ProcessResult)
+	}{
+		{
+			name:      "rules after rejection are pending",
+			rules:     []appsv1alpha1.TransitionRule{labelCheck("ready", "ready", "true"), labelCheck("checked", "checked", "true")},
+			pods:      []*corev1.Pod{newTestPod("pod-a", true)},
+			rejected:  map[string]string{"pod-a": "ready"},
+			passRules: map[string][]string{"pod-a": nil},
+			pending:   map[string][]string{"pod-a": {"checked"}},
+		},
+		{
+			name:      "pod passing a rule is rejected by the next",
+			rules:     []appsv1alpha1.TransitionRule{labelCheck("ready", "ready", "true"), labelCheck("checked", "checked", "true")},
+			pods:      []*corev1.Pod{withLabels(newTestPod("pod-a", true), map[string]string{"ready": "true"})},
+			rejected:  map[string]string{"pod-a": "checked"},
+			passRules: map[string][]string{"pod-a": {"ready"}},
+			pending:   map[string][]string{"pod-a": nil},
+		},
+		{
+			name:      "rules of lower priority are evaluated first",
+			rules:     []appsv1alpha1.TransitionRule{labelCheck("ready", "ready", "true"), withPriority(labelCheck("checked", "checked", "true"), -1)},
+			pods:      []*corev1.Pod{newTestPod("pod-a", true)},
+			rejected:  map[string]string{"pod-a": "checked"},
+			passRules: map[string][]string{"pod-a": nil},
+			pending:   map[string][]string{"pod-a": {"ready"}},
+		},
+		{
+			name:      "pods out of stage are not processed",
+			rules:     []appsv1alpha1.TransitionRule{labelCheck("ready", "ready", "true")},
+			pods:      []*corev1.Pod{newTestPod("pod-a", true), newTestPod("pod-b", false)},
+			rejected:  map[string]string{"pod-a": "ready"},
+			passRules: map[string][]string{"pod-a": nil},
+			check: func(g *gomega.WithT, res *ProcessResult) {
+				g.Expect(res.PassRules).ShouldNot(gomega.HaveKey("pod-b"))
+			},
+		},
+		{
+			name:      "rejections of rule in audit mode do not block pods",
+			rules:     []appsv1alpha1.TransitionRule{withMode(labelCheck("checked", "checked", "true"), appsv1alpha1.TransitionRuleModeAudit)},
+			pods:      []*corev1.Pod{newTestPod("pod-a", true)},
+			passRules: map[string][]string{"pod-a": {"checked"}},
+			check: func(g *gomega.WithT, res *ProcessResult) {
+				g.Expect(res.Audited["pod-a"]).Should(gomega.HaveLen(1))
+				g.Expect(res.Audited["pod-a"][0].RuleName).Should(gomega.Equal("checked"))
+			},
+		},
+		{
+			name:  "disabled rule does not gate pods and keeps its last state",
+			rules: []appsv1alpha1.TransitionRule{disabled(labelCheck("ready", "ready", "true"))},
+			pods:  []*corev1.Pod{newTestPod("pod-a", true)},
+			status: appsv1alpha1.PodTransitionRuleStatus{RuleStates: []*appsv1alpha1.RuleState{
+				{Name: "ready", ApprovalStatus: &appsv1alpha1.ApprovalStatus{Pending: []string{"pod-a"}}},
+			}},
+			passRules: map[string][]string{"pod-a": nil},
+			check: func(g *gomega.WithT, res *ProcessResult) {
+				g.Expect(res.RuleStates).Should(gomega.HaveLen(1))
+				g.Expect(res.RuleStates[0].Disabled).Should(gomega.BeTrue())
+				g.Expect(res.RuleStates[0].ApprovalStatus.Pending).Should(gomega.Equal([]string{"pod-a"}))
+			},
+		},
+		{
+			name:      "delayed pod is rejected until the delay expires",
+			rules:     []appsv1alpha1.TransitionRule{labelCheck("ready", "ready", "true")},
+			pods:      []*corev1.Pod{withLabels(newTestPod("pod-a", true), map[string]string{"ready": "true"})},
+			status:    delayed(time.Now().Add(time.Minute)),
+			rejected:  map[string]string{"pod-a": "ready"},
+			reasons:   map[string]string{"pod-a": "delayed until"},
+			passRules: map[string][]string{"pod-a": nil},
+			pending:   map[string][]string{"pod-a": {"ready"}},
+			check: func(g *gomega.WithT, res *ProcessResult) {
+				g.Expect(res.Delays["pod-a"]).Should(gomega.HaveLen(1))
+				g.Expect(res.Retry).Should(gomega.BeTrue())
+				g.Expect(*res.Interval).Should(gomega.BeNumerically("<=", time.Minute))
+			},
+		},
+		{
+			name: "delayed pod passes once the delay expires, without evaluating the rule again",
+			// the pod does not pass the rule any more, the expired delay lets it pass
+			rules:     []appsv1alpha1.TransitionRule{labelCheck("ready", "ready", "true")},
+			pods:      []*corev1.Pod{newTestPod("pod-a", true)},
+			status:    delayed(time.Now().Add(-time.Minute)),
+			passRules: map[string][]string{"pod-a": {"ready"}},
+			check: func(g *gomega.WithT, res *ProcessResult) {
+				g.Expect(res.Delays["pod-a"]).Should(gomega.HaveLen(1))
+			},
+		},
+		{
+			name: "available rule admits pods up to max unavailable",
+			rules: []appsv1alpha1.TransitionRule{{
+				Name: "available",
+				TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+					AvailablePolicy: &appsv1alpha1.AvailableRule{MaxUnavailableValue: &maxUnavailable},
+				},
+			}},
+			pods:      []*corev1.Pod{newTestPod("pod-a", true), newTestPod("pod-b", true)},
+			rejected:  map[string]string{"pod-b": "available"},
+			passRules: map[string][]string{"pod-a": {"available"}, "pod-b": nil},
+		},
+		{
+			name: "available rule counts pods passed before as unavailable",
+			rules: []appsv1alpha1.TransitionRule{{
+				Name: "available",
+				TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+					AvailablePolicy: &appsv1alpha1.AvailableRule{MaxUnavailableValue: &maxUnavailable},
+				},
+			}},
+			pods: []*corev1.Pod{newTestPod("pod-a", true), newTestPod("pod-b", true)},
+			status: appsv1alpha1.PodTransitionRuleStatus{Details: []*appsv1alpha1.PodTransitionDetail{
+				{Name: "pod-b", Stage: testStage, PassedRules: []string{"available"}},
+			}},
+			rejected:  map[string]string{"pod-a": "available"},
+			passRules: map[string][]string{"pod-a": nil, "pod-b": {"available"}},
+		},
+		{
+			name:      "max in progress admits pods while slots are free",
+			rules:     []appsv1alpha1.TransitionRule{withMaxInProgress(labelCheck("ready", "ready", "true"), 1)},
+			pods:      []*corev1.Pod{withLabels(newTestPod("pod-a", true), map[string]string{"ready": "true"}), withLabels(newTestPod("pod-b", true), map[string]string{"ready": "true"})},
+			rejected:  map[string]string{"pod-b": "ready"},
+			reasons:   map[string]string{"pod-b": "operation slots exhausted"},
+			passRules: map[string][]string{"pod-a": {"ready"}, "pod-b": nil},
+			pending:   map[string][]string{"pod-b": {"ready"}},
+			check: func(g *gomega.WithT, res *ProcessResult) {
+				g.Expect(res.RuleStates).Should(gomega.HaveLen(1))
+				g.Expect(res.RuleStates[0].InProgressStatus.Pods).Should(gomega.Equal([]string{"pod-a"}))
+			},
+		},
+		{
+			name:  "max in progress keeps slots of pods holding them",
+			rules: []appsv1alpha1.TransitionRule{withMaxInProgress(labelCheck("ready", "ready", "true"), 1)},
+			pods:  []*corev1.Pod{withLabels(newTestPod("pod-a", true), map[string]string{"ready": "true"}), withLabels(newTestPod("pod-b", true), map[string]string{"ready": "true"})},
+			status: appsv1alpha1.PodTransitionRuleStatus{RuleStates: []*appsv1alpha1.RuleState{
+				{Name: "ready", InProgressStatus: &appsv1alpha1.InProgressStatus{Pods: []string{"pod-b"}}},
+			}},
+			rejected:  map[string]string{"pod-a": "ready"},
+			reasons:   map[string]string{"pod-a": "operation slots exhausted"},
+			passRules: map[string][]string{"pod-a": nil, "pod-b": {"ready"}},
+		},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			rs := newTestPodTransitionRule(fmt.Sprintf("process-%d", i), tc.rules...)
+			rs.Status = tc.status
+			targets := map[string]*corev1.Pod{}
+			for _, pod := range tc.pods {
+				targets[pod.Name] = pod
+			}
+			res := newTestProcessor(t, rs).Process(targets)
+
+			rejected := map[string]string{}
+			for podName, info := range res.Rejected {
+				rejected[podName] = info.RuleName
+			}
+			g.Expect(rejected).Should(gomega.Equal(nonNil(tc.rejected)))
+			for podName, reason := range tc.reasons {
+				g.Expect(res.Rejected[podName].Reason).Should(gomega.ContainSubstring(reason))
+			}
+			for podName, ruleNames := range tc.passRules {
+				g.Expect(res.PassRules).Should(gomega.HaveKey(podName))
+				g.Expect(res.PassRules[podName].List()).Should(gomega.ConsistOf(ruleNames), podName)
+			}
+			for podName, ruleNames := range tc.pending {
+				g.Expect(res.Pending[podName].List()).Should(gomega.ConsistOf(ruleNames), podName)
+			}
+			if tc.check != nil {
+				tc.check(g, res)
+			}
+		})
+	}
+}
+
+func nonNil(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
@@ -0,0 +1,167 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	utilshttp "kusionstack.io/operating/pkg/utils/http"
+)
+
+// defaultAlertCheckInterval is the interval to query alerts again while pods are blocked by firing alerts
+const defaultAlertCheckInterval = 30 * time.Second
+
+type AlertCheckRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.AlertCheckRule
+}
+
+// alert is an active alert of either Alertmanager or Prometheus
+type alert struct {
+	Labels map[string]string `json:"labels"`
+	// State is the state of Prometheus alerts, pending or firing
+	State string `json:"state,omitempty"`
+	// Status is the status of Alertmanager alerts
+	Status *struct {
+		State string `json:"state"`
+	} `json:"status,omitempty"`
+}
+
+func (a *alert) firing() bool {
+	if a.Status != nil {
+		return a.Status.State == "active"
+	}
+	return a.State == "" || a.State == "firing"
+}
+
+// Filter rejects pods while firing alerts match labels of them
+func (r *AlertCheckRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+	for _, podName := range subjects.List() {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+		}
+	}
+	if pass.Len() == subjects.Len() {
+		return &FilterResult{Passed: pass, Rejected: rejects}
+	}
+
+	countWebhookCall(podTransitionRule.Namespace + "/" + podTransitionRule.Name)
+	alerts, err := r.queryAlerts()
+	if err != nil {
+		res := rejectAllWithErr(subjects, pass, rejects, "[%s] fail to query alerts from %s, error: %v", r.Name, r.Rule.URL, err)
+		res.Interval = r.interval()
+		return res
+	}
+	for _, podName := range subjects.List() {
+		if pass.Has(podName) {
+			continue
+		}
+		firing, err := r.firingAlerts(targets[podName], alerts)
+		if err != nil {
+			rejects[podName] = fmt.Sprintf("[%s] fail to match alerts of pod, error: %v", r.Name, err)
+			continue
+		}
+		if len(firing) == 0 {
+			pass.Insert(podName)
+			continue
+		}
+		rejects[podName] = fmt.Sprintf("[%s] blocked by firing alerts: [alerts]=%s", r.Name, strings.Join(firing, ","))
+	}
+	res := &FilterResult{Passed: pass, Rejected: rejects}
+	if len(rejects) > 0 {
+		res.Interval = r.interval()
+	}
+	return res
+}
+
+func (r *AlertCheckRuler) interval() *time.Duration {
+	interval := defaultAlertCheckInterval
+	if r.Rule.IntervalSeconds != nil && *r.Rule.IntervalSeconds > 0 {
+		interval = time.Duration(*r.Rule.IntervalSeconds) * time.Second
+	}
+	return &interval
+}
+
+// queryAlerts returns active alerts from Alertmanager, whose response is a list, or Prometheus, whose alerts are in data
+func (r *AlertCheckRuler) queryAlerts() ([]alert, error) {
+	httpResp, err := utilshttp.DoHttpAndHttpsRequestWithCa(http.MethodGet, r.Rule.URL, nil, nil, r.Rule.CABundle)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := utilshttp.ParseResponse(httpResp, &raw); err != nil {
+		return nil, err
+	}
+	var alerts []alert
+	if err := json.Unmarshal(raw, &alerts); err == nil {
+		return alerts, nil
+	}
+	prometheusResp := &struct {
+		Data struct {
+			Alerts []alert `json:"alerts"`
+		} `json:"data"`
+	}{}
+	if err := json.Unmarshal(raw, prometheusResp); err != nil {
+		return nil, fmt.Errorf("unexpected alerts response: %v", err)
+	}
+	return prometheusResp.Data.Alerts, nil
+}
+
+// firingAlerts returns sorted names of firing alerts matching labels of pod
+func (r *AlertCheckRuler) firingAlerts(pod *corev1.Pod, alerts []alert) ([]string, error) {
+	matchers := make(map[string]string, len(r.Rule.Matchers))
+	for i := range r.Rule.Matchers {
+		matcher := &r.Rule.Matchers[i]
+		value := matcher.Value
+		if value == "" && matcher.ValueFrom != nil && matcher.ValueFrom.FieldRef != nil {
+			var err error
+			if value, err = ExtractValueFromPod(pod, matcher.Key, matcher.ValueFrom.FieldRef.FieldPath); err != nil {
+				return nil, err
+			}
+		}
+		matchers[matcher.Key] = value
+	}
+	names := sets.NewString()
+	for i := range alerts {
+		if !alerts[i].firing() || !matchLabels(alerts[i].Labels, matchers) {
+			continue
+		}
+		names.Insert(alerts[i].Labels["alertname"])
+	}
+	return names.List(), nil
+}
+
+func matchLabels(labels, matchers map[string]string) bool {
+	for key, value := range matchers {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestAlertCheck(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	alerts := `[
+		{"labels": {"alertname": "SLOBurn", "app": "test-app"}, "status": {"state": "active"}},
+		{"labels": {"alertname": "HighLatency", "app": "test-app"}, "status": {"state": "suppressed"}},
+		{"labels": {"alertname": "DiskFull", "app": "other-app"}, "status": {"state": "active"}}
+	]`
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(resp, alerts)
+	}))
+	defer server.Close()
+
+	other := (&podTemplate{Name: "test-pod-b"}).GetPod()
+	other.Labels["app"] = "idle-app"
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a"}).GetPod(),
+		"test-pod-b": other,
+	}
+	ruler := &AlertCheckRuler{
+		Name: "alert",
+		Rule: &appsv1alpha1.AlertCheckRule{
+			URL: server.URL,
+			Matchers: []appsv1alpha1.Parameter{
+				{Key: "app", ValueFrom: &appsv1alpha1.ParameterSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['app']"}}},
+			},
+		},
+	}
+	res := ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("test-pod-a", "test-pod-b"))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b"}))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[alert] blocked by firing alerts: [alerts]=SLOBurn"))
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+	g.Expect(*res.Interval).Should(gomega.Equal(defaultAlertCheckInterval))
+
+	// prometheus alerts
+	alerts = `{"status": "success", "data": {"alerts": [
+		{"labels": {"alertname": "SLOBurn", "app": "test-app"}, "state": "pending"},
+		{"labels": {"alertname": "HighLatency", "app": "test-app"}, "state": "firing"}
+	]}}`
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[alert] blocked by firing alerts: [alerts]=HighLatency"))
+
+	// resolved
+	alerts = `[]`
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+	g.Expect(res.Interval).Should(gomega.BeNil())
+
+	// pods are blocked if alerts fail to be queried
+	server.Close()
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+}
@@ -0,0 +1,102 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+type AnnotationCheckRuler struct {
+	Name string
+	Rule *appsv1alpha1.AnnotationCheckRule
+}
+
+// Filter passes pods whose annotations meet all requirements, or the opposite if the rule is blocking
+func (r *AnnotationCheckRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	passed := sets.NewString()
+	rejected := map[string]string{}
+	regexps := map[int]*regexp.Regexp{}
+	for i, req := range r.Rule.Requirements {
+		if req.Operator != appsv1alpha1.AnnotationOpRegex {
+			continue
+		}
+		re, err := regexp.Compile(req.Value)
+		if err != nil {
+			return rejectAllWithErr(subjects, passed, rejected, "[%s] fail to compile regex %s of annotation %s: %v", r.Name, req.Value, req.Key, err)
+		}
+		regexps[i] = re
+	}
+
+	for podName := range subjects {
+		pod := targets[podName]
+		unmet := r.unmetRequirement(pod, regexps)
+		switch {
+		case !r.Rule.Blocking && unmet != nil:
+			rejected[podName] = fmt.Sprintf("[%s] blocked by annotation check: [key]=%s, [operator]=%s, [expected]=%s, [value]=%s", r.Name, unmet.Key, unmet.Operator, unmet.Value, annotationValue(pod, unmet.Key))
+		case r.Rule.Blocking && unmet == nil:
+			rejected[podName] = fmt.Sprintf("[%s] blocked by annotation check: all requirements are met, %s", r.Name, r.annotationValues(pod))
+		default:
+			passed.Insert(podName)
+		}
+	}
+	return &FilterResult{Passed: passed, Rejected: rejected}
+}
+
+// unmetRequirement returns the first requirement not met by pod, nil if all are met
+func (r *AnnotationCheckRuler) unmetRequirement(pod *corev1.Pod, regexps map[int]*regexp.Regexp) *appsv1alpha1.AnnotationRequirement {
+	for i, req := range r.Rule.Requirements {
+		val, ok := pod.Annotations[req.Key]
+		var met bool
+		switch req.Operator {
+		case appsv1alpha1.AnnotationOpExists:
+			met = ok
+		case appsv1alpha1.AnnotationOpEquals:
+			met = ok && val == req.Value
+		case appsv1alpha1.AnnotationOpRegex:
+			met = ok && regexps[i].MatchString(val)
+		}
+		if !met {
+			return &r.Rule.Requirements[i]
+		}
+	}
+	return nil
+}
+
+func (r *AnnotationCheckRuler) annotationValues(pod *corev1.Pod) string {
+	var values string
+	for i, req := range r.Rule.Requirements {
+		if i > 0 {
+			values += ", "
+		}
+		values += fmt.Sprintf("[%s]=%s", req.Key, annotationValue(pod, req.Key))
+	}
+	return values
+}
+
+func annotationValue(pod *corev1.Pod, key string) string {
+	val, ok := pod.Annotations[key]
+	if !ok {
+		return "<none>"
+	}
+	return val
+}
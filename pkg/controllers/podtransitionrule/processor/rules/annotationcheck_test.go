@@ -0,0 +1,79 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestAnnotationCheck(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	withWave := func(name, wave string) *corev1.Pod {
+		pod := (&podTemplate{Name: name}).GetPod()
+		if wave != "" {
+			pod.Annotations["pipeline.io/wave"] = wave
+		}
+		return pod
+	}
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": withWave("test-pod-a", "wave-1"),
+		"test-pod-b": withWave("test-pod-b", "wave-2"),
+		"test-pod-c": withWave("test-pod-c", ""),
+	}
+	subjects := sets.NewString("test-pod-a", "test-pod-b", "test-pod-c")
+	rs := &appsv1alpha1.PodTransitionRule{}
+	ruler := &AnnotationCheckRuler{
+		Name: "wave",
+		Rule: &appsv1alpha1.AnnotationCheckRule{
+			Requirements: []appsv1alpha1.AnnotationRequirement{
+				{Key: "pipeline.io/wave", Operator: appsv1alpha1.AnnotationOpExists},
+				{Key: "pipeline.io/wave", Operator: appsv1alpha1.AnnotationOpRegex, Value: "^wave-[1]$"},
+			},
+		},
+	}
+	res := ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a"}))
+	g.Expect(res.Rejected["test-pod-b"]).Should(gomega.Equal("[wave] blocked by annotation check: [key]=pipeline.io/wave, [operator]=Regex, [expected]=^wave-[1]$, [value]=wave-2"))
+	g.Expect(res.Rejected["test-pod-c"]).Should(gomega.Equal("[wave] blocked by annotation check: [key]=pipeline.io/wave, [operator]=Exists, [expected]=, [value]=<none>"))
+
+	// blocking rule blocks pods meeting all requirements
+	ruler.Rule = &appsv1alpha1.AnnotationCheckRule{
+		Requirements: []appsv1alpha1.AnnotationRequirement{
+			{Key: "pipeline.io/wave", Operator: appsv1alpha1.AnnotationOpEquals, Value: "wave-2"},
+		},
+		Blocking: true,
+	}
+	res = ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a", "test-pod-c"}))
+	g.Expect(res.Rejected["test-pod-b"]).Should(gomega.Equal("[wave] blocked by annotation check: all requirements are met, [pipeline.io/wave]=wave-2"))
+
+	// invalid regex rejects all
+	ruler.Rule = &appsv1alpha1.AnnotationCheckRule{
+		Requirements: []appsv1alpha1.AnnotationRequirement{
+			{Key: "pipeline.io/wave", Operator: appsv1alpha1.AnnotationOpRegex, Value: "("},
+		},
+	}
+	res = ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(res.Rejected).Should(gomega.HaveLen(3))
+}
@@ -0,0 +1,96 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type ManualApprovalRuler struct {
+	Name string
+	Rule *appsv1alpha1.ManualApprovalRule
+}
+
+// Filter rejects pods until they are approved by approval annotations, or by approval label if it is set
+func (r *ManualApprovalRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	approvers := map[string]string{}
+	for _, state := range podTransitionRule.Status.RuleStates {
+		if state.Name == r.Name && state.ApprovalStatus != nil {
+			for _, pod := range state.ApprovalStatus.Approved {
+				approvers[pod.Name] = pod.Approver
+			}
+		}
+	}
+
+	approvalStatus := &appsv1alpha1.ApprovalStatus{}
+	for _, podName := range subjects.List() {
+		approver := r.approver(targets[podName], podTransitionRule)
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			// keep approver of pods already passed
+			if approver == "" {
+				approver = approvers[podName]
+			}
+			if approver != "" {
+				approvalStatus.Approved = append(approvalStatus.Approved, appsv1alpha1.ApprovedPod{Name: podName, Approver: approver})
+			}
+			pass.Insert(podName)
+			continue
+		}
+		if approver == "" && r.labelApproval() {
+			approvalStatus.Pending = append(approvalStatus.Pending, podName)
+			rejects[podName] = fmt.Sprintf("[%s] awaiting approval, approve by label %s=true on podtransitionrule", r.Name, r.Rule.ApprovalLabel)
+			continue
+		}
+		if approver == "" {
+			approvalStatus.Pending = append(approvalStatus.Pending, podName)
+			rejects[podName] = fmt.Sprintf("[%s] pending manual approval, approve by annotation %s/%s on pod or %s/%s on podtransitionrule",
+				r.Name, appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix, podTransitionRule.Name, appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix, podName)
+			continue
+		}
+		approvalStatus.Approved = append(approvalStatus.Approved, appsv1alpha1.ApprovedPod{Name: podName, Approver: approver})
+		pass.Insert(podName)
+	}
+	return &FilterResult{
+		Passed:    pass,
+		Rejected:  rejects,
+		RuleState: &appsv1alpha1.RuleState{Name: r.Name, ApprovalStatus: approvalStatus},
+	}
+}
+
+func (r *ManualApprovalRuler) labelApproval() bool {
+	return r.Rule != nil && r.Rule.ApprovalLabel != ""
+}
+
+// approver returns the approver of pod, which is the approval label if it is set
+func (r *ManualApprovalRuler) approver(pod *corev1.Pod, podTransitionRule *appsv1alpha1.PodTransitionRule) string {
+	if !r.labelApproval() {
+		return utils.GetApprover(pod, podTransitionRule)
+	}
+	if podTransitionRule.Labels[r.Rule.ApprovalLabel] == "true" {
+		return "label " + r.Rule.ApprovalLabel
+	}
+	return ""
+}
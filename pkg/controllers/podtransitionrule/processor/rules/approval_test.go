@@ -0,0 +1,92 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestManualApproval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	podA := (&podTemplate{Name: "test-pod-a"}).GetPod()
+	podA.Annotations = map[string]string{appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix + "/approval-rs": "alice"}
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": podA,
+		"test-pod-b": (&podTemplate{Name: "test-pod-b"}).GetPod(),
+		"test-pod-c": (&podTemplate{Name: "test-pod-c"}).GetPod(),
+	}
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "approval-rs",
+			Annotations: map[string]string{appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix + "/test-pod-b": "true"},
+		},
+	}
+	ruler := &ManualApprovalRuler{Name: "approval"}
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a", "test-pod-b", "test-pod-c"))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a", "test-pod-b"}))
+	g.Expect(res.Rejected["test-pod-c"]).Should(gomega.ContainSubstring("pending manual approval"))
+	g.Expect(res.RuleState.ApprovalStatus.Pending).Should(gomega.Equal([]string{"test-pod-c"}))
+	g.Expect(res.RuleState.ApprovalStatus.Approved).Should(gomega.Equal([]appsv1alpha1.ApprovedPod{
+		{Name: "test-pod-a", Approver: "alice"},
+		{Name: "test-pod-b", Approver: "true"},
+	}))
+
+	// approver is kept after annotation removed, as long as pod has passed the rule
+	rs.Annotations = nil
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{res.RuleState}
+	rs.Status.Details = []*appsv1alpha1.PodTransitionDetail{
+		{Name: "test-pod-b", PassedRules: []string{"approval"}},
+	}
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-b", "test-pod-c"))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b"}))
+	g.Expect(res.RuleState.ApprovalStatus.Approved).Should(gomega.Equal([]appsv1alpha1.ApprovedPod{{Name: "test-pod-b", Approver: "true"}}))
+}
+
+func TestLabelApproval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a"}).GetPod(),
+		"test-pod-b": (&podTemplate{Name: "test-pod-b"}).GetPod(),
+	}
+	// approval annotations are ignored by label approval
+	rs := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "approval-rs",
+			Annotations: map[string]string{appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix + "/test-pod-b": "true"},
+		},
+	}
+	ruler := &ManualApprovalRuler{Name: "approval", Rule: &appsv1alpha1.ManualApprovalRule{ApprovalLabel: "approved"}}
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a", "test-pod-b"))
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[approval] awaiting approval, approve by label approved=true on podtransitionrule"))
+	g.Expect(res.RuleState.ApprovalStatus.Pending).Should(gomega.Equal([]string{"test-pod-a", "test-pod-b"}))
+
+	rs.Labels = map[string]string{"approved": "true"}
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a", "test-pod-b"))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a", "test-pod-b"}))
+	g.Expect(res.RuleState.ApprovalStatus.Approved).Should(gomega.Equal([]appsv1alpha1.ApprovedPod{
+		{Name: "test-pod-a", Approver: "label approved"},
+		{Name: "test-pod-b", Approver: "label approved"},
+	}))
+}
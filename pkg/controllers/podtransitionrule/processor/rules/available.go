@@ -19,6 +19,7 @@ package rules
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
@@ -32,6 +33,7 @@ import (
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/register"
 	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	controllerutils "kusionstack.io/operating/pkg/controllers/utils"
 )
 
 type AvailableRuler struct {
@@ -40,6 +42,9 @@ type AvailableRuler struct {
 	MinAvailableValue   *intstr.IntOrString
 	MaxUnavailableValue *intstr.IntOrString
 
+	// ContainerReadiness judges availability by named containers instead of registered unavailable funcs if set
+	ContainerReadiness *appsv1alpha1.ContainerReadiness
+
 	Client client.Client
 }
 
@@ -48,8 +53,8 @@ func (r *AvailableRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRul
 	effectiveTargets := sets.NewString()
 	pass := sets.NewString()
 	rejects := map[string]string{}
-	for _, t := range targets {
-		effectiveTargets.Insert(t.Name)
+	for podName := range targets {
+		effectiveTargets.Insert(podName)
 	}
 	maxUnavailableQuota := len(effectiveTargets)
 	allowUnavailable := maxUnavailableQuota
@@ -66,7 +71,7 @@ func (r *AvailableRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRul
 	if r.MinAvailableValue != nil {
 		quota, err := intstr.GetScaledValueFromIntOrPercent(r.MinAvailableValue, len(effectiveTargets), false)
 		if err != nil {
-			return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get int value from raw min available value(%s), error: %v", r.Name, r.MaxUnavailableValue.String(), err)
+			return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get int value from raw min available value(%s), error: %v", r.Name, r.MinAvailableValue.String(), err)
 		}
 		minAvailableQuota = quota
 	}
@@ -74,15 +79,19 @@ func (r *AvailableRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRul
 	// allowUnavailable -= uncreatedReplicas
 	allAvailableSize := 0
 	var minTimeLeft *int64
+	unavailableReasons := map[string]string{}
 	// filter unavailable pods
 	for podName := range effectiveTargets {
 		pod := targets[podName]
-		if utils.IsPodPassRule(pod.Name, podTransitionRule, r.Name) {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
 			allowUnavailable--
 			continue
 		}
 
-		isUnavailable, timeLeft := processUnavailableFunc(pod)
+		isUnavailable, timeLeft, reason := r.isUnavailable(pod)
+		if reason != "" {
+			unavailableReasons[podName] = reason
+		}
 		if isUnavailable {
 			allowUnavailable--
 			minTimeLeft = min(minTimeLeft, timeLeft)
@@ -91,15 +100,24 @@ func (r *AvailableRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRul
 		allAvailableSize++
 	}
 	rejectByMaxUnavailablePods, keepMinAvailablePods := map[string]*corev1.Pod{}, map[string]*corev1.Pod{}
-	// try approve available pod
+	// try approve available pod in the order of ordering policy, pods with lower disruption cost are approved first by default
+	found := sets.NewString()
 	for podName := range subjects {
+		if targets[podName] == nil {
+			rejects[podName] = fmt.Sprintf("[%s] pod is not found in targets", r.Name)
+			continue
+		}
+		found.Insert(podName)
+	}
+	ordered := orderSubjects(podTransitionRule.Spec.OrderingPolicy, targets, found)
+	for _, podName := range ordered {
 		pod := targets[podName]
-		if utils.IsPodPassRule(pod.Name, podTransitionRule, r.Name) {
-			pass.Insert(pod.Name)
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
 			continue
 		}
 
-		isUnavailable, _ := processUnavailableFunc(pod)
+		isUnavailable, _, _ := r.isUnavailable(pod)
 		if isUnavailable {
 			pass.Insert(podName)
 			continue
@@ -120,19 +138,96 @@ func (r *AvailableRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRul
 		rejectByMaxUnavailablePods[podName] = pod
 	}
 
-	for podName := range keepMinAvailablePods {
-		rejects[podName] = fmt.Sprintf("blocked by min available policy: [min available]=%d/%d, [current keep available]=%d/%d", minAvailableQuota, len(effectiveTargets), allAvailableSize, len(effectiveTargets))
+	// blocked pods queue in the order pods are approved
+	var queue []string
+	for _, podName := range ordered {
+		if keepMinAvailablePods[podName] != nil || rejectByMaxUnavailablePods[podName] != nil {
+			queue = append(queue, podName)
+		}
 	}
-	for podName := range rejectByMaxUnavailablePods {
-		rejects[podName] = fmt.Sprintf("[%s] blocked by max unavailable policy: [max unavailable]=%d/%d, [current unavailable]=%d/%d", r.Name, maxUnavailableQuota, len(effectiveTargets), len(effectiveTargets)-allAvailableSize, len(effectiveTargets))
+	for i, podName := range queue {
+		queueInfo := fmt.Sprintf("[disruption cost]=%d, [queue position]=%d/%d", utils.GetDisruptionCost(targets[podName]), i+1, len(queue))
+		if policy := podTransitionRule.Spec.OrderingPolicy; policy != "" {
+			queueInfo = fmt.Sprintf("waiting for ordering slot, [ordering policy]=%s, [queue position]=%d/%d", policy, i+1, len(queue))
+		}
+		if _, ok := keepMinAvailablePods[podName]; ok {
+			rejects[podName] = fmt.Sprintf("[%s] blocked by min available policy: [min available]=%d/%d, [current keep available]=%d/%d, %s", r.Name, minAvailableQuota, len(effectiveTargets), allAvailableSize, len(effectiveTargets), queueInfo)
+			continue
+		}
+		rejects[podName] = fmt.Sprintf("[%s] blocked by max unavailable policy: [max unavailable]=%d/%d, [current unavailable]=%d/%d, %s", r.Name, maxUnavailableQuota, len(effectiveTargets), len(effectiveTargets)-allAvailableSize, len(effectiveTargets), queueInfo)
 	}
 
+	var ruleState *appsv1alpha1.RuleState
+	if len(unavailableReasons) > 0 {
+		ruleState = &appsv1alpha1.RuleState{Name: r.Name, AvailableStatus: &appsv1alpha1.AvailableStatus{UnavailableReasons: unavailableReasons}}
+	}
 	if minTimeLeft != nil {
 		interval := time.Duration(*minTimeLeft) * time.Second
-		return &FilterResult{Passed: pass, Rejected: rejects, Interval: &interval, Err: fmt.Errorf("[%s] pods not finish warm up until %d seconds later", r.Name, minTimeLeft)}
+		return &FilterResult{Passed: pass, Rejected: rejects, Interval: &interval, Err: fmt.Errorf("[%s] pods not finish warm up until %d seconds later", r.Name, minTimeLeft), RuleState: ruleState}
 	}
 
-	return &FilterResult{Passed: pass, Rejected: rejects}
+	return &FilterResult{Passed: pass, Rejected: rejects, RuleState: ruleState}
+}
+
+// isUnavailable judges availability of pod by container readiness if configured, otherwise by registered unavailable
+// funcs. The reason is returned if pod is unavailable by container readiness.
+func (r *AvailableRuler) isUnavailable(pod *corev1.Pod) (bool, *int64, string) {
+	if r.ContainerReadiness == nil {
+		unavailable, timeLeft := processUnavailableFunc(pod)
+		return unavailable, timeLeft, ""
+	}
+	if controllerutils.IsPodTerminal(pod) {
+		return true, nil, fmt.Sprintf("pod is %s", pod.Status.Phase)
+	}
+	ready := map[string]bool{}
+	for _, status := range pod.Status.ContainerStatuses {
+		ready[status.Name] = status.Ready
+	}
+	for _, name := range r.ContainerReadiness.ContainerNames {
+		isReady, ok := ready[name]
+		if !ok {
+			return true, nil, fmt.Sprintf("container %s is not found", name)
+		}
+		if !isReady {
+			return true, nil, fmt.Sprintf("container %s is not ready", name)
+		}
+	}
+	return false, nil, ""
+}
+
+// orderSubjects sorts subjects by ordering policy, pods equal by the policy are sorted by disruption cost
+func orderSubjects(policy appsv1alpha1.PodTransitionRuleOrderingPolicy, targets map[string]*corev1.Pod, subjects sets.String) []string {
+	ordered := orderByDisruptionCost(targets, subjects)
+	switch policy {
+	case appsv1alpha1.OrderingByPriorityClass:
+		priority := func(podName string) int32 {
+			if p := targets[podName].Spec.Priority; p != nil {
+				return *p
+			}
+			return 0
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return priority(ordered[i]) < priority(ordered[j])
+		})
+	case appsv1alpha1.OrderingByCreationTimestamp:
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return targets[ordered[i]].CreationTimestamp.Before(&targets[ordered[j]].CreationTimestamp)
+		})
+	}
+	return ordered
+}
+
+// orderByDisruptionCost sorts subjects by disruption cost ascending, and by name for equal cost
+func orderByDisruptionCost(targets map[string]*corev1.Pod, subjects sets.String) []string {
+	ordered := subjects.List()
+	costs := make(map[string]int64, len(ordered))
+	for _, podName := range ordered {
+		costs[podName] = utils.GetDisruptionCost(targets[podName])
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return costs[ordered[i]] < costs[ordered[j]]
+	})
+	return ordered
 }
 
 func (r *AvailableRuler) getPodReplicaSetReplication(controllerRef *metav1.OwnerReference, namespace string) (int, bool, error) {
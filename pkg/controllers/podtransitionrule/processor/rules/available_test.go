@@ -0,0 +1,220 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestAvailableDisruptionCost(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	withCost := func(name, cost string) *corev1.Pod {
+		pod := (&podTemplate{Name: name}).GetPod()
+		if cost != "" {
+			pod.Annotations[appsv1alpha1.AnnotationPodDisruptionCost] = cost
+		}
+		return pod
+	}
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": withCost("test-pod-a", "10"),
+		"test-pod-b": withCost("test-pod-b", ""),
+		"test-pod-c": withCost("test-pod-c", "5"),
+		"test-pod-d": withCost("test-pod-d", "invalid"),
+	}
+	maxUnavailable := intstr.FromInt(2)
+	ruler := &AvailableRuler{Name: "available", MaxUnavailableValue: &maxUnavailable}
+	rs := &appsv1alpha1.PodTransitionRule{}
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a", "test-pod-b", "test-pod-c", "test-pod-d"))
+	// pods without valid cost cost 0, and are let pass first
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b", "test-pod-d"}))
+	g.Expect(res.Rejected["test-pod-c"]).Should(gomega.HaveSuffix("[disruption cost]=5, [queue position]=1/2"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.HaveSuffix("[disruption cost]=10, [queue position]=2/2"))
+}
+
+func TestAvailableOrderingPolicy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	now := time.Now()
+	targets := map[string]*corev1.Pod{}
+	for i, name := range []string{"test-pod-a", "test-pod-b", "test-pod-c", "test-pod-d"} {
+		pod := (&podTemplate{Name: name}).GetPod()
+		// pods created later have lower priority
+		priority := int32(100 - i*10)
+		pod.Spec.Priority = &priority
+		pod.CreationTimestamp = metav1.NewTime(now.Add(time.Duration(i) * time.Minute))
+		targets[name] = pod
+	}
+	maxUnavailable := intstr.FromInt(2)
+	ruler := &AvailableRuler{Name: "available", MaxUnavailableValue: &maxUnavailable}
+	rs := &appsv1alpha1.PodTransitionRule{}
+
+	rs.Spec.OrderingPolicy = appsv1alpha1.OrderingByPriorityClass
+	res := ruler.Filter(rs, targets, sets.StringKeySet(targets))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-c", "test-pod-d"}))
+	g.Expect(res.Rejected["test-pod-b"]).Should(gomega.HaveSuffix("waiting for ordering slot, [ordering policy]=ByPriorityClass, [queue position]=1/2"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.HaveSuffix("waiting for ordering slot, [ordering policy]=ByPriorityClass, [queue position]=2/2"))
+
+	rs.Spec.OrderingPolicy = appsv1alpha1.OrderingByCreationTimestamp
+	res = ruler.Filter(rs, targets, sets.StringKeySet(targets))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a", "test-pod-b"}))
+	g.Expect(res.Rejected["test-pod-c"]).Should(gomega.HaveSuffix("[queue position]=1/2"))
+	g.Expect(res.Rejected["test-pod-d"]).Should(gomega.HaveSuffix("[queue position]=2/2"))
+}
+
+func TestAvailableQuota(t *testing.T) {
+	intOrStr := func(v intstr.IntOrString) *intstr.IntOrString {
+		return &v
+	}
+	cases := []struct {
+		name           string
+		maxUnavailable *intstr.IntOrString
+		minAvailable   *intstr.IntOrString
+		// passedBefore are pods which passed the rule before, and are counted as unavailable
+		passedBefore []string
+		passed       []string
+		// reasons is pod:prefix of reject reason
+		reasons map[string]string
+		err     bool
+	}{
+		{
+			name:           "max unavailable",
+			maxUnavailable: intOrStr(intstr.FromInt(1)),
+			passed:         []string{"test-pod-a"},
+		},
+		{
+			name:           "max unavailable percentage",
+			maxUnavailable: intOrStr(intstr.FromString("50%")),
+			passed:         []string{"test-pod-a", "test-pod-b"},
+		},
+		{
+			name:           "max unavailable taken by pods passed before",
+			maxUnavailable: intOrStr(intstr.FromInt(2)),
+			passedBefore:   []string{"test-pod-d"},
+			passed:         []string{"test-pod-a", "test-pod-d"},
+		},
+		{
+			name:         "min available",
+			minAvailable: intOrStr(intstr.FromString("75%")),
+			passed:       []string{"test-pod-a"},
+			reasons:      map[string]string{"test-pod-b": "[available] blocked by min available policy: [min available]=3/4, [current keep available]=3/4"},
+		},
+		{
+			name:         "invalid min available percentage",
+			minAvailable: intOrStr(intstr.FromString("abc%")),
+			err:          true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			targets := map[string]*corev1.Pod{}
+			for _, name := range []string{"test-pod-a", "test-pod-b", "test-pod-c", "test-pod-d"} {
+				targets[name] = (&podTemplate{Name: name}).GetPod()
+			}
+			rs := &appsv1alpha1.PodTransitionRule{}
+			for _, name := range tc.passedBefore {
+				rs.Status.Details = append(rs.Status.Details, &appsv1alpha1.PodTransitionDetail{Name: name, PassedRules: []string{"available"}})
+			}
+			ruler := &AvailableRuler{Name: "available", MaxUnavailableValue: tc.maxUnavailable, MinAvailableValue: tc.minAvailable}
+			res := ruler.Filter(rs, targets, sets.StringKeySet(targets))
+			if tc.err {
+				g.Expect(res.Err).Should(gomega.HaveOccurred())
+				g.Expect(res.Rejected).Should(gomega.HaveLen(len(targets)))
+				return
+			}
+			g.Expect(res.Passed.List()).Should(gomega.Equal(tc.passed))
+			g.Expect(res.Rejected).Should(gomega.HaveLen(len(targets) - len(tc.passed)))
+			for podName, reason := range tc.reasons {
+				g.Expect(res.Rejected[podName]).Should(gomega.HavePrefix(reason))
+			}
+		})
+	}
+}
+
+func TestAvailableContainerReadiness(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	withContainers := func(name string, appReady *bool) *corev1.Pod {
+		pod := (&podTemplate{Name: name}).GetPod()
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "sidecar", Ready: true}}
+		if appReady != nil {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{Name: "app", Ready: *appReady})
+		}
+		return pod
+	}
+	ready, notReady := true, false
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": withContainers("test-pod-a", &ready),
+		"test-pod-b": withContainers("test-pod-b", &notReady),
+		"test-pod-c": withContainers("test-pod-c", nil),
+		"test-pod-d": withContainers("test-pod-d", &ready),
+	}
+	maxUnavailable := intstr.FromInt(2)
+	ruler := &AvailableRuler{
+		Name:                "available",
+		MaxUnavailableValue: &maxUnavailable,
+		ContainerReadiness:  &appsv1alpha1.ContainerReadiness{ContainerNames: []string{"app"}},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+	res := ruler.Filter(rs, targets, sets.StringKeySet(targets))
+	// pods with app container not ready or missing are unavailable, and use up the budget
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b", "test-pod-c"}))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.HavePrefix("[available] blocked by max unavailable policy: [max unavailable]=2/4, [current unavailable]=2/4"))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-d"))
+	g.Expect(res.RuleState.AvailableStatus.UnavailableReasons).Should(gomega.Equal(map[string]string{
+		"test-pod-b": "container app is not ready",
+		"test-pod-c": "container app is not found",
+	}))
+
+	// all pods are available by app container
+	targets["test-pod-b"] = withContainers("test-pod-b", &ready)
+	targets["test-pod-c"] = withContainers("test-pod-c", &ready)
+	res = ruler.Filter(rs, targets, sets.StringKeySet(targets))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a", "test-pod-b"}))
+	g.Expect(res.RuleState).Should(gomega.BeNil())
+}
+
+func TestAvailableNamespaceSelector(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	// targets are keyed by namespace/name if namespace selector is set, pods with the same name may be selected
+	targets := map[string]*corev1.Pod{}
+	for _, ns := range []string{"ns-a", "ns-b", "ns-c"} {
+		pod := (&podTemplate{Name: "test-pod"}).GetPod()
+		pod.Namespace = ns
+		targets[ns+"/test-pod"] = pod
+	}
+	maxUnavailable := intstr.FromInt(2)
+	ruler := &AvailableRuler{Name: "available", MaxUnavailableValue: &maxUnavailable}
+	rs := &appsv1alpha1.PodTransitionRule{
+		Spec: appsv1alpha1.PodTransitionRuleSpec{NamespaceSelector: &metav1.LabelSelector{}},
+		Status: appsv1alpha1.PodTransitionRuleStatus{
+			Details: []*appsv1alpha1.PodTransitionDetail{{Name: "ns-a/test-pod", PassedRules: []string{"available"}}},
+		},
+	}
+	res := ruler.Filter(rs, targets, sets.NewString("ns-a/test-pod", "ns-b/test-pod", "ns-c/test-pod", "ns-d/test-pod"))
+	// pod passed before takes one of the budget
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"ns-a/test-pod", "ns-b/test-pod"}))
+	g.Expect(res.Rejected["ns-c/test-pod"]).Should(gomega.HavePrefix("[available] blocked by max unavailable policy: [max unavailable]=2/3"))
+	g.Expect(res.Rejected["ns-d/test-pod"]).Should(gomega.Equal("[available] pod is not found in targets"))
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+var (
+	breakerFailureThreshold = 5
+	breakerCooldown         = 30 * time.Second
+
+	circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podtransitionrule_webhook_circuit_state",
+		Help: "Circuit breaker state of podtransitionrule webhook endpoint, 0 is Closed, 1 is HalfOpen and 2 is Open.",
+	}, []string{"endpoint"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(circuitState)
+}
+
+// SetBreakerOptions sets the number of consecutive failures to open circuit breakers, non-positive means circuit
+// breakers are disabled, and the duration open breakers keep open. It must be called before starting the controller.
+func SetBreakerOptions(failureThreshold int, cooldown time.Duration) {
+	breakerFailureThreshold, breakerCooldown = failureThreshold, cooldown
+}
+
+// Breakers contains the circuit breakers keyed by webhook endpoint
+var Breakers = &breakerSet{breakers: map[string]*Breaker{}}
+
+type breakerSet struct {
+	breakers map[string]*Breaker
+	mu       sync.Mutex
+}
+
+func (s *breakerSet) Get(endpoint string) *Breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.breakers[endpoint]
+	if !ok {
+		b = &Breaker{endpoint: endpoint, state: appsv1alpha1.CircuitClosed}
+		s.breakers[endpoint] = b
+	}
+	return b
+}
+
+// Breaker opens after consecutive failures, and fast-fails requests during cooldown.
+// After cooldown it is half-open and lets a single trial request through to test recovery.
+type Breaker struct {
+	endpoint string
+	state    appsv1alpha1.CircuitState
+	failures int
+	openedAt time.Time
+	trialing bool
+
+	mu sync.Mutex
+}
+
+// Allow returns false if the request should fast-fail
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case appsv1alpha1.CircuitOpen:
+		if time.Since(b.openedAt) < breakerCooldown {
+			return false
+		}
+		b.setState(appsv1alpha1.CircuitHalfOpen)
+		b.trialing = true
+		return true
+	case appsv1alpha1.CircuitHalfOpen:
+		if b.trialing {
+			return false
+		}
+		b.trialing = true
+		return true
+	}
+	return true
+}
+
+// Record records the result of an allowed request
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trialing = false
+	if err == nil {
+		b.failures = 0
+		b.setState(appsv1alpha1.CircuitClosed)
+		return
+	}
+	b.failures++
+	if b.state == appsv1alpha1.CircuitHalfOpen || (breakerFailureThreshold > 0 && b.failures >= breakerFailureThreshold) {
+		b.openedAt = time.Now()
+		b.setState(appsv1alpha1.CircuitOpen)
+	}
+}
+
+func (b *Breaker) State() appsv1alpha1.CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) setState(state appsv1alpha1.CircuitState) {
+	b.state = state
+	switch state {
+	case appsv1alpha1.CircuitClosed:
+		circuitState.WithLabelValues(b.endpoint).Set(0)
+	case appsv1alpha1.CircuitHalfOpen:
+		circuitState.WithLabelValues(b.endpoint).Set(1)
+	case appsv1alpha1.CircuitOpen:
+		circuitState.WithLabelValues(b.endpoint).Set(2)
+	}
+}
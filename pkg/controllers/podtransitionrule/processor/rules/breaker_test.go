@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestBreaker(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	defer func(threshold int, cooldown time.Duration) {
+		breakerFailureThreshold, breakerCooldown = threshold, cooldown
+	}(breakerFailureThreshold, breakerCooldown)
+	breakerFailureThreshold, breakerCooldown = 2, 200*time.Millisecond
+
+	b := Breakers.Get("http://breaker-test")
+	g.Expect(b.State()).Should(gomega.Equal(appsv1alpha1.CircuitClosed))
+	g.Expect(b.Allow()).Should(gomega.BeTrue())
+	b.Record(fmt.Errorf("failed"))
+	g.Expect(b.State()).Should(gomega.Equal(appsv1alpha1.CircuitClosed))
+	b.Record(fmt.Errorf("failed"))
+	g.Expect(b.State()).Should(gomega.Equal(appsv1alpha1.CircuitOpen))
+	g.Expect(b.Allow()).Should(gomega.BeFalse())
+
+	// half-open after cooldown, only a single trial is allowed
+	<-time.After(breakerCooldown)
+	g.Expect(b.Allow()).Should(gomega.BeTrue())
+	g.Expect(b.State()).Should(gomega.Equal(appsv1alpha1.CircuitHalfOpen))
+	g.Expect(b.Allow()).Should(gomega.BeFalse())
+	// trial failed, open again
+	b.Record(fmt.Errorf("failed"))
+	g.Expect(b.State()).Should(gomega.Equal(appsv1alpha1.CircuitOpen))
+
+	<-time.After(breakerCooldown)
+	g.Expect(b.Allow()).Should(gomega.BeTrue())
+	b.Record(nil)
+	g.Expect(b.State()).Should(gomega.Equal(appsv1alpha1.CircuitClosed))
+	g.Expect(b.Allow()).Should(gomega.BeTrue())
+}
+
+func TestWebhookBreakerOpen(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	rs := normalRS.DeepCopy()
+	rs.Spec.Rules[0].Webhook.ClientConfig.URL = "http://127.0.0.1:8887"
+	b := Breakers.Get(rs.Spec.Rules[0].Webhook.ClientConfig.URL)
+	for i := 0; i < breakerFailureThreshold; i++ {
+		b.Record(fmt.Errorf("failed"))
+	}
+
+	// Fail policy rejects
+	res := GetWebhook(rs)[0].Do(targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+	g.Expect(res.RuleState.WebhookStatus.CircuitState).Should(gomega.Equal(appsv1alpha1.CircuitOpen))
+
+	// Ignore policy passes
+	ignore := appsv1alpha1.Ignore
+	rs.Spec.Rules[0].Webhook.FailurePolicy = &ignore
+	res = GetWebhook(rs)[0].Do(targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+}
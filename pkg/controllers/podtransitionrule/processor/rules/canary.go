@@ -0,0 +1,133 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	controllerutils "kusionstack.io/operating/pkg/controllers/utils"
+)
+
+// canaryCheckInterval is the interval to check health of canary cohort again while it is unhealthy
+const canaryCheckInterval = 10 * time.Second
+
+type CanaryRuler struct {
+	Name string
+	Rule *appsv1alpha1.CanaryRule
+}
+
+// Filter passes pods into the canary cohort until it is full, and holds the rest until the cohort is soaked
+func (r *CanaryRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+	status := r.lastStatus(podTransitionRule)
+	// the rest is released and no pod is in the stage, the canary restarts
+	if status.Promoted && subjects.Len() == 0 {
+		status = &appsv1alpha1.CanaryStatus{}
+	}
+
+	count, err := intstr.GetScaledValueFromIntOrPercent(&r.Rule.Count, len(targets), true)
+	if err != nil {
+		return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get int value from raw canary count(%s), error: %v", r.Name, r.Rule.Count.String(), err)
+	}
+
+	released := sets.NewString(status.Released...)
+	var held []string
+	for _, podName := range orderSubjects(podTransitionRule.Spec.OrderingPolicy, targets, subjects) {
+		switch {
+		case utils.IsPodPassRule(podName, podTransitionRule, r.Name), status.Promoted:
+			pass.Insert(podName)
+		case released.Len() < count:
+			released.Insert(podName)
+			pass.Insert(podName)
+		default:
+			held = append(held, podName)
+		}
+	}
+	status.Released = released.List()
+
+	res := &FilterResult{Passed: pass, Rejected: rejects, RuleState: &appsv1alpha1.RuleState{Name: r.Name, CanaryStatus: status}}
+	if status.Promoted || released.Len() < count {
+		return res
+	}
+	if unhealthy := canaryUnhealthy(released, targets, subjects); unhealthy != "" {
+		status.HealthySince = nil
+		for _, podName := range held {
+			rejects[podName] = fmt.Sprintf("[%s] waiting for canary cohort healthy: %s, [canary]=%d", r.Name, unhealthy, released.Len())
+		}
+		if len(held) > 0 {
+			interval := canaryCheckInterval
+			res.Interval = &interval
+		}
+		return res
+	}
+
+	now := time.Now()
+	if status.HealthySince == nil {
+		status.HealthySince = &metav1.Time{Time: now}
+	}
+	soak := time.Duration(r.Rule.SoakSeconds) * time.Second
+	remaining := status.HealthySince.Add(soak).Sub(now)
+	if remaining <= 0 {
+		status.Promoted = true
+		pass.Insert(held...)
+		return res
+	}
+	for _, podName := range held {
+		rejects[podName] = fmt.Sprintf("[%s] soaking canary cohort: [canary]=%d, [soaked]=%s/%s",
+			r.Name, released.Len(), (soak - remaining).Truncate(time.Second), soak)
+	}
+	if len(held) > 0 {
+		res.Interval = &remaining
+	}
+	return res
+}
+
+func (r *CanaryRuler) lastStatus(podTransitionRule *appsv1alpha1.PodTransitionRule) *appsv1alpha1.CanaryStatus {
+	for _, state := range podTransitionRule.Status.RuleStates {
+		if state.Name == r.Name && state.CanaryStatus != nil {
+			return state.CanaryStatus.DeepCopy()
+		}
+	}
+	return &appsv1alpha1.CanaryStatus{}
+}
+
+// canaryUnhealthy describes the first canary pod still in the stage or not ready, empty if the cohort is healthy.
+// Canary pods no longer in targets are ignored.
+func canaryUnhealthy(released sets.String, targets map[string]*corev1.Pod, subjects sets.String) string {
+	for _, podName := range released.List() {
+		pod, ok := targets[podName]
+		if !ok {
+			continue
+		}
+		if subjects.Has(podName) {
+			return fmt.Sprintf("[pod]=%s, [state]=in transition", podName)
+		}
+		if !controllerutils.IsPodReady(pod) {
+			return fmt.Sprintf("[pod]=%s, [state]=not ready", podName)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestCanary(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	targets := map[string]*corev1.Pod{}
+	for _, name := range []string{"pod-a", "pod-b", "pod-c", "pod-d"} {
+		targets[name] = (&podTemplate{Name: name}).GetPod()
+	}
+	ptr := &appsv1alpha1.PodTransitionRule{}
+	ruler := &CanaryRuler{Name: "canary", Rule: &appsv1alpha1.CanaryRule{Count: intstr.FromString("25%"), SoakSeconds: 60}}
+	filter := func(subjects sets.String) *FilterResult {
+		res := ruler.Filter(ptr, targets, subjects)
+		ptr.Status.RuleStates = []*appsv1alpha1.RuleState{res.RuleState}
+		return res
+	}
+
+	// canary cohort is released, the rest waits for it leaving the stage
+	res := filter(sets.NewString("pod-a", "pod-b", "pod-c", "pod-d"))
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(1))
+	canary := res.Passed.List()[0]
+	g.Expect(res.Rejected).Should(gomega.HaveLen(3))
+	for _, reason := range res.Rejected {
+		g.Expect(reason).Should(gomega.ContainSubstring("[pod]=" + canary + ", [state]=in transition"))
+	}
+	g.Expect(res.RuleState.CanaryStatus.Released).Should(gomega.Equal([]string{canary}))
+
+	// canary left the stage but is not ready
+	rest := sets.NewString("pod-a", "pod-b", "pod-c", "pod-d")
+	rest.Delete(canary)
+	res = filter(rest)
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	for _, reason := range res.Rejected {
+		g.Expect(reason).Should(gomega.ContainSubstring("[state]=not ready"))
+	}
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+
+	// canary is ready, soaking
+	targets[canary].Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+	res = filter(rest)
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	for _, reason := range res.Rejected {
+		g.Expect(reason).Should(gomega.ContainSubstring("soaking canary cohort"))
+	}
+	g.Expect(res.RuleState.CanaryStatus.HealthySince).ShouldNot(gomega.BeNil())
+	g.Expect(*res.Interval).Should(gomega.BeNumerically("~", time.Minute, time.Second))
+
+	// soaked, the rest is released
+	healthySince := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	ptr.Status.RuleStates[0].CanaryStatus.HealthySince = &healthySince
+	res = filter(rest)
+	g.Expect(res.Passed.Equal(rest)).Should(gomega.BeTrue())
+	g.Expect(res.RuleState.CanaryStatus.Promoted).Should(gomega.BeTrue())
+
+	// canary restarts once no pod is in the stage
+	res = filter(sets.NewString())
+	g.Expect(res.RuleState.CanaryStatus.Released).Should(gomega.BeEmpty())
+	g.Expect(res.RuleState.CanaryStatus.Promoted).Should(gomega.BeFalse())
+}
@@ -0,0 +1,50 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"strings"
+	"sync"
+)
+
+// webhookCalls counts webhook calls of each PodTransitionRule since last taken
+var webhookCalls = &callCounter{counts: map[string]int{}}
+
+type callCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// countWebhookCall counts a call of webhook with key namespace/podTransitionRule/rule
+func countWebhookCall(key string) {
+	if parts := strings.SplitN(key, "/", 3); len(parts) == 3 {
+		key = parts[0] + "/" + parts[1]
+	}
+	webhookCalls.mu.Lock()
+	defer webhookCalls.mu.Unlock()
+	webhookCalls.counts[key]++
+}
+
+// TakeWebhookCalls returns the number of webhook calls of PodTransitionRule namespace/name since last taken,
+// including calls of polling tasks
+func TakeWebhookCalls(podTransitionRule string) int {
+	webhookCalls.mu.Lock()
+	defer webhookCalls.mu.Unlock()
+	calls := webhookCalls.counts[podTransitionRule]
+	delete(webhookCalls.counts, podTransitionRule)
+	return calls
+}
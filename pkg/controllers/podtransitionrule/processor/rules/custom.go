@@ -0,0 +1,62 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// RulerFactory creates the Ruler of a custom rule. It is called on each processing of the rule, so rulers are free
+// to read the rule and keep no state across calls.
+type RulerFactory func(client client.Client, rule *appsv1alpha1.TransitionRule) Ruler
+
+var (
+	customRulersMu sync.RWMutex
+	customRulers   = map[string]RulerFactory{}
+)
+
+// RegisterCustomRuler registers factory of custom rules with ruleType, a registered ruleType is overridden
+func RegisterCustomRuler(ruleType string, factory RulerFactory) {
+	customRulersMu.Lock()
+	defer customRulersMu.Unlock()
+	customRulers[ruleType] = factory
+}
+
+func getCustomRuler(rule *appsv1alpha1.TransitionRule, client client.Client) Ruler {
+	customRulersMu.RLock()
+	factory, ok := customRulers[rule.Custom.Type]
+	customRulersMu.RUnlock()
+	if !ok {
+		return &UnregisteredRuler{Type: rule.Custom.Type}
+	}
+	return factory(client, rule)
+}
+
+// UnregisteredRuler rejects all pods, since the custom rule of Type can not be checked
+type UnregisteredRuler struct {
+	Type string
+}
+
+func (r *UnregisteredRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	return rejectAllWithErr(subjects, sets.NewString(), map[string]string{}, "custom rule type %s is not registered", r.Type)
+}
@@ -0,0 +1,226 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	utilshttp "kusionstack.io/operating/pkg/utils/http"
+)
+
+// drainPollInterval is the interval to query pods waiting for connections drained
+const drainPollInterval = 5 * time.Second
+
+type ConnectionDrainRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.ConnectionDrainRule
+}
+
+// Filter rejects pods until their active connections are drained or max wait is reached
+func (r *ConnectionDrainRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	beginTimes := drainBeginTimes(podTransitionRule, r.Name)
+	now := metav1.Now()
+	maxWait := time.Duration(r.Rule.MaxWaitSeconds) * time.Second
+	drainStatus := &appsv1alpha1.DrainStatus{}
+	for _, podName := range subjects.List() {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		pod := targets[podName]
+		crashLoop := crashLoopingContainer(pod)
+		if crashLoop != "" && r.Rule.CrashLoopPolicy == appsv1alpha1.CrashLoopPolicyAlwaysPass {
+			pass.Insert(podName)
+			continue
+		}
+		if crashLoop != "" && r.Rule.CrashLoopPolicy == appsv1alpha1.CrashLoopPolicyAlwaysBlock {
+			rejects[podName] = crashLoopBlockedReason(r.Name, crashLoop)
+			continue
+		}
+		// pods without ip have no connections
+		if pod.Status.PodIP == "" {
+			pass.Insert(podName)
+			continue
+		}
+		beginTime, ok := beginTimes[podName]
+		if !ok {
+			beginTime = now
+		}
+		elapsed := now.Sub(beginTime.Time)
+
+		connections, err := r.queryActiveConnections(pod)
+		if err == nil && connections == 0 {
+			pass.Insert(podName)
+			continue
+		}
+		if maxWait > 0 && elapsed >= maxWait {
+			pass.Insert(podName)
+			continue
+		}
+		if err != nil {
+			connections = -1
+			rejects[podName] = fmt.Sprintf("[%s] fail to query active connections, [elapsed]=%s%s, error: %v", r.Name, drainElapsedString(elapsed, r.Rule.MaxWaitSeconds), crashLoopString(crashLoop), err)
+		} else {
+			rejects[podName] = fmt.Sprintf("[%s] waiting for connections drained: [active connections]=%d, [elapsed]=%s%s", r.Name, connections, drainElapsedString(elapsed, r.Rule.MaxWaitSeconds), crashLoopString(crashLoop))
+		}
+		drainStatus.Pods = append(drainStatus.Pods, appsv1alpha1.DrainingPod{
+			Name:              podName,
+			BeginTime:         beginTime,
+			ActiveConnections: connections,
+		})
+	}
+
+	res := &FilterResult{
+		Passed:    pass,
+		Rejected:  rejects,
+		RuleState: &appsv1alpha1.RuleState{Name: r.Name, DrainStatus: drainStatus},
+	}
+	if len(drainStatus.Pods) > 0 {
+		interval := drainPollInterval
+		res.Interval = &interval
+	}
+	return res
+}
+
+// drainBeginTimes returns the time each pod begins waiting for drained in the rule
+func drainBeginTimes(podTransitionRule *appsv1alpha1.PodTransitionRule, ruleName string) map[string]metav1.Time {
+	beginTimes := map[string]metav1.Time{}
+	for _, state := range podTransitionRule.Status.RuleStates {
+		if state.Name == ruleName && state.DrainStatus != nil {
+			for _, pod := range state.DrainStatus.Pods {
+				beginTimes[pod.Name] = pod.BeginTime
+			}
+		}
+	}
+	return beginTimes
+}
+
+// crashLoopingContainer returns the name of the first container of pod in CrashLoopBackOff, empty if none
+func crashLoopingContainer(pod *corev1.Pod) string {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return status.Name
+		}
+	}
+	return ""
+}
+
+func crashLoopBlockedReason(ruleName, container string) string {
+	return fmt.Sprintf("[%s] blocked by crash loop policy: [container]=%s, [state]=CrashLoopBackOff", ruleName, container)
+}
+
+// crashLoopString describes the crash looping container in reject reasons
+func crashLoopString(container string) string {
+	if container == "" {
+		return ""
+	}
+	return fmt.Sprintf(", [crash loop]=%s", container)
+}
+
+func drainElapsedString(elapsed time.Duration, maxWaitSeconds int32) string {
+	elapsed = elapsed.Truncate(time.Second)
+	if maxWaitSeconds > 0 {
+		return fmt.Sprintf("%s/%s", elapsed, time.Duration(maxWaitSeconds)*time.Second)
+	}
+	return elapsed.String()
+}
+
+func (r *ConnectionDrainRuler) queryActiveConnections(pod *corev1.Pod) (int64, error) {
+	path := r.Rule.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(r.Rule.Port))), path)
+	resp, err := utilshttp.DoHttpAndHttpsRequestWithCa(http.MethodGet, url, nil, nil, "")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("failed by response status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+	if r.Rule.MetricName != "" {
+		return sumMetric(body, r.Rule.MetricName)
+	}
+	status := &struct {
+		ActiveConnections *int64 `json:"activeConnections"`
+	}{}
+	if err := json.Unmarshal(body, status); err != nil {
+		return 0, err
+	}
+	if status.ActiveConnections == nil {
+		return 0, fmt.Errorf("activeConnections not found in response")
+	}
+	return *status.ActiveConnections, nil
+}
+
+// sumMetric sums values of the metric in prometheus text format
+func sumMetric(body []byte, name string) (int64, error) {
+	var sum float64
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, name) {
+			continue
+		}
+		rest := line[len(name):]
+		if !strings.HasPrefix(rest, " ") && !strings.HasPrefix(rest, "{") {
+			continue
+		}
+		if idx := strings.LastIndex(rest, "}"); idx >= 0 {
+			rest = rest[idx+1:]
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		val, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value of metric %s: %s", name, fields[0])
+		}
+		sum += val
+		found = true
+	}
+	if !found {
+		return 0, fmt.Errorf("metric %s not found", name)
+	}
+	return int64(sum), nil
+}
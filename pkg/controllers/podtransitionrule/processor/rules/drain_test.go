@@ -0,0 +1,123 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestConnectionDrain(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	connections := 3
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/metrics" {
+			fmt.Fprintf(resp, "# TYPE active_connections gauge\nactive_connections{listener=\"a\"} %d\nactive_connections{listener=\"b\"} 1\nactive_connections_total 100\n", connections)
+			return
+		}
+		fmt.Fprintf(resp, `{"activeConnections": %d}`, connections)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	portNum, _ := strconv.Atoi(port)
+
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "127.0.0.1"}).GetPod(),
+	}
+	ruler := &ConnectionDrainRuler{
+		Name: "drain",
+		Rule: &appsv1alpha1.ConnectionDrainRule{Port: int32(portNum), Path: "/drain", MaxWaitSeconds: 60},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[drain] waiting for connections drained: [active connections]=3, [elapsed]=0s/1m0s"))
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+	g.Expect(res.RuleState.DrainStatus.Pods).Should(gomega.HaveLen(1))
+	g.Expect(res.RuleState.DrainStatus.Pods[0].ActiveConnections).Should(gomega.BeEquivalentTo(3))
+
+	// metric of active connections
+	ruler.Rule.Path = "/metrics"
+	ruler.Rule.MetricName = "active_connections"
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(strings.Contains(res.Rejected["test-pod-a"], "[active connections]=4")).Should(gomega.BeTrue())
+
+	// max wait reached
+	res.RuleState.DrainStatus.Pods[0].BeginTime = metav1.NewTime(time.Now().Add(-time.Minute))
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{res.RuleState}
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+
+	// drained
+	connections = 0
+	ruler.Rule.Path, ruler.Rule.MetricName = "/drain", ""
+	rs.Status.RuleStates = nil
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+	g.Expect(res.Interval).Should(gomega.BeNil())
+}
+
+func TestConnectionDrainCrashLoop(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(resp, `{"activeConnections": 2}`)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	portNum, _ := strconv.Atoi(port)
+
+	pod := (&podTemplate{Name: "test-pod-a", Ip: "127.0.0.1"}).GetPod()
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "main", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+	targets := map[string]*corev1.Pod{"test-pod-a": pod}
+	ruler := &ConnectionDrainRuler{
+		Name: "drain",
+		Rule: &appsv1alpha1.ConnectionDrainRule{Port: int32(portNum), Path: "/drain"},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+
+	// normal policy waits for connections drained and surfaces the crash loop
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[drain] waiting for connections drained: [active connections]=2, [elapsed]=0s, [crash loop]=main"))
+
+	ruler.Rule.CrashLoopPolicy = appsv1alpha1.CrashLoopPolicyAlwaysPass
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+
+	ruler.Rule.CrashLoopPolicy = appsv1alpha1.CrashLoopPolicyAlwaysBlock
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[drain] blocked by crash loop policy: [container]=main, [state]=CrashLoopBackOff"))
+
+	// recovered pods are not affected by the policy
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.HavePrefix("[drain] waiting for connections drained"))
+}
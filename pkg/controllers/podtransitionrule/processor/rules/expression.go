@@ -0,0 +1,68 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type ExpressionRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.ExpressionRule
+}
+
+// Filter passes pods on which the expression is evaluated to true, evaluation errors are reasons of rejected pods
+func (r *ExpressionRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+	prg, err := utils.CompileExpression(r.Rule.Expression)
+	if err != nil {
+		return rejectAllWithErr(subjects, pass, rejects, "[%s] invalid expression: %v", r.Name, err)
+	}
+	message := r.Rule.Message
+	if message == "" {
+		message = r.Rule.Expression
+	}
+	for podName := range subjects {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(targets[podName])
+		if err != nil {
+			rejects[podName] = fmt.Sprintf("[%s] fail to convert pod: %v", r.Name, err)
+			continue
+		}
+		out, _, err := prg.Eval(map[string]interface{}{"pod": obj})
+		if err != nil {
+			rejects[podName] = fmt.Sprintf("[%s] fail to evaluate expression: %v", r.Name, err)
+			continue
+		}
+		if passed, ok := out.Value().(bool); !ok {
+			rejects[podName] = fmt.Sprintf("[%s] expression returns %v instead of bool", r.Name, out.Value())
+		} else if passed {
+			pass.Insert(podName)
+		} else {
+			rejects[podName] = fmt.Sprintf("[%s] %s", r.Name, message)
+		}
+	}
+	return &FilterResult{Passed: pass, Rejected: rejects}
+}
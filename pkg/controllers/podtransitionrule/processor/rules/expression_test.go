@@ -0,0 +1,78 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+func TestExpression(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	podA := (&podTemplate{Name: "test-pod-a"}).GetPod()
+	podA.Annotations = map[string]string{"foo": "bar"}
+	podB := (&podTemplate{Name: "test-pod-b"}).GetPod()
+	podB.Annotations = map[string]string{"foo": "baz"}
+	podC := (&podTemplate{Name: "test-pod-c"}).GetPod()
+	targets := map[string]*corev1.Pod{podA.Name: podA, podB.Name: podB, podC.Name: podC}
+	subjects := sets.NewString(podA.Name, podB.Name, podC.Name)
+	rs := &appsv1alpha1.PodTransitionRule{}
+
+	ruler := &ExpressionRuler{
+		Name: "expression",
+		Rule: &appsv1alpha1.ExpressionRule{
+			Expression: `pod.metadata.annotations["foo"] == "bar"`,
+			Message:    "foo is not bar",
+		},
+	}
+	res := ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKeyWithValue("test-pod-b", "[expression] foo is not bar"))
+	// pod without annotations fails evaluation, the error is the reason
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-c"))
+	g.Expect(res.Rejected["test-pod-c"]).Should(gomega.ContainSubstring("fail to evaluate expression"))
+
+	ruler.Rule = &appsv1alpha1.ExpressionRule{Expression: `pod.status.phase == "Running" && pod.metadata.name.startsWith("test-")`}
+	res = ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal(subjects.List()))
+}
+
+func TestCompileExpression(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	prg, err := utils.CompileExpression(`has(pod.metadata.labels.app)`)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	// programs are cached by expression
+	cached, err := utils.CompileExpression(`has(pod.metadata.labels.app)`)
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(cached).Should(gomega.BeIdenticalTo(prg))
+
+	_, err = utils.CompileExpression(`pod.metadata.name ==`)
+	g.Expect(err).Should(gomega.HaveOccurred())
+	_, err = utils.CompileExpression(`"not bool"`)
+	g.Expect(err).Should(gomega.HaveOccurred())
+
+	ruler := &ExpressionRuler{Name: "invalid", Rule: &appsv1alpha1.ExpressionRule{Expression: `pod.metadata.name ==`}}
+	res := ruler.Filter(&appsv1alpha1.PodTransitionRule{}, map[string]*corev1.Pod{}, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.ContainSubstring("invalid expression"))
+}
@@ -0,0 +1,91 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+// pdbCheckInterval is the interval to check blocked pods again, in case changes of PodDisruptionBudget are missed
+const pdbCheckInterval = 30 * time.Second
+
+type PDBRefRuler struct {
+	Name string
+	Rule *appsv1alpha1.PDBRefRule
+
+	Client client.Client
+}
+
+// Filter passes pods as many as disruptions allowed by the referenced PodDisruptionBudget. Unavailable pods
+// always pass, and pods passed before but still available take up disruptions allowed.
+func (r *PDBRefRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+	pdb := &policyv1.PodDisruptionBudget{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: podTransitionRule.Namespace, Name: r.Rule.Name}, pdb); err != nil {
+		if errors.IsNotFound(err) && r.Rule.FailurePolicy != nil && *r.Rule.FailurePolicy == appsv1alpha1.Ignore {
+			return &FilterResult{Passed: sets.NewString(subjects.UnsortedList()...), Rejected: rejects}
+		}
+		return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get PodDisruptionBudget %s, error: %v", r.Name, r.Rule.Name, err)
+	}
+
+	allowed := int(pdb.Status.DisruptionsAllowed)
+	for podName, pod := range targets {
+		if !utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			continue
+		}
+		if isUnavailable, _ := processUnavailableFunc(pod); !isUnavailable {
+			allowed--
+		}
+	}
+
+	for _, podName := range orderSubjects(podTransitionRule.Spec.OrderingPolicy, targets, subjects) {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		if isUnavailable, _ := processUnavailableFunc(targets[podName]); isUnavailable {
+			pass.Insert(podName)
+			continue
+		}
+		if allowed > 0 {
+			allowed--
+			pass.Insert(podName)
+			continue
+		}
+		rejects[podName] = fmt.Sprintf("[%s] no disruptions allowed by PodDisruptionBudget %s: [disruptions allowed]=%d, [current healthy]=%d, [desired healthy]=%d",
+			r.Name, pdb.Name, pdb.Status.DisruptionsAllowed, pdb.Status.CurrentHealthy, pdb.Status.DesiredHealthy)
+	}
+	res := &FilterResult{Passed: pass, Rejected: rejects}
+	if len(rejects) > 0 {
+		interval := pdbCheckInterval
+		res.Interval = &interval
+	}
+	return res
+}
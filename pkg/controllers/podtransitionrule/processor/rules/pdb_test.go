@@ -0,0 +1,72 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestPDBRef(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: "pdb", Namespace: "default"},
+		Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 2, CurrentHealthy: 3, DesiredHealthy: 1},
+	}
+	c := fake.NewClientBuilder().WithObjects(pdb).Build()
+	targets := map[string]*corev1.Pod{}
+	for _, name := range []string{"pod-a", "pod-b", "pod-c"} {
+		targets[name] = (&podTemplate{Name: name}).GetPod()
+	}
+	ptr := &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "ptr", Namespace: "default"},
+		Status: appsv1alpha1.PodTransitionRuleStatus{
+			Details: []*appsv1alpha1.PodTransitionDetail{{Name: "pod-a", PassedRules: []string{"pdb"}}},
+		},
+	}
+	ruler := &PDBRefRuler{Name: "pdb", Rule: &appsv1alpha1.PDBRefRule{Name: "pdb"}, Client: c}
+
+	// pod-a passed before and takes up one disruption
+	res := ruler.Filter(ptr, targets, sets.NewString("pod-a", "pod-b", "pod-c"))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(2))
+	g.Expect(res.Passed.Has("pod-a")).Should(gomega.BeTrue())
+	g.Expect(res.Rejected).Should(gomega.HaveLen(1))
+	for _, reason := range res.Rejected {
+		g.Expect(reason).Should(gomega.Equal("[pdb] no disruptions allowed by PodDisruptionBudget pdb: [disruptions allowed]=2, [current healthy]=3, [desired healthy]=1"))
+	}
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+
+	// missing PodDisruptionBudget
+	ruler.Rule.Name = "missing"
+	res = ruler.Filter(ptr, targets, sets.NewString("pod-b"))
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-b"))
+	ignore := appsv1alpha1.Ignore
+	ruler.Rule.FailurePolicy = &ignore
+	res = ruler.Filter(ptr, targets, sets.NewString("pod-b"))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(res.Passed.Has("pod-b")).Should(gomega.BeTrue())
+}
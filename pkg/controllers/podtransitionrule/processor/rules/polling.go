@@ -18,6 +18,7 @@ package rules
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -44,7 +45,7 @@ var (
 
 type PollingManagerInterface interface {
 	Delete(id string)
-	Add(id, url, caBundle, resourceKey string, timeout, interval time.Duration)
+	Add(id, url, caBundle string, pool *utilshttp.PoolConfig, resourceKey string, timeout, interval time.Duration)
 	GetResult(id string) *PollResult
 	Start(ctx context.Context)
 	AddListener(chan<- event.GenericEvent)
@@ -149,7 +150,7 @@ func (r *pollingRunner) addAfter(id string, d time.Duration) {
 	})
 }
 
-func (r *pollingRunner) Add(id, url, caBundle, resourceKey string, timeout, interval time.Duration) {
+func (r *pollingRunner) Add(id, url, caBundle string, pool *utilshttp.PoolConfig, resourceKey string, timeout, interval time.Duration) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	tm := time.Now()
@@ -157,6 +158,7 @@ func (r *pollingRunner) Add(id, url, caBundle, resourceKey string, timeout, inte
 		id:           id,
 		url:          url,
 		caBundle:     caBundle,
+		pool:         pool,
 		resourceKey:  resourceKey,
 		timeoutTime:  tm.Add(timeout),
 		deadlineTime: tm.Add(timeout + (TaskDeadLineSeconds-1)*time.Second),
@@ -195,6 +197,7 @@ type task struct {
 	id          string
 	url         string
 	caBundle    string
+	pool        *utilshttp.PoolConfig
 	resourceKey string
 
 	timeoutTime  time.Time
@@ -230,6 +233,9 @@ func (t *task) do() bool {
 	t.result.Approved.Insert(res.FinishedNames...)
 	t.result.Info = t.info()
 	t.result.LastMessage = res.Message
+	if res.DelayUntil != nil {
+		t.result.DelayUntil = res.DelayUntil
+	}
 	if res.Success && res.Finished {
 		t.result.ApproveAll = true
 		t.result.Stopped = true
@@ -241,12 +247,17 @@ func (t *task) do() bool {
 }
 
 func (t *task) query() (*appsv1alpha1.PollResponse, error) {
-	httpResp, err := utilshttp.DoHttpAndHttpsRequestWithCa(http.MethodGet, t.url, nil, nil, t.caBundle)
+	countWebhookCall(t.resourceKey)
+	httpResp, err := utilshttp.DoHttpAndHttpsRequestWithCaAndPool(context.Background(), http.MethodGet, t.url, nil, nil, t.caBundle, t.pool)
 	if err != nil {
 		return nil, err
 	}
+	var raw json.RawMessage
+	if err = utilshttp.ParseResponse(httpResp, &raw); err != nil {
+		return nil, err
+	}
 	resp := &appsv1alpha1.PollResponse{}
-	if err = utilshttp.ParseResponse(httpResp, resp); err != nil {
+	if err = parseResponse(raw, pollResponseSchema, resp); err != nil {
 		return nil, err
 	}
 
@@ -272,4 +283,5 @@ type PollResult struct {
 	Info          string
 	LastError     error
 	LastQueryTime time.Time
+	DelayUntil    *metav1.Time
 }
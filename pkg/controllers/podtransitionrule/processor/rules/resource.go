@@ -0,0 +1,118 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type ResourceStateRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.ResourceStateRule
+
+	Store *resources.Store
+}
+
+// Filter rejects pods while any selected resource is in blocking state
+func (r *ResourceStateRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	objs, err := r.list(podTransitionRule)
+	if err != nil {
+		return rejectAllWithErr(subjects, pass, rejects, "[%s] %v", r.Name, err)
+	}
+
+	var blockedBy string
+	blockingValues := sets.NewString(r.Rule.BlockingValues...)
+	for _, obj := range objs {
+		val, found, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(r.Rule.FieldPath, ".")...)
+		if err != nil || !found {
+			continue
+		}
+		if blockingValues.Has(fmt.Sprint(val)) {
+			blockedBy = fmt.Sprintf("[%s] blocked by %s %s: %s=%v", r.Name, r.Rule.Kind, obj.GetName(), r.Rule.FieldPath, val)
+			break
+		}
+	}
+
+	for podName := range subjects {
+		if blockedBy != "" && !utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			rejects[podName] = blockedBy
+			continue
+		}
+		pass.Insert(podName)
+	}
+	return &FilterResult{Passed: pass, Rejected: rejects}
+}
+
+// Versions returns the same token for all subjects, which changes once any selected resource changes
+func (r *ResourceStateRuler) Versions(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) map[string]string {
+	objs, err := r.list(podTransitionRule)
+	if err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		if obj.GetResourceVersion() == "" {
+			return nil
+		}
+		keys = append(keys, obj.GetName()+"/"+obj.GetResourceVersion())
+	}
+	sort.Strings(keys)
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(keys, ",")))
+	version := fmt.Sprintf("%x", h.Sum64())
+	versions := make(map[string]string, subjects.Len())
+	for podName := range subjects {
+		versions[podName] = version
+	}
+	return versions
+}
+
+func (r *ResourceStateRuler) list(podTransitionRule *appsv1alpha1.PodTransitionRule) ([]*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(r.Rule.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("fail to parse apiVersion %s, error: %v", r.Rule.APIVersion, err)
+	}
+	selector := labels.Everything()
+	if r.Rule.Selector != nil {
+		if selector, err = metav1.LabelSelectorAsSelector(r.Rule.Selector); err != nil {
+			return nil, fmt.Errorf("fail to parse selector, error: %v", err)
+		}
+	}
+	objs, err := r.Store.List(gv.WithKind(r.Rule.Kind), podTransitionRule.Namespace, selector)
+	if err != nil {
+		return nil, fmt.Errorf("fail to list %s, error: %v", r.Rule.Kind, err)
+	}
+	return objs, nil
+}
@@ -0,0 +1,78 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
+)
+
+func TestResourceState(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	store := resources.NewStore(func() int { return 10 })
+	maintenance := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "test.kusionstack.io/v1",
+		"kind":       "MaintenanceMode",
+		"metadata": map[string]interface{}{
+			"name":      "maintenance",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"active": false,
+		},
+	}}
+	g.Expect(store.Update(maintenance)).Should(gomega.Succeed())
+
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a"}).GetPod(),
+	}
+	ruler := &ResourceStateRuler{
+		Name: "maintenance",
+		Rule: &appsv1alpha1.ResourceStateRule{
+			APIVersion:     "test.kusionstack.io/v1",
+			Kind:           "MaintenanceMode",
+			FieldPath:      "spec.active",
+			BlockingValues: []string{"true"},
+		},
+		Store: store,
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+	rs.Namespace = "default"
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+	// resource without resourceVersion is not versioned
+	g.Expect(ruler.Versions(rs, targets, sets.NewString("test-pod-a"))).Should(gomega.BeEmpty())
+	maintenance.SetResourceVersion("1")
+	g.Expect(store.Update(maintenance)).Should(gomega.Succeed())
+	version := ruler.Versions(rs, targets, sets.NewString("test-pod-a"))["test-pod-a"]
+	g.Expect(version).ShouldNot(gomega.BeEmpty())
+
+	g.Expect(unstructured.SetNestedField(maintenance.Object, true, "spec", "active")).Should(gomega.Succeed())
+	maintenance.SetResourceVersion("2")
+	g.Expect(store.Update(maintenance)).Should(gomega.Succeed())
+	g.Expect(ruler.Versions(rs, targets, sets.NewString("test-pod-a"))["test-pod-a"]).ShouldNot(gomega.Equal(version))
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[maintenance] blocked by MaintenanceMode maintenance: spec.active=true"))
+}
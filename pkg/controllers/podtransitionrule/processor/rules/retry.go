@@ -0,0 +1,81 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultMaxRetryBackoff = time.Minute
+)
+
+// backoffWait returns the duration to wait before the webhook is requested again, false if it can be requested now
+func (w *Webhook) backoffWait(status *appsv1alpha1.WebhookRetryStatus, now time.Time) (time.Duration, bool) {
+	if w.Webhook.Retry == nil || status == nil || status.NextAttemptTime == nil || !now.Before(status.NextAttemptTime.Time) {
+		return 0, false
+	}
+	return status.NextAttemptTime.Sub(now), true
+}
+
+// recordFailure records a failed request on subjects in retry status of state, and returns true if attempts are
+// exhausted and subjects are passed by failure policy Ignore. Otherwise, the next attempt is scheduled after backoff.
+func (w *Webhook) recordFailure(state *appsv1alpha1.WebhookStatus, subjects sets.String, err error, now time.Time) bool {
+	retry := w.Webhook.Retry
+	var attempts int32
+	// attempts start over once exhausted attempts are ignored
+	if last := state.RetryStatus; last != nil && len(last.IgnoredPods) == 0 {
+		attempts = last.Attempts
+	}
+	attempts++
+	status := &appsv1alpha1.WebhookRetryStatus{
+		Attempts:  attempts,
+		LastError: err.Error(),
+	}
+	state.RetryStatus = status
+	if attempts >= retry.MaxAttempts && (w.Webhook.FailurePolicy == nil || *w.Webhook.FailurePolicy == appsv1alpha1.Ignore) {
+		status.IgnoredPods = subjects.List()
+		return true
+	}
+	next := metav1.NewTime(now.Add(retryBackoff(retry, attempts)))
+	status.NextAttemptTime = &next
+	return false
+}
+
+// retryBackoff returns the backoff after attempts failed requests, which is doubled after each one up to the max
+func retryBackoff(retry *appsv1alpha1.WebhookRetry, attempts int32) time.Duration {
+	backoff, maxBackoff := defaultRetryBackoff, defaultMaxRetryBackoff
+	if retry.BackoffSeconds != nil {
+		backoff = time.Duration(*retry.BackoffSeconds) * time.Second
+	}
+	if retry.MaxBackoffSeconds != nil {
+		maxBackoff = time.Duration(*retry.MaxBackoffSeconds) * time.Second
+	}
+	for i := int32(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
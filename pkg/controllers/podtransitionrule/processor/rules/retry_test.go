@@ -0,0 +1,132 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	backoff, maxBackoff := int32(2), int32(10)
+	retry := &appsv1alpha1.WebhookRetry{MaxAttempts: 5, BackoffSeconds: &backoff, MaxBackoffSeconds: &maxBackoff}
+	g.Expect(retryBackoff(retry, 1)).Should(gomega.Equal(2 * time.Second))
+	g.Expect(retryBackoff(retry, 2)).Should(gomega.Equal(4 * time.Second))
+	g.Expect(retryBackoff(retry, 3)).Should(gomega.Equal(8 * time.Second))
+	g.Expect(retryBackoff(retry, 4)).Should(gomega.Equal(10 * time.Second))
+	g.Expect(retryBackoff(&appsv1alpha1.WebhookRetry{MaxAttempts: 1}, 100)).Should(gomega.Equal(defaultMaxRetryBackoff))
+}
+
+func TestWebhookRetry(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	// flaky webhook succeeds on the third attempt
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			http.Error(resp, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handleHttpAlwaysSuccess(resp, req)
+	}))
+	defer server.Close()
+
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+		"test-pod-b": (&podTemplate{Name: "test-pod-b", Ip: "1.1.1.59"}).GetPod(),
+	}
+	subjects := sets.NewString("test-pod-a", "test-pod-b")
+	rs := normalRS.DeepCopy()
+	rs.Spec.Rules[0].Webhook.ClientConfig.URL = server.URL
+	rs.Spec.Rules[0].Webhook.Retry = &appsv1alpha1.WebhookRetry{MaxAttempts: 5}
+	do := func() *FilterResult {
+		res := GetWebhook(rs)[0].Do(targets, subjects)
+		rs.Status.RuleStates = []*appsv1alpha1.RuleState{res.RuleState}
+		return res
+	}
+	expire := func() {
+		past := metav1.NewTime(time.Now().Add(-time.Second))
+		rs.Status.RuleStates[0].WebhookStatus.RetryStatus.NextAttemptTime = &past
+	}
+
+	res := do()
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(*res.Interval).Should(gomega.BeNumerically("~", time.Second, 100*time.Millisecond))
+	g.Expect(res.RuleState.WebhookStatus.RetryStatus.Attempts).Should(gomega.BeEquivalentTo(1))
+
+	// not requested again during backoff
+	res = do()
+	g.Expect(atomic.LoadInt32(&requests)).Should(gomega.BeEquivalentTo(1))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.HavePrefix("Webhook default/podtransitionrule-test/test-webhook failed 1 times, retry at "))
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+
+	// backoff is doubled
+	expire()
+	res = do()
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	g.Expect(*res.Interval).Should(gomega.BeNumerically("~", 2*time.Second, 100*time.Millisecond))
+	g.Expect(res.RuleState.WebhookStatus.RetryStatus.Attempts).Should(gomega.BeEquivalentTo(2))
+
+	expire()
+	res = do()
+	g.Expect(atomic.LoadInt32(&requests)).Should(gomega.BeEquivalentTo(3))
+	g.Expect(res.Passed.List()).Should(gomega.Equal(subjects.List()))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(res.RuleState.WebhookStatus.RetryStatus).Should(gomega.BeNil())
+}
+
+func TestWebhookRetryExhausted(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		http.Error(resp, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	subjects := sets.NewString("test-pod-a")
+	ignore := appsv1alpha1.Ignore
+	rs := normalRS.DeepCopy()
+	rs.Spec.Rules[0].Webhook.ClientConfig.URL = server.URL
+	rs.Spec.Rules[0].Webhook.FailurePolicy = &ignore
+	rs.Spec.Rules[0].Webhook.Retry = &appsv1alpha1.WebhookRetry{MaxAttempts: 2}
+
+	res := GetWebhook(rs)[0].Do(targets, subjects)
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{res.RuleState}
+	past := metav1.NewTime(time.Now().Add(-time.Second))
+	rs.Status.RuleStates[0].WebhookStatus.RetryStatus.NextAttemptTime = &past
+
+	// passed by failure policy Ignore once attempts are exhausted
+	res = GetWebhook(rs)[0].Do(targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a"}))
+	g.Expect(res.Rejected).Should(gomega.BeEmpty())
+	g.Expect(res.RuleState.WebhookStatus.RetryStatus.Attempts).Should(gomega.BeEquivalentTo(2))
+	g.Expect(res.RuleState.WebhookStatus.RetryStatus.IgnoredPods).Should(gomega.Equal([]string{"test-pod-a"}))
+}
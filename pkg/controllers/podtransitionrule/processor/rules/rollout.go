@@ -0,0 +1,165 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type WorkloadRolloutRuler struct {
+	Name string
+
+	IgnoreScaling bool
+
+	Client client.Client
+}
+
+// rolloutStatus is the rollout progress of a workload
+type rolloutStatus struct {
+	kind string
+	key  string
+
+	generation         int64
+	observedGeneration int64
+	desiredReplicas    int32
+	replicas           int32
+	updatedReplicas    int32
+}
+
+// inFlight returns true if the workload is in the middle of rollout
+func (s *rolloutStatus) inFlight(ignoreScaling bool) bool {
+	if s.observedGeneration < s.generation {
+		return true
+	}
+	if s.updatedReplicas < s.replicas {
+		return true
+	}
+	return !ignoreScaling && s.updatedReplicas < s.desiredReplicas
+}
+
+func (s *rolloutStatus) String() string {
+	return fmt.Sprintf("[workload] %s %s, [observedGeneration]=%d/%d, [updatedReplicas]=%d/%d, [replicas]=%d",
+		s.kind, s.key, s.observedGeneration, s.generation, s.updatedReplicas, s.desiredReplicas, s.replicas)
+}
+
+// Filter rejects pods whose owner workload is in the middle of rollout, so that transitions of pods never overlap with rollout
+func (r *WorkloadRolloutRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	workloads := map[types.UID]*rolloutStatus{}
+	for _, podName := range subjects.List() {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		status, err := r.getRolloutStatus(targets[podName], workloads)
+		if err != nil {
+			return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get owner workload of pod %s, error: %v", r.Name, podName, err)
+		}
+		// pods not owned by Deployment or CollaSet are not blocked
+		if status == nil || !status.inFlight(r.IgnoreScaling) {
+			pass.Insert(podName)
+			continue
+		}
+		rejects[podName] = fmt.Sprintf("[%s] blocked by in-flight rollout: %s", r.Name, status.String())
+	}
+	return &FilterResult{Passed: pass, Rejected: rejects}
+}
+
+func (r *WorkloadRolloutRuler) getRolloutStatus(pod *corev1.Pod, workloads map[types.UID]*rolloutStatus) (*rolloutStatus, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil, nil
+	}
+	if status, ok := workloads[owner.UID]; ok {
+		return status, nil
+	}
+
+	var status *rolloutStatus
+	var err error
+	switch {
+	case owner.Kind == "CollaSet" && owner.APIVersion == appsv1alpha1.GroupVersion.String():
+		status, err = r.getCollaSetRolloutStatus(pod.Namespace, owner.Name)
+	case owner.Kind == "ReplicaSet" && owner.APIVersion == appsv1.SchemeGroupVersion.String():
+		status, err = r.getDeploymentRolloutStatus(pod.Namespace, owner.Name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	workloads[owner.UID] = status
+	return status, nil
+}
+
+func (r *WorkloadRolloutRuler) getCollaSetRolloutStatus(namespace, name string) (*rolloutStatus, error) {
+	cls := &appsv1alpha1.CollaSet{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, cls); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	status := &rolloutStatus{
+		kind:               "CollaSet",
+		key:                namespace + "/" + name,
+		generation:         cls.Generation,
+		observedGeneration: cls.Status.ObservedGeneration,
+		replicas:           cls.Status.Replicas,
+		updatedReplicas:    cls.Status.UpdatedReplicas,
+		desiredReplicas:    1,
+	}
+	if cls.Spec.Replicas != nil {
+		status.desiredReplicas = *cls.Spec.Replicas
+	}
+	return status, nil
+}
+
+func (r *WorkloadRolloutRuler) getDeploymentRolloutStatus(namespace, replicaSetName string) (*rolloutStatus, error) {
+	rs := &appsv1.ReplicaSet{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: replicaSetName}, rs); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	owner := metav1.GetControllerOf(rs)
+	if owner == nil || owner.Kind != "Deployment" || owner.APIVersion != appsv1.SchemeGroupVersion.String() {
+		return nil, nil
+	}
+	deploy := &appsv1.Deployment{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: owner.Name}, deploy); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	status := &rolloutStatus{
+		kind:               "Deployment",
+		key:                namespace + "/" + owner.Name,
+		generation:         deploy.Generation,
+		observedGeneration: deploy.Status.ObservedGeneration,
+		replicas:           deploy.Status.Replicas,
+		updatedReplicas:    deploy.Status.UpdatedReplicas,
+		desiredReplicas:    1,
+	}
+	if deploy.Spec.Replicas != nil {
+		status.desiredReplicas = *deploy.Spec.Replicas
+	}
+	return status, nil
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestWorkloadRollout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).Should(gomega.Succeed())
+	g.Expect(appsv1alpha1.AddToScheme(scheme)).Should(gomega.Succeed())
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy", Namespace: "default", UID: "deploy", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: pointer.Int32(3)},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 1},
+	}
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "deploy-rs", Namespace: "default", UID: "deploy-rs",
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deploy, appsv1.SchemeGroupVersion.WithKind("Deployment"))}},
+	}
+	cls := &appsv1alpha1.CollaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "cls", Namespace: "default", UID: "cls", Generation: 1},
+		Spec:       appsv1alpha1.CollaSetSpec{Replicas: pointer.Int32(2)},
+		Status:     appsv1alpha1.CollaSetStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deploy, replicaSet, cls).Build()
+
+	targets := map[string]*corev1.Pod{}
+	for name, owner := range map[string]*metav1.OwnerReference{
+		"pod-deploy": metav1.NewControllerRef(replicaSet, appsv1.SchemeGroupVersion.WithKind("ReplicaSet")),
+		"pod-cls":    metav1.NewControllerRef(cls, appsv1alpha1.GroupVersion.WithKind("CollaSet")),
+		"pod-bare":   nil,
+	} {
+		pod := (&podTemplate{Name: name}).GetPod()
+		if owner != nil {
+			pod.OwnerReferences = []metav1.OwnerReference{*owner}
+		}
+		targets[name] = pod
+	}
+	ruler := &WorkloadRolloutRuler{Name: "rollout", Client: c}
+	res := ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-deploy", "pod-cls", "pod-bare"))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"pod-bare", "pod-cls"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-deploy"))
+	g.Expect(strings.Contains(res.Rejected["pod-deploy"], "Deployment default/deploy")).Should(gomega.BeTrue())
+	g.Expect(strings.Contains(res.Rejected["pod-deploy"], "[updatedReplicas]=1/3")).Should(gomega.BeTrue())
+
+	// generation not observed yet
+	cls.Generation = 2
+	g.Expect(c.Update(context.TODO(), cls)).Should(gomega.Succeed())
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-cls"))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-cls"))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type fieldType string
+
+const (
+	fieldBool        fieldType = "boolean"
+	fieldString      fieldType = "string"
+	fieldStringArray fieldType = "array of string"
+	fieldTime        fieldType = "RFC3339 time string"
+)
+
+type fieldSchema struct {
+	Type     fieldType
+	Required bool
+}
+
+// responseSchema describes the fields of a webhook response, unknown fields are allowed
+type responseSchema map[string]fieldSchema
+
+var (
+	webhookResponseSchema = responseSchema{
+		"success":       {Type: fieldBool, Required: true},
+		"message":       {Type: fieldString},
+		"finishedNames": {Type: fieldStringArray},
+		"poll":          {Type: fieldBool},
+		"async":         {Type: fieldBool},
+		"traceId":       {Type: fieldString},
+		"taskId":        {Type: fieldString},
+		"delayUntil":    {Type: fieldTime},
+	}
+
+	pollResponseSchema = responseSchema{
+		"success":       {Type: fieldBool, Required: true},
+		"message":       {Type: fieldString},
+		"finished":      {Type: fieldBool},
+		"finishedNames": {Type: fieldStringArray},
+		"stop":          {Type: fieldBool},
+		"delayUntil":    {Type: fieldTime},
+	}
+)
+
+// SchemaError indicates a webhook response violates the schema at Path
+type SchemaError struct {
+	Path   string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("invalid webhook response at %s: %s", e.Path, e.Reason)
+}
+
+// Validate checks data against schema, and returns SchemaError with the offending field path
+func (s responseSchema) Validate(data []byte) error {
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return &SchemaError{Path: "$", Reason: fmt.Sprintf("expected JSON object, %v", err)}
+	}
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	// ensure the order of errors
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := s[key]
+		path := "$." + key
+		val, ok := obj[key]
+		if !ok || val == nil {
+			if field.Required {
+				return &SchemaError{Path: path, Reason: "required field is missing"}
+			}
+			continue
+		}
+		if err := field.validate(path, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fieldSchema) validate(path string, val interface{}) error {
+	switch f.Type {
+	case fieldBool:
+		if _, ok := val.(bool); !ok {
+			return typeError(path, f.Type, val)
+		}
+	case fieldString:
+		if _, ok := val.(string); !ok {
+			return typeError(path, f.Type, val)
+		}
+	case fieldTime:
+		str, ok := val.(string)
+		if !ok {
+			return typeError(path, f.Type, val)
+		}
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("expected %s, got %q", f.Type, str)}
+		}
+	case fieldStringArray:
+		items, ok := val.([]interface{})
+		if !ok {
+			return typeError(path, f.Type, val)
+		}
+		for i, item := range items {
+			if _, ok := item.(string); !ok {
+				return typeError(fmt.Sprintf("%s[%d]", path, i), fieldString, item)
+			}
+		}
+	}
+	return nil
+}
+
+func typeError(path string, expected fieldType, val interface{}) error {
+	return &SchemaError{Path: path, Reason: fmt.Sprintf("expected %s, got %s", expected, jsonKind(val))}
+}
+
+func jsonKind(val interface{}) string {
+	switch val.(type) {
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "null"
+	}
+}
+
+// parseResponse validates data against schema before decoding it into resp
+func parseResponse(data []byte, schema responseSchema, resp interface{}) error {
+	if err := schema.Validate(data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, resp)
+}
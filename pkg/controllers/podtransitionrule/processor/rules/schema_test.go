@@ -0,0 +1,88 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestValidateWebhookResponse(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	cases := []struct {
+		resp string
+		path string
+	}{
+		{resp: `{"success": true, "message": "ok", "finishedNames": ["a"], "delayUntil": "2023-01-01T00:00:00Z", "unknown": 1}`},
+		{resp: `[]`, path: "$"},
+		{resp: `not json`, path: "$"},
+		{resp: `{"message": "ok"}`, path: "$.success"},
+		{resp: `{"success": "true"}`, path: "$.success"},
+		{resp: `{"success": true, "message": 1}`, path: "$.message"},
+		{resp: `{"success": true, "finishedNames": "a"}`, path: "$.finishedNames"},
+		{resp: `{"success": true, "finishedNames": ["a", 1]}`, path: "$.finishedNames[1]"},
+		{resp: `{"success": true, "poll": "yes"}`, path: "$.poll"},
+		{resp: `{"success": true, "delayUntil": "30s"}`, path: "$.delayUntil"},
+	}
+	for _, c := range cases {
+		err := webhookResponseSchema.Validate([]byte(c.resp))
+		if c.path == "" {
+			g.Expect(err).ShouldNot(gomega.HaveOccurred(), c.resp)
+			continue
+		}
+		g.Expect(err).Should(gomega.HaveOccurred(), c.resp)
+		schemaErr, ok := err.(*SchemaError)
+		g.Expect(ok).Should(gomega.BeTrue(), c.resp)
+		g.Expect(schemaErr.Path).Should(gomega.Equal(c.path), c.resp)
+	}
+}
+
+func TestValidatePollResponse(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	g.Expect(pollResponseSchema.Validate([]byte(`{"success": true, "finished": false, "stop": false}`))).ShouldNot(gomega.HaveOccurred())
+	err := pollResponseSchema.Validate([]byte(`{"success": true, "finished": "no"}`))
+	g.Expect(err).Should(gomega.HaveOccurred())
+	g.Expect(err.Error()).Should(gomega.ContainSubstring("$.finished"))
+}
+
+func handleHttpMalformed(resp http.ResponseWriter, req *http.Request) {
+	fmt.Printf("handleHttpMalformed, %s\n", req.URL)
+	resp.Write([]byte(`{"success": true, "finishedNames": ["test-pod-a", 1]}`))
+}
+
+func TestWebhookMalformedResponse(t *testing.T) {
+	stop, finish := RunHttpServer(handleHttpMalformed, "8888")
+	defer func() {
+		stop <- struct{}{}
+		<-finish
+	}()
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	g := gomega.NewGomegaWithT(t)
+	web := GetWebhook(normalRS)[0]
+	res := web.Do(targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(strings.Contains(res.Rejected["test-pod-a"], "$.finishedNames[1]")).Should(gomega.BeTrue())
+}
@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
@@ -88,6 +89,18 @@ func handleHttpAlwaysSuccess(resp http.ResponseWriter, req *http.Request) {
 	resp.Write(byt)
 }
 
+func handleHttpSuccessWithDelay(resp http.ResponseWriter, req *http.Request) {
+	fmt.Printf("handleHttpSuccessWithDelay, %s\n", req.URL)
+	delayUntil := metav1.NewTime(time.Now().Add(30 * time.Second))
+	webhookResp := &appsv1alpha1.WebhookResponse{
+		Success:    true,
+		Message:    "test success with delay",
+		DelayUntil: &delayUntil,
+	}
+	byt, _ := json.Marshal(webhookResp)
+	resp.Write(byt)
+}
+
 func handleHttpAlwaysFalse(resp http.ResponseWriter, req *http.Request) {
 	fmt.Printf("handleHttpAlwaysFalse, %s\n", req.URL)
 	all, err := io.ReadAll(req.Body)
@@ -215,6 +228,27 @@ func handleHttpWithTaskIdSucc(resp http.ResponseWriter, req *http.Request) {
 	resp.Write(byt)
 }
 
+func handleHttpWithTaskIdSuccWithDelay(resp http.ResponseWriter, req *http.Request) {
+	fmt.Printf("handleHttpWithTaskIdSuccWithDelay, %s\n", req.URL)
+	taskId := req.URL.Query().Get("trace-id")
+
+	col, ok := traceCache[taskId]
+	if !ok {
+		panic(fmt.Sprintf("taskId %s not found", taskId))
+	}
+	passed, ok := col.getNow()
+	delayUntil := metav1.NewTime(time.Now().Add(30 * time.Second))
+	webhookResp := &appsv1alpha1.PollResponse{
+		Success:       true,
+		Message:       fmt.Sprintf("server: success size %d", passed.Len()),
+		Finished:      ok,
+		FinishedNames: passed.List(),
+		DelayUntil:    &delayUntil,
+	}
+	byt, _ := json.Marshal(webhookResp)
+	resp.Write(byt)
+}
+
 func handleHttpWithTaskIdFail(resp http.ResponseWriter, req *http.Request) {
 	fmt.Printf("handleHttpWithTaskIdFail, %s\n", req.URL)
 	taskId := req.URL.Query().Get("trace-id")
@@ -0,0 +1,206 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+	utilshttp "kusionstack.io/operating/pkg/utils/http"
+)
+
+const (
+	// DefaultSidecarContainerName is the sidecar container injected by istio
+	DefaultSidecarContainerName = "istio-proxy"
+
+	SidecarStateReady      = "Ready"
+	SidecarStateDrained    = "Drained"
+	SidecarStateTerminated = "Terminated"
+	SidecarStateUnknown    = "Unknown"
+)
+
+type SidecarDrainRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.SidecarDrainRule
+}
+
+// Filter rejects pods until their service mesh sidecar is drained or max wait is reached
+func (r *SidecarDrainRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	beginTimes := drainBeginTimes(podTransitionRule, r.Name)
+	now := metav1.Now()
+	maxWait := time.Duration(r.Rule.MaxWaitSeconds) * time.Second
+	containerName := r.containerName()
+	drainStatus := &appsv1alpha1.DrainStatus{}
+	for _, podName := range subjects.List() {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		pod := targets[podName]
+		crashLoop := crashLoopingContainer(pod)
+		if crashLoop != "" && r.Rule.CrashLoopPolicy == appsv1alpha1.CrashLoopPolicyAlwaysPass {
+			pass.Insert(podName)
+			continue
+		}
+		if crashLoop != "" && r.Rule.CrashLoopPolicy == appsv1alpha1.CrashLoopPolicyAlwaysBlock {
+			rejects[podName] = crashLoopBlockedReason(r.Name, crashLoop)
+			continue
+		}
+		container := getContainer(pod, containerName)
+		// pods without sidecar or ip have nothing to drain
+		if container == nil || pod.Status.PodIP == "" {
+			pass.Insert(podName)
+			continue
+		}
+		beginTime, ok := beginTimes[podName]
+		if !ok {
+			beginTime = now
+		}
+		elapsed := now.Sub(beginTime.Time)
+
+		state, err := r.querySidecarState(pod, container)
+		if err == nil && state != SidecarStateReady {
+			pass.Insert(podName)
+			continue
+		}
+		if maxWait > 0 && elapsed >= maxWait {
+			pass.Insert(podName)
+			continue
+		}
+		if err != nil {
+			state = SidecarStateUnknown
+			rejects[podName] = fmt.Sprintf("[%s] fail to query sidecar state, [container]=%s, [elapsed]=%s%s, error: %v", r.Name, containerName, drainElapsedString(elapsed, r.Rule.MaxWaitSeconds), crashLoopString(crashLoop), err)
+		} else {
+			rejects[podName] = fmt.Sprintf("[%s] waiting for sidecar drained: [container]=%s, [state]=%s, [elapsed]=%s%s", r.Name, containerName, state, drainElapsedString(elapsed, r.Rule.MaxWaitSeconds), crashLoopString(crashLoop))
+		}
+		drainStatus.Pods = append(drainStatus.Pods, appsv1alpha1.DrainingPod{
+			Name:         podName,
+			BeginTime:    beginTime,
+			SidecarState: state,
+		})
+	}
+
+	res := &FilterResult{
+		Passed:    pass,
+		Rejected:  rejects,
+		RuleState: &appsv1alpha1.RuleState{Name: r.Name, DrainStatus: drainStatus},
+	}
+	if len(drainStatus.Pods) > 0 {
+		interval := drainPollInterval
+		res.Interval = &interval
+	}
+	return res
+}
+
+func (r *SidecarDrainRuler) containerName() string {
+	if r.Rule.ContainerName != "" {
+		return r.Rule.ContainerName
+	}
+	return DefaultSidecarContainerName
+}
+
+// querySidecarState returns Ready if the sidecar is still serving, otherwise it is drained or terminated
+func (r *SidecarDrainRuler) querySidecarState(pod *corev1.Pod, container *corev1.Container) (string, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container.Name && status.State.Terminated != nil {
+			return SidecarStateTerminated, nil
+		}
+	}
+	port, path, err := r.drainEndpoint(container)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port))), path)
+	resp, err := utilshttp.DoHttpAndHttpsRequestWithCa(http.MethodGet, url, nil, nil, "")
+	if err != nil {
+		// sidecar stops listening after drained
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return SidecarStateDrained, nil
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return SidecarStateDrained, nil
+	}
+	return SidecarStateReady, nil
+}
+
+// drainEndpoint returns port and path of the drain endpoint, which defaults to the HTTP readiness probe of sidecar
+func (r *SidecarDrainRuler) drainEndpoint(container *corev1.Container) (int32, string, error) {
+	port, path := r.Rule.Port, r.Rule.Path
+	var probe *corev1.HTTPGetAction
+	if container.ReadinessProbe != nil {
+		probe = container.ReadinessProbe.HTTPGet
+	}
+	if port == 0 {
+		if probe == nil {
+			return 0, "", fmt.Errorf("port is not set and container %s has no HTTP readiness probe", container.Name)
+		}
+		var err error
+		if port, err = resolveContainerPort(container, probe.Port); err != nil {
+			return 0, "", err
+		}
+	}
+	if path == "" && probe != nil {
+		path = probe.Path
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return port, path, nil
+}
+
+func resolveContainerPort(container *corev1.Container, port intstr.IntOrString) (int32, error) {
+	if port.Type == intstr.Int {
+		return port.IntVal, nil
+	}
+	for _, p := range container.Ports {
+		if p.Name == port.StrVal {
+			return p.ContainerPort, nil
+		}
+	}
+	return 0, fmt.Errorf("port %s not found in container %s", port.StrVal, container.Name)
+}
+
+func getContainer(pod *corev1.Pod, name string) *corev1.Container {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return &pod.Spec.Containers[i]
+		}
+	}
+	return nil
+}
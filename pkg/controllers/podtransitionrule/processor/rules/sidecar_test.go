@@ -0,0 +1,91 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestSidecarDrain(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ready := true
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/healthz/ready" || !ready {
+			resp.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	portNum, _ := strconv.Atoi(port)
+
+	pod := (&podTemplate{Name: "test-pod-a", Ip: "127.0.0.1"}).GetPod()
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+		Name:  DefaultSidecarContainerName,
+		Ports: []corev1.ContainerPort{{Name: "http-envoy-prom", ContainerPort: int32(portNum)}},
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/healthz/ready", Port: intstr.FromString("http-envoy-prom")},
+			},
+		},
+	})
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": pod,
+		"test-pod-b": (&podTemplate{Name: "test-pod-b", Ip: "127.0.0.1"}).GetPod(),
+	}
+	ruler := &SidecarDrainRuler{
+		Name: "sidecar",
+		Rule: &appsv1alpha1.SidecarDrainRule{MaxWaitSeconds: 60},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+
+	// drain endpoint defaults to readiness probe, pods without sidecar pass
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a", "test-pod-b"))
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b"}))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[sidecar] waiting for sidecar drained: [container]=istio-proxy, [state]=Ready, [elapsed]=0s/1m0s"))
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+	g.Expect(res.RuleState.DrainStatus.Pods[0].SidecarState).Should(gomega.Equal(SidecarStateReady))
+
+	// drained
+	ready = false
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+	g.Expect(res.Interval).Should(gomega.BeNil())
+
+	// sidecar stops listening
+	ready = true
+	server.Close()
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Has("test-pod-a")).Should(gomega.BeTrue())
+
+	// no drain endpoint
+	ruler.Rule.ContainerName = "nginx"
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.ContainSubstring("fail to query sidecar state, [container]=nginx"))
+	g.Expect(res.RuleState.DrainStatus.Pods[0].SidecarState).Should(gomega.Equal(SidecarStateUnknown))
+}
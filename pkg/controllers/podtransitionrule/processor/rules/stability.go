@@ -0,0 +1,89 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type PodStabilityRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.PodStabilityRule
+}
+
+// Filter rejects pods with containers restarted more than max restarts, so that flapping pods are not disrupted further
+func (r *PodStabilityRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	now := time.Now()
+	for _, podName := range subjects.List() {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		status := r.unstableContainer(targets[podName], now)
+		if status == nil {
+			pass.Insert(podName)
+			continue
+		}
+		rejects[podName] = fmt.Sprintf("[%s] blocked by unstable container: [container]=%s, [restarts]=%d/%d%s",
+			r.Name, status.Name, status.RestartCount, r.Rule.MaxRestarts, lastTerminationString(status))
+	}
+	return &FilterResult{Passed: pass, Rejected: rejects}
+}
+
+// unstableContainer returns the container restarted most among containers exceeding max restarts
+func (r *PodStabilityRuler) unstableContainer(pod *corev1.Pod, now time.Time) *corev1.ContainerStatus {
+	reasons := sets.NewString(r.Rule.BlockingReasons...)
+	var unstable *corev1.ContainerStatus
+	for i := range pod.Status.ContainerStatuses {
+		status := &pod.Status.ContainerStatuses[i]
+		if status.RestartCount <= r.Rule.MaxRestarts {
+			continue
+		}
+		terminated := status.LastTerminationState.Terminated
+		if r.Rule.WithinSeconds > 0 {
+			if terminated == nil || now.Sub(terminated.FinishedAt.Time) > time.Duration(r.Rule.WithinSeconds)*time.Second {
+				continue
+			}
+		}
+		if reasons.Len() > 0 && (terminated == nil || !reasons.Has(terminated.Reason)) {
+			continue
+		}
+		if unstable == nil || status.RestartCount > unstable.RestartCount {
+			unstable = status
+		}
+	}
+	return unstable
+}
+
+func lastTerminationString(status *corev1.ContainerStatus) string {
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		return ""
+	}
+	return fmt.Sprintf(", [last termination]=%s(exit code %d) at %s", terminated.Reason, terminated.ExitCode, terminated.FinishedAt.UTC().Format(time.RFC3339))
+}
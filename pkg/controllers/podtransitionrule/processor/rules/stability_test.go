@@ -0,0 +1,74 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestPodStability(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	finishedAt := metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	restarted := func(name string, restarts int32, reason string, finishedAt metav1.Time) *corev1.Pod {
+		pod := (&podTemplate{Name: name}).GetPod()
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+			{
+				Name:         "nginx",
+				RestartCount: restarts,
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: reason, ExitCode: 137, FinishedAt: finishedAt},
+				},
+			},
+		}
+		return pod
+	}
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": restarted("test-pod-a", 5, "OOMKilled", finishedAt),
+		"test-pod-b": restarted("test-pod-b", 1, "OOMKilled", finishedAt),
+		"test-pod-c": restarted("test-pod-c", 5, "Error", metav1.Now()),
+	}
+	ruler := &PodStabilityRuler{
+		Name: "stability",
+		Rule: &appsv1alpha1.PodStabilityRule{MaxRestarts: 3},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+	subjects := sets.NewString("test-pod-a", "test-pod-b", "test-pod-c")
+	res := ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b"}))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[stability] blocked by unstable container: [container]=nginx, [restarts]=5/3, [last termination]=OOMKilled(exit code 137) at 2023-01-01T00:00:00Z"))
+
+	// only recent restarts are counted
+	ruler.Rule.WithinSeconds = 600
+	res = ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a", "test-pod-b"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-c"))
+
+	// only blocking reasons are counted
+	ruler.Rule.WithinSeconds = 0
+	ruler.Rule.BlockingReasons = []string{"OOMKilled"}
+	res = ruler.Filter(rs, targets, subjects)
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-b", "test-pod-c"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+}
@@ -0,0 +1,115 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type TimeWindowRuler struct {
+	Name string
+
+	Rule *appsv1alpha1.TimeWindowRule
+}
+
+// Filter passes pods only while a maintenance window is open, rejected pods are filtered again once the next window opens
+func (r *TimeWindowRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	return r.filter(podTransitionRule, subjects, time.Now())
+}
+
+func (r *TimeWindowRuler) filter(podTransitionRule *appsv1alpha1.PodTransitionRule, subjects sets.String, now time.Time) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+	for podName := range subjects {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+		}
+	}
+	if pass.Len() == subjects.Len() {
+		return &FilterResult{Passed: pass, Rejected: rejects}
+	}
+
+	open, next, err := r.window(now)
+	if err != nil {
+		return rejectAllWithErr(subjects, pass, rejects, "[%s] invalid time window: %v", r.Name, err)
+	}
+	if open {
+		pass.Insert(subjects.UnsortedList()...)
+		return &FilterResult{Passed: pass, Rejected: rejects}
+	}
+	if next.IsZero() {
+		reject(subjects, pass, rejects, fmt.Sprintf("[%s] outside maintenance window", r.Name))
+		return &FilterResult{Passed: pass, Rejected: rejects}
+	}
+	reject(subjects, pass, rejects, fmt.Sprintf("[%s] outside maintenance window, [next window]=%s", r.Name, next.Format(time.RFC3339)))
+	interval := next.Sub(now)
+	return &FilterResult{Passed: pass, Rejected: rejects, Interval: &interval}
+}
+
+// window returns whether any window is open at now, or else the time the next window opens
+func (r *TimeWindowRuler) window(now time.Time) (bool, time.Time, error) {
+	loc, err := time.LoadLocation(r.Rule.TimeZone)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	now = now.In(loc)
+	var next time.Time
+	for _, window := range r.Rule.Daily {
+		start, err := utils.ParseTimeOfDay(window.Start)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		end, err := utils.ParseTimeOfDay(window.End)
+		if err != nil {
+			return false, time.Time{}, err
+		}
+		length := end - start
+		if length <= 0 {
+			length += 24 * time.Hour
+		}
+		days := map[time.Weekday]bool{}
+		for _, day := range window.Days {
+			weekday, err := utils.ParseWeekday(day)
+			if err != nil {
+				return false, time.Time{}, err
+			}
+			days[weekday] = true
+		}
+
+		// the window opened yesterday may still be open, and the next one opens within a week
+		for offset := -1; offset <= 7; offset++ {
+			opens := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, loc).Add(start)
+			if len(days) > 0 && !days[opens.Weekday()] {
+				continue
+			}
+			if !now.Before(opens) && now.Before(opens.Add(length)) {
+				return true, time.Time{}, nil
+			}
+			if opens.After(now) && (next.IsZero() || opens.Before(next)) {
+				next = opens
+			}
+		}
+	}
+	return false, next, nil
+}
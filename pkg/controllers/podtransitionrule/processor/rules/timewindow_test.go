@@ -0,0 +1,72 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestTimeWindow(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ruler := &TimeWindowRuler{
+		Name: "window",
+		Rule: &appsv1alpha1.TimeWindowRule{
+			Daily: []appsv1alpha1.DailyWindow{
+				{Start: "22:00", End: "02:00", Days: []string{"Fri", "saturday"}},
+			},
+			TimeZone: "Asia/Shanghai",
+		},
+	}
+	rs := &appsv1alpha1.PodTransitionRule{}
+	subjects := sets.NewString("test-pod-a", "test-pod-b")
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+
+	// Friday 23:00 and Saturday 01:00 are in the window opened on Friday
+	for _, now := range []time.Time{
+		time.Date(2023, 1, 6, 23, 0, 0, 0, loc),
+		time.Date(2023, 1, 7, 1, 0, 0, 0, loc),
+	} {
+		res := ruler.filter(rs, subjects, now)
+		g.Expect(res.Passed.List()).Should(gomega.Equal(subjects.List()))
+		g.Expect(res.Interval).Should(gomega.BeNil())
+	}
+
+	// Sunday 01:00 is in the window opened on Saturday, closed at 02:00
+	res := ruler.filter(rs, subjects, time.Date(2023, 1, 8, 1, 0, 0, 0, loc))
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(2))
+
+	// Sunday 03:00 waits until next Friday 22:00
+	now := time.Date(2023, 1, 8, 3, 0, 0, 0, loc)
+	res = ruler.filter(rs, subjects, now)
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.Equal("[window] outside maintenance window, [next window]=2023-01-13T22:00:00+08:00"))
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+	g.Expect(*res.Interval).Should(gomega.Equal(5*24*time.Hour + 19*time.Hour))
+
+	// time zone defaults to UTC
+	ruler.Rule.TimeZone = ""
+	res = ruler.filter(rs, subjects, time.Date(2023, 1, 6, 23, 0, 0, 0, loc))
+	g.Expect(res.Passed.Len()).Should(gomega.Equal(0))
+	g.Expect(*res.Interval).Should(gomega.Equal(7 * time.Hour))
+}
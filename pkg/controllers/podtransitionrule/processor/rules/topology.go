@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+type TopologySpreadRuler struct {
+	Name string
+
+	TopologyKey           string
+	MinAvailablePerDomain *intstr.IntOrString
+
+	Client client.Client
+}
+
+type topologyDomain struct {
+	total     int
+	available int
+}
+
+// Filter approves available pods as long as their topology domains keep min available pods
+func (r *TopologySpreadRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+
+	podDomains := map[string]string{}
+	domains := map[string]*topologyDomain{}
+	for podName, pod := range targets {
+		domain, err := r.getPodDomain(pod)
+		if err != nil {
+			return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get topology domain of pod %s, error: %v", r.Name, podName, err)
+		}
+		// pods not scheduled or on nodes without topology key are not counted
+		if domain == "" {
+			continue
+		}
+		podDomains[podName] = domain
+		if domains[domain] == nil {
+			domains[domain] = &topologyDomain{}
+		}
+		domains[domain].total++
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			continue
+		}
+		if isUnavailable, _ := processUnavailableFunc(pod); isUnavailable {
+			continue
+		}
+		domains[domain].available++
+	}
+
+	for _, podName := range subjects.List() {
+		pod := targets[podName]
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		if isUnavailable, _ := processUnavailableFunc(pod); isUnavailable {
+			pass.Insert(podName)
+			continue
+		}
+		domainName, ok := podDomains[podName]
+		if !ok {
+			pass.Insert(podName)
+			continue
+		}
+		domain := domains[domainName]
+		floor, err := intstr.GetScaledValueFromIntOrPercent(r.MinAvailablePerDomain, domain.total, true)
+		if err != nil {
+			return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to get int value from raw min available per domain value(%s), error: %v", r.Name, r.MinAvailablePerDomain.String(), err)
+		}
+		if domain.available-1 < floor {
+			rejects[podName] = fmt.Sprintf("[%s] blocked by topology spread policy: [domain] %s=%s, [min available]=%d/%d, [current available]=%d/%d", r.Name, r.TopologyKey, domainName, floor, domain.total, domain.available, domain.total)
+			continue
+		}
+		domain.available--
+		pass.Insert(podName)
+	}
+	return &FilterResult{Passed: pass, Rejected: rejects}
+}
+
+func (r *TopologySpreadRuler) getPodDomain(pod *corev1.Pod) (string, error) {
+	if pod.Spec.NodeName == "" {
+		return "", nil
+	}
+	node := &corev1.Node{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pod.Spec.NodeName}, node); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	return node.Labels[r.TopologyKey], nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rules
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestTopologySpread(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	zoneKey := "topology.kubernetes.io/zone"
+	c := fake.NewClientBuilder().WithObjects(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{zoneKey: "zone-a"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-b", Labels: map[string]string{zoneKey: "zone-b"}}},
+	).Build()
+
+	targets := map[string]*corev1.Pod{}
+	for name, node := range map[string]string{
+		"pod-a1": "node-a", "pod-a2": "node-a", "pod-a3": "node-a",
+		"pod-b1": "node-b", "pod-b2": "node-b",
+	} {
+		pod := (&podTemplate{Name: name}).GetPod()
+		pod.Spec.NodeName = node
+		targets[name] = pod
+	}
+	floor := intstr.FromInt(2)
+	ruler := &TopologySpreadRuler{
+		Name:                  "topology",
+		TopologyKey:           zoneKey,
+		MinAvailablePerDomain: &floor,
+		Client:                c,
+	}
+	res := ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-a1", "pod-a2", "pod-b1"))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	// zone-a keeps 2 of 3, zone-b can not go below 2
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"pod-a1"}))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-a2"))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-b1"))
+	g.Expect(strings.Contains(res.Rejected["pod-b1"], "topology.kubernetes.io/zone=zone-b")).Should(gomega.BeTrue())
+	g.Expect(strings.Contains(res.Rejected["pod-b1"], "[current available]=2/2")).Should(gomega.BeTrue())
+}
@@ -18,6 +18,7 @@ package rules
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -25,18 +26,37 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/resources"
 )
 
+// Ruler checks pods against a rule. Filter checks subjects, which are pods in the stage of the rule and not rejected
+// by former rules, and returns the passed and rejected ones among them. Targets are all pods of podTransitionRule keyed
+// by target key, for rules counting other pods, e.g. available policy.
 type Ruler interface {
 	Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult
 }
 
+// VersionedRuler is a Ruler whose verdict on a pod only changes with a version token. Its verdicts are
+// cached and reused while the token of a pod is unchanged, and pods are filtered again once it changes.
+type VersionedRuler interface {
+	Ruler
+	// Versions returns the version tokens of subjects, pods without a token are never cached
+	Versions(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) map[string]string
+}
+
 type FilterResult struct {
 	Passed   sets.String
 	Rejected map[string]string
 	Interval *time.Duration
 	Err      error
 
+	// DelayUntil contains passed pods which should be blocked until the given time
+	DelayUntil map[string]time.Time
+
+	// Pending contains rejected pods which are awaiting external state, e.g. an async webhook task,
+	// rather than actively rejected
+	Pending sets.String
+
 	RuleState *appsv1alpha1.RuleState
 }
 
@@ -47,6 +67,7 @@ func GetRuler(rule *appsv1alpha1.TransitionRule, client client.Client) Ruler {
 			Client:              client,
 			MinAvailableValue:   rule.AvailablePolicy.MinAvailableValue,
 			MaxUnavailableValue: rule.AvailablePolicy.MaxUnavailableValue,
+			ContainerReadiness:  rule.AvailablePolicy.ContainerReadiness,
 			Name:                rule.Name,
 		}
 	}
@@ -56,8 +77,101 @@ func GetRuler(rule *appsv1alpha1.TransitionRule, client client.Client) Ruler {
 			Selector: rule.LabelCheck.Requires,
 		}
 	}
+	if rule.TopologySpread != nil {
+		return &TopologySpreadRuler{
+			Client:                client,
+			TopologyKey:           rule.TopologySpread.TopologyKey,
+			MinAvailablePerDomain: rule.TopologySpread.MinAvailablePerDomain,
+			Name:                  rule.Name,
+		}
+	}
+	if rule.WorkloadRollout != nil {
+		return &WorkloadRolloutRuler{
+			Client:        client,
+			IgnoreScaling: rule.WorkloadRollout.IgnoreScaling,
+			Name:          rule.Name,
+		}
+	}
+	if rule.ResourceState != nil {
+		return &ResourceStateRuler{
+			Rule:  rule.ResourceState,
+			Store: resources.DefaultStore,
+			Name:  rule.Name,
+		}
+	}
+	if rule.ConnectionDrain != nil {
+		return &ConnectionDrainRuler{
+			Rule: rule.ConnectionDrain,
+			Name: rule.Name,
+		}
+	}
+	if rule.SidecarDrain != nil {
+		return &SidecarDrainRuler{
+			Rule: rule.SidecarDrain,
+			Name: rule.Name,
+		}
+	}
+	if rule.ManualApproval != nil {
+		return &ManualApprovalRuler{
+			Rule: rule.ManualApproval,
+			Name: rule.Name,
+		}
+	}
+	if rule.PodStability != nil {
+		return &PodStabilityRuler{
+			Rule: rule.PodStability,
+			Name: rule.Name,
+		}
+	}
+	if rule.AnnotationCheck != nil {
+		return &AnnotationCheckRuler{
+			Rule: rule.AnnotationCheck,
+			Name: rule.Name,
+		}
+	}
+	if rule.VolumeCheck != nil {
+		return &VolumeCheckRuler{
+			Client: client,
+			Rule:   rule.VolumeCheck,
+			Name:   rule.Name,
+		}
+	}
+	if rule.AlertCheck != nil {
+		return &AlertCheckRuler{
+			Rule: rule.AlertCheck,
+			Name: rule.Name,
+		}
+	}
+	if rule.TimeWindow != nil {
+		return &TimeWindowRuler{
+			Rule: rule.TimeWindow,
+			Name: rule.Name,
+		}
+	}
+	if rule.Expression != nil {
+		return &ExpressionRuler{
+			Rule: rule.Expression,
+			Name: rule.Name,
+		}
+	}
+	if rule.PDBRef != nil {
+		return &PDBRefRuler{
+			Client: client,
+			Rule:   rule.PDBRef,
+			Name:   rule.Name,
+		}
+	}
+	if rule.Canary != nil {
+		return &CanaryRuler{
+			Rule: rule.Canary,
+			Name: rule.Name,
+		}
+	}
 	if rule.Webhook != nil {
-		return &WebhookRuler{Name: rule.Name}
+		return &WebhookRuler{Name: rule.Name, SecretReader: client}
+	}
+	if rule.Custom != nil {
+		return getCustomRuler(rule, client)
 	}
 	return nil
 }
@@ -120,6 +234,48 @@ func (w *Webhook) buildRequest(pods sets.String, targets map[string]*corev1.Pod)
 	return req, nil
 }
 
+// buildPayload shapes request body by PayloadTemplate, returns req itself if there is no template
+func (w *Webhook) buildPayload(req *appsv1alpha1.WebhookRequest, pods sets.String, targets map[string]*corev1.Pod) (interface{}, error) {
+	template := w.Webhook.PayloadTemplate
+	if template == nil {
+		return req, nil
+	}
+	resourcesKey := template.ResourcesKey
+	if resourcesKey == "" {
+		resourcesKey = "resources"
+	}
+	resources := make([]map[string]interface{}, 0, pods.Len())
+	for _, podName := range pods.List() {
+		resource := map[string]interface{}{}
+		for _, field := range template.Fields {
+			value, err := ExtractValueFromPod(targets[podName], field.Path, field.FieldPath)
+			if err != nil {
+				return nil, fmt.Errorf("%s failed to shape payload, %v", w.Key, err)
+			}
+			setNestedValue(resource, strings.Split(field.Path, "."), value)
+		}
+		resources = append(resources, resource)
+	}
+	return map[string]interface{}{
+		"traceId":    req.TraceId,
+		"ruleName":   req.RuleName,
+		"stage":      req.Stage,
+		resourcesKey: resources,
+	}, nil
+}
+
+func setNestedValue(obj map[string]interface{}, path []string, value string) {
+	for _, key := range path[:len(path)-1] {
+		child, ok := obj[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			obj[key] = child
+		}
+		obj = child
+	}
+	obj[path[len(path)-1]] = value
+}
+
 func (w *Webhook) parseParameter(parameter *appsv1alpha1.Parameter, pod *corev1.Pod) (value string, err error) {
 
 	defer func() {
@@ -0,0 +1,129 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+// volumeCheckInterval is the interval to check volumes of blocked pods again, since PVCs and PVs are not watched
+const volumeCheckInterval = 10 * time.Second
+
+type VolumeCheckRuler struct {
+	Name string
+	Rule *appsv1alpha1.VolumeCheckRule
+
+	Client client.Client
+}
+
+// Filter passes pods whose persistent volumes are all safe. Pods without persistent volumes always pass.
+func (r *VolumeCheckRuler) Filter(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+	pass := sets.NewString()
+	rejects := map[string]string{}
+	for _, podName := range subjects.List() {
+		if utils.IsPodPassRule(podName, podTransitionRule, r.Name) {
+			pass.Insert(podName)
+			continue
+		}
+		unsafe, err := r.unsafeVolume(targets[podName])
+		if err != nil {
+			return rejectAllWithErr(subjects, pass, rejects, "[%s] fail to check volumes of pod %s, error: %v", r.Name, podName, err)
+		}
+		if unsafe == "" {
+			pass.Insert(podName)
+			continue
+		}
+		rejects[podName] = fmt.Sprintf("[%s] waiting for volume safe: %s", r.Name, unsafe)
+	}
+	res := &FilterResult{Passed: pass, Rejected: rejects}
+	if len(rejects) > 0 {
+		interval := volumeCheckInterval
+		res.Interval = &interval
+	}
+	return res
+}
+
+// unsafeVolume describes the first volume of pod not in safe state, empty if all are safe
+func (r *VolumeCheckRuler) unsafeVolume(pod *corev1.Pod) (string, error) {
+	for _, volume := range pod.Spec.Volumes {
+		claimName := volumeClaimName(pod, &volume)
+		if claimName == "" {
+			continue
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Namespace: pod.Namespace, Name: claimName}, pvc); err != nil {
+			// volumes whose claims are deleted have nothing to protect
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		if r.annotatedSafe(pvc.Annotations) {
+			continue
+		}
+		if pvc.Spec.VolumeName == "" {
+			return fmt.Sprintf("[volume]=%s, [pvc]=%s, [pvc phase]=%s", volume.Name, claimName, pvc.Status.Phase), nil
+		}
+		pv := &corev1.PersistentVolume{}
+		if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Spec.VolumeName}, pv); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return "", err
+		}
+		if r.annotatedSafe(pv.Annotations) || r.safePhase(pv.Status.Phase) {
+			continue
+		}
+		return fmt.Sprintf("[volume]=%s, [pvc]=%s, [pv]=%s, [pv phase]=%s", volume.Name, claimName, pv.Name, pv.Status.Phase), nil
+	}
+	return "", nil
+}
+
+func (r *VolumeCheckRuler) annotatedSafe(annotations map[string]string) bool {
+	return r.Rule.SafeAnnotation != "" && annotations[r.Rule.SafeAnnotation] == "true"
+}
+
+func (r *VolumeCheckRuler) safePhase(phase corev1.PersistentVolumePhase) bool {
+	for _, safe := range r.Rule.SafePhases {
+		if safe == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// volumeClaimName returns the PVC name of persistent or generic ephemeral volume, empty for other volumes
+func volumeClaimName(pod *corev1.Pod, volume *corev1.Volume) string {
+	if volume.PersistentVolumeClaim != nil {
+		return volume.PersistentVolumeClaim.ClaimName
+	}
+	if volume.Ephemeral != nil {
+		return pod.Name + "-" + volume.Name
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"context"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+func TestVolumeCheck(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+		Status:     corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-data"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	c := fake.NewClientBuilder().WithObjects(pv, pvc).Build()
+
+	withVolume := (&podTemplate{Name: "pod-volume"}).GetPod()
+	withVolume.Namespace = "default"
+	withVolume.Spec.Volumes = []corev1.Volume{
+		{Name: "config", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+	}
+	targets := map[string]*corev1.Pod{
+		"pod-volume": withVolume,
+		"pod-bare":   (&podTemplate{Name: "pod-bare"}).GetPod(),
+	}
+	ruler := &VolumeCheckRuler{
+		Name:   "volume",
+		Rule:   &appsv1alpha1.VolumeCheckRule{SafeAnnotation: "snapshotted", SafePhases: []corev1.PersistentVolumePhase{corev1.VolumeReleased}},
+		Client: c,
+	}
+	res := ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-volume", "pod-bare"))
+	g.Expect(res.Err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"pod-bare"}))
+	g.Expect(res.Rejected["pod-volume"]).Should(gomega.Equal("[volume] waiting for volume safe: [volume]=data, [pvc]=data, [pv]=pv-data, [pv phase]=Bound"))
+	g.Expect(res.Interval).ShouldNot(gomega.BeNil())
+
+	// safe phase of pv
+	pv.Status.Phase = corev1.VolumeReleased
+	g.Expect(c.Update(context.TODO(), pv)).Should(gomega.Succeed())
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-volume"))
+	g.Expect(res.Passed.Has("pod-volume")).Should(gomega.BeTrue())
+	g.Expect(res.Interval).Should(gomega.BeNil())
+
+	// safe annotation on pvc
+	ruler.Rule.SafePhases = nil
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-volume"))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("pod-volume"))
+	pvc.Annotations = map[string]string{"snapshotted": "true"}
+	g.Expect(c.Update(context.TODO(), pvc)).Should(gomega.Succeed())
+	res = ruler.Filter(&appsv1alpha1.PodTransitionRule{}, targets, sets.NewString("pod-volume"))
+	g.Expect(res.Passed.Has("pod-volume")).Should(gomega.BeTrue())
+}
@@ -17,14 +17,22 @@ limitations under the License.
 package rules
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	controllerutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
@@ -34,6 +42,12 @@ import (
 
 type WebhookRuler struct {
 	Name string
+	// Context cancels webhook requests, e.g. once the reconcile is done
+	Context context.Context
+	// Headers are added to webhook requests, e.g. trace context
+	Headers map[string]string
+	// SecretReader reads Secrets of client certificates
+	SecretReader client.Reader
 }
 
 func (r *WebhookRuler) Filter(
@@ -41,11 +55,17 @@ func (r *WebhookRuler) Filter(
 	targets map[string]*corev1.Pod,
 	subjects sets.String,
 ) *FilterResult {
-	return GetWebhook(podTransitionRule, r.Name)[0].Do(targets, subjects)
+	web := GetWebhook(podTransitionRule, r.Name)[0]
+	web.Context = r.Context
+	web.Headers = r.Headers
+	web.SecretReader = r.SecretReader
+	return web.Do(targets, subjects)
 }
 
 const (
 	defaultInterval = 5 * time.Second
+	// defaultRequestTimeout is the timeout of webhook requests without timeoutSeconds
+	defaultRequestTimeout = 10 * time.Second
 )
 
 func GetWebhook(pt *appsv1alpha1.PodTransitionRule, names ...string) (webs []*Webhook) {
@@ -73,11 +93,12 @@ func GetWebhook(pt *appsv1alpha1.PodTransitionRule, names ...string) (webs []*We
 		}
 
 		webs = append(webs, &Webhook{
-			Stage:    rule.Stage,
-			RuleName: rule.Name,
-			Key:      pt.Namespace + "/" + pt.Name + "/" + rule.Name,
-			Webhook:  web,
-			State:    ruleState,
+			Stage:     rule.Stage,
+			RuleName:  rule.Name,
+			Key:       pt.Namespace + "/" + pt.Name + "/" + rule.Name,
+			Namespace: pt.Namespace,
+			Webhook:   web,
+			State:     ruleState,
 			Approved: func(po string) bool {
 				return controllerutils.IsPodPassRule(po, pt, rule.Name)
 			},
@@ -87,25 +108,49 @@ func GetWebhook(pt *appsv1alpha1.PodTransitionRule, names ...string) (webs []*We
 }
 
 type Webhook struct {
-	Key      string
-	RuleName string
-	Stage    *string
+	Key       string
+	Namespace string
+	RuleName  string
+	Stage     *string
 
 	Webhook *appsv1alpha1.TransitionRuleWebhook
 	State   *appsv1alpha1.RuleState
 
 	Approved func(string) bool
 
+	// Context cancels webhook requests, nil means requests are only canceled by timeout
+	Context context.Context
+	// Headers are added to webhook requests
+	Headers map[string]string
+	// SecretReader reads the Secret of client certificate in Namespace
+	SecretReader client.Reader
+
 	retryInterval *time.Duration
 	taskInfo      map[string]*appsv1alpha1.TaskInfo
+
+	// lastRequest and lastPolls are calls of webhook in this round, recorded in webhook states
+	lastRequest *appsv1alpha1.WebhookState
+	lastPolls   []appsv1alpha1.WebhookState
 }
 
-func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *FilterResult {
+func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) (result *FilterResult) {
 	w.taskInfo = map[string]*appsv1alpha1.TaskInfo{}
+	w.lastRequest, w.lastPolls = nil, nil
 	effectiveSubjects := sets.NewString(subjects.List()...)
 	checked := sets.NewString()
 	rejectedPods := map[string]string{}
+	pendingPods := sets.NewString()
 	historyTaskInfo := map[string]*appsv1alpha1.TaskInfo{}
+	// pods passing in this call are delayed until the time given by the webhook
+	delayUntil := map[string]time.Time{}
+	delay := func(until *metav1.Time, pods ...string) {
+		if until == nil {
+			return
+		}
+		for _, po := range pods {
+			delayUntil[po] = until.Time
+		}
+	}
 	for sub := range subjects {
 		if w.Approved(targets[sub].Name) {
 			effectiveSubjects.Delete(sub)
@@ -116,10 +161,20 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 	newWebhookState := &appsv1alpha1.WebhookStatus{
 		TaskStates: []appsv1alpha1.TaskInfo{},
 	}
+	if w.Webhook.Retry != nil {
+		newWebhookState.RetryStatus = w.State.WebhookStatus.RetryStatus
+	}
 	defer func() {
 		newWebhookState.TaskStates = w.convTaskInfo(w.taskInfo)
 		newWebhookState.History = w.convTaskInfo(historyTaskInfo)
 		w.State.WebhookStatus = newWebhookState
+		w.State.WebhookStates = w.webhookStates()
+		if result != nil && result.RuleState != nil {
+			result.RuleState.WebhookStates = w.State.WebhookStates
+		}
+		if result != nil && len(delayUntil) > 0 {
+			result.DelayUntil = delayUntil
+		}
 	}()
 	allTracingPods := sets.NewString()
 	nowTime := time.Now()
@@ -151,6 +206,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 				taskId,
 				pollUrl,
 				w.Webhook.ClientConfig.Poll.CABundle,
+				poolConfig(w.Webhook.ClientConfig.ConnectionPool),
 				w.Key,
 				time.Duration(*w.Webhook.ClientConfig.Poll.TimeoutSeconds)*time.Second,
 				time.Duration(*w.Webhook.ClientConfig.Poll.IntervalSeconds)*time.Second,
@@ -163,13 +219,16 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 			for po := range currentPods {
 				rejectedPods[po] = rejectMsg
 			}
+			pendingPods.Insert(currentPods.List()...)
 			continue
 		}
+		w.recordPoll(taskId, pollingResult)
 
 		if pollingResult.ApproveAll {
 			klog.Infof("polling task finished, approve all pods after %d times, %s, %s", pollingResult.Count, pollingResult.Info, pollingResult.LastMessage)
 			w.recordTaskInfo(&state, pollingResult.LastMessage, pollingResult.LastQueryTime, state.Processing)
 			checked.Insert(currentPods.List()...)
+			delay(pollingResult.DelayUntil, currentPods.List()...)
 			PollingManager.Delete(taskId)
 			continue
 		}
@@ -207,9 +266,12 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 		for po := range currentPods {
 			if pollingResult.Approved.Has(po) {
 				checked.Insert(po)
+				delay(pollingResult.DelayUntil, po)
 			} else {
 				if pollingResult.Stopped {
 					allTracingPods.Delete(po)
+				} else {
+					pendingPods.Insert(po)
 				}
 				rejectedPods[po] = rejectMsg
 			}
@@ -224,12 +286,70 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 			Passed:    checked,
 			Rejected:  rejectedPods,
 			Interval:  w.retryInterval,
+			Pending:   pendingPods,
+			RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
+		}
+	}
+
+	// failed requests are not retried until backoff expires
+	if wait, ok := w.backoffWait(newWebhookState.RetryStatus, nowTime); ok {
+		retry := newWebhookState.RetryStatus
+		for eft := range effectiveSubjects {
+			rejectedPods[eft] = fmt.Sprintf(
+				"Webhook %s failed %d times, retry at %s, last error: %s",
+				w.Key,
+				retry.Attempts,
+				retry.NextAttemptTime.Format(time.RFC3339),
+				retry.LastError,
+			)
+		}
+		w.updateInterval(wait)
+		return &FilterResult{
+			Passed:    checked,
+			Rejected:  rejectedPods,
+			Interval:  w.retryInterval,
+			Pending:   pendingPods,
+			RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
+		}
+	}
+
+	// fast-fail according to failure policy if circuit is open
+	breaker := Breakers.Get(w.Webhook.ClientConfig.URL)
+	if !breaker.Allow() {
+		newWebhookState.CircuitState = breaker.State()
+		if w.Webhook.FailurePolicy == nil || *w.Webhook.FailurePolicy == appsv1alpha1.Ignore {
+			checked.Insert(effectiveSubjects.List()...)
+		} else {
+			for eft := range effectiveSubjects {
+				rejectedPods[eft] = fmt.Sprintf("Circuit breaker of webhook %s is open", w.Key)
+			}
+		}
+		w.updateInterval(defaultInterval)
+		return &FilterResult{
+			Passed:    checked,
+			Rejected:  rejectedPods,
+			Interval:  w.retryInterval,
+			Pending:   pendingPods,
 			RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
 		}
 	}
 
 	// First request
 	selfTraceId, res, err := w.query(effectiveSubjects, targets)
+	breaker.Record(err)
+	newWebhookState.CircuitState = breaker.State()
+	if err != nil && isTimeout(err) && (w.Webhook.FailurePolicy == nil || *w.Webhook.FailurePolicy == appsv1alpha1.Ignore) {
+		// pods of timed out request are passed by failure policy Ignore, and requested again on the next reconcile
+		klog.Warningf("podtransitionrule webhook %s timed out, pass pods by failure policy: %v, traceId: %s", w.Key, effectiveSubjects.List(), selfTraceId)
+		checked.Insert(effectiveSubjects.List()...)
+		return &FilterResult{
+			Passed:    checked,
+			Rejected:  rejectedPods,
+			Err:       err,
+			Pending:   pendingPods,
+			RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
+		}
+	}
 	if err != nil {
 		for eft := range effectiveSubjects {
 			rejectedPods[eft] = fmt.Sprintf(
@@ -246,13 +366,33 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 			selfTraceId,
 			utils.DumpJSON(res),
 		)
+		if w.Webhook.Retry != nil {
+			if w.recordFailure(newWebhookState, effectiveSubjects, err, nowTime) {
+				// attempts are exhausted, pods are passed by failure policy Ignore
+				klog.Warningf("podtransitionrule webhook %s failed %d times, pass pods by failure policy: %v", w.Key, newWebhookState.RetryStatus.Attempts, effectiveSubjects.List())
+				for eft := range effectiveSubjects {
+					delete(rejectedPods, eft)
+				}
+				checked.Insert(effectiveSubjects.List()...)
+				return &FilterResult{
+					Passed:    checked,
+					Rejected:  rejectedPods,
+					Pending:   pendingPods,
+					RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
+				}
+			}
+			w.updateInterval(newWebhookState.RetryStatus.NextAttemptTime.Sub(nowTime))
+		}
 		return &FilterResult{
 			Passed:    checked,
 			Rejected:  rejectedPods,
+			Interval:  w.retryInterval,
 			Err:       err,
+			Pending:   pendingPods,
 			RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
 		}
 	}
+	newWebhookState.RetryStatus = nil
 	taskId := getTaskId(res)
 	klog.Infof(
 		"request podtransitionrule webhook %s, pods: %v, taskId: %s, traceId: %s, resp: %s",
@@ -269,6 +409,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 	approved := Intersection(effectiveSubjects, res.FinishedNames)
 	if !res.Success {
 		checked.Insert(approved...)
+		delay(res.DelayUntil, approved...)
 		for _, po := range processing {
 			rejectedPods[po] = fmt.Sprintf(
 				"Webhook check %s rejected, traceId %s, taskId %s, msg: %s",
@@ -283,6 +424,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 	} else if !shouldPoll(res) {
 		// success, All passed
 		checked.Insert(effectiveSubjects.List()...)
+		delay(res.DelayUntil, effectiveSubjects.List()...)
 	} else {
 		// success, init poll task
 		// trigger reconcile by PollingManager listener
@@ -295,6 +437,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 				Passed:    checked,
 				Rejected:  rejectedPods,
 				Err:       err,
+				Pending:   pendingPods,
 				RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
 			}
 		}
@@ -308,6 +451,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 				Passed:    checked,
 				Rejected:  rejectedPods,
 				Err:       err,
+				Pending:   pendingPods,
 				RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
 			}
 		}
@@ -316,6 +460,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 			taskId,
 			pollUrl,
 			w.Webhook.ClientConfig.Poll.CABundle,
+			poolConfig(w.Webhook.ClientConfig.ConnectionPool),
 			w.Key,
 			time.Duration(*w.Webhook.ClientConfig.Poll.TimeoutSeconds)*time.Second,
 			time.Duration(*w.Webhook.ClientConfig.Poll.IntervalSeconds)*time.Second,
@@ -323,6 +468,7 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 		klog.Infof("%s, polling task %s initialized.", w.Key, taskId)
 		w.newTaskInfo(taskId, res.Message, processing, approved)
 		checked.Insert(approved...)
+		delay(res.DelayUntil, approved...)
 		for _, po := range processing {
 			rejectedPods[po] = fmt.Sprintf(
 				"Polling task %s initialized, will polling by taskId %s, msg: %s",
@@ -331,12 +477,14 @@ func (w *Webhook) Do(targets map[string]*corev1.Pod, subjects sets.String) *Filt
 				res.Message,
 			)
 		}
+		pendingPods.Insert(processing...)
 	}
 
 	return &FilterResult{
 		Passed:    checked,
 		Rejected:  rejectedPods,
 		Interval:  w.retryInterval,
+		Pending:   pendingPods,
 		RuleState: &appsv1alpha1.RuleState{Name: w.RuleName, WebhookStatus: newWebhookState},
 	}
 }
@@ -410,25 +558,118 @@ func (w *Webhook) getPollingUrl(taskId string) (string, error) {
 
 func (w *Webhook) query(podSet sets.String, targets map[string]*corev1.Pod) (string, *appsv1alpha1.WebhookResponse, error) {
 	req, err := w.buildRequest(podSet, targets)
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := w.buildPayload(req, podSet, targets)
 	if err != nil {
 		return req.TraceId, nil, err
 	}
-	res, err := w.doHttp(req)
+	res, err := w.doHttp(req.TraceId, payload)
 	return req.TraceId, res, err
 }
 
-func (w *Webhook) doHttp(req *appsv1alpha1.WebhookRequest) (*appsv1alpha1.WebhookResponse, error) {
-	httpResp, err := utilshttp.DoHttpAndHttpsRequestWithCa(http.MethodPost, w.Webhook.ClientConfig.URL, *req, nil, w.Webhook.ClientConfig.CABundle)
+func (w *Webhook) doHttp(traceId string, payload interface{}) (*appsv1alpha1.WebhookResponse, error) {
+	countWebhookCall(w.Key)
+	ctx, cancel := context.WithTimeout(w.context(), w.requestTimeout())
+	defer cancel()
+	httpResp, err := w.post(ctx, payload)
+	if err != nil {
+		w.recordRequest(traceId, 0, nil, err.Error())
+		return nil, err
+	}
+	// body is read ahead to be recorded in webhook states, and parsed from the buffer
+	body, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
 	if err != nil {
+		w.recordRequest(traceId, httpResp.StatusCode, httpResp.Header, err.Error())
+		return nil, err
+	}
+	w.recordRequest(traceId, httpResp.StatusCode, httpResp.Header, string(body))
+	httpResp.Body = io.NopCloser(bytes.NewReader(body))
+	var raw json.RawMessage
+	if err = utilshttp.ParseResponse(httpResp, &raw); err != nil {
 		return nil, err
 	}
 	resp := &appsv1alpha1.WebhookResponse{}
-	if err = utilshttp.ParseResponse(httpResp, resp); err != nil {
+	if err = parseResponse(raw, webhookResponseSchema, resp); err != nil {
 		return nil, err
 	}
 	return resp, nil
 }
 
+// isTimeout tells whether err is a timeout of request, e.g. exceeding the deadline of context
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (w *Webhook) context() context.Context {
+	if w.Context == nil {
+		return context.Background()
+	}
+	return w.Context
+}
+
+func (w *Webhook) requestTimeout() time.Duration {
+	if w.Webhook.TimeoutSeconds == nil || *w.Webhook.TimeoutSeconds <= 0 {
+		return defaultRequestTimeout
+	}
+	return time.Duration(*w.Webhook.TimeoutSeconds) * time.Second
+}
+
+// post sends payload to webhook, with client certificate if configured
+func (w *Webhook) post(ctx context.Context, payload interface{}) (*http.Response, error) {
+	config := &w.Webhook.ClientConfig
+	pool := poolConfig(config.ConnectionPool)
+	if config.ClientCertSecretRef == nil && !config.InsecureSkipVerify {
+		return utilshttp.DoHttpAndHttpsRequestWithCaAndPool(ctx, http.MethodPost, config.URL, payload, w.Headers, config.CABundle, pool)
+	}
+	tlsConfig := utilshttp.TLSConfig{CA: config.CABundle, InsecureSkipVerify: config.InsecureSkipVerify}
+	if ref := config.ClientCertSecretRef; ref != nil {
+		if w.SecretReader == nil {
+			return nil, fmt.Errorf("no reader of client certificate secret %s/%s", w.Namespace, ref.Name)
+		}
+		secret := &corev1.Secret{}
+		if err := w.SecretReader.Get(ctx, types.NamespacedName{Namespace: w.Namespace, Name: ref.Name}, secret); err != nil {
+			return nil, fmt.Errorf("fail to get client certificate secret %s/%s: %s", w.Namespace, ref.Name, err)
+		}
+		tlsConfig.ClientCert, tlsConfig.ClientKey = secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	}
+	return utilshttp.DoHttpAndHttpsRequestWithTLS(ctx, http.MethodPost, config.URL, payload, w.Headers, tlsConfig, pool)
+}
+
+const (
+	defaultMaxIdleConnsPerHost = 2
+	defaultIdleTimeoutSeconds  = 90
+	defaultKeepAliveSeconds    = 30
+)
+
+// poolConfig returns pool config of webhook with defaults, nil if pool is not configured
+func poolConfig(pool *appsv1alpha1.ConnectionPool) *utilshttp.PoolConfig {
+	if pool == nil {
+		return nil
+	}
+	config := &utilshttp.PoolConfig{
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleTimeoutSeconds * time.Second,
+		KeepAlive:           defaultKeepAliveSeconds * time.Second,
+	}
+	if pool.MaxIdleConnsPerHost != nil {
+		config.MaxIdleConnsPerHost = int(*pool.MaxIdleConnsPerHost)
+	}
+	if pool.IdleTimeoutSeconds != nil {
+		config.IdleConnTimeout = time.Duration(*pool.IdleTimeoutSeconds) * time.Second
+	}
+	if pool.KeepAliveSeconds != nil {
+		config.KeepAlive = time.Duration(*pool.KeepAliveSeconds) * time.Second
+		if config.KeepAlive < 0 {
+			config.KeepAlive = -1
+		}
+	}
+	return config
+}
+
 func shouldPoll(resp *appsv1alpha1.WebhookResponse) bool {
 	return resp.Async || resp.Poll
 }
@@ -17,8 +17,11 @@ limitations under the License.
 package rules
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -29,6 +32,7 @@ import (
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
 	"kusionstack.io/operating/pkg/utils"
+	utilshttp "kusionstack.io/operating/pkg/utils/http"
 )
 
 var (
@@ -150,6 +154,28 @@ func TestWebhookAlwaysSuccess(t *testing.T) {
 	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(2))
 }
 
+func TestWebhookSuccessWithDelay(t *testing.T) {
+	stop, finish := RunHttpServer(handleHttpSuccessWithDelay, "8888")
+	defer func() {
+		stop <- struct{}{}
+		<-finish
+	}()
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+		"test-pod-b": (&podTemplate{Name: "test-pod-b", Ip: "1.1.1.59"}).GetPod(),
+	}
+	subjects := sets.NewString("test-pod-a", "test-pod-b")
+	g := gomega.NewGomegaWithT(t)
+	webhooks := GetWebhook(normalRS)
+	g.Expect(len(webhooks)).Should(gomega.BeEquivalentTo(1))
+	web := webhooks[0]
+	res := web.Do(targets, subjects)
+	fmt.Printf("res: %s", utils.DumpJSON(res))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(2))
+	g.Expect(len(res.DelayUntil)).Should(gomega.BeEquivalentTo(2))
+	g.Expect(res.DelayUntil["test-pod-a"].After(time.Now())).Should(gomega.BeTrue())
+}
+
 func TestWebhookAlwaysFail(t *testing.T) {
 	stop, finish := RunHttpServer(handleHttpAlwaysFalse, "8888")
 	defer func() {
@@ -312,6 +338,58 @@ func TestWebhookPoll(t *testing.T) {
 	g.Expect(len(res.Rejected)).Should(gomega.BeEquivalentTo(0))
 }
 
+func TestWebhookPollWithDelay(t *testing.T) {
+	stopA, finishA := RunHttpServer(handleFirstPollSucc, "8888")
+	stopB, finishB := RunHttpServer(handleHttpWithTaskIdSuccWithDelay, "8889")
+	defer func() {
+		stopA <- struct{}{}
+		stopB <- struct{}{}
+		<-finishA
+		<-finishB
+	}()
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+		"test-pod-b": (&podTemplate{Name: "test-pod-b", Ip: "1.1.1.59"}).GetPod(),
+		"test-pod-c": (&podTemplate{Name: "test-pod-c", Ip: "1.1.1.60"}).GetPod(),
+	}
+	subjects := sets.NewString("test-pod-a", "test-pod-b", "test-pod-c")
+	g := gomega.NewGomegaWithT(t)
+	pollRS := poRS.DeepCopy()
+	webhooks := GetWebhook(pollRS)
+	g.Expect(len(webhooks)).Should(gomega.BeEquivalentTo(1))
+	web := webhooks[0]
+	res := web.Do(targets, subjects)
+	fmt.Printf("res: %s", utils.DumpJSON(res))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(len(res.DelayUntil)).Should(gomega.BeEquivalentTo(0))
+
+	// partially finished pods are delayed
+	<-time.After(6 * time.Second)
+	state := &appsv1alpha1.RuleState{Name: web.RuleName, WebhookStatus: res.RuleState.WebhookStatus}
+	pollRS.Status.RuleStates = []*appsv1alpha1.RuleState{state}
+	webhooks = GetWebhook(pollRS.DeepCopy())
+	web = webhooks[0]
+	res = web.Do(targets, subjects)
+	fmt.Printf("res: %s", utils.DumpJSON(res))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(2))
+	g.Expect(len(res.DelayUntil)).Should(gomega.BeEquivalentTo(2))
+	for po := range res.Passed {
+		g.Expect(res.DelayUntil[po].After(time.Now())).Should(gomega.BeTrue())
+	}
+
+	// all finished pods are delayed
+	<-time.After(5 * time.Second)
+	state = &appsv1alpha1.RuleState{Name: web.RuleName, WebhookStatus: res.RuleState.WebhookStatus}
+	pollRS.Status.RuleStates = []*appsv1alpha1.RuleState{state}
+	webhooks = GetWebhook(pollRS.DeepCopy())
+	web = webhooks[0]
+	res = web.Do(targets, subjects)
+	fmt.Printf("res: %s", utils.DumpJSON(res))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(3))
+	g.Expect(res.DelayUntil).Should(gomega.HaveKey("test-pod-c"))
+	g.Expect(res.DelayUntil["test-pod-c"].After(time.Now())).Should(gomega.BeTrue())
+}
+
 func TestWebhookPollError(t *testing.T) {
 	stopA, finishA := RunHttpServer(handleFirstPollSucc, "8888")
 	stopB, finishB := RunHttpServer(handleHttpError, "8889")
@@ -361,6 +439,46 @@ func TestWebhookPollError(t *testing.T) {
 	g.Expect(len(res.Rejected)).Should(gomega.BeEquivalentTo(3))
 }
 
+func TestWebhookPayloadTemplate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	received := make(chan map[string]interface{}, 1)
+	stop, finish := RunHttpServer(func(resp http.ResponseWriter, req *http.Request) {
+		body := map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&body)
+		received <- body
+		byt, _ := json.Marshal(&appsv1alpha1.WebhookResponse{Success: true})
+		resp.Write(byt)
+	}, "8888")
+	defer func() {
+		stop <- struct{}{}
+		<-finish
+	}()
+	rs := normalRS.DeepCopy()
+	rs.Spec.Rules[0].Webhook.PayloadTemplate = &appsv1alpha1.PayloadTemplate{
+		ResourcesKey: "pods",
+		Fields: []appsv1alpha1.PayloadField{
+			{Path: "name", FieldPath: "metadata.name"},
+			{Path: "meta.ip", FieldPath: "status.podIP"},
+		},
+	}
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	res := GetWebhook(rs)[0].Do(targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(1))
+
+	var body map[string]interface{}
+	g.Eventually(received).Should(gomega.Receive(&body))
+	g.Expect(body["ruleName"]).Should(gomega.Equal(rs.Spec.Rules[0].Name))
+	g.Expect(body).ShouldNot(gomega.HaveKey("resources"))
+	pods := body["pods"].([]interface{})
+	g.Expect(len(pods)).Should(gomega.Equal(1))
+	g.Expect(pods[0]).Should(gomega.Equal(map[string]interface{}{
+		"name": "test-pod-a",
+		"meta": map[string]interface{}{"ip": "1.1.1.58"},
+	}))
+}
+
 type podTemplate struct {
 	Name  string
 	Ip    string
@@ -411,3 +529,71 @@ func printJson(obj any) {
 	byt, _ := json.MarshalIndent(obj, "", "  ")
 	fmt.Printf("%s\n", string(byt))
 }
+
+func TestWebhookConnectionPool(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	g.Expect(poolConfig(nil)).Should(gomega.BeNil())
+	maxIdle, keepAlive := int32(10), int32(-1)
+	config := poolConfig(&appsv1alpha1.ConnectionPool{MaxIdleConnsPerHost: &maxIdle, KeepAliveSeconds: &keepAlive})
+	g.Expect(*config).Should(gomega.Equal(utilshttp.PoolConfig{MaxIdleConnsPerHost: 10, IdleConnTimeout: 90 * time.Second, KeepAlive: -1}))
+
+	// clients are reused by the same endpoint and pool
+	c1, err := utilshttp.DefaultClient.GetClientWithPool("", "http://127.0.0.1:8889", *config)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	c2, _ := utilshttp.DefaultClient.GetClientWithPool("", "http://127.0.0.1:8889", *config)
+	g.Expect(c2).Should(gomega.BeIdenticalTo(c1))
+	c3, _ := utilshttp.DefaultClient.GetClientWithPool("", "http://127.0.0.1:8890", *config)
+	g.Expect(c3).ShouldNot(gomega.BeIdenticalTo(c1))
+	c4, _ := utilshttp.DefaultClient.GetClientWithPool("", "http://127.0.0.1:8889", *poolConfig(&appsv1alpha1.ConnectionPool{}))
+	g.Expect(c4).ShouldNot(gomega.BeIdenticalTo(c1))
+	g.Expect(c1.Transport.(*http.Transport).MaxIdleConnsPerHost).Should(gomega.Equal(10))
+}
+
+func TestWebhookTimeout(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		select {
+		case <-release:
+		case <-req.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	subjects := sets.NewString("test-pod-a")
+	timeoutSeconds := int32(1)
+	newWebhook := func(failurePolicy appsv1alpha1.FailurePolicyType) *Webhook {
+		rs := normalRS.DeepCopy()
+		rs.Spec.Rules[0].Webhook.ClientConfig.URL = server.URL
+		rs.Spec.Rules[0].Webhook.FailurePolicy = &failurePolicy
+		rs.Spec.Rules[0].Webhook.TimeoutSeconds = &timeoutSeconds
+		return GetWebhook(rs)[0]
+	}
+
+	// pods of timed out request are rejected by failure policy Fail
+	start := time.Now()
+	res := newWebhook(appsv1alpha1.Fail).Do(targets, subjects)
+	g.Expect(time.Since(start)).Should(gomega.BeNumerically("<", 5*time.Second))
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(isTimeout(res.Err)).Should(gomega.BeTrue())
+	g.Expect(res.Passed.Len()).Should(gomega.BeEquivalentTo(0))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+
+	// pods of timed out request are passed by failure policy Ignore, and requested again
+	res = newWebhook(appsv1alpha1.Ignore).Do(targets, subjects)
+	g.Expect(res.Err).Should(gomega.HaveOccurred())
+	g.Expect(res.Passed.List()).Should(gomega.Equal([]string{"test-pod-a"}))
+	g.Expect(res.Rejected).Should(gomega.BeEmpty())
+
+	// request is canceled with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	web := newWebhook(appsv1alpha1.Fail)
+	web.Context = ctx
+	res = web.Do(targets, subjects)
+	g.Expect(res.Err).Should(gomega.MatchError(gomega.ContainSubstring("context canceled")))
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+}
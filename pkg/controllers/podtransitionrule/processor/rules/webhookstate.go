@@ -0,0 +1,114 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+const (
+	webhookCallRequest = "request"
+	webhookCallPoll    = "poll"
+
+	// maxWebhookMessageLength is the max length of response body or error kept in webhook states
+	maxWebhookMessageLength = 512
+
+	redactedValue = "<redacted>"
+)
+
+// defaultRedactHeaders are headers always redacted in webhook states since they carry credentials
+var defaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// recordRequest records the last request call of webhook, it is called once the call is done
+func (w *Webhook) recordRequest(traceId string, statusCode int, header http.Header, message string) {
+	w.lastRequest = &appsv1alpha1.WebhookState{
+		Name:         webhookCallRequest,
+		StatusCode:   int32(statusCode),
+		Message:      truncateMessage(message),
+		Headers:      w.redactHeaders(header),
+		TraceID:      traceId,
+		LastCallTime: metav1.Now(),
+	}
+}
+
+// recordPoll records the last poll call of task
+func (w *Webhook) recordPoll(taskId string, result *PollResult) {
+	message := result.LastMessage
+	if result.LastError != nil {
+		message = result.LastError.Error()
+	}
+	w.lastPolls = append(w.lastPolls, appsv1alpha1.WebhookState{
+		Name:         webhookCallPoll,
+		Message:      truncateMessage(message),
+		TraceID:      taskId,
+		LastCallTime: metav1.NewTime(result.LastQueryTime),
+	})
+}
+
+// webhookStates returns the last request, which is inherited from last state if no request is sent,
+// and last polls of tasks polled in this round
+func (w *Webhook) webhookStates() []appsv1alpha1.WebhookState {
+	var states []appsv1alpha1.WebhookState
+	if w.lastRequest != nil {
+		states = append(states, *w.lastRequest)
+	} else {
+		for _, state := range w.State.WebhookStates {
+			if state.Name == webhookCallRequest {
+				states = append(states, state)
+				break
+			}
+		}
+	}
+	polls := append([]appsv1alpha1.WebhookState{}, w.lastPolls...)
+	sort.Slice(polls, func(i, j int) bool {
+		return polls[i].TraceID < polls[j].TraceID
+	})
+	return append(states, polls...)
+}
+
+func (w *Webhook) redactHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+	redact := sets.NewString(defaultRedactHeaders...)
+	for _, name := range w.Webhook.RedactHeaders {
+		redact.Insert(http.CanonicalHeaderKey(name))
+	}
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if redact.Has(http.CanonicalHeaderKey(name)) {
+			headers[name] = redactedValue
+			continue
+		}
+		headers[name] = truncateMessage(strings.Join(values, ","))
+	}
+	return headers
+}
+
+func truncateMessage(message string) string {
+	if len(message) <= maxWebhookMessageLength {
+		return message
+	}
+	return message[:maxWebhookMessageLength] + "...(truncated)"
+}
@@ -0,0 +1,153 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package rules
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/client-go/util/keyutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/utils"
+)
+
+func TestWebhookStates(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		resp.Header().Set("Set-Cookie", "session=secret")
+		resp.Header().Set("X-Api-Key", "secret")
+		resp.Header().Set("X-Request-Id", "request-1")
+		http.Error(resp, "denied: "+strings.Repeat("x", 2*maxWebhookMessageLength), http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	subjects := sets.NewString("test-pod-a")
+	rs := normalRS.DeepCopy()
+	rs.Spec.Rules[0].Webhook.ClientConfig.URL = server.URL
+	rs.Spec.Rules[0].Webhook.RedactHeaders = []string{"x-api-key"}
+	res := GetWebhook(rs)[0].Do(targets, subjects)
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+	g.Expect(res.RuleState.WebhookStates).Should(gomega.HaveLen(1))
+	state := res.RuleState.WebhookStates[0]
+	g.Expect(state.Name).Should(gomega.Equal(webhookCallRequest))
+	g.Expect(state.StatusCode).Should(gomega.BeEquivalentTo(http.StatusForbidden))
+	g.Expect(state.Message).Should(gomega.HavePrefix("denied: xxx"))
+	g.Expect(state.Message).Should(gomega.HaveSuffix("...(truncated)"))
+	g.Expect(len(state.Message)).Should(gomega.BeNumerically("<", maxWebhookMessageLength+20))
+	g.Expect(state.TraceID).ShouldNot(gomega.BeEmpty())
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.ContainSubstring(state.TraceID))
+	g.Expect(state.Headers).Should(gomega.HaveKeyWithValue("Set-Cookie", redactedValue))
+	g.Expect(state.Headers).Should(gomega.HaveKeyWithValue("X-Api-Key", redactedValue))
+	g.Expect(state.Headers).Should(gomega.HaveKeyWithValue("X-Request-Id", "request-1"))
+
+	// last request is kept while no request is sent
+	rs.Status.RuleStates = []*appsv1alpha1.RuleState{res.RuleState}
+	res = GetWebhook(rs)[0].Do(targets, sets.NewString())
+	g.Expect(res.RuleState.WebhookStates).Should(gomega.Equal([]appsv1alpha1.WebhookState{state}))
+}
+
+func TestWebhookHeaders(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		received <- req.Header.Get("traceparent")
+		http.Error(resp, "denied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	rs := normalRS.DeepCopy()
+	rs.Spec.Rules[0].Webhook.ClientConfig.URL = server.URL
+	ruler := &WebhookRuler{Name: rs.Spec.Rules[0].Name, Headers: map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(<-received).Should(gomega.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+}
+
+func TestWebhookClientCert(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	caKey, err := utils.NewPrivateKey()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	caCert, err := certutil.NewSelfSignedCACert(certutil.Config{CommonName: "webhook-client-ca"}, caKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	clientKey, err := utils.NewPrivateKey()
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	clientCert, err := utils.NewSignedCert(&certutil.Config{CommonName: "podtransitionrule", Usages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, clientKey, caCert, caKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	clientKeyPEM, err := keyutil.MarshalPrivateKeyToPEM(clientKey)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+	received := make(chan string, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		received <- req.TLS.PeerCertificates[0].Subject.CommonName
+		http.Error(resp, "denied", http.StatusForbidden)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "client-cert", Namespace: "default"},
+		Data:       map[string][]byte{corev1.TLSCertKey: utils.EncodeCertPEM(clientCert), corev1.TLSPrivateKeyKey: clientKeyPEM},
+	}
+	targets := map[string]*corev1.Pod{
+		"test-pod-a": (&podTemplate{Name: "test-pod-a", Ip: "1.1.1.58"}).GetPod(),
+	}
+	rs := normalRS.DeepCopy()
+	rs.Namespace = "default"
+	rs.Spec.Rules[0].Webhook.ClientConfig.URL = server.URL
+	rs.Spec.Rules[0].Webhook.ClientConfig.CABundle = base64.StdEncoding.EncodeToString(utils.EncodeCertPEM(server.Certificate()))
+	rs.Spec.Rules[0].Webhook.ClientConfig.ClientCertSecretRef = &corev1.LocalObjectReference{Name: "client-cert"}
+
+	// client certificate is presented to webhook requiring mutual TLS
+	ruler := &WebhookRuler{Name: rs.Spec.Rules[0].Name, SecretReader: fake.NewClientBuilder().WithObjects(secret).Build()}
+	res := ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(<-received).Should(gomega.Equal("podtransitionrule"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.ContainSubstring("denied"))
+
+	// missing secret fails the request
+	ruler.SecretReader = fake.NewClientBuilder().Build()
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(res.Rejected["test-pod-a"]).Should(gomega.ContainSubstring("fail to get client certificate secret default/client-cert"))
+	g.Expect(received).Should(gomega.BeEmpty())
+
+	// without client certificate the handshake fails, even if server certificate is not verified
+	rs.Spec.Rules[0].Webhook.ClientConfig.ClientCertSecretRef = nil
+	rs.Spec.Rules[0].Webhook.ClientConfig.CABundle = ""
+	rs.Spec.Rules[0].Webhook.ClientConfig.InsecureSkipVerify = true
+	res = ruler.Filter(rs, targets, sets.NewString("test-pod-a"))
+	g.Expect(received).Should(gomega.BeEmpty())
+	g.Expect(res.Rejected).Should(gomega.HaveKey("test-pod-a"))
+}
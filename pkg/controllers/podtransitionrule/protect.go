@@ -0,0 +1,121 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+// podProtection identifies protected pods, which are excluded from targets of PodTransitionRules not opted in.
+// Blocking them could deadlock upgrades of the controller itself or other critical operators.
+type podProtection struct {
+	// namespace and name of the pod this controller runs in
+	namespace string
+	name      string
+	// selector matches pods of the controller and other critical operators, nil matches nothing
+	selector labels.Selector
+}
+
+// newPodProtection identifies the pod of this controller by POD_NAMESPACE and POD_NAME, falling back to hostname
+func newPodProtection(selector string) (*podProtection, error) {
+	p := &podProtection{
+		namespace: os.Getenv("POD_NAMESPACE"),
+		name:      os.Getenv("POD_NAME"),
+	}
+	if p.name == "" {
+		p.name, _ = os.Hostname()
+	}
+	if strings.TrimSpace(selector) != "" {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return p, fmt.Errorf("invalid protected pod selector %q, %v", selector, err)
+		}
+		p.selector = s
+	}
+	return p, nil
+}
+
+func (p *podProtection) isProtected(pod *corev1.Pod) bool {
+	if pod.Labels[appsv1alpha1.PodTransitionRuleProtectedLabelKey] == "true" {
+		return true
+	}
+	if p.name != "" && pod.Name == p.name && (p.namespace == "" || pod.Namespace == p.namespace) {
+		return true
+	}
+	return p.selector != nil && p.selector.Matches(labels.Set(pod.Labels))
+}
+
+// excludeProtectedPods removes protected pods from pods unless podTransitionRule opts in, and returns names of the excluded
+func (r *PodTransitionRuleReconciler) excludeProtectedPods(podTransitionRule *appsv1alpha1.PodTransitionRule, pods []corev1.Pod) ([]corev1.Pod, []string) {
+	if r.podProtection == nil || podTransitionRule.Annotations[appsv1alpha1.AnnotationGovernProtectedPods] == "true" {
+		return pods, nil
+	}
+	var excluded []string
+	kept := pods[:0]
+	for i := range pods {
+		if r.podProtection.isProtected(&pods[i]) {
+			excluded = append(excluded, pods[i].Name)
+			continue
+		}
+		kept = append(kept, pods[i])
+	}
+	return kept, excluded
+}
+
+// recordExcludedPods emits a warning event of the number and names of protected pods excluded from targets
+func (r *PodTransitionRuleReconciler) recordExcludedPods(podTransitionRule *appsv1alpha1.PodTransitionRule, excluded []string) {
+	if len(excluded) > 0 && r.Recorder != nil {
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeWarning, "ProtectedPodsExcluded",
+			"%d protected pods are excluded from targets, annotate %s=true to govern them: %s", len(excluded), appsv1alpha1.AnnotationGovernProtectedPods, strings.Join(excluded, ", "))
+	}
+}
+
+// excludeAnnotatedPods removes pods annotated to be excluded from podTransitionRule, and returns target keys of the excluded
+func excludeAnnotatedPods(podTransitionRule *appsv1alpha1.PodTransitionRule, pods []corev1.Pod) ([]corev1.Pod, []string) {
+	var excluded []string
+	kept := pods[:0]
+	for i := range pods {
+		if podtransitionruleutils.ExcludeRequested(&pods[i], podTransitionRule.Name) {
+			excluded = append(excluded, podtransitionruleutils.TargetKey(podTransitionRule, &pods[i]))
+			continue
+		}
+		kept = append(kept, pods[i])
+	}
+	return kept, excluded
+}
+
+// recordAnnotatedExcludedPods emits an event of pods newly excluded from targets by annotation, whose annotations of
+// podTransitionRule are cleaned up as unselected pods
+func (r *PodTransitionRuleReconciler) recordAnnotatedExcludedPods(podTransitionRule *appsv1alpha1.PodTransitionRule, excluded []string) {
+	if len(excluded) == 0 || r.Recorder == nil {
+		return
+	}
+	newlyExcluded := sets.NewString(excluded...).Intersection(sets.NewString(podTransitionRule.Status.Targets...))
+	if newlyExcluded.Len() > 0 {
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeNormal, "PodsExcluded",
+			"pods annotated with %s are excluded from targets: %s", appsv1alpha1.AnnotationPodExclude, strings.Join(newlyExcluded.List(), ", "))
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// PodEventQueues are notified of pods whose transition details are changed by PodTransitionRules, so that downstream
+// controllers react to the changes without watching pod annotations themselves.
+var PodEventQueues = NewQueueRegistry()
+
+// QueueRegistry is a thread-safe registry of queues, which can be registered and unregistered by multiple controllers.
+// A key is broadcast at most once in each min interval, broadcasts within the interval are merged into a single
+// delayed one at its end, so that flapping details of a pod do not flood the queues.
+type QueueRegistry struct {
+	// queues are keyed by registration id, so that a queue registered twice is unregistered once at a time
+	queues map[int]workqueue.DelayingInterface
+	nextID int
+
+	minInterval time.Duration
+	// broadcasts are the times keys are last broadcast at, which are in future if delayed broadcasts are pending
+	broadcasts map[types.NamespacedName]time.Time
+	lastSweep  time.Time
+	now        func() time.Time
+
+	mu sync.Mutex
+}
+
+func NewQueueRegistry() *QueueRegistry {
+	return &QueueRegistry{
+		queues:     map[int]workqueue.DelayingInterface{},
+		broadcasts: map[types.NamespacedName]time.Time{},
+		now:        time.Now,
+	}
+}
+
+// SetMinInterval sets the min interval between broadcasts of the same key. Non-positive means every key is broadcast
+// immediately.
+func (r *QueueRegistry) SetMinInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.minInterval = d
+}
+
+// Register adds queue to registry, and returns a func unregistering it
+func (r *QueueRegistry) Register(queue workqueue.DelayingInterface) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.queues[id] = queue
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.queues, id)
+	}
+}
+
+// Broadcast adds key to every registered queue which is not shutting down. If key is broadcast within min interval,
+// it is added after the interval ends instead, unless such a delayed broadcast is already pending.
+func (r *QueueRegistry) Broadcast(key types.NamespacedName) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var delay time.Duration
+	if r.minInterval > 0 {
+		now := r.now()
+		r.sweep(now)
+		if last, ok := r.broadcasts[key]; ok {
+			if now.Before(last) {
+				return
+			}
+			delay = last.Add(r.minInterval).Sub(now)
+		}
+		if delay > 0 {
+			r.broadcasts[key] = now.Add(delay)
+		} else {
+			r.broadcasts[key] = now
+		}
+	}
+	for _, queue := range r.queues {
+		if queue.ShuttingDown() {
+			continue
+		}
+		if delay > 0 {
+			queue.AddAfter(key, delay)
+		} else {
+			queue.Add(key)
+		}
+	}
+}
+
+// sweep forgets keys whose min interval has ended, at most once in a min interval
+func (r *QueueRegistry) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.minInterval {
+		return
+	}
+	r.lastSweep = now
+	for key, last := range r.broadcasts {
+		if now.Sub(last) >= r.minInterval {
+			delete(r.broadcasts, key)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
+)
+
+// selectorError returns the error of invalid selectors of podTransitionRule
+func selectorError(podTransitionRule *appsv1alpha1.PodTransitionRule) error {
+	if _, err := podtransitionruleutils.PodSelectors(podTransitionRule); err != nil {
+		return err
+	}
+	if _, err := metav1.LabelSelectorAsSelector(podTransitionRule.Spec.NamespaceSelector); err != nil {
+		return err
+	}
+	_, err := podtransitionruleutils.ParsePodFieldSelector(podTransitionRule.Spec.FieldSelector)
+	return err
+}
+
+// setCondition replaces the condition of the same type in conditions with cond, LastTransitionTime is kept
+// unless status is changed
+func setCondition(conditions []appsv1alpha1.PodTransitionRuleCondition, cond appsv1alpha1.PodTransitionRuleCondition) []appsv1alpha1.PodTransitionRuleCondition {
+	cond.LastTransitionTime = metav1.NewTime(time.Now())
+	res := make([]appsv1alpha1.PodTransitionRuleCondition, 0, len(conditions)+1)
+	for _, current := range conditions {
+		if current.Type != cond.Type {
+			res = append(res, current)
+			continue
+		}
+		if current.Status == cond.Status {
+			cond.LastTransitionTime = current.LastTransitionTime
+		}
+	}
+	return append(res, cond)
+}
+
+// readinessConditions sets SelectorValid, Progressing and Ready in conditions. progressingReason is empty
+// if the reconcile is complete.
+func readinessConditions(conditions []appsv1alpha1.PodTransitionRuleCondition, selectorErr error, progressingReason, progressingMessage string) []appsv1alpha1.PodTransitionRuleCondition {
+	selectorValid := appsv1alpha1.PodTransitionRuleCondition{
+		Type:   appsv1alpha1.PodTransitionRuleSelectorValid,
+		Status: corev1.ConditionTrue,
+		Reason: "Valid",
+	}
+	if selectorErr != nil {
+		selectorValid.Status, selectorValid.Reason, selectorValid.Message = corev1.ConditionFalse, "InvalidSelector", selectorErr.Error()
+	}
+	progressing := appsv1alpha1.PodTransitionRuleCondition{
+		Type:   appsv1alpha1.PodTransitionRuleProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: "Reconciled",
+	}
+	if progressingReason != "" {
+		progressing.Status, progressing.Reason, progressing.Message = corev1.ConditionTrue, progressingReason, progressingMessage
+	}
+	ready := appsv1alpha1.PodTransitionRuleCondition{
+		Type:   appsv1alpha1.PodTransitionRuleReady,
+		Status: corev1.ConditionTrue,
+		Reason: "Reconciled",
+	}
+	switch {
+	case selectorErr != nil:
+		ready.Status, ready.Reason, ready.Message = corev1.ConditionFalse, selectorValid.Reason, selectorValid.Message
+	case progressingReason != "":
+		ready.Status, ready.Reason, ready.Message = corev1.ConditionFalse, progressingReason, progressingMessage
+	}
+	conditions = setCondition(conditions, selectorValid)
+	conditions = setCondition(conditions, progressing)
+	return setCondition(conditions, ready)
+}
+
+// reportInvalidSelector writes conditions of invalid selectors in status, other status fields are kept
+func (r *PodTransitionRuleReconciler) reportInvalidSelector(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, selectorErr error) error {
+	if isDryRun(podTransitionRule) {
+		return nil
+	}
+	newStatus := podTransitionRule.Status.DeepCopy()
+	newStatus.ObservedGeneration = podTransitionRule.Generation
+	newStatus.Conditions = readinessConditions(podTransitionRule.Status.Conditions, selectorErr, "", "")
+	if equalStatus(newStatus, &podTransitionRule.Status) {
+		return nil
+	}
+	return r.updateStatus(ctx, podTransitionRule, newStatus)
+}
+
+// equalConditions compares conditions ignoring LastTransitionTime
+func equalConditions(a, b []appsv1alpha1.PodTransitionRuleCondition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Status != b[i].Status || a[i].Reason != b[i].Reason || a[i].Message != b[i].Message {
+			return false
+		}
+	}
+	return true
+}
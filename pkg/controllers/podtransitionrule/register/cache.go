@@ -19,6 +19,7 @@ package register
 import (
 	"sync"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -33,6 +34,7 @@ func newCache() *cache {
 		condition:    NewFuncCache(),
 		pre:          NewFuncCache(),
 		post:         NewFuncCache(),
+		topology:     map[string]stageTopology{},
 	}
 }
 
@@ -40,6 +42,8 @@ type cache struct {
 	stageKey    sets.String
 	inStageFunc *FuncCache
 	stages      []string
+	topology    map[string]stageTopology
+	resources   []schema.GroupVersionKind
 
 	conditionKey sets.String
 	condition    *FuncCache
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -45,3 +46,54 @@ func TestCache(t *testing.T) {
 	g.Expect(ca.Conditions(nil)[0]).Should(gomega.Equal("condition-a"))
 	g.Expect(len(ca.MatchConditions(nil, "xxx", "condition-a", "condition-b"))).Should(gomega.Equal(1))
 }
+
+func TestStageGroups(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ca := newCache()
+	for _, stage := range []string{"stage-a", "stage-b", "stage-c", "stage-d"} {
+		ca.RegisterStage(stage, func(obj client.Object) bool {
+			return true
+		})
+	}
+	// default, all stages in parallel
+	groups := GetStageGroups(ca)
+	g.Expect(len(groups)).Should(gomega.Equal(1))
+	g.Expect(groups[0].Serial).Should(gomega.BeFalse())
+	g.Expect(groups[0].Stages).Should(gomega.Equal([]string{"stage-a", "stage-b", "stage-c", "stage-d"}))
+
+	ca.RegisterStageTopology("stage-a", 2, false)
+	ca.RegisterStageTopology("stage-c", 1, true)
+	ca.RegisterStageTopology("stage-d", 1, false)
+	groups = GetStageGroups(ca)
+	g.Expect(len(groups)).Should(gomega.Equal(3))
+	g.Expect(groups[0].Stages).Should(gomega.Equal([]string{"stage-b"}))
+	g.Expect(groups[1].Stages).Should(gomega.Equal([]string{"stage-c", "stage-d"}))
+	g.Expect(groups[1].Serial).Should(gomega.BeTrue())
+	g.Expect(groups[2].Stages).Should(gomega.Equal([]string{"stage-a"}))
+}
+
+type stagesOnlyPolicy struct {
+	Policy
+}
+
+func (p *stagesOnlyPolicy) GetStages() []string {
+	return []string{"stage-a", "stage-b"}
+}
+
+func TestStageGroupsBackwardCompatible(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	groups := GetStageGroups(&stagesOnlyPolicy{})
+	g.Expect(len(groups)).Should(gomega.Equal(1))
+	g.Expect(groups[0].Serial).Should(gomega.BeFalse())
+	g.Expect(groups[0].Stages).Should(gomega.Equal([]string{"stage-a", "stage-b"}))
+}
+
+func TestResources(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ca := newCache()
+	g.Expect(GetResources(ca)).Should(gomega.BeEmpty())
+	gvk := schema.GroupVersionKind{Group: "test.kusionstack.io", Version: "v1", Kind: "MaintenanceMode"}
+	ca.RegisterResource(gvk)
+	ca.RegisterResource(gvk)
+	g.Expect(GetResources(ca)).Should(gomega.Equal([]schema.GroupVersionKind{gvk}))
+}
@@ -0,0 +1,59 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package register
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ResourceRegister is an optional extension of Register to register resources of arbitrary GVKs,
+// which are watched and cached so that rules are able to check their state.
+type ResourceRegister interface {
+	RegisterResource(gvk schema.GroupVersionKind)
+}
+
+// ResourcePolicy is an optional extension of Policy, which exposes the registered resources
+type ResourcePolicy interface {
+	GetResources() []schema.GroupVersionKind
+}
+
+// GetResources returns the registered resources of policy, nil if policy does not support resources
+func GetResources(policy Policy) []schema.GroupVersionKind {
+	if p, ok := policy.(ResourcePolicy); ok {
+		return p.GetResources()
+	}
+	return nil
+}
+
+func (r *cache) RegisterResource(gvk schema.GroupVersionKind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, registered := range r.resources {
+		if registered == gvk {
+			return
+		}
+	}
+	r.resources = append(r.resources, gvk)
+}
+
+func (r *cache) GetResources() []schema.GroupVersionKind {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := make([]schema.GroupVersionKind, 0, len(r.resources))
+	res = append(res, r.resources...)
+	return res
+}
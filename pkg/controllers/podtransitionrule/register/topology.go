@@ -0,0 +1,90 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package register
+
+import (
+	"sort"
+)
+
+// StageGroup is a group of stages with the same order. Groups are processed one by one
+// in ascending order, stages in a group are processed in parallel unless Serial is set.
+type StageGroup struct {
+	Order  int
+	Serial bool
+	Stages []string
+}
+
+// StageTopologyPolicy is an optional extension of Policy, which declares the execution
+// topology of stages. Policies not implementing it process all stages in parallel.
+type StageTopologyPolicy interface {
+	GetStageGroups() []StageGroup
+}
+
+// StageTopologyRegister is an optional extension of Register to declare the order of a stage
+// and whether it runs serially with other stages of the same order. Stages default to order 0 and parallel.
+type StageTopologyRegister interface {
+	RegisterStageTopology(stage string, order int, serial bool)
+}
+
+// GetStageGroups returns the stage groups of policy, it is backward compatible with Policy
+// which only exposes GetStages.
+func GetStageGroups(policy Policy) []StageGroup {
+	if p, ok := policy.(StageTopologyPolicy); ok {
+		return p.GetStageGroups()
+	}
+	stages := policy.GetStages()
+	if len(stages) == 0 {
+		return nil
+	}
+	return []StageGroup{{Stages: stages}}
+}
+
+type stageTopology struct {
+	order  int
+	serial bool
+}
+
+func (r *cache) RegisterStageTopology(stage string, order int, serial bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topology[stage] = stageTopology{order: order, serial: serial}
+}
+
+func (r *cache) GetStageGroups() []StageGroup {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	groups := map[int]*StageGroup{}
+	var orders []int
+	// keep the registration order of stages in a group
+	for _, stage := range r.stages {
+		topo := r.topology[stage]
+		group, ok := groups[topo.order]
+		if !ok {
+			group = &StageGroup{Order: topo.order}
+			groups[topo.order] = group
+			orders = append(orders, topo.order)
+		}
+		group.Stages = append(group.Stages, stage)
+		group.Serial = group.Serial || topo.serial
+	}
+	sort.Ints(orders)
+	res := make([]StageGroup, 0, len(orders))
+	for _, order := range orders {
+		res = append(res, *groups[order])
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+const (
+	// RuleRejectedReason is the reason of events on pods rejected by rules, and on PodTransitionRules summarizing them
+	RuleRejectedReason = "RuleRejected"
+)
+
+// recordRejectionEvents emits a warning event on each pod newly rejected, and one on podTransitionRule summarizing them.
+// Rejections identical to last details are not recorded again, so that a stuck pod does not emit events every reconcile.
+// Pods let pass by kill switch are not blocked, and not recorded either, nor are rejections by rules in audit mode.
+func (r *PodTransitionRuleReconciler) recordRejectionEvents(podTransitionRule *appsv1alpha1.PodTransitionRule, targets map[string]*corev1.Pod,
+	lastDetails []*appsv1alpha1.PodTransitionDetail, details map[string]*appsv1alpha1.PodTransitionDetail) {
+	if r.Recorder == nil {
+		return
+	}
+	lastRejects := map[string]map[appsv1alpha1.RejectInfo]bool{}
+	for _, last := range lastDetails {
+		if last == nil {
+			continue
+		}
+		rejects := map[appsv1alpha1.RejectInfo]bool{}
+		for _, info := range last.RejectInfo {
+			rejects[info] = true
+		}
+		lastRejects[last.Name] = rejects
+	}
+
+	rejectedByRule := map[string]int{}
+	names := make([]string, 0, len(details))
+	for name := range details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		detail, pod := details[name], targets[name]
+		if detail.Passed || pod == nil {
+			continue
+		}
+		for _, info := range detail.RejectInfo {
+			if info.Audited || lastRejects[name][info] {
+				continue
+			}
+			r.Recorder.Eventf(pod, corev1.EventTypeWarning, RuleRejectedReason, "[%s] rejected by rule %s in stage %s: %s", podTransitionRule.Name, info.RuleName, detail.Stage, info.Reason)
+			rejectedByRule[info.RuleName]++
+		}
+	}
+	if len(rejectedByRule) == 0 {
+		return
+	}
+	rules := make([]string, 0, len(rejectedByRule))
+	for rule, count := range rejectedByRule {
+		rules = append(rules, fmt.Sprintf("%s=%d", rule, count))
+	}
+	sort.Strings(rules)
+	r.Recorder.Eventf(podTransitionRule, corev1.EventTypeWarning, RuleRejectedReason, "pods newly rejected by rules: %s", strings.Join(rules, ", "))
+}
@@ -0,0 +1,105 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package resources
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var maxObjectsPerResource = 1000
+
+// SetMaxObjectsPerResource sets the max number of objects cached for each resource of DefaultStore, it must be
+// called before starting the controller.
+func SetMaxObjectsPerResource(n int) {
+	maxObjectsPerResource = n
+}
+
+// DefaultStore caches objects of the resources registered to PodTransitionRule
+var DefaultStore = NewStore(func() int { return maxObjectsPerResource })
+
+// Store is a bounded cache of objects keyed by GVK. Once a GVK has more objects than the limit,
+// it is marked as overflowed and List on it fails, since its objects are not complete any more.
+type Store struct {
+	limit func() int
+
+	objects    map[schema.GroupVersionKind]map[types.NamespacedName]*unstructured.Unstructured
+	overflowed map[schema.GroupVersionKind]bool
+	mu         sync.RWMutex
+}
+
+func NewStore(limit func() int) *Store {
+	return &Store{
+		limit:      limit,
+		objects:    map[schema.GroupVersionKind]map[types.NamespacedName]*unstructured.Unstructured{},
+		overflowed: map[schema.GroupVersionKind]bool{},
+	}
+}
+
+// Update adds or updates obj in store, it returns error if the limit is exceeded
+func (s *Store) Update(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	objs, ok := s.objects[gvk]
+	if !ok {
+		objs = map[types.NamespacedName]*unstructured.Unstructured{}
+		s.objects[gvk] = objs
+	}
+	if _, exist := objs[key]; !exist {
+		if limit := s.limit(); limit > 0 && len(objs) >= limit {
+			s.overflowed[gvk] = true
+			return fmt.Errorf("too many objects of %s, limit is %d", gvk.String(), limit)
+		}
+	}
+	objs[key] = obj.DeepCopy()
+	return nil
+}
+
+func (s *Store) Delete(obj *unstructured.Unstructured) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if objs, ok := s.objects[obj.GroupVersionKind()]; ok {
+		delete(objs, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()})
+	}
+}
+
+// List returns objects of gvk matching selector in namespace, cluster-scoped objects are always included
+func (s *Store) List(gvk schema.GroupVersionKind, namespace string, selector labels.Selector) ([]*unstructured.Unstructured, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.overflowed[gvk] {
+		return nil, fmt.Errorf("objects of %s are not completely cached, limit is %d", gvk.String(), s.limit())
+	}
+	var res []*unstructured.Unstructured
+	for key, obj := range s.objects[gvk] {
+		if key.Namespace != "" && key.Namespace != namespace {
+			continue
+		}
+		if selector != nil && !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+		res = append(res, obj)
+	}
+	return res, nil
+}
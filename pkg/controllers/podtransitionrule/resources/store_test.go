@@ -0,0 +1,65 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package resources
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newObject(gvk schema.GroupVersionKind, namespace, name string, lbs map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetLabels(lbs)
+	return obj
+}
+
+func TestStore(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	gvk := schema.GroupVersionKind{Group: "test.kusionstack.io", Version: "v1", Kind: "MaintenanceMode"}
+	store := NewStore(func() int { return 3 })
+
+	g.Expect(store.Update(newObject(gvk, "default", "a", map[string]string{"app": "foo"}))).Should(gomega.Succeed())
+	g.Expect(store.Update(newObject(gvk, "default", "b", nil))).Should(gomega.Succeed())
+	g.Expect(store.Update(newObject(gvk, "", "cluster", nil))).Should(gomega.Succeed())
+	// update existing object is always allowed
+	g.Expect(store.Update(newObject(gvk, "default", "b", map[string]string{"app": "foo"}))).Should(gomega.Succeed())
+
+	objs, err := store.List(gvk, "default", labels.SelectorFromSet(labels.Set{"app": "foo"}))
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(objs).Should(gomega.HaveLen(2))
+	objs, err = store.List(gvk, "other", labels.Everything())
+	g.Expect(err).ShouldNot(gomega.HaveOccurred())
+	g.Expect(objs).Should(gomega.HaveLen(1))
+	g.Expect(objs[0].GetName()).Should(gomega.Equal("cluster"))
+
+	store.Delete(newObject(gvk, "default", "a", nil))
+	objs, _ = store.List(gvk, "default", nil)
+	g.Expect(objs).Should(gomega.HaveLen(2))
+
+	// exceed the limit
+	g.Expect(store.Update(newObject(gvk, "default", "c", nil))).Should(gomega.Succeed())
+	g.Expect(store.Update(newObject(gvk, "default", "d", nil))).Should(gomega.HaveOccurred())
+	_, err = store.List(gvk, "default", nil)
+	g.Expect(err).Should(gomega.HaveOccurred())
+}
@@ -0,0 +1,140 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// ruleStatesKey is the key of RuleStates in companion ConfigMap
+const ruleStatesKey = "ruleStates"
+
+func ruleStatesConfigMapName(podTransitionRuleName string) string {
+	return podTransitionRuleName + "-rulestates"
+}
+
+// loadRuleStates replaces RuleStates summaries in status with the detailed RuleStates in companion ConfigMap
+func (r *PodTransitionRuleReconciler) loadRuleStates(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule) error {
+	ref := podTransitionRule.Status.RuleStatesRef
+	if ref == nil {
+		return nil
+	}
+	cm := &corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: podTransitionRule.Namespace, Name: ref.ConfigMapName}, cm); err != nil {
+		return err
+	}
+	var ruleStates []*appsv1alpha1.RuleState
+	if err := json.Unmarshal([]byte(cm.Data[ruleStatesKey]), &ruleStates); err != nil {
+		return err
+	}
+	podTransitionRule.Status.RuleStates = ruleStates
+	return nil
+}
+
+// compactRuleStates moves RuleStates into companion ConfigMap if they are larger than maxRuleStatesBytes, and returns
+// summaries to keep in status. The companion ConfigMap is deleted once RuleStates are small enough.
+func (r *PodTransitionRuleReconciler) compactRuleStates(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, ruleStates []*appsv1alpha1.RuleState) ([]*appsv1alpha1.RuleState, *appsv1alpha1.RuleStatesReference, error) {
+	data, err := json.Marshal(ruleStates)
+	if err != nil {
+		return nil, nil, err
+	}
+	name := ruleStatesConfigMapName(podTransitionRule.Name)
+	if r.maxRuleStatesBytes <= 0 || len(data) <= r.maxRuleStatesBytes {
+		if podTransitionRule.Status.RuleStatesRef != nil {
+			if err := r.deleteCompanionConfigMap(ctx, podTransitionRule, name); err != nil {
+				return nil, nil, err
+			}
+		}
+		return ruleStates, nil, nil
+	}
+	if err := r.applyCompanionConfigMap(ctx, podTransitionRule, name, ruleStatesKey, string(data)); err != nil {
+		return nil, nil, err
+	}
+	return summarizeRuleStates(ruleStates), &appsv1alpha1.RuleStatesReference{ConfigMapName: name}, nil
+}
+
+func summarizeRuleStates(ruleStates []*appsv1alpha1.RuleState) []*appsv1alpha1.RuleState {
+	summaries := make([]*appsv1alpha1.RuleState, 0, len(ruleStates))
+	for _, state := range ruleStates {
+		if state == nil {
+			continue
+		}
+		pods := sets.NewString()
+		summary := &appsv1alpha1.RuleStateSummary{}
+		if state.WebhookStatus != nil {
+			summary.Tasks = int32(len(state.WebhookStatus.TaskStates))
+			for _, task := range state.WebhookStatus.TaskStates {
+				pods.Insert(task.Processing...)
+			}
+		}
+		if state.DrainStatus != nil {
+			for _, pod := range state.DrainStatus.Pods {
+				pods.Insert(pod.Name)
+			}
+		}
+		if state.ApprovalStatus != nil {
+			pods.Insert(state.ApprovalStatus.Pending...)
+		}
+		if state.InProgressStatus != nil {
+			pods.Insert(state.InProgressStatus.Pods...)
+		}
+		summary.Pods = int32(pods.Len())
+		summaries = append(summaries, &appsv1alpha1.RuleState{Name: state.Name, Summary: summary})
+	}
+	return summaries
+}
+
+// applyCompanionConfigMap creates or updates the ConfigMap owned by podTransitionRule to hold value in key
+func (r *PodTransitionRuleReconciler) applyCompanionConfigMap(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, name, key, value string) error {
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: podTransitionRule.Namespace, Name: name}, cm)
+	switch {
+	case errors.IsNotFound(err):
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       podTransitionRule.Namespace,
+				Name:            name,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(podTransitionRule, appsv1alpha1.GroupVersion.WithKind("PodTransitionRule"))},
+			},
+			Data: map[string]string{key: value},
+		}
+		return r.Client.Create(ctx, cm)
+	case err != nil:
+		return err
+	case cm.Data[key] != value:
+		cm.Data = map[string]string{key: value}
+		return r.Client.Update(ctx, cm)
+	}
+	return nil
+}
+
+func (r *PodTransitionRuleReconciler) deleteCompanionConfigMap(ctx context.Context, podTransitionRule *appsv1alpha1.PodTransitionRule, name string) error {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: podTransitionRule.Namespace, Name: name}}
+	if err := r.Client.Delete(ctx, cm); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
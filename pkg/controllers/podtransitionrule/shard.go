@@ -0,0 +1,299 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// Sharding runs PodTransitionRule controller active-active without leader election. Each replica holds a Lease
+// labeled shardLeaseLabel, and live replicas are the holders of unexpired Leases. A PodTransitionRule is owned by
+// the replica chosen by rendezvous hashing of its key over live replicas, so that only PodTransitionRules of the
+// joining or leaving replica move on rebalancing.
+//
+// To avoid two replicas briefly owning the same PodTransitionRule, a replica releases PodTransitionRules moved away
+// as soon as it observes a membership change, while it acquires PodTransitionRules moved in only after the change
+// is settled for a handoff delay. The delay covers a renew interval, in which all replicas observe the change, and a
+// lease duration, in which in-flight reconciles of the previous owner finish. A replica failing to renew its Lease
+// owns nothing, since others may regard it as dead.
+const (
+	shardLeaseLabel  = "podtransitionrule.kusionstack.io/shard"
+	shardLeasePrefix = "podtransitionrule-shard-"
+)
+
+var shardMembersGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "podtransitionrule_shard_members",
+	Help: "Live replicas sharing PodTransitionRules observed by this replica, 0 if sharding is disabled.",
+})
+
+func init() {
+	metrics.Registry.MustRegister(shardMembersGauge)
+}
+
+// shardMembers is the shard membership of this replica, nil if sharding is disabled
+var shardMembers *shardMembership
+
+type shardMembership struct {
+	client        client.Client
+	reader        client.Reader
+	logger        logr.Logger
+	namespace     string
+	identity      string
+	leaseDuration time.Duration
+	events        chan event.GenericEvent
+	now           func() time.Time
+
+	mu sync.RWMutex
+	// members are live replicas, settled are members adopted after handoff delay
+	members   []string
+	settled   []string
+	changedAt time.Time
+	renewedAt time.Time
+}
+
+// NeedLeaderElection lets every replica hold its Lease
+func (m *shardMembership) NeedLeaderElection() bool {
+	return false
+}
+
+func (m *shardMembership) renewInterval() time.Duration {
+	return m.leaseDuration / 3
+}
+
+func (m *shardMembership) handoffDelay() time.Duration {
+	return m.leaseDuration + m.renewInterval()
+}
+
+func (m *shardMembership) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.renewInterval())
+	defer ticker.Stop()
+	for {
+		if err := m.sync(ctx); err != nil {
+			m.logger.Error(err, "failed to sync shard membership")
+		}
+		if m.settle() {
+			m.resync(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			m.release()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync renews the Lease of this replica and refreshes live members
+func (m *shardMembership) sync(ctx context.Context) error {
+	now := m.now()
+	if err := m.renew(ctx, now); err != nil {
+		return err
+	}
+	leases := &coordinationv1.LeaseList{}
+	if err := m.reader.List(ctx, leases, client.InNamespace(m.namespace), client.MatchingLabels{shardLeaseLabel: "true"}); err != nil {
+		return err
+	}
+	members := []string{}
+	for _, lease := range leases.Items {
+		if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil {
+			continue
+		}
+		duration := m.leaseDuration
+		if lease.Spec.LeaseDurationSeconds != nil {
+			duration = time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second
+		}
+		if lease.Spec.RenewTime.Add(duration).Before(now) {
+			continue
+		}
+		members = append(members, *lease.Spec.HolderIdentity)
+	}
+	sort.Strings(members)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renewedAt = now
+	if !equality.Semantic.DeepEqual(members, m.members) {
+		m.logger.Info("shard members changed, acquire moved podtransitionrules after handoff delay", "members", members, "handoffDelay", m.handoffDelay())
+		m.members = members
+		m.changedAt = now
+	}
+	shardMembersGauge.Set(float64(len(members)))
+	return nil
+}
+
+func (m *shardMembership) renew(ctx context.Context, now time.Time) error {
+	renewTime := metav1.NewMicroTime(now)
+	durationSeconds := int32(m.leaseDuration / time.Second)
+	lease := &coordinationv1.Lease{}
+	err := m.reader.Get(ctx, types.NamespacedName{Namespace: m.namespace, Name: shardLeasePrefix + m.identity}, lease)
+	if errors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: m.namespace,
+				Name:      shardLeasePrefix + m.identity,
+				Labels:    map[string]string{shardLeaseLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.identity,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &renewTime,
+				RenewTime:            &renewTime,
+			},
+		}
+		return m.client.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = &m.identity
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &renewTime
+	return m.client.Update(ctx, lease)
+}
+
+// release deletes the Lease of this replica on stop, so that others observe its leaving without waiting for expiry
+func (m *shardMembership) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.renewInterval())
+	defer cancel()
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: m.namespace, Name: shardLeasePrefix + m.identity}}
+	if err := m.client.Delete(ctx, lease); client.IgnoreNotFound(err) != nil {
+		m.logger.Error(err, "failed to release shard lease")
+	}
+}
+
+// settle adopts members once they are unchanged for handoff delay, returns true if settled members are changed
+func (m *shardMembership) settle() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if equality.Semantic.DeepEqual(m.settled, m.members) || m.now().Sub(m.changedAt) < m.handoffDelay() {
+		return false
+	}
+	m.settled = m.members
+	return true
+}
+
+// resync enqueues all PodTransitionRules, so that those acquired by this replica are reconciled
+func (m *shardMembership) resync(ctx context.Context) {
+	podTransitionRules := &appsv1alpha1.PodTransitionRuleList{}
+	if err := m.client.List(ctx, podTransitionRules); err != nil {
+		m.logger.Error(err, "failed to list podtransitionrules to resync shard")
+		return
+	}
+	for i := range podTransitionRules.Items {
+		select {
+		case m.events <- event.GenericEvent{Object: &podTransitionRules.Items[i]}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// owns returns true if the PodTransitionRule of key is reconciled by this replica
+func (m *shardMembership) owns(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	now := m.now()
+	if now.Sub(m.renewedAt) > m.leaseDuration {
+		return false
+	}
+	if shardOwner(key, m.members) != m.identity {
+		return false
+	}
+	return shardOwner(key, m.settled) == m.identity || now.Sub(m.changedAt) >= m.handoffDelay()
+}
+
+// shardOwner returns the member with the highest hash of key, empty if there is no member
+func shardOwner(key string, members []string) string {
+	var owner string
+	var highest uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(member))
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+		if sum := mix64(h.Sum64()); owner == "" || sum > highest {
+			owner, highest = member, sum
+		}
+	}
+	return owner
+}
+
+// addShardsToMgr adds c and shard membership of this replica to mgr, both run without leader election
+func addShardsToMgr(mgr manager.Manager, c controller.Controller, namespace string, leaseDuration time.Duration) error {
+	identity, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	shardMembers = &shardMembership{
+		client:        mgr.GetClient(),
+		reader:        mgr.GetAPIReader(),
+		logger:        mgr.GetLogger().WithName(controllerName).WithName("shard"),
+		namespace:     namespace,
+		identity:      identity,
+		leaseDuration: leaseDuration,
+		events:        make(chan event.GenericEvent, 1<<10),
+		now:           time.Now,
+	}
+	if err := c.Watch(&source.Channel{Source: shardMembers.events}, &QueueWaitEventHandler{EventHandler: &handler.EnqueueRequestForObject{}}); err != nil {
+		return err
+	}
+	if err := mgr.Add(shardMembers); err != nil {
+		return err
+	}
+	return mgr.Add(&unelectedController{Controller: c})
+}
+
+// unelectedController runs the controller on every replica regardless of leader election
+type unelectedController struct {
+	controller.Controller
+}
+
+func (c *unelectedController) NeedLeaderElection() bool {
+	return false
+}
+
+// mix64 is the finalizer of MurmurHash3, fnv alone barely spreads members differing in last bytes
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
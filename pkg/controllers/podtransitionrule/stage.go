@@ -0,0 +1,91 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+const (
+	// UnknownStage is the stage of pods not in any stage of current policy
+	UnknownStage = "Unknown"
+
+	// UnknownStageVerdictPass lets pods whose stage is removed from policy pass
+	UnknownStageVerdictPass = "pass"
+	// UnknownStageVerdictBlock blocks pods whose stage is removed from policy until they enter a stage of current policy
+	UnknownStageVerdictBlock = "block"
+)
+
+func parseUnknownStageVerdict(verdict string) (string, error) {
+	switch verdict {
+	case UnknownStageVerdictPass, UnknownStageVerdictBlock:
+		return verdict, nil
+	}
+	return UnknownStageVerdictPass, fmt.Errorf("unknown verdict %q of unknown stages, use %s", verdict, UnknownStageVerdictPass)
+}
+
+// applyUnknownStageVerdict blocks target pods which have no detail in stages of current policy, but whose last
+// detail is in a stage removed from policy, if the verdict is block
+func applyUnknownStageVerdict(verdict string, stages sets.String, targets map[string]*corev1.Pod, lastDetails []*appsv1alpha1.PodTransitionDetail, details map[string]*appsv1alpha1.PodTransitionDetail) {
+	if verdict != UnknownStageVerdictBlock {
+		return
+	}
+	for _, last := range lastDetails {
+		if last == nil || targets[last.Name] == nil || details[last.Name] != nil || last.Stage == "" || stages.Has(last.Stage) {
+			continue
+		}
+		reason := fmt.Sprintf("blocked by default verdict, stage %s is not in current policy", last.Stage)
+		// keep the removed stage in reason once the pod is in unknown stage
+		if last.Stage == UnknownStage && len(last.RejectInfo) > 0 {
+			reason = last.RejectInfo[0].Reason
+		}
+		details[last.Name] = &appsv1alpha1.PodTransitionDetail{
+			Name:       last.Name,
+			Stage:      UnknownStage,
+			RejectInfo: []appsv1alpha1.RejectInfo{{Reason: reason}},
+		}
+	}
+}
+
+// pruneUnknownStages drops details and rule states of stages not in current policy
+func pruneUnknownStages(stages sets.String, podTransitionRule *appsv1alpha1.PodTransitionRule, details []*appsv1alpha1.PodTransitionDetail, ruleStates []*appsv1alpha1.RuleState) ([]*appsv1alpha1.PodTransitionDetail, []*appsv1alpha1.RuleState) {
+	prunedDetails := make([]*appsv1alpha1.PodTransitionDetail, 0, len(details))
+	for _, detail := range details {
+		if detail.Stage == UnknownStage || stages.Has(detail.Stage) {
+			prunedDetails = append(prunedDetails, detail)
+		}
+	}
+	unknownRules := sets.NewString()
+	for _, rule := range podTransitionRule.Spec.Rules {
+		if rule.Stage != nil && !stages.Has(*rule.Stage) {
+			unknownRules.Insert(rule.Name)
+		}
+	}
+	var prunedStates []*appsv1alpha1.RuleState
+	for _, state := range ruleStates {
+		if state != nil && unknownRules.Has(state.Name) {
+			continue
+		}
+		prunedStates = append(prunedStates, state)
+	}
+	return prunedDetails, prunedStates
+}
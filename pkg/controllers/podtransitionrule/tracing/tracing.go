@@ -0,0 +1,117 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+// Package tracing traces reconciles of PodTransitionRule across stages and webhook calls. Spans are dropped
+// unless a Tracer is set, e.g. an adapter of an OpenTelemetry tracer provider.
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// TraceParentHeader is the W3C trace context header propagated to webhooks
+const TraceParentHeader = "traceparent"
+
+// Attribute is a key value pair attached to span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// TraceParent returns W3C traceparent of the span, empty means trace context is not propagated
+	TraceParent() string
+	End()
+}
+
+type Tracer interface {
+	// Start starts a span, which is a child of the span in ctx if any
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+var (
+	mu     sync.RWMutex
+	tracer Tracer = noopTracer{}
+)
+
+// SetTracer sets the tracer of PodTransitionRule controller, nil means no-op
+func SetTracer(t Tracer) {
+	mu.Lock()
+	defer mu.Unlock()
+	if t == nil {
+		t = noopTracer{}
+	}
+	tracer = t
+}
+
+// Start starts a span with attributes, the span is retrievable from returned context by SpanFromContext
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	mu.RLock()
+	t := tracer
+	mu.RUnlock()
+	ctx, span := t.Start(ctx, name)
+	span.SetAttributes(attrs...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the span started in ctx, or a no-op span
+func SpanFromContext(ctx context.Context) Span {
+	if ctx != nil {
+		if span, ok := ctx.Value(spanKey{}).(Span); ok {
+			return span
+		}
+	}
+	return noopSpan{}
+}
+
+// InjectHeaders adds trace context of the span in ctx to header, header is allocated if nil and trace context exists
+func InjectHeaders(ctx context.Context, header map[string]string) map[string]string {
+	traceParent := SpanFromContext(ctx).TraceParent()
+	if traceParent == "" {
+		return header
+	}
+	if header == nil {
+		header = map[string]string{}
+	}
+	header[TraceParentHeader] = traceParent
+	return header
+}
+
+type spanKey struct{}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+
+func (noopSpan) TraceParent() string { return "" }
+
+func (noopSpan) End() {}
@@ -0,0 +1,93 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	id     int
+	attrs  map[string]interface{}
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) TraceParent() string {
+	return fmt.Sprintf("00-%032x-%016x-01", 1, s.id)
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &recordedSpan{name: name, id: len(t.spans) + 1, attrs: map[string]interface{}{}}
+	if parent, ok := SpanFromContext(ctx).(*recordedSpan); ok {
+		span.parent = parent
+	}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestNoopTracer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ctx, span := Start(context.Background(), "reconcile", String("podtransitionrule", "default/rs"))
+	span.End()
+	g.Expect(SpanFromContext(ctx).TraceParent()).Should(gomega.BeEmpty())
+	g.Expect(InjectHeaders(ctx, nil)).Should(gomega.BeNil())
+	g.Expect(SpanFromContext(nil)).ShouldNot(gomega.BeNil())
+}
+
+func TestTracer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	tracer := &recordingTracer{}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, reconcileSpan := Start(context.Background(), "reconcile", String("podtransitionrule", "default/rs"))
+	SpanFromContext(ctx).SetAttributes(Int("targets", 3))
+	stageCtx, stageSpan := Start(ctx, "stage", String("stage", "PreCheck"))
+	stageSpan.SetAttributes(Int("passed", 2), Int("rejected", 1))
+	stageSpan.End()
+	reconcileSpan.End()
+
+	g.Expect(tracer.spans).Should(gomega.HaveLen(2))
+	g.Expect(tracer.spans[0].attrs).Should(gomega.Equal(map[string]interface{}{"podtransitionrule": "default/rs", "targets": 3}))
+	g.Expect(tracer.spans[1].parent).Should(gomega.Equal(tracer.spans[0]))
+	g.Expect(tracer.spans[1].attrs).Should(gomega.Equal(map[string]interface{}{"stage": "PreCheck", "passed": 2, "rejected": 1}))
+	g.Expect(tracer.spans[0].ended && tracer.spans[1].ended).Should(gomega.BeTrue())
+
+	header := InjectHeaders(stageCtx, map[string]string{"Content-Type": "application/json"})
+	g.Expect(header).Should(gomega.HaveKeyWithValue(TraceParentHeader, "00-00000000000000000000000000000001-0000000000000002-01"))
+	g.Expect(header).Should(gomega.HaveKeyWithValue("Content-Type", "application/json"))
+}
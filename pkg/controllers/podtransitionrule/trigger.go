@@ -0,0 +1,120 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// triggerObject is the kind of watched objects whose events enqueue PodTransitionRules
+type triggerObject string
+
+const (
+	triggerObjectPod               triggerObject = "pod"
+	triggerObjectPodTransitionRule triggerObject = "podtransitionrule"
+	// triggerObjectDependency is objects referenced by PodTransitionRules, e.g. ConfigMaps and workloads
+	triggerObjectDependency triggerObject = ""
+)
+
+// sources of reconcile triggers
+const (
+	triggerPodAdd           = "pod_add"
+	triggerPodUpdate        = "pod_update"
+	triggerPodDelete        = "pod_delete"
+	triggerRuleChange       = "ruleset_change"
+	triggerDependencyChange = "dependency_change"
+	triggerResync           = "resync"
+	triggerManual           = "manual"
+	triggerRequeue          = "requeue"
+)
+
+var (
+	reconcileTriggerTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podtransitionrule_reconcile_trigger_total",
+		Help: "Reconciles of PodTransitionRules by trigger source, which is one of pod_add, pod_update, pod_delete, ruleset_change, dependency_change, resync, manual and requeue. A reconcile triggered by several sources is counted once for each.",
+	}, []string{"source"})
+
+	reconcileTriggers = &triggerTracker{sources: map[reconcile.Request]sets.String{}}
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileTriggerTotal)
+}
+
+// triggerSource returns the trigger source of an event on object kind o. Update events without change of
+// resourceVersion are resyncs.
+func (o triggerObject) triggerSource(eventType string, oldObj, newObj client.Object) string {
+	if eventType == "update" && oldObj != nil && newObj != nil && oldObj.GetResourceVersion() == newObj.GetResourceVersion() {
+		return triggerResync
+	}
+	if eventType == "generic" {
+		return triggerManual
+	}
+	switch o {
+	case triggerObjectPod:
+		switch eventType {
+		case "create":
+			return triggerPodAdd
+		case "delete":
+			return triggerPodDelete
+		}
+		return triggerPodUpdate
+	case triggerObjectPodTransitionRule:
+		return triggerRuleChange
+	}
+	return triggerDependencyChange
+}
+
+// triggerTracker records the trigger sources of requests enqueued since last reconcile
+type triggerTracker struct {
+	sources map[reconcile.Request]sets.String
+	mu      sync.Mutex
+}
+
+func (t *triggerTracker) add(item interface{}, source string) {
+	req, ok := item.(reconcile.Request)
+	if !ok || source == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sources[req] == nil {
+		t.sources[req] = sets.NewString()
+	}
+	t.sources[req].Insert(source)
+}
+
+// observe counts the trigger sources of req when it is reconciled, reconciles not enqueued by events are requeues
+func (t *triggerTracker) observe(req reconcile.Request) {
+	t.mu.Lock()
+	sources := t.sources[req]
+	delete(t.sources, req)
+	t.mu.Unlock()
+	if sources.Len() == 0 {
+		reconcileTriggerTotal.WithLabelValues(triggerRequeue).Inc()
+		return
+	}
+	for _, source := range sources.List() {
+		reconcileTriggerTotal.WithLabelValues(source).Inc()
+	}
+}
@@ -18,6 +18,9 @@ package utils
 
 import (
 	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -47,8 +50,23 @@ type PodSkipRuleConditions struct {
 	SkipRules []string `json:"skipRules,omitempty"`
 }
 
+// GetDisruptionCost returns the disruption cost of pod, which is 0 if not set or invalid
+func GetDisruptionCost(po *corev1.Pod) int64 {
+	if po.Annotations == nil {
+		return 0
+	}
+	cost, err := strconv.ParseInt(po.Annotations[appsv1alpha1.AnnotationPodDisruptionCost], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return cost
+}
+
 func MoveAllPodTransitionRuleInfo(po *corev1.Pod, podtransitionruleName string) bool {
-	return MoveDetailAnno(po, podtransitionruleName)
+	movedDetail := MoveDetailAnno(po, podtransitionruleName)
+	movedApprove := MoveApproveAnno(po, podtransitionruleName)
+	movedExplain := MoveExplainAnno(po, podtransitionruleName)
+	return movedDetail || movedApprove || movedExplain
 }
 
 // MoveDetailAnno move PodTransitionRule detail annotation podtransitionrule.kusionstack.io/detail-${podTransitionRuleName}
@@ -62,3 +80,90 @@ func MoveDetailAnno(po *corev1.Pod, podtransitionruleName string) bool {
 	}
 	return ok
 }
+
+// MoveApproveAnno move PodTransitionRule approve annotation approve.podtransitionrule.kusionstack.io/${podTransitionRuleName}
+func MoveApproveAnno(po *corev1.Pod, podtransitionruleName string) bool {
+	if po.Annotations == nil {
+		return false
+	}
+	_, ok := po.Annotations[appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix+"/"+podtransitionruleName]
+	if ok {
+		delete(po.Annotations, appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix+"/"+podtransitionruleName)
+	}
+	return ok
+}
+
+// GetApprover returns the approver of pod for ManualApprovalRule of podTransitionRule, which is the value of annotation
+// approve.podtransitionrule.kusionstack.io/${podTransitionRuleName} on pod, or annotation
+// approve.podtransitionrule.kusionstack.io/${podName} on podTransitionRule. Empty means not approved.
+func GetApprover(po *corev1.Pod, podTransitionRule *appsv1alpha1.PodTransitionRule) string {
+	for _, approver := range []string{
+		po.Annotations[appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix+"/"+podTransitionRule.Name],
+		podTransitionRule.Annotations[appsv1alpha1.AnnotationPodTransitionRuleApprovePrefix+"/"+po.Name],
+	} {
+		if approver != "" && approver != "false" {
+			return approver
+		}
+	}
+	return ""
+}
+
+// ExplainRequested returns true if annotation podtransitionrule.kusionstack.io/explain on pod requests
+// podTransitionRule to explain
+func ExplainRequested(po *corev1.Pod, podtransitionruleName string) bool {
+	return annotationNames(po, appsv1alpha1.AnnotationPodExplain, podtransitionruleName)
+}
+
+// ExcludeRequested returns true if annotation podtransitionrule.kusionstack.io/exclude on pod exempts pod from
+// podTransitionRule
+func ExcludeRequested(po *corev1.Pod, podtransitionruleName string) bool {
+	return annotationNames(po, appsv1alpha1.AnnotationPodExclude, podtransitionruleName)
+}
+
+// annotationNames returns true if annotation key on pod is true, or names podTransitionRule in comma separated names
+func annotationNames(po *corev1.Pod, key, podtransitionruleName string) bool {
+	val, ok := po.Annotations[key]
+	if !ok {
+		return false
+	}
+	if val == "true" {
+		return true
+	}
+	for _, name := range strings.Split(val, ",") {
+		if strings.TrimSpace(name) == podtransitionruleName {
+			return true
+		}
+	}
+	return false
+}
+
+// MoveExplainAnno removes podTransitionRule from annotation podtransitionrule.kusionstack.io/explain, true is
+// expanded to PodTransitionRules with detail annotations on pod. The annotation is removed if no one is left.
+func MoveExplainAnno(po *corev1.Pod, podtransitionruleName string) bool {
+	if !ExplainRequested(po, podtransitionruleName) {
+		return false
+	}
+	var names []string
+	if po.Annotations[appsv1alpha1.AnnotationPodExplain] == "true" {
+		for key := range po.Annotations {
+			if strings.HasPrefix(key, appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix+"/") {
+				names = append(names, strings.TrimPrefix(key, appsv1alpha1.AnnotationPodTransitionRuleDetailPrefix+"/"))
+			}
+		}
+		sort.Strings(names)
+	} else {
+		names = strings.Split(po.Annotations[appsv1alpha1.AnnotationPodExplain], ",")
+	}
+	var left []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" && name != podtransitionruleName {
+			left = append(left, name)
+		}
+	}
+	if len(left) == 0 {
+		delete(po.Annotations, appsv1alpha1.AnnotationPodExplain)
+	} else {
+		po.Annotations[appsv1alpha1.AnnotationPodExplain] = strings.Join(left, ",")
+	}
+	return true
+}
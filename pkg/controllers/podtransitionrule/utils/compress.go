@@ -0,0 +1,99 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// CompressedStatusKey is the key of compressed status in ConfigMap referred by CompressedStatusRef
+const CompressedStatusKey = "status"
+
+type compressedStatus struct {
+	Details    []*appsv1alpha1.PodTransitionDetail `json:"details,omitempty"`
+	RuleStates []*appsv1alpha1.RuleState           `json:"ruleStates,omitempty"`
+}
+
+// EncodeStatus encodes details and ruleStates into a gzip and base64 encoded string
+func EncodeStatus(details []*appsv1alpha1.PodTransitionDetail, ruleStates []*appsv1alpha1.RuleState) (string, error) {
+	data, err := json.Marshal(&compressedStatus{Details: details, RuleStates: ruleStates})
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeStatus decodes details and ruleStates from the string encoded by EncodeStatus
+func DecodeStatus(encoded string) ([]*appsv1alpha1.PodTransitionDetail, []*appsv1alpha1.RuleState, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	data, err = io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	status := &compressedStatus{}
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, nil, err
+	}
+	return status.Details, status.RuleStates, nil
+}
+
+// GetDetails returns details of podTransitionRule, which are decoded from the ConfigMap referred by
+// CompressedStatusRef if status is compressed
+func GetDetails(ctx context.Context, c client.Reader, podTransitionRule *appsv1alpha1.PodTransitionRule) ([]*appsv1alpha1.PodTransitionDetail, error) {
+	if podTransitionRule.Status.CompressedStatusRef == nil {
+		return podTransitionRule.Status.Details, nil
+	}
+	details, _, err := GetCompressedStatus(ctx, c, podTransitionRule)
+	return details, err
+}
+
+// GetCompressedStatus decodes details and ruleStates from the ConfigMap referred by CompressedStatusRef
+func GetCompressedStatus(ctx context.Context, c client.Reader, podTransitionRule *appsv1alpha1.PodTransitionRule) ([]*appsv1alpha1.PodTransitionDetail, []*appsv1alpha1.RuleState, error) {
+	cm := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: podTransitionRule.Namespace, Name: podTransitionRule.Status.CompressedStatusRef.ConfigMapName}
+	if err := c.Get(ctx, key, cm); err != nil {
+		return nil, nil, err
+	}
+	return DecodeStatus(cm.Data[CompressedStatusKey])
+}
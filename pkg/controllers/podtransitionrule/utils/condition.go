@@ -0,0 +1,123 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+const (
+	PodConditionReasonPassed  = "Passed"
+	PodConditionReasonBlocked = "Blocked"
+	PodConditionReasonNoStage = "NoStage"
+	// PodConditionReasonReleased is the reason of readiness gate condition on pods no longer targets
+	PodConditionReasonReleased = "Released"
+)
+
+// PodConditionType returns the pod condition type managed by PodTransitionRule
+func PodConditionType(podTransitionRuleName string) corev1.PodConditionType {
+	return corev1.PodConditionType(appsv1alpha1.PodTransitionRuleConditionTypePrefix + "/" + podTransitionRuleName)
+}
+
+// NewPodCondition returns the pod condition reflecting detail, nil detail means the pod is not in any stage
+func NewPodCondition(podTransitionRuleName string, detail *appsv1alpha1.PodTransitionDetail) *corev1.PodCondition {
+	return newPodCondition(PodConditionType(podTransitionRuleName), detail)
+}
+
+// NewReadinessGateCondition returns the readiness gate condition reflecting detail, nil detail means the pod
+// is not in any stage
+func NewReadinessGateCondition(gate *appsv1alpha1.PodTransitionRuleReadinessGate, detail *appsv1alpha1.PodTransitionDetail) *corev1.PodCondition {
+	return newPodCondition(gate.ConditionType, detail)
+}
+
+// ReleasedReadinessGateCondition returns the readiness gate condition of pods no longer controlled by
+// PodTransitionRule. It is True since a missing readiness gate condition makes pods unready.
+func ReleasedReadinessGateCondition(gate *appsv1alpha1.PodTransitionRuleReadinessGate) *corev1.PodCondition {
+	return &corev1.PodCondition{
+		Type:    gate.ConditionType,
+		Status:  corev1.ConditionTrue,
+		Reason:  PodConditionReasonReleased,
+		Message: "pod is released by podtransitionrule",
+	}
+}
+
+func newPodCondition(conditionType corev1.PodConditionType, detail *appsv1alpha1.PodTransitionDetail) *corev1.PodCondition {
+	condition := &corev1.PodCondition{
+		Type:   conditionType,
+		Status: corev1.ConditionTrue,
+		Reason: PodConditionReasonNoStage,
+	}
+	if detail == nil {
+		return condition
+	}
+	if detail.Passed {
+		condition.Reason = PodConditionReasonPassed
+		condition.Message = "passed all rules in stage " + detail.Stage
+		return condition
+	}
+	condition.Status = corev1.ConditionFalse
+	condition.Reason = PodConditionReasonBlocked
+	reasons := make([]string, 0, len(detail.RejectInfo))
+	for _, info := range detail.RejectInfo {
+		if info.Audited {
+			continue
+		}
+		reasons = append(reasons, info.Reason)
+	}
+	condition.Message = strings.Join(reasons, "; ")
+	return condition
+}
+
+// SetPodCondition sets condition on pod, it returns false if pod already has the condition.
+// LastTransitionTime is updated only if the status of condition changes.
+func SetPodCondition(pod *corev1.Pod, condition *corev1.PodCondition) bool {
+	for i := range pod.Status.Conditions {
+		existing := &pod.Status.Conditions[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+			return false
+		}
+		if existing.Status != condition.Status {
+			existing.LastTransitionTime = metav1.Now()
+		}
+		existing.Status, existing.Reason, existing.Message = condition.Status, condition.Reason, condition.Message
+		return true
+	}
+	newCondition := *condition
+	newCondition.LastTransitionTime = metav1.Now()
+	pod.Status.Conditions = append(pod.Status.Conditions, newCondition)
+	return true
+}
+
+// RemovePodCondition removes the condition managed by PodTransitionRule, it returns false if pod has no such condition
+func RemovePodCondition(pod *corev1.Pod, podTransitionRuleName string) bool {
+	conditionType := PodConditionType(podTransitionRuleName)
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			pod.Status.Conditions = append(pod.Status.Conditions[:i], pod.Status.Conditions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// keys of namespace defaults ConfigMap podtransitionrule-defaults
+const (
+	DefaultsKeyWebhookFailurePolicy       = "webhookFailurePolicy"
+	DefaultsKeyWebhookPollIntervalSeconds = "webhookPollIntervalSeconds"
+	DefaultsKeyWebhookPollTimeoutSeconds  = "webhookPollTimeoutSeconds"
+	DefaultsKeyTimeWindowTimeZone         = "timeWindowTimeZone"
+)
+
+// DefaultTimeWindowTimeZone is the time zone of maintenance windows without time zone in spec or namespace defaults
+const DefaultTimeWindowTimeZone = "UTC"
+
+// NamespaceDefaults is the configuration inherited by PodTransitionRules in the same namespace,
+// fields set in PodTransitionRule spec always win over these defaults.
+type NamespaceDefaults struct {
+	WebhookFailurePolicy       *appsv1alpha1.FailurePolicyType
+	WebhookPollIntervalSeconds *int64
+	WebhookPollTimeoutSeconds  *int64
+	TimeWindowTimeZone         *string
+}
+
+// GetNamespaceDefaults returns nil if there is no defaults ConfigMap in namespace
+func GetNamespaceDefaults(ctx context.Context, c client.Reader, namespace string) (*NamespaceDefaults, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: appsv1alpha1.PodTransitionRuleDefaultsConfigMap}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return ParseNamespaceDefaults(cm)
+}
+
+func ParseNamespaceDefaults(cm *corev1.ConfigMap) (*NamespaceDefaults, error) {
+	defaults := &NamespaceDefaults{}
+	if val, ok := cm.Data[DefaultsKeyWebhookFailurePolicy]; ok {
+		policy := appsv1alpha1.FailurePolicyType(val)
+		if policy != appsv1alpha1.Ignore && policy != appsv1alpha1.Fail {
+			return nil, fmt.Errorf("invalid %s %q in ConfigMap %s/%s", DefaultsKeyWebhookFailurePolicy, val, cm.Namespace, cm.Name)
+		}
+		defaults.WebhookFailurePolicy = &policy
+	}
+	var err error
+	if defaults.WebhookPollIntervalSeconds, err = parseSeconds(cm, DefaultsKeyWebhookPollIntervalSeconds); err != nil {
+		return nil, err
+	}
+	if defaults.WebhookPollTimeoutSeconds, err = parseSeconds(cm, DefaultsKeyWebhookPollTimeoutSeconds); err != nil {
+		return nil, err
+	}
+	if val, ok := cm.Data[DefaultsKeyTimeWindowTimeZone]; ok {
+		if _, err := time.LoadLocation(val); val == "" || err != nil {
+			return nil, fmt.Errorf("invalid %s %q in ConfigMap %s/%s", DefaultsKeyTimeWindowTimeZone, val, cm.Namespace, cm.Name)
+		}
+		defaults.TimeWindowTimeZone = &val
+	}
+	return defaults, nil
+}
+
+func parseSeconds(cm *corev1.ConfigMap, key string) (*int64, error) {
+	val, ok := cm.Data[key]
+	if !ok {
+		return nil, nil
+	}
+	seconds, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("invalid %s %q in ConfigMap %s/%s", key, val, cm.Namespace, cm.Name)
+	}
+	return &seconds, nil
+}
+
+// ApplyDefaults fills the unset fields of podTransitionRule with namespace defaults first, and then built-in defaults.
+func ApplyDefaults(podTransitionRule *appsv1alpha1.PodTransitionRule, defaults *NamespaceDefaults) {
+	if defaults == nil {
+		defaults = &NamespaceDefaults{}
+	}
+	for i := range podTransitionRule.Spec.Rules {
+		if timeWindow := podTransitionRule.Spec.Rules[i].TimeWindow; timeWindow != nil && timeWindow.TimeZone == "" {
+			timeWindow.TimeZone = DefaultTimeWindowTimeZone
+			if defaults.TimeWindowTimeZone != nil {
+				timeWindow.TimeZone = *defaults.TimeWindowTimeZone
+			}
+		}
+		webhook := podTransitionRule.Spec.Rules[i].Webhook
+		if webhook == nil {
+			continue
+		}
+		if webhook.FailurePolicy == nil {
+			failurePolicy := appsv1alpha1.Ignore
+			if defaults.WebhookFailurePolicy != nil {
+				failurePolicy = *defaults.WebhookFailurePolicy
+			}
+			webhook.FailurePolicy = &failurePolicy
+		}
+		if webhook.ClientConfig.Poll == nil {
+			continue
+		}
+		if webhook.ClientConfig.Poll.IntervalSeconds == nil {
+			interval := appsv1alpha1.DefaultWebhookInterval
+			if defaults.WebhookPollIntervalSeconds != nil {
+				interval = *defaults.WebhookPollIntervalSeconds
+			}
+			webhook.ClientConfig.Poll.IntervalSeconds = &interval
+		}
+		if webhook.ClientConfig.Poll.TimeoutSeconds == nil {
+			timeout := appsv1alpha1.DefaultWebhookTimeout
+			if defaults.WebhookPollTimeoutSeconds != nil {
+				timeout = *defaults.WebhookPollTimeoutSeconds
+			}
+			webhook.ClientConfig.Poll.TimeoutSeconds = &timeout
+		}
+	}
+}
@@ -0,0 +1,64 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+)
+
+var (
+	expressionEnvOnce sync.Once
+	expressionEnv     *cel.Env
+	expressionEnvErr  error
+
+	// compiled programs keyed by expression, so that expressions are not compiled every reconcile
+	programsMu sync.Mutex
+	programs   = map[string]cel.Program{}
+)
+
+// CompileExpression compiles a CEL expression of expression rule, the pod is bound to variable pod. The expression
+// must return bool.
+func CompileExpression(expression string) (cel.Program, error) {
+	programsMu.Lock()
+	defer programsMu.Unlock()
+	if prg, ok := programs[expression]; ok {
+		return prg, nil
+	}
+
+	expressionEnvOnce.Do(func() {
+		expressionEnv, expressionEnvErr = cel.NewEnv(cel.Variable("pod", cel.DynType))
+	})
+	if expressionEnvErr != nil {
+		return nil, expressionEnvErr
+	}
+	ast, issues := expressionEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !cel.BoolType.IsAssignableType(ast.OutputType()) {
+		return nil, fmt.Errorf("expression must return bool, got %s", ast.OutputType())
+	}
+	prg, err := expressionEnv.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	programs[expression] = prg
+	return prg, nil
+}
@@ -0,0 +1,65 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// SelectablePodFields are pod fields FieldSelector of PodTransitionRule can select on
+var SelectablePodFields = sets.NewString(
+	"metadata.name",
+	"spec.nodeName",
+	"spec.restartPolicy",
+	"spec.schedulerName",
+	"spec.serviceAccountName",
+	"status.phase",
+	"status.podIP",
+	"status.nominatedNodeName",
+)
+
+// ParsePodFieldSelector parses FieldSelector of PodTransitionRule, empty selector matches everything
+func ParsePodFieldSelector(selector string) (fields.Selector, error) {
+	s, err := fields.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range s.Requirements() {
+		if !SelectablePodFields.Has(req.Field) {
+			return nil, fmt.Errorf("field %s is not selectable, supported fields are %v", req.Field, SelectablePodFields.List())
+		}
+	}
+	return s, nil
+}
+
+// PodFields returns the selectable fields of pod
+func PodFields(pod *corev1.Pod) fields.Set {
+	return fields.Set{
+		"metadata.name":            pod.Name,
+		"spec.nodeName":            pod.Spec.NodeName,
+		"spec.restartPolicy":       string(pod.Spec.RestartPolicy),
+		"spec.schedulerName":       pod.Spec.SchedulerName,
+		"spec.serviceAccountName":  pod.Spec.ServiceAccountName,
+		"status.phase":             string(pod.Status.Phase),
+		"status.podIP":             pod.Status.PodIP,
+		"status.nominatedNodeName": pod.Status.NominatedNodeName,
+	}
+}
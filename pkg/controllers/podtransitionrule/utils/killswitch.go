@@ -0,0 +1,48 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// KillSwitchKeyActive is the key of kill switch ConfigMap podtransitionrule-kill-switch
+const KillSwitchKeyActive = "active"
+
+// IsKillSwitchActive returns whether the kill switch ConfigMap in namespace is active
+func IsKillSwitchActive(ctx context.Context, c client.Reader, namespace string) (bool, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: appsv1alpha1.PodTransitionRuleKillSwitchConfigMap}, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	val, ok := cm.Data[KillSwitchKeyActive]
+	if !ok {
+		return false, nil
+	}
+	return strconv.ParseBool(val)
+}
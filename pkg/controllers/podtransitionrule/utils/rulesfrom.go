@@ -0,0 +1,97 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// MergeRulesFrom merges shared rules of ConfigMaps referred by rulesFrom into rules of podTransitionRule
+func MergeRulesFrom(ctx context.Context, c client.Reader, podTransitionRule *appsv1alpha1.PodTransitionRule) error {
+	if len(podTransitionRule.Spec.RulesFrom) == 0 {
+		return nil
+	}
+	shared := make([][]appsv1alpha1.TransitionRule, 0, len(podTransitionRule.Spec.RulesFrom))
+	for _, ref := range podTransitionRule.Spec.RulesFrom {
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: podTransitionRule.Namespace, Name: ref.Name}, cm); err != nil {
+			return fmt.Errorf("fail to get rules ConfigMap %s/%s: %w", podTransitionRule.Namespace, ref.Name, err)
+		}
+		rules, err := ParseRules(cm, ref.Key)
+		if err != nil {
+			return err
+		}
+		shared = append(shared, rules)
+	}
+	podTransitionRule.Spec.Rules = MergeRules(podTransitionRule.Spec.Rules, shared...)
+	return nil
+}
+
+// ParseRules parses the YAML list of rules in key of ConfigMap, key defaults to rules
+func ParseRules(cm *corev1.ConfigMap, key string) ([]appsv1alpha1.TransitionRule, error) {
+	if key == "" {
+		key = appsv1alpha1.DefaultRulesConfigMapKey
+	}
+	val, ok := cm.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("no key %s in rules ConfigMap %s/%s", key, cm.Namespace, cm.Name)
+	}
+	var rules []appsv1alpha1.TransitionRule
+	if err := yaml.UnmarshalStrict([]byte(val), &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules in key %s of ConfigMap %s/%s: %v", key, cm.Namespace, cm.Name, err)
+	}
+	for i := range rules {
+		if rules[i].Name == "" {
+			return nil, fmt.Errorf("rule %d in key %s of ConfigMap %s/%s has no name", i, key, cm.Namespace, cm.Name)
+		}
+	}
+	return rules, nil
+}
+
+// MergeRules returns rules followed by shared rules of names not in rules. Shared rules of the same name in later lists
+// win over earlier ones, and keep the position of the first.
+func MergeRules(rules []appsv1alpha1.TransitionRule, shared ...[]appsv1alpha1.TransitionRule) []appsv1alpha1.TransitionRule {
+	merged := make([]appsv1alpha1.TransitionRule, 0, len(rules))
+	merged = append(merged, rules...)
+	inline := map[string]bool{}
+	for _, rule := range rules {
+		inline[rule.Name] = true
+	}
+	positions := map[string]int{}
+	for _, list := range shared {
+		for _, rule := range list {
+			if inline[rule.Name] {
+				continue
+			}
+			if i, ok := positions[rule.Name]; ok {
+				merged[i] = rule
+				continue
+			}
+			positions[rule.Name] = len(merged)
+			merged = append(merged, rule)
+		}
+	}
+	return merged
+}
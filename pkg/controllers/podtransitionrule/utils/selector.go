@@ -0,0 +1,128 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+)
+
+// PodSelectors returns the label selectors of podTransitionRule, pods matching any of them are selected.
+// SelectorTerms take precedence over Selector if not empty.
+func PodSelectors(podTransitionRule *appsv1alpha1.PodTransitionRule) ([]labels.Selector, error) {
+	if len(podTransitionRule.Spec.SelectorTerms) == 0 {
+		selector, err := metav1.LabelSelectorAsSelector(podTransitionRule.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		return []labels.Selector{selector}, nil
+	}
+	selectors := make([]labels.Selector, 0, len(podTransitionRule.Spec.SelectorTerms))
+	for i := range podTransitionRule.Spec.SelectorTerms {
+		selector, err := metav1.LabelSelectorAsSelector(&podTransitionRule.Spec.SelectorTerms[i])
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// SelectsLabels returns true if podTransitionRule selects pods with labels
+func SelectsLabels(podTransitionRule *appsv1alpha1.PodTransitionRule, podLabels map[string]string) (bool, error) {
+	selectors, err := PodSelectors(podTransitionRule)
+	if err != nil {
+		return false, err
+	}
+	for _, selector := range selectors {
+		if selector.Matches(labels.Set(podLabels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SelectedNamespaces returns namespaces whose pods may be selected by podTransitionRule. Only the namespace of
+// podTransitionRule is returned if NamespaceSelector is nil.
+func SelectedNamespaces(ctx context.Context, c client.Client, podTransitionRule *appsv1alpha1.PodTransitionRule) ([]string, error) {
+	if podTransitionRule.Spec.NamespaceSelector == nil {
+		return []string{podTransitionRule.Namespace}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(podTransitionRule.Spec.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	namespaceList := &corev1.NamespaceList{}
+	if err := c.List(ctx, namespaceList, &client.ListOptions{LabelSelector: selector}); err != nil {
+		return nil, err
+	}
+	namespaces := make([]string, 0, len(namespaceList.Items))
+	for _, ns := range namespaceList.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
+}
+
+// SelectsNamespace returns true if podTransitionRule selects pods in namespace
+func SelectsNamespace(ctx context.Context, c client.Client, podTransitionRule *appsv1alpha1.PodTransitionRule, namespace string) (bool, error) {
+	if podTransitionRule.Spec.NamespaceSelector == nil {
+		return namespace == podTransitionRule.Namespace, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return SelectsNamespaceLabels(podTransitionRule, namespace, ns.Labels)
+}
+
+// SelectsNamespaceLabels returns true if podTransitionRule selects pods in namespace with namespaceLabels
+func SelectsNamespaceLabels(podTransitionRule *appsv1alpha1.PodTransitionRule, namespace string, namespaceLabels map[string]string) (bool, error) {
+	if podTransitionRule.Spec.NamespaceSelector == nil {
+		return namespace == podTransitionRule.Namespace, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(podTransitionRule.Spec.NamespaceSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(namespaceLabels)), nil
+}
+
+// TargetKey returns the key of pod in targets and details of podTransitionRule, which is namespace/name if
+// NamespaceSelector is set, otherwise pod name.
+func TargetKey(podTransitionRule *appsv1alpha1.PodTransitionRule, pod *corev1.Pod) string {
+	if podTransitionRule.Spec.NamespaceSelector == nil {
+		return pod.Name
+	}
+	return pod.Namespace + "/" + pod.Name
+}
+
+// SplitTargetKey returns namespace and name of the pod keyed by key in targets of podTransitionRule. Keys without
+// namespace are pods in the namespace of podTransitionRule.
+func SplitTargetKey(podTransitionRule *appsv1alpha1.PodTransitionRule, key string) (namespace, name string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return podTransitionRule.Namespace, key
+}
@@ -25,6 +25,9 @@ type Rules []*appsv1alpha1.TransitionRule
 func (e Rules) Len() int      { return len(e) }
 func (e Rules) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
 func (e Rules) Less(i, j int) bool {
+	if e[i].Priority != e[j].Priority {
+		return e[i].Priority < e[j].Priority
+	}
 	return weight(e[i]) < weight(e[j])
 }
 
@@ -0,0 +1,50 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{}
+
+func init() {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		weekdays[strings.ToLower(d.String())] = d
+		weekdays[strings.ToLower(d.String()[:3])] = d
+	}
+}
+
+// ParseTimeOfDay parses HH:MM into the offset from midnight
+func ParseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expect HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ParseWeekday parses full or abbreviated English day names case-insensitively, e.g. Saturday or sat
+func ParseWeekday(value string) (time.Weekday, error) {
+	day, ok := weekdays[strings.ToLower(value)]
+	if !ok {
+		return 0, fmt.Errorf("invalid day of week %q", value)
+	}
+	return day, nil
+}
@@ -0,0 +1,125 @@
+/*
+ Copyright 2023 The KusionStack Authors.
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	commonutils "kusionstack.io/operating/pkg/utils"
+)
+
+// podWriteFailures counts consecutive failed annotation writes on pods of each PodTransitionRule
+type podWriteFailures struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int
+}
+
+// observe records the result of a write on pod, and returns the number of consecutive failures
+func (f *podWriteFailures) observe(key, pod string, err error) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		if pods, ok := f.counts[key]; ok {
+			delete(pods, pod)
+			if len(pods) == 0 {
+				delete(f.counts, key)
+			}
+		}
+		return 0
+	}
+	if f.counts == nil {
+		f.counts = map[string]map[string]int{}
+	}
+	if f.counts[key] == nil {
+		f.counts[key] = map[string]int{}
+	}
+	f.counts[key][pod]++
+	return f.counts[key][pod]
+}
+
+// failingPods returns sorted pods with at least threshold consecutive failures
+func (f *podWriteFailures) failingPods(key string, threshold int) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var pods []string
+	for pod, count := range f.counts[key] {
+		if count >= threshold {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Strings(pods)
+	return pods
+}
+
+func (f *podWriteFailures) forget(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.counts, key)
+}
+
+// observePodWrite records the result of an annotation write on pod, and emits a warning event once
+// the write fails podWriteFailureThreshold times in a row
+func (r *PodTransitionRuleReconciler) observePodWrite(podTransitionRule *appsv1alpha1.PodTransitionRule, pod string, err error) {
+	if r.podWriteFailureThreshold <= 0 {
+		return
+	}
+	failures := r.podWriteFailures.observe(commonutils.ObjectKeyString(podTransitionRule), pod, err)
+	if failures == r.podWriteFailureThreshold {
+		r.Recorder.Eventf(podTransitionRule, corev1.EventTypeWarning, string(appsv1alpha1.PodTransitionRulePodWriteConflict),
+			"annotation writes on pod %s failed %d times in a row, it may be conflicting with another mutator: %v", pod, failures, err)
+	}
+}
+
+// podWriteConditions returns conditions of podTransitionRule with PodWriteConflict naming pods whose annotation
+// writes repeatedly fail, the condition is removed once writes on all pods succeed
+func (r *PodTransitionRuleReconciler) podWriteConditions(podTransitionRule *appsv1alpha1.PodTransitionRule) []appsv1alpha1.PodTransitionRuleCondition {
+	var conditions []appsv1alpha1.PodTransitionRuleCondition
+	var current *appsv1alpha1.PodTransitionRuleCondition
+	for i, cond := range podTransitionRule.Status.Conditions {
+		if cond.Type == appsv1alpha1.PodTransitionRulePodWriteConflict {
+			current = &podTransitionRule.Status.Conditions[i]
+			continue
+		}
+		conditions = append(conditions, cond)
+	}
+	if r.podWriteFailureThreshold <= 0 {
+		return conditions
+	}
+	pods := r.podWriteFailures.failingPods(commonutils.ObjectKeyString(podTransitionRule), r.podWriteFailureThreshold)
+	if len(pods) == 0 {
+		return conditions
+	}
+	cond := appsv1alpha1.PodTransitionRuleCondition{
+		Type:               appsv1alpha1.PodTransitionRulePodWriteConflict,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(time.Now()),
+		Reason:             "PodWriteFailed",
+		Message:            fmt.Sprintf("annotation writes repeatedly failed on pods: %s", strings.Join(pods, ", ")),
+	}
+	if current != nil && current.Status == cond.Status {
+		cond.LastTransitionTime = current.LastTransitionTime
+	}
+	return append(conditions, cond)
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtransitionruletemplate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/utils/mixin"
+)
+
+const (
+	controllerName = "podtransitionruletemplate-controller"
+)
+
+// PodTransitionRuleTemplateReconciler generates a PodTransitionRule for each workload selected by PodTransitionRuleTemplate
+type PodTransitionRuleTemplateReconciler struct {
+	*mixin.ReconcilerMixin
+}
+
+func Add(mgr ctrl.Manager) error {
+	return AddToMgr(mgr, NewReconciler(mgr))
+}
+
+// NewReconciler returns a new reconcile.Reconciler
+func NewReconciler(mgr ctrl.Manager) reconcile.Reconciler {
+	return &PodTransitionRuleTemplateReconciler{
+		ReconcilerMixin: mixin.NewReconcilerMixin(controllerName, mgr),
+	}
+}
+
+func AddToMgr(mgr ctrl.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New(controllerName, mgr, controller.Options{
+		MaxConcurrentReconciles: 5,
+		Reconciler:              r,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &appsv1alpha1.PodTransitionRuleTemplate{}}, &handler.EnqueueRequestForObject{})
+	if err != nil {
+		return err
+	}
+
+	err = c.Watch(&source.Kind{Type: &appsv1alpha1.PodTransitionRule{}}, &handler.EnqueueRequestForOwner{
+		OwnerType:    &appsv1alpha1.PodTransitionRuleTemplate{},
+		IsController: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, workload := range []client.Object{&appsv1alpha1.CollaSet{}, &appsv1.Deployment{}} {
+		err = c.Watch(&source.Kind{Type: workload}, handler.EnqueueRequestsFromMapFunc(enqueueNamespaceTemplates(mgr.GetClient())))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueueNamespaceTemplates enqueues all PodTransitionRuleTemplates in the namespace of workload
+func enqueueNamespaceTemplates(c client.Client) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		templates := &appsv1alpha1.PodTransitionRuleTemplateList{}
+		if err := c.List(context.TODO(), templates, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+		var requests []reconcile.Request
+		for i := range templates.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: templates.Items[i].Namespace, Name: templates.Items[i].Name}})
+		}
+		return requests
+	}
+}
+
+// +kubebuilder:rbac:groups=apps.kusionstack.io,resources=podtransitionruletemplates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps.kusionstack.io,resources=podtransitionruletemplates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=apps.kusionstack.io,resources=podtransitionrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps.kusionstack.io,resources=collasets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch
+
+// Reconcile creates or updates a PodTransitionRule for each selected workload, and deletes PodTransitionRules of
+// workloads which are not selected any more. PodTransitionRules of deleted workloads are collected by owner reference.
+func (r *PodTransitionRuleTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := r.Logger.WithValues("podtransitionruletemplate", req.String())
+	template := &appsv1alpha1.PodTransitionRuleTemplate{}
+	if err := r.Client.Get(ctx, req.NamespacedName, template); err != nil {
+		if !errors.IsNotFound(err) {
+			logger.Error(err, "failed to get podtransitionruletemplate")
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{}, nil
+	}
+	if template.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	workloads, err := r.selectWorkloads(ctx, template)
+	if err != nil {
+		logger.Error(err, "failed to select workloads")
+		return reconcile.Result{}, err
+	}
+	desired := map[string]*appsv1alpha1.PodTransitionRule{}
+	for _, workload := range workloads {
+		podTransitionRule := generatePodTransitionRule(template, workload)
+		desired[podTransitionRule.Name] = podTransitionRule
+	}
+
+	current := &appsv1alpha1.PodTransitionRuleList{}
+	if err := r.Client.List(ctx, current, client.InNamespace(template.Namespace), client.MatchingLabels{appsv1alpha1.PodTransitionRuleTemplateLabelKey: template.Name}); err != nil {
+		logger.Error(err, "failed to list generated podtransitionrules")
+		return reconcile.Result{}, err
+	}
+	existing := map[string]*appsv1alpha1.PodTransitionRule{}
+	for i := range current.Items {
+		podTransitionRule := &current.Items[i]
+		if !metav1.IsControlledBy(podTransitionRule, template) {
+			continue
+		}
+		if _, ok := desired[podTransitionRule.Name]; ok {
+			existing[podTransitionRule.Name] = podTransitionRule
+			continue
+		}
+		if podTransitionRule.DeletionTimestamp != nil {
+			continue
+		}
+		logger.Info("delete podtransitionrule of unselected workload", "podtransitionrule", podTransitionRule.Name)
+		if err := r.Client.Delete(ctx, podTransitionRule); err != nil && !errors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+	}
+
+	names := make([]string, 0, len(desired))
+	for name, podTransitionRule := range desired {
+		names = append(names, name)
+		if err := r.applyPodTransitionRule(ctx, template, existing[name], podTransitionRule); err != nil {
+			logger.Error(err, "failed to apply podtransitionrule", "podtransitionrule", name)
+			return reconcile.Result{}, err
+		}
+	}
+	sort.Strings(names)
+
+	if template.Status.ObservedGeneration != template.Generation || !equality.Semantic.DeepEqual(template.Status.PodTransitionRules, names) {
+		template.Status.ObservedGeneration = template.Generation
+		template.Status.PodTransitionRules = names
+		if err := r.Client.Status().Update(ctx, template); err != nil {
+			logger.Error(err, "failed to update podtransitionruletemplate status")
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+func (r *PodTransitionRuleTemplateReconciler) applyPodTransitionRule(ctx context.Context, template *appsv1alpha1.PodTransitionRuleTemplate, existing, desired *appsv1alpha1.PodTransitionRule) error {
+	if existing == nil {
+		err := r.Client.Create(ctx, desired)
+		if errors.IsAlreadyExists(err) {
+			return fmt.Errorf("podtransitionrule %s already exists and is not generated by podtransitionruletemplate %s", desired.Name, template.Name)
+		}
+		return err
+	}
+	if existing.DeletionTimestamp != nil {
+		return nil
+	}
+	if equality.Semantic.DeepEqual(existing.Spec, desired.Spec) && equality.Semantic.DeepEqual(existing.OwnerReferences, desired.OwnerReferences) {
+		return nil
+	}
+	existing.Spec = desired.Spec
+	existing.OwnerReferences = desired.OwnerReferences
+	return r.Client.Update(ctx, existing)
+}
+
+// workload is a selected workload with its pod selector
+type workload struct {
+	metav1.Object
+	apiVersion string
+	kind       string
+	selector   *metav1.LabelSelector
+}
+
+func (r *PodTransitionRuleTemplateReconciler) selectWorkloads(ctx context.Context, template *appsv1alpha1.PodTransitionRuleTemplate) ([]*workload, error) {
+	selector := labels.Everything()
+	if template.Spec.WorkloadSelector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(template.Spec.WorkloadSelector); err != nil {
+			return nil, err
+		}
+	}
+	opts := []client.ListOption{client.InNamespace(template.Namespace), client.MatchingLabelsSelector{Selector: selector}}
+
+	var workloads []*workload
+	switch template.Spec.WorkloadKind {
+	case appsv1alpha1.WorkloadKindDeployment:
+		list := &appsv1.DeploymentList{}
+		if err := r.Client.List(ctx, list, opts...); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			workloads = append(workloads, &workload{
+				Object:     &list.Items[i],
+				apiVersion: appsv1.SchemeGroupVersion.String(),
+				kind:       "Deployment",
+				selector:   list.Items[i].Spec.Selector,
+			})
+		}
+	default:
+		list := &appsv1alpha1.CollaSetList{}
+		if err := r.Client.List(ctx, list, opts...); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			workloads = append(workloads, &workload{
+				Object:     &list.Items[i],
+				apiVersion: appsv1alpha1.GroupVersion.String(),
+				kind:       "CollaSet",
+				selector:   list.Items[i].Spec.Selector,
+			})
+		}
+	}
+
+	// workloads being deleted or without selector are not selected
+	selected := workloads[:0]
+	for _, w := range workloads {
+		if w.GetDeletionTimestamp() == nil && w.selector != nil {
+			selected = append(selected, w)
+		}
+	}
+	return selected, nil
+}
+
+// generatePodTransitionRule generates the PodTransitionRule of workload, which is controlled by template and collected
+// once workload is deleted
+func generatePodTransitionRule(template *appsv1alpha1.PodTransitionRuleTemplate, w *workload) *appsv1alpha1.PodTransitionRule {
+	spec := template.Spec.Template.DeepCopy()
+	spec.Selector = w.selector.DeepCopy()
+	return &appsv1alpha1.PodTransitionRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: template.Namespace,
+			Name:      fmt.Sprintf("%s-%s", template.Name, w.GetName()),
+			Labels:    map[string]string{appsv1alpha1.PodTransitionRuleTemplateLabelKey: template.Name},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(template, appsv1alpha1.GroupVersion.WithKind("PodTransitionRuleTemplate")),
+				{
+					APIVersion: w.apiVersion,
+					Kind:       w.kind,
+					Name:       w.GetName(),
+					UID:        w.GetUID(),
+				},
+			},
+		},
+		Spec: *spec,
+	}
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtransitionruletemplate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/utils/mixin"
+)
+
+func TestReconcile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	ctx := context.TODO()
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).Should(gomega.Succeed())
+	g.Expect(appsv1alpha1.AddToScheme(scheme)).Should(gomega.Succeed())
+
+	template := &appsv1alpha1.PodTransitionRuleTemplate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "drain", UID: "template-uid"},
+		Spec: appsv1alpha1.PodTransitionRuleTemplateSpec{
+			WorkloadSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+			Template: appsv1alpha1.PodTransitionRuleSpec{
+				Rules: []appsv1alpha1.TransitionRule{
+					{
+						Name: "drain",
+						TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+							ConnectionDrain: &appsv1alpha1.ConnectionDrainRule{Port: 8080},
+						},
+					},
+				},
+			},
+		},
+	}
+	collaSet := func(name, team string) *appsv1alpha1.CollaSet {
+		return &appsv1alpha1.CollaSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID(name), Labels: map[string]string{"team": team}},
+			Spec: appsv1alpha1.CollaSetSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+			},
+		}
+	}
+	cls1, cls2 := collaSet("foo", "a"), collaSet("bar", "b")
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "baz", Labels: map[string]string{"team": "a"}},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "baz"}},
+		},
+	}
+	fc := fake.NewClientBuilder().WithScheme(scheme).WithObjects(template, cls1, cls2, deploy).Build()
+	r := &PodTransitionRuleTemplateReconciler{
+		ReconcilerMixin: &mixin.ReconcilerMixin{Client: fc, Logger: logr.Discard()},
+	}
+	key := types.NamespacedName{Namespace: "default", Name: "drain"}
+
+	// only selected CollaSet gets its PodTransitionRule
+	_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	ptr := &appsv1alpha1.PodTransitionRule{}
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "drain-foo"}, ptr)).NotTo(gomega.HaveOccurred())
+	g.Expect(ptr.Spec.Selector).Should(gomega.Equal(cls1.Spec.Selector))
+	g.Expect(ptr.Spec.Rules).Should(gomega.Equal(template.Spec.Template.Rules))
+	g.Expect(metav1.IsControlledBy(ptr, template)).Should(gomega.BeTrue())
+	g.Expect(ptr.OwnerReferences).Should(gomega.HaveLen(2))
+	g.Expect(ptr.OwnerReferences[1].UID).Should(gomega.Equal(cls1.UID))
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "drain-bar"}, ptr))).Should(gomega.BeTrue())
+	g.Expect(fc.Get(ctx, key, template)).NotTo(gomega.HaveOccurred())
+	g.Expect(template.Status.PodTransitionRules).Should(gomega.Equal([]string{"drain-foo"}))
+
+	// template changed, PodTransitionRule is updated
+	template.Spec.Template.Rules[0].ConnectionDrain.Port = 9090
+	g.Expect(fc.Update(ctx, template)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "drain-foo"}, ptr)).NotTo(gomega.HaveOccurred())
+	g.Expect(ptr.Spec.Rules[0].ConnectionDrain.Port).Should(gomega.BeEquivalentTo(9090))
+
+	// workload not selected any more, PodTransitionRule is deleted
+	cls1.Labels["team"] = "b"
+	g.Expect(fc.Update(ctx, cls1)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(apierrors.IsNotFound(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "drain-foo"}, ptr))).Should(gomega.BeTrue())
+	g.Expect(fc.Get(ctx, key, template)).NotTo(gomega.HaveOccurred())
+	g.Expect(template.Status.PodTransitionRules).Should(gomega.BeEmpty())
+
+	// deployments are selected by workload kind
+	template.Spec.WorkloadKind = appsv1alpha1.WorkloadKindDeployment
+	g.Expect(fc.Update(ctx, template)).NotTo(gomega.HaveOccurred())
+	_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(fc.Get(ctx, types.NamespacedName{Namespace: "default", Name: "drain-baz"}, ptr)).NotTo(gomega.HaveOccurred())
+	g.Expect(ptr.OwnerReferences[1].Kind).Should(gomega.Equal("Deployment"))
+}
@@ -28,11 +28,14 @@ const (
 	AlibabaCloudSlb featuregate.Feature = "AlibabaCloudSlb"
 	// GraceDeleteWebhook enables the gracedelete webhook
 	GraceDeleteWebhook featuregate.Feature = "GraceDeleteWebhook"
+	// PodTransitionRuleStatusProtection enables the webhook rejecting PodTransitionRule status writes from users other than the controller
+	PodTransitionRuleStatusProtection featuregate.Feature = "PodTransitionRuleStatusProtection"
 )
 
 var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
-	AlibabaCloudSlb:    {Default: false, PreRelease: featuregate.Alpha},
-	GraceDeleteWebhook: {Default: false, PreRelease: featuregate.Alpha},
+	AlibabaCloudSlb:                   {Default: false, PreRelease: featuregate.Alpha},
+	GraceDeleteWebhook:                {Default: false, PreRelease: featuregate.Alpha},
+	PodTransitionRuleStatusProtection: {Default: false, PreRelease: featuregate.Alpha},
 }
 
 func init() {
@@ -18,12 +18,15 @@ package http
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -69,6 +72,39 @@ func DoHttpAndHttpsRequestWithCa(method, url string, body interface{}, header ma
 	return c.Do(req)
 }
 
+// DoHttpAndHttpsRequestWithCaAndPool is DoHttpAndHttpsRequestWithCa with client of pool, the request is canceled once
+// ctx is done. Clients are shared by requests with the same ca, endpoint and pool, nil pool uses the default client of ca.
+func DoHttpAndHttpsRequestWithCaAndPool(ctx context.Context, method, url string, body interface{}, header map[string]string, ca string, pool *PoolConfig) (*http.Response, error) {
+	req, err := buildReqWithContext(ctx, method, url, body, header)
+	if err != nil {
+		return nil, err
+	}
+	var c *http.Client
+	if pool == nil {
+		c, err = DefaultClient.GetClientWithCa(ca)
+	} else {
+		c, err = DefaultClient.GetClientWithPool(ca, req.URL.Scheme+"://"+req.URL.Host, *pool)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// DoHttpAndHttpsRequestWithTLS is DoHttpAndHttpsRequestWithCaAndPool with client of tlsConfig, e.g. presenting client
+// certificate to servers requiring mutual TLS. Clients are shared by requests with the same endpoint, tlsConfig and pool.
+func DoHttpAndHttpsRequestWithTLS(ctx context.Context, method, url string, body interface{}, header map[string]string, tlsConfig TLSConfig, pool *PoolConfig) (*http.Response, error) {
+	req, err := buildReqWithContext(ctx, method, url, body, header)
+	if err != nil {
+		return nil, err
+	}
+	c, err := DefaultClient.GetClientWithTLS(req.URL.Scheme+"://"+req.URL.Host, tlsConfig, pool)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
 func DoHttpAndHttpsRequestWithToken(method, url string, body interface{}, header map[string]string, token string) (*http.Response, error) {
 	req, err := buildReq(method, url, body, header)
 	if err != nil {
@@ -82,14 +118,21 @@ func DoHttpAndHttpsRequestWithToken(method, url string, body interface{}, header
 }
 
 func buildReq(method, url string, body interface{}, header map[string]string) (*http.Request, error) {
+	return buildReqWithContext(context.Background(), method, url, body, header)
+}
+
+func buildReqWithContext(ctx context.Context, method, url string, body interface{}, header map[string]string) (*http.Request, error) {
 	buf := &bytes.Buffer{}
-	if body != nil {
+	if raw, ok := body.([]byte); ok {
+		// raw body is sent as it is, e.g. the body is signed by caller
+		buf.Write(raw)
+	} else if body != nil {
 		err := json.NewEncoder(buf).Encode(body)
 		if err != nil {
 			return nil, err
 		}
 	}
-	req, err := http.NewRequest(method, url, buf)
+	req, err := http.NewRequestWithContext(ctx, method, url, buf)
 	if err != nil {
 		return nil, err
 	}
@@ -104,15 +147,132 @@ var DefaultClient = newSharedClient()
 
 func newSharedClient() *clientSet {
 	return &clientSet{
-		caClientSet: map[string]*http.Client{},
-		tkClientSet: map[string]*http.Client{},
+		caClientSet:   map[string]*http.Client{},
+		tkClientSet:   map[string]*http.Client{},
+		poolClientSet: map[string]*http.Client{},
+		tlsClientSet:  map[string]*http.Client{},
 	}
 }
 
 type clientSet struct {
-	caClientSet map[string]*http.Client
-	tkClientSet map[string]*http.Client
-	mu          sync.RWMutex
+	caClientSet   map[string]*http.Client
+	tkClientSet   map[string]*http.Client
+	poolClientSet map[string]*http.Client
+	tlsClientSet  map[string]*http.Client
+	mu            sync.RWMutex
+}
+
+// TLSConfig configures TLS of a client
+type TLSConfig struct {
+	// CA is the base64 encoded CA bundle verifying server certificates, empty means system roots are used
+	CA string
+	// ClientCert and ClientKey are the PEM encoded certificate and key presented to servers, empty means no client
+	// certificate is presented
+	ClientCert []byte
+	ClientKey  []byte
+	// InsecureSkipVerify skips verifying server certificates
+	InsecureSkipVerify bool
+}
+
+// PoolConfig configures connection pool of a client
+type PoolConfig struct {
+	// MaxIdleConnsPerHost is the max idle connections kept to each host
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long idle connections are kept, 0 means no limit
+	IdleConnTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes, negative disables keep-alive
+	KeepAlive time.Duration
+}
+
+// GetClientWithPool returns the client of ca and pool for endpoint
+func (s *clientSet) GetClientWithPool(ca, endpoint string, pool PoolConfig) (*http.Client, error) {
+	key := fmt.Sprintf("%s|%s|%d|%s|%s", endpoint, ca, pool.MaxIdleConnsPerHost, pool.IdleConnTimeout, pool.KeepAlive)
+	s.mu.RLock()
+	c, ok := s.poolClientSet[key]
+	s.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+	certPool, err := caCertPool(ca)
+	if err != nil {
+		return nil, err
+	}
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: pool.KeepAlive,
+		}).DialContext,
+		TLSClientConfig: &tls.Config{
+			ClientCAs: certPool,
+		},
+		MaxIdleConnsPerHost: pool.MaxIdleConnsPerHost,
+		IdleConnTimeout:     pool.IdleConnTimeout,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.poolClientSet[key]; ok {
+		return c, nil
+	}
+	c = &http.Client{Transport: t, Timeout: timeout}
+	s.poolClientSet[key] = c
+	return c, nil
+}
+
+// GetClientWithTLS returns the client of tlsConfig and pool for endpoint, nil pool uses default connection pool.
+// Clients are keyed by hash of the configs, so that rotated client certificates take effect.
+func (s *clientSet) GetClientWithTLS(endpoint string, tlsConfig TLSConfig, pool *PoolConfig) (*http.Client, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%t|", endpoint, tlsConfig.CA, tlsConfig.InsecureSkipVerify)
+	h.Write(tlsConfig.ClientCert)
+	h.Write([]byte("|"))
+	h.Write(tlsConfig.ClientKey)
+	if pool != nil {
+		fmt.Fprintf(h, "|%d|%s|%s", pool.MaxIdleConnsPerHost, pool.IdleConnTimeout, pool.KeepAlive)
+	}
+	key := fmt.Sprintf("%x", h.Sum(nil))
+	s.mu.RLock()
+	c, ok := s.tlsClientSet[key]
+	s.mu.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	certPool, err := caCertPool(tlsConfig.CA)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		RootCAs:            certPool,
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+	}
+	if len(tlsConfig.ClientCert) > 0 || len(tlsConfig.ClientKey) > 0 {
+		cert, err := tls.X509KeyPair(tlsConfig.ClientCert, tlsConfig.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	t := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: config,
+	}
+	if pool != nil {
+		t.DialContext = (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: pool.KeepAlive,
+		}).DialContext
+		t.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+		t.IdleConnTimeout = pool.IdleConnTimeout
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.tlsClientSet[key]; ok {
+		return c, nil
+	}
+	c = &http.Client{Transport: t, Timeout: timeout}
+	s.tlsClientSet[key] = c
+	return c, nil
 }
 
 func (s *clientSet) GetClientWithCa(ca string) (c *http.Client, err error) {
@@ -143,13 +303,10 @@ func (s *clientSet) GetClientWithKey(key string) (c *http.Client, err error) {
  */
 func (s *clientSet) newClient(ca *string, key *string) (c *http.Client, err error) {
 	var pool *x509.CertPool
-	if ca != nil && *ca != "" && *ca != "Cg==" {
-		pool = x509.NewCertPool()
-		bt, err := base64.StdEncoding.DecodeString(*ca)
-		if err != nil {
+	if ca != nil {
+		if pool, err = caCertPool(*ca); err != nil {
 			return nil, err
 		}
-		pool.AppendCertsFromPEM(bt)
 	}
 	t := &http.Transport{
 		TLSClientConfig: &tls.Config{
@@ -166,3 +323,17 @@ func (s *clientSet) newClient(ca *string, key *string) (c *http.Client, err erro
 	}
 	return c, err
 }
+
+// caCertPool returns cert pool of base64 encoded ca, nil for empty ca
+func caCertPool(ca string) (*x509.CertPool, error) {
+	if ca == "" || ca == "Cg==" {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	bt, err := base64.StdEncoding.DecodeString(ca)
+	if err != nil {
+		return nil, err
+	}
+	pool.AppendCertsFromPEM(bt)
+	return pool, nil
+}
@@ -18,6 +18,7 @@ package inject
 
 import (
 	"context"
+	"strings"
 
 	appv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -33,6 +34,8 @@ import (
 const (
 	FieldIndexOwnerRefUID            = "ownerRefUID"
 	FieldIndexPodTransitionRule      = "podTransitionRuleIndex"
+	FieldIndexNamespaceSelector      = "namespaceSelector"
+	FieldIndexRulesFromConfigMap     = "rulesFromConfigMap"
 	FieldIndexPodDecorationCollaSets = "podDecorationCollaSets"
 )
 
@@ -78,7 +81,37 @@ func NewCacheWithFieldIndex(config *rest.Config, opts cache.Options) (cache.Cach
 		&appsv1alpha1.PodTransitionRule{},
 		FieldIndexPodTransitionRule,
 		func(obj client.Object) []string {
-			return obj.(*appsv1alpha1.PodTransitionRule).Status.Targets
+			// targets keyed by namespace/name are also indexed by pod name
+			var res []string
+			for _, target := range obj.(*appsv1alpha1.PodTransitionRule).Status.Targets {
+				res = append(res, target)
+				if i := strings.Index(target, "/"); i >= 0 {
+					res = append(res, target[i+1:])
+				}
+			}
+			return res
+		}))
+
+	runtime.Must(c.IndexField(
+		context.TODO(),
+		&appsv1alpha1.PodTransitionRule{},
+		FieldIndexNamespaceSelector,
+		func(obj client.Object) []string {
+			if obj.(*appsv1alpha1.PodTransitionRule).Spec.NamespaceSelector == nil {
+				return nil
+			}
+			return []string{"true"}
+		}))
+
+	runtime.Must(c.IndexField(
+		context.TODO(),
+		&appsv1alpha1.PodTransitionRule{},
+		FieldIndexRulesFromConfigMap,
+		func(obj client.Object) (res []string) {
+			for _, ref := range obj.(*appsv1alpha1.PodTransitionRule).Spec.RulesFrom {
+				res = append(res, ref.Name)
+			}
+			return
 		}))
 
 	runtime.Must(c.IndexField(
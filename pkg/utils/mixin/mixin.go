@@ -135,13 +135,15 @@ func (m *ReconcilerMixin) InjectRecorder(p recorder.Provider) error {
 }
 
 var _ inject.Client = &WebhookHandlerMixin{}
+var _ inject.APIReader = &WebhookHandlerMixin{}
 var _ inject.Logger = &WebhookHandlerMixin{}
 var _ admission.DecoderInjector = &WebhookHandlerMixin{}
 
 type WebhookHandlerMixin struct {
-	Client  client.Client
-	Decoder *admission.Decoder
-	Logger  logr.Logger
+	Client    client.Client
+	APIReader client.Reader
+	Decoder   *admission.Decoder
+	Logger    logr.Logger
 }
 
 func NewWebhookHandlerMixin() *WebhookHandlerMixin {
@@ -165,3 +167,9 @@ func (m *WebhookHandlerMixin) InjectClient(c client.Client) error {
 	m.Client = c
 	return nil
 }
+
+// InjectAPIReader implements inject.APIReader.
+func (m *WebhookHandlerMixin) InjectAPIReader(c client.Reader) error {
+	m.APIReader = c
+	return nil
+}
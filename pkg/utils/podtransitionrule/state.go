@@ -33,6 +33,9 @@ func PodHasPodTransitionRuleStage(c client.Client, pod *corev1.Pod, stage string
 		return false, err
 	}
 	for _, rs := range rsList.Items {
+		if rs.Spec.DryRun {
+			continue
+		}
 		for _, rule := range rs.Spec.Rules {
 			if rule.Stage != nil && *rule.Stage == stage {
 				return true, nil
@@ -44,8 +44,9 @@ func init() {
 	MutatingTypeHandlerMap["Pod/status"] = podMutatingHandler
 	ValidatingTypeHandlerMap["Pod"] = pod.NewValidatingHandler()
 
-	MutatingTypeHandlerMap["PodTransitionRule"] = podtransitionrule.NewMutatingHandler()
+	// PodTransitionRule is not mutated, its defaults are resolved by controller at runtime
 	ValidatingTypeHandlerMap["PodTransitionRule"] = podtransitionrule.NewValidatingHandler()
+	ValidatingTypeHandlerMap["PodTransitionRule/status"] = podtransitionrule.NewStatusValidatingHandler()
 
 	MutatingTypeHandlerMap["PodDecoration"] = poddecoration.NewMutatingHandler()
 	ValidatingTypeHandlerMap["PodDecoration"] = poddecoration.NewValidatingHandler()
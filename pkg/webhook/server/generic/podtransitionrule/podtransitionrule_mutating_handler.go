@@ -26,6 +26,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 	commonutils "kusionstack.io/operating/pkg/utils"
 	"kusionstack.io/operating/pkg/utils/mixin"
 )
@@ -33,6 +34,9 @@ import (
 var _ inject.Client = &MutatingHandler{}
 var _ admission.DecoderInjector = &MutatingHandler{}
 
+// MutatingHandler admits PodTransitionRules without mutation.
+//
+// Deprecated: it is no longer registered, since defaults of PodTransitionRule are resolved by controller at runtime.
 type MutatingHandler struct {
 	*mixin.WebhookHandlerMixin
 }
@@ -58,7 +62,8 @@ func (h *MutatingHandler) Handle(ctx context.Context, req admission.Request) (re
 		logger.Error(err, "failed to decode podtransitionrule")
 		return admission.Errored(http.StatusBadRequest, err)
 	}
-	SetDefaultPodTransitionRule(rs)
+	// unset fields are left to be resolved by controller at runtime, namespace defaults first and then built-in
+	// defaults, so that namespace defaults created or changed later also apply to existing podtransitionrules
 	marshalled, err := json.Marshal(rs)
 	if err != nil {
 		logger.Error(err, "failed to marshal podtransitionrule json")
@@ -68,23 +73,10 @@ func (h *MutatingHandler) Handle(ctx context.Context, req admission.Request) (re
 	return admission.PatchResponseFromRaw(req.AdmissionRequest.Object.Raw, marshalled)
 }
 
+// SetDefaultPodTransitionRule fills the unset fields of rs with built-in defaults.
+//
+// Deprecated: defaults are resolved by controller at runtime, namespace defaults first and then built-in defaults,
+// use utils.ApplyDefaults instead.
 func SetDefaultPodTransitionRule(rs *appsv1alpha1.PodTransitionRule) {
-	for i := range rs.Spec.Rules {
-		if rs.Spec.Rules[i].Webhook != nil {
-			if rs.Spec.Rules[i].Webhook.ClientConfig.Poll != nil {
-				if rs.Spec.Rules[i].Webhook.ClientConfig.Poll.IntervalSeconds == nil {
-					interval := appsv1alpha1.DefaultWebhookInterval
-					rs.Spec.Rules[i].Webhook.ClientConfig.Poll.IntervalSeconds = &interval
-				}
-				if rs.Spec.Rules[i].Webhook.ClientConfig.Poll.TimeoutSeconds == nil {
-					timeout := appsv1alpha1.DefaultWebhookTimeout
-					rs.Spec.Rules[i].Webhook.ClientConfig.Poll.TimeoutSeconds = &timeout
-				}
-			}
-			if rs.Spec.Rules[i].Webhook.FailurePolicy == nil {
-				failurePolicy := appsv1alpha1.Ignore
-				rs.Spec.Rules[i].Webhook.FailurePolicy = &failurePolicy
-			}
-		}
-	}
+	podtransitionruleutils.ApplyDefaults(rs, nil)
 }
@@ -0,0 +1,72 @@
+/*
+Copyright 2023 The KusionStack Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package podtransitionrule
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"kusionstack.io/operating/pkg/features"
+	"kusionstack.io/operating/pkg/utils/feature"
+	"kusionstack.io/operating/pkg/utils/mixin"
+)
+
+var statusWriters = "system:serviceaccount:kusionstack-system:kusionstack-controller-manager"
+
+// AddFlags binds settings of PodTransitionRule webhooks to podtransitionrule-* flags of fs
+func AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&statusWriters, "podtransitionrule-status-writers", statusWriters, "Comma separated users allowed to write PodTransitionRule status when PodTransitionRuleStatusProtection is enabled.")
+}
+
+// StatusValidatingHandler rejects writes to PodTransitionRule status from users other than the controller
+type StatusValidatingHandler struct {
+	*mixin.WebhookHandlerMixin
+}
+
+func NewStatusValidatingHandler() *StatusValidatingHandler {
+	return &StatusValidatingHandler{
+		WebhookHandlerMixin: mixin.NewWebhookHandlerMixin(),
+	}
+}
+
+func (h *StatusValidatingHandler) Handle(ctx context.Context, req admission.Request) (resp admission.Response) {
+	// PodTransitionRuleStatusProtection FeatureGate defaults to false
+	// Add '--feature-gates=PodTransitionRuleStatusProtection=true' to container args, to enable it
+	if !feature.DefaultFeatureGate.Enabled(features.PodTransitionRuleStatusProtection) || req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	if allowedStatusWriters().Has(req.UserInfo.Username) {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("status of PodTransitionRule is owned by controller, user %s is not allowed to update it", req.UserInfo.Username))
+}
+
+func allowedStatusWriters() sets.String {
+	writers := sets.NewString()
+	for _, writer := range strings.Split(statusWriters, ",") {
+		if writer = strings.TrimSpace(writer); writer != "" {
+			writers.Insert(writer)
+		}
+	}
+	return writers
+}
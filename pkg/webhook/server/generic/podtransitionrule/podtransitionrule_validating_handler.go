@@ -25,15 +25,27 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	podtransitionruleutils "kusionstack.io/operating/pkg/controllers/podtransitionrule/utils"
 	commonutils "kusionstack.io/operating/pkg/utils"
 	"kusionstack.io/operating/pkg/utils/mixin"
 )
@@ -67,36 +79,341 @@ func (h *ValidatingHandler) Handle(ctx context.Context, req admission.Request) (
 		logger.Error(err, "failed to decode podtransitionrule")
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	// shared rules are validated together with rules in spec
+	rs, err := h.mergeRulesFrom(ctx, rs)
+	if err != nil {
+		logger.Error(err, "illegal PodTransitionRule")
+		return admission.Denied(err.Error())
+	}
 	if err := h.validate(rs); err != nil {
 		logger.Error(err, "illegal PodTransitionRule")
 		return admission.Denied(err.Error())
 	}
+	if err := h.validatePDBRefs(ctx, rs); err != nil {
+		logger.Error(err, "illegal PodTransitionRule")
+		return admission.Denied(err.Error())
+	}
+	if err := h.validateClientCertSecrets(ctx, rs); err != nil {
+		logger.Error(err, "illegal PodTransitionRule")
+		return admission.Denied(err.Error())
+	}
 	return admission.Allowed("")
 }
 
+// mergeRulesFrom returns a copy of rs whose rules are merged with shared rules of ConfigMaps referred by rulesFrom,
+// ConfigMaps not existing or holding unparsable rules are rejected
+func (h *ValidatingHandler) mergeRulesFrom(ctx context.Context, rs *appsv1alpha1.PodTransitionRule) (*appsv1alpha1.PodTransitionRule, error) {
+	if len(rs.Spec.RulesFrom) == 0 {
+		return rs, nil
+	}
+	var errList field.ErrorList
+	fRulesFrom := field.NewPath("spec").Child("rulesFrom")
+	var shared [][]appsv1alpha1.TransitionRule
+	for i, ref := range rs.Spec.RulesFrom {
+		fRef := fRulesFrom.Index(i)
+		if ref.Name == "" {
+			errList = append(errList, field.Required(fRef.Child("name"), "ConfigMap name is required"))
+			continue
+		}
+		cm := &corev1.ConfigMap{}
+		err := h.Client.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: ref.Name}, cm)
+		if errors.IsNotFound(err) {
+			errList = append(errList, field.NotFound(fRef.Child("name"), ref.Name))
+			continue
+		} else if err != nil {
+			errList = append(errList, field.InternalError(fRef.Child("name"), err))
+			continue
+		}
+		rules, err := podtransitionruleutils.ParseRules(cm, ref.Key)
+		if err != nil {
+			errList = append(errList, field.Invalid(fRef, ref.Name, err.Error()))
+			continue
+		}
+		shared = append(shared, rules)
+	}
+	if len(errList) > 0 {
+		return nil, errList.ToAggregate()
+	}
+	merged := rs.DeepCopy()
+	merged.Spec.Rules = podtransitionruleutils.MergeRules(rs.Spec.Rules, shared...)
+	return merged, nil
+}
+
+// validatePDBRefs rejects rules referring to PodDisruptionBudgets not existing, unless the failure policy is Ignore
+func (h *ValidatingHandler) validatePDBRefs(ctx context.Context, rs *appsv1alpha1.PodTransitionRule) error {
+	var errList field.ErrorList
+	fRule := field.NewPath("spec").Child("rule")
+	for _, rule := range rs.Spec.Rules {
+		if rule.PDBRef == nil || (rule.PDBRef.FailurePolicy != nil && *rule.PDBRef.FailurePolicy == appsv1alpha1.Ignore) {
+			continue
+		}
+		fPDBRef := fRule.Child(rule.Name).Child("pdbRef", "name")
+		pdb := &policyv1.PodDisruptionBudget{}
+		err := h.Client.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: rule.PDBRef.Name}, pdb)
+		if errors.IsNotFound(err) {
+			errList = append(errList, field.NotFound(fPDBRef, rule.PDBRef.Name))
+		} else if err != nil {
+			errList = append(errList, field.InternalError(fPDBRef, err))
+		}
+	}
+	return errList.ToAggregate()
+}
+
+// validateClientCertSecrets rejects webhooks referring to client certificate Secrets not existing. Secrets are read by
+// APIReader if injected, so that Secrets are not cached.
+func (h *ValidatingHandler) validateClientCertSecrets(ctx context.Context, rs *appsv1alpha1.PodTransitionRule) error {
+	var reader client.Reader = h.Client
+	if h.APIReader != nil {
+		reader = h.APIReader
+	}
+	var errList field.ErrorList
+	fRule := field.NewPath("spec").Child("rule")
+	for _, rule := range rs.Spec.Rules {
+		if rule.Webhook == nil || rule.Webhook.ClientConfig.ClientCertSecretRef == nil {
+			continue
+		}
+		ref := rule.Webhook.ClientConfig.ClientCertSecretRef
+		fRef := fRule.Child(rule.Name).Child("webhook", "clientConfig", "clientCertSecretRef", "name")
+		secret := &corev1.Secret{}
+		err := reader.Get(ctx, types.NamespacedName{Namespace: rs.Namespace, Name: ref.Name}, secret)
+		if errors.IsNotFound(err) {
+			errList = append(errList, field.NotFound(fRef, ref.Name))
+		} else if err != nil {
+			errList = append(errList, field.InternalError(fRef, err))
+		} else if len(secret.Data[corev1.TLSCertKey]) == 0 || len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+			errList = append(errList, field.Invalid(fRef, ref.Name, fmt.Sprintf("secret must contain %s and %s", corev1.TLSCertKey, corev1.TLSPrivateKeyKey)))
+		}
+	}
+	return errList.ToAggregate()
+}
+
 func (h *ValidatingHandler) validate(rs *appsv1alpha1.PodTransitionRule) error {
 	var errList field.ErrorList
 	fSpec := field.NewPath("spec")
 
-	if rs.Spec.Selector == nil {
+	if rs.Spec.Selector == nil && len(rs.Spec.SelectorTerms) == 0 {
 		return fmt.Errorf("podtransitionrule selector cannot be nil")
 	}
+	if len(rs.Spec.SelectorTerms) == 0 {
+		if _, err := metav1.LabelSelectorAsSelector(rs.Spec.Selector); err != nil {
+			errList = append(errList, field.Invalid(fSpec.Child("selector"), rs.Spec.Selector, err.Error()))
+		}
+	}
+	for i := range rs.Spec.SelectorTerms {
+		if _, err := metav1.LabelSelectorAsSelector(&rs.Spec.SelectorTerms[i]); err != nil {
+			errList = append(errList, field.Invalid(fSpec.Child("selectorTerms").Index(i), rs.Spec.SelectorTerms[i], err.Error()))
+		}
+	}
+	if rs.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(rs.Spec.NamespaceSelector); err != nil {
+			errList = append(errList, field.Invalid(fSpec.Child("namespaceSelector"), rs.Spec.NamespaceSelector, err.Error()))
+		}
+	}
+	if rs.Spec.FieldSelector != "" {
+		if _, err := podtransitionruleutils.ParsePodFieldSelector(rs.Spec.FieldSelector); err != nil {
+			errList = append(errList, field.Invalid(fSpec.Child("fieldSelector"), rs.Spec.FieldSelector, err.Error()))
+		}
+	}
+	switch rs.Spec.OrderingPolicy {
+	case "", appsv1alpha1.OrderingByPriorityClass, appsv1alpha1.OrderingByCreationTimestamp:
+	default:
+		errList = append(errList, field.NotSupported(fSpec.Child("orderingPolicy"), rs.Spec.OrderingPolicy,
+			[]string{string(appsv1alpha1.OrderingByPriorityClass), string(appsv1alpha1.OrderingByCreationTimestamp)}))
+	}
+	if protection := rs.Spec.DeletionProtection; protection != nil && protection.GracePeriodSeconds != nil && *protection.GracePeriodSeconds < 0 {
+		errList = append(errList, field.Invalid(fSpec.Child("deletionProtection", "gracePeriodSeconds"), *protection.GracePeriodSeconds, "must be non-negative"))
+	}
+	if gate := rs.Spec.ReadinessGate; gate != nil {
+		fGate := fSpec.Child("readinessGate", "conditionType")
+		switch gate.ConditionType {
+		case corev1.PodReady, corev1.ContainersReady, corev1.PodInitialized, corev1.PodScheduled:
+			errList = append(errList, field.Invalid(fGate, gate.ConditionType, "must not be a built-in pod condition"))
+		default:
+			for _, msg := range validation.IsQualifiedName(string(gate.ConditionType)) {
+				errList = append(errList, field.Invalid(fGate, gate.ConditionType, msg))
+			}
+		}
+	}
 	fRule := fSpec.Child("rule")
 	for _, rule := range rs.Spec.Rules {
 		if rule.Name == "" {
 			return fmt.Errorf("podtransitionrule rule name is required")
 		}
+		if rule.CooldownSeconds < 0 {
+			errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("cooldownSeconds"), rule.CooldownSeconds, "cooldownSeconds must not be negative"))
+		}
+		if rule.MaxInProgress < 0 {
+			errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("maxInProgress"), rule.MaxInProgress, "maxInProgress must not be negative"))
+		}
+		switch rule.Mode {
+		case "", appsv1alpha1.TransitionRuleModeEnforce, appsv1alpha1.TransitionRuleModeAudit:
+		default:
+			errList = append(errList, field.NotSupported(fRule.Child(rule.Name).Child("mode"), rule.Mode,
+				[]string{string(appsv1alpha1.TransitionRuleModeEnforce), string(appsv1alpha1.TransitionRuleModeAudit)}))
+		}
 		if rule.Webhook != nil {
 			if err := ValidateWebhook(rule.Webhook, fRule.Child(rule.Name)); err != nil {
 				errList = append(errList, err)
 			}
 		}
+		if rule.Filter != nil && rule.Filter.LabelSelector != nil {
+			if _, err := metav1.LabelSelectorAsSelector(rule.Filter.LabelSelector); err != nil {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("filter", "labelSelector"), rule.Filter.LabelSelector, err.Error()))
+			}
+		}
 		if rule.LabelCheck != nil && rule.LabelCheck.Requires == nil {
 			errList = append(errList, field.Invalid(fRule.Child(rule.Name), nil, "nil label check required"))
 		}
+		if rule.LabelCheck != nil && rule.LabelCheck.Requires != nil {
+			if _, err := metav1.LabelSelectorAsSelector(rule.LabelCheck.Requires); err != nil {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("labelCheck", "requires"), rule.LabelCheck.Requires, err.Error()))
+			}
+		}
 		if rule.AvailablePolicy != nil && rule.AvailablePolicy.MaxUnavailableValue == nil && rule.AvailablePolicy.MinAvailableValue == nil {
 			errList = append(errList, field.Invalid(fRule.Child(rule.Name), nil, "minAvailableValue and maxUnavailableValue must have at least one configured"))
 		}
+		if rule.AvailablePolicy != nil {
+			fAvailable := fRule.Child(rule.Name).Child("availablePolicy")
+			if err := validateIntOrPercent(rule.AvailablePolicy.MaxUnavailableValue, fAvailable.Child("maxUnavailableValue")); err != nil {
+				errList = append(errList, err)
+			}
+			if err := validateIntOrPercent(rule.AvailablePolicy.MinAvailableValue, fAvailable.Child("minAvailableValue")); err != nil {
+				errList = append(errList, err)
+			}
+			if readiness := rule.AvailablePolicy.ContainerReadiness; readiness != nil && len(readiness.ContainerNames) == 0 {
+				errList = append(errList, field.Required(fAvailable.Child("containerReadiness", "containerNames"), "at least one container is required"))
+			}
+		}
+		if rule.TopologySpread != nil && (rule.TopologySpread.TopologyKey == "" || rule.TopologySpread.MinAvailablePerDomain == nil) {
+			errList = append(errList, field.Invalid(fRule.Child(rule.Name), nil, "topologyKey and minAvailablePerDomain are required"))
+		}
+		if rule.ResourceState != nil {
+			if rule.ResourceState.APIVersion == "" || rule.ResourceState.Kind == "" || rule.ResourceState.FieldPath == "" || len(rule.ResourceState.BlockingValues) == 0 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name), nil, "apiVersion, kind, fieldPath and blockingValues are required"))
+			} else if _, err := schema.ParseGroupVersion(rule.ResourceState.APIVersion); err != nil {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("resourceState", "apiVersion"), rule.ResourceState.APIVersion, err.Error()))
+			}
+		}
+		if rule.ConnectionDrain != nil {
+			if rule.ConnectionDrain.Port <= 0 || rule.ConnectionDrain.Port > 65535 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("connectionDrain", "port"), rule.ConnectionDrain.Port, "port must be in range 1-65535"))
+			}
+			if rule.ConnectionDrain.MaxWaitSeconds < 0 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("connectionDrain", "maxWaitSeconds"), rule.ConnectionDrain.MaxWaitSeconds, "maxWaitSeconds must not be negative"))
+			}
+		}
+		if rule.SidecarDrain != nil {
+			if rule.SidecarDrain.Port < 0 || rule.SidecarDrain.Port > 65535 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("sidecarDrain", "port"), rule.SidecarDrain.Port, "port must be in range 1-65535"))
+			}
+			if rule.SidecarDrain.MaxWaitSeconds < 0 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("sidecarDrain", "maxWaitSeconds"), rule.SidecarDrain.MaxWaitSeconds, "maxWaitSeconds must not be negative"))
+			}
+		}
+		if rule.PodStability != nil {
+			if rule.PodStability.MaxRestarts < 0 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("podStability", "maxRestarts"), rule.PodStability.MaxRestarts, "maxRestarts must not be negative"))
+			}
+			if rule.PodStability.WithinSeconds < 0 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("podStability", "withinSeconds"), rule.PodStability.WithinSeconds, "withinSeconds must not be negative"))
+			}
+		}
+		if rule.AnnotationCheck != nil {
+			if len(rule.AnnotationCheck.Requirements) == 0 {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("annotationCheck", "requirements"), nil, "at least one requirement is required"))
+			}
+			for i, req := range rule.AnnotationCheck.Requirements {
+				fReq := fRule.Child(rule.Name).Child("annotationCheck", "requirements").Index(i)
+				if req.Key == "" {
+					errList = append(errList, field.Invalid(fReq.Child("key"), req.Key, "key is required"))
+				}
+				switch req.Operator {
+				case appsv1alpha1.AnnotationOpExists, appsv1alpha1.AnnotationOpEquals:
+				case appsv1alpha1.AnnotationOpRegex:
+					if _, err := regexp.Compile(req.Value); err != nil {
+						errList = append(errList, field.Invalid(fReq.Child("value"), req.Value, err.Error()))
+					}
+				default:
+					errList = append(errList, field.NotSupported(fReq.Child("operator"), req.Operator, []string{string(appsv1alpha1.AnnotationOpExists), string(appsv1alpha1.AnnotationOpEquals), string(appsv1alpha1.AnnotationOpRegex)}))
+				}
+			}
+		}
+		if rule.VolumeCheck != nil && rule.VolumeCheck.SafeAnnotation == "" && len(rule.VolumeCheck.SafePhases) == 0 {
+			errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("volumeCheck"), nil, "at least one of safeAnnotation and safePhases is required"))
+		}
+		if rule.AlertCheck != nil {
+			fAlert := fRule.Child(rule.Name).Child("alertCheck")
+			if rule.AlertCheck.URL == "" {
+				errList = append(errList, field.Required(fAlert.Child("url"), ""))
+			}
+			if len(rule.AlertCheck.Matchers) == 0 {
+				errList = append(errList, field.Invalid(fAlert.Child("matchers"), nil, "at least one matcher is required"))
+			}
+			for i, matcher := range rule.AlertCheck.Matchers {
+				if matcher.Key == "" {
+					errList = append(errList, field.Required(fAlert.Child("matchers").Index(i).Child("key"), ""))
+				}
+			}
+		}
+		if rule.TimeWindow != nil {
+			fWindow := fRule.Child(rule.Name).Child("timeWindow")
+			if len(rule.TimeWindow.Daily) == 0 {
+				errList = append(errList, field.Invalid(fWindow.Child("daily"), nil, "at least one window is required"))
+			}
+			for i, window := range rule.TimeWindow.Daily {
+				fDaily := fWindow.Child("daily").Index(i)
+				if _, err := podtransitionruleutils.ParseTimeOfDay(window.Start); err != nil {
+					errList = append(errList, field.Invalid(fDaily.Child("start"), window.Start, err.Error()))
+				}
+				if _, err := podtransitionruleutils.ParseTimeOfDay(window.End); err != nil {
+					errList = append(errList, field.Invalid(fDaily.Child("end"), window.End, err.Error()))
+				}
+				for j, day := range window.Days {
+					if _, err := podtransitionruleutils.ParseWeekday(day); err != nil {
+						errList = append(errList, field.Invalid(fDaily.Child("days").Index(j), day, err.Error()))
+					}
+				}
+			}
+			if _, err := time.LoadLocation(rule.TimeWindow.TimeZone); err != nil {
+				errList = append(errList, field.Invalid(fWindow.Child("timeZone"), rule.TimeWindow.TimeZone, err.Error()))
+			}
+		}
+		if rule.Expression != nil {
+			if _, err := podtransitionruleutils.CompileExpression(rule.Expression.Expression); err != nil {
+				errList = append(errList, field.Invalid(fRule.Child(rule.Name).Child("expression", "expression"), rule.Expression.Expression, err.Error()))
+			}
+		}
+		if rule.ManualApproval != nil {
+			fApproval := fRule.Child(rule.Name).Child("manualApproval")
+			if label := rule.ManualApproval.ApprovalLabel; label != "" {
+				for _, msg := range validation.IsQualifiedName(label) {
+					errList = append(errList, field.Invalid(fApproval.Child("approvalLabel"), label, msg))
+				}
+			} else if rule.ManualApproval.OneShot {
+				errList = append(errList, field.Required(fApproval.Child("approvalLabel"), "approval label is required by one shot approval"))
+			}
+		}
+		if rule.Canary != nil {
+			fCanary := fRule.Child(rule.Name).Child("canary")
+			if err := validateIntOrPercent(&rule.Canary.Count, fCanary.Child("count")); err != nil {
+				errList = append(errList, err)
+			}
+			if rule.Canary.SoakSeconds < 0 {
+				errList = append(errList, field.Invalid(fCanary.Child("soakSeconds"), rule.Canary.SoakSeconds, "must be non-negative"))
+			}
+		}
+		if rule.Custom != nil && rule.Custom.Type == "" {
+			errList = append(errList, field.Required(fRule.Child(rule.Name).Child("custom", "type"), "custom rule type is required"))
+		}
+		if rule.PDBRef != nil {
+			fPDBRef := fRule.Child(rule.Name).Child("pdbRef")
+			if rule.PDBRef.Name == "" {
+				errList = append(errList, field.Required(fPDBRef.Child("name"), "PodDisruptionBudget name is required"))
+			}
+			if policy := rule.PDBRef.FailurePolicy; policy != nil && *policy != appsv1alpha1.Ignore && *policy != appsv1alpha1.Fail {
+				errList = append(errList, field.NotSupported(fPDBRef.Child("failurePolicy"), *policy, []string{string(appsv1alpha1.Ignore), string(appsv1alpha1.Fail)}))
+			}
+		}
 	}
 	return errList.ToAggregate()
 }
@@ -109,6 +426,71 @@ func ValidateWebhook(webhook *appsv1alpha1.TransitionRuleWebhook, f *field.Path)
 	if err := CheckCaBundle(webhook.ClientConfig.CABundle); err != nil {
 		return field.Invalid(f.Child("clientConfig").Child("caBundle"), webhook.ClientConfig.CABundle, err.Error())
 	}
+	if pool := webhook.ClientConfig.ConnectionPool; pool != nil {
+		fPool := f.Child("clientConfig").Child("connectionPool")
+		if pool.MaxIdleConnsPerHost != nil && *pool.MaxIdleConnsPerHost < 0 {
+			return field.Invalid(fPool.Child("maxIdleConnsPerHost"), *pool.MaxIdleConnsPerHost, "must not be negative")
+		}
+		if pool.IdleTimeoutSeconds != nil && *pool.IdleTimeoutSeconds < 0 {
+			return field.Invalid(fPool.Child("idleTimeoutSeconds"), *pool.IdleTimeoutSeconds, "must not be negative")
+		}
+	}
+	if webhook.TimeoutSeconds != nil && *webhook.TimeoutSeconds < 1 {
+		return field.Invalid(f.Child("timeoutSeconds"), *webhook.TimeoutSeconds, "must be at least 1")
+	}
+	if retry := webhook.Retry; retry != nil {
+		fRetry := f.Child("retry")
+		if retry.MaxAttempts < 1 {
+			return field.Invalid(fRetry.Child("maxAttempts"), retry.MaxAttempts, "must be at least 1")
+		}
+		if retry.BackoffSeconds != nil && *retry.BackoffSeconds < 1 {
+			return field.Invalid(fRetry.Child("backoffSeconds"), *retry.BackoffSeconds, "must be at least 1")
+		}
+		if retry.MaxBackoffSeconds != nil && *retry.MaxBackoffSeconds < 1 {
+			return field.Invalid(fRetry.Child("maxBackoffSeconds"), *retry.MaxBackoffSeconds, "must be at least 1")
+		}
+	}
+	if webhook.PayloadTemplate != nil {
+		if err := ValidatePayloadTemplate(webhook.PayloadTemplate, f.Child("payloadTemplate")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidatePayloadTemplate checks paths of fields are well-formed and do not conflict with each other
+func ValidatePayloadTemplate(template *appsv1alpha1.PayloadTemplate, f *field.Path) *field.Error {
+	if len(template.Fields) == 0 {
+		return field.Required(f.Child("fields"), "at least one field is required")
+	}
+	reserved := sets.NewString("traceId", "ruleName", "stage")
+	if reserved.Has(template.ResourcesKey) {
+		return field.Invalid(f.Child("resourcesKey"), template.ResourcesKey, "conflicts with reserved keys traceId, ruleName and stage")
+	}
+	paths := sets.NewString()
+	for i, payloadField := range template.Fields {
+		fField := f.Child("fields").Index(i)
+		if payloadField.FieldPath == "" {
+			return field.Required(fField.Child("fieldPath"), "")
+		}
+		for _, key := range strings.Split(payloadField.Path, ".") {
+			if key == "" {
+				return field.Invalid(fField.Child("path"), payloadField.Path, "path must be dot separated non-empty keys")
+			}
+		}
+		paths.Insert(payloadField.Path)
+	}
+	if paths.Len() != len(template.Fields) {
+		return field.Invalid(f.Child("fields"), nil, "duplicated paths")
+	}
+	// a path can not be both a value and an object, e.g. meta and meta.ip
+	for _, path := range paths.List() {
+		for _, other := range paths.List() {
+			if strings.HasPrefix(other, path+".") {
+				return field.Invalid(f.Child("fields"), nil, fmt.Sprintf("path %s conflicts with %s", path, other))
+			}
+		}
+	}
 	return nil
 }
 
@@ -153,3 +535,18 @@ func CheckCaBundle(ca string) error {
 	}
 	return nil
 }
+
+// validateIntOrPercent requires value to be a non-negative integer or percentage if set
+func validateIntOrPercent(value *intstr.IntOrString, fldPath *field.Path) *field.Error {
+	if value == nil {
+		return nil
+	}
+	v, err := intstr.GetScaledValueFromIntOrPercent(value, 100, true)
+	if err != nil {
+		return field.Invalid(fldPath, value.String(), err.Error())
+	}
+	if v < 0 {
+		return field.Invalid(fldPath, value.String(), "must be non-negative")
+	}
+	return nil
+}
@@ -17,15 +17,27 @@
 package podtransitionrule
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
 
+	"github.com/go-logr/logr"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	appsv1alpha1 "kusionstack.io/operating/apis/apps/v1alpha1"
+	"kusionstack.io/operating/pkg/utils/feature"
 )
 
 var _ = Describe("PodTransitionRule Validating", func() {
@@ -131,6 +143,13 @@ var _ = Describe("PodTransitionRule Validating", func() {
 			},
 		}
 		Expect(NewValidatingHandler().validate(rs)).Should(BeNil())
+
+		rs.Spec.Rules[0].AvailablePolicy.ContainerReadiness = &appsv1alpha1.ContainerReadiness{}
+		err := NewValidatingHandler().validate(rs)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.rule.available.availablePolicy.containerReadiness.containerNames: Required value"))
+		rs.Spec.Rules[0].AvailablePolicy.ContainerReadiness.ContainerNames = []string{"app"}
+		Expect(NewValidatingHandler().validate(rs)).Should(BeNil())
 	})
 	It("Validate LabelCheck", func() {
 		rs.Spec = appsv1alpha1.PodTransitionRuleSpec{
@@ -166,6 +185,203 @@ var _ = Describe("PodTransitionRule Validating", func() {
 		}
 		Expect(NewValidatingHandler().validate(rs)).Should(BeNil())
 	})
+	It("Validate Selectors", func() {
+		invalid := &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "test", Operator: "Unknown"}},
+		}
+		rs.Spec = appsv1alpha1.PodTransitionRuleSpec{Selector: invalid}
+		err := NewValidatingHandler().validate(rs)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.selector"))
+		Expect(err.Error()).Should(ContainSubstring(`"Unknown" is not a valid pod selector operator`))
+
+		rs.Spec = appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"test": "test"},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name:   "label",
+					Filter: &appsv1alpha1.TransitionRuleFilter{LabelSelector: invalid},
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: invalid},
+					},
+				},
+			},
+		}
+		err = NewValidatingHandler().validate(rs)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.rule.label.filter.labelSelector"))
+		Expect(err.Error()).Should(ContainSubstring("spec.rule.label.labelCheck.requires"))
+	})
+	It("Validate ReadinessGate", func() {
+		rs.Spec = appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"test": "test"},
+			},
+			ReadinessGate: &appsv1alpha1.PodTransitionRuleReadinessGate{ConditionType: "example.com/traffic-ready"},
+		}
+		Expect(NewValidatingHandler().validate(rs)).Should(BeNil())
+
+		rs.Spec.ReadinessGate.ConditionType = corev1.PodReady
+		err := NewValidatingHandler().validate(rs)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.readinessGate.conditionType"))
+		Expect(err.Error()).Should(ContainSubstring("must not be a built-in pod condition"))
+
+		rs.Spec.ReadinessGate.ConditionType = "traffic ready"
+		Expect(NewValidatingHandler().validate(rs)).Should(HaveOccurred())
+	})
+	It("Validate PDBRef", func() {
+		pdb := &policyv1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: "pdb", Namespace: "default"}}
+		h := NewValidatingHandler()
+		h.Client = fake.NewClientBuilder().WithObjects(pdb).Build()
+		ptr := &appsv1alpha1.PodTransitionRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "ptr", Namespace: "default"},
+			Spec: appsv1alpha1.PodTransitionRuleSpec{
+				Rules: []appsv1alpha1.TransitionRule{
+					{
+						Name: "pdb",
+						TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+							PDBRef: &appsv1alpha1.PDBRefRule{Name: "pdb"},
+						},
+					},
+				},
+			},
+		}
+		Expect(h.validatePDBRefs(context.TODO(), ptr)).Should(BeNil())
+
+		ptr.Spec.Rules[0].PDBRef.Name = "missing"
+		err := h.validatePDBRefs(context.TODO(), ptr)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.rule.pdb.pdbRef.name: Not found"))
+
+		ignore := appsv1alpha1.Ignore
+		ptr.Spec.Rules[0].PDBRef.FailurePolicy = &ignore
+		Expect(h.validatePDBRefs(context.TODO(), ptr)).Should(BeNil())
+	})
+	It("Validate RulesFrom", func() {
+		shared := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-rules", Namespace: "default"},
+			Data: map[string]string{appsv1alpha1.DefaultRulesConfigMapKey: `
+- name: labelCheck
+  labelCheck:
+    requires:
+      matchLabels:
+        shared: "true"
+- name: sharedOnly
+  maxInProgress: -1
+  labelCheck:
+    requires: {}
+`},
+		}
+		invalid := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid-rules", Namespace: "default"},
+			Data:       map[string]string{"custom": "- name: typo\n  labelChek: {}\n"},
+		}
+		h := NewValidatingHandler()
+		h.Client = fake.NewClientBuilder().WithObjects(shared, invalid).Build()
+		ptr := &appsv1alpha1.PodTransitionRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "ptr", Namespace: "default"},
+			Spec: appsv1alpha1.PodTransitionRuleSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"test": "test"}},
+				Rules: []appsv1alpha1.TransitionRule{
+					{
+						Name: "labelCheck",
+						TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+							LabelCheck: &appsv1alpha1.LabelCheckRule{Requires: &metav1.LabelSelector{}},
+						},
+					},
+				},
+				RulesFrom: []appsv1alpha1.ConfigMapRulesRef{{Name: "shared-rules"}},
+			},
+		}
+		merged, err := h.mergeRulesFrom(context.TODO(), ptr)
+		Expect(err).Should(BeNil())
+		Expect(merged.Spec.Rules).Should(HaveLen(2))
+		Expect(merged.Spec.Rules[0].LabelCheck.Requires.MatchLabels).Should(BeEmpty())
+		Expect(merged.Spec.Rules[1].Name).Should(Equal("sharedOnly"))
+		Expect(ptr.Spec.Rules).Should(HaveLen(1))
+
+		// merged rules are validated
+		err = h.validate(merged)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.rule.sharedOnly.maxInProgress"))
+
+		ptr.Spec.RulesFrom = []appsv1alpha1.ConfigMapRulesRef{{Name: "invalid-rules", Key: "custom"}}
+		_, err = h.mergeRulesFrom(context.TODO(), ptr)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("invalid rules in key custom of ConfigMap default/invalid-rules"))
+
+		ptr.Spec.RulesFrom = []appsv1alpha1.ConfigMapRulesRef{{Name: "missing"}}
+		_, err = h.mergeRulesFrom(context.TODO(), ptr)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.rulesFrom[0].name: Not found"))
+	})
+
+	It("Validate ClientCertSecretRef", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "client-cert", Namespace: "default"},
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert"), corev1.TLSPrivateKeyKey: []byte("key")},
+		}
+		incomplete := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "incomplete", Namespace: "default"},
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert")},
+		}
+		h := NewValidatingHandler()
+		h.APIReader = fake.NewClientBuilder().WithObjects(secret, incomplete).Build()
+		ptr := &appsv1alpha1.PodTransitionRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "ptr", Namespace: "default"},
+			Spec: appsv1alpha1.PodTransitionRuleSpec{
+				Rules: []appsv1alpha1.TransitionRule{
+					{
+						Name: "webhook",
+						TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+							Webhook: &appsv1alpha1.TransitionRuleWebhook{
+								ClientConfig: appsv1alpha1.ClientConfigBeta1{
+									URL:                 "https://webhook.default.svc",
+									ClientCertSecretRef: &corev1.LocalObjectReference{Name: "client-cert"},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		Expect(h.validateClientCertSecrets(context.TODO(), ptr)).Should(BeNil())
+
+		ptr.Spec.Rules[0].Webhook.ClientConfig.ClientCertSecretRef.Name = "missing"
+		err := h.validateClientCertSecrets(context.TODO(), ptr)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("spec.rule.webhook.webhook.clientConfig.clientCertSecretRef.name: Not found"))
+
+		ptr.Spec.Rules[0].Webhook.ClientConfig.ClientCertSecretRef.Name = "incomplete"
+		err = h.validateClientCertSecrets(context.TODO(), ptr)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("secret must contain tls.crt and tls.key"))
+	})
+	It("Validate Custom", func() {
+		rs.Spec = appsv1alpha1.PodTransitionRuleSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"test": "test"},
+			},
+			Rules: []appsv1alpha1.TransitionRule{
+				{
+					Name: "custom",
+					TransitionRuleDefinition: appsv1alpha1.TransitionRuleDefinition{
+						Custom: &appsv1alpha1.CustomRule{Type: "example", Params: map[string]string{"key": "value"}},
+					},
+				},
+			},
+		}
+		Expect(NewValidatingHandler().validate(rs)).Should(BeNil())
+
+		rs.Spec.Rules[0].Custom.Type = ""
+		err := NewValidatingHandler().validate(rs)
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).Should(ContainSubstring("custom rule type is required"))
+	})
+
 	It("Mutating PodTransitionRule", func() {
 		rs.Spec = appsv1alpha1.PodTransitionRuleSpec{
 			Selector: &metav1.LabelSelector{
@@ -187,10 +403,73 @@ var _ = Describe("PodTransitionRule Validating", func() {
 				},
 			},
 		}
-		SetDefaultPodTransitionRule(rs)
-		Expect(*rs.Spec.Rules[0].Webhook.FailurePolicy).Should(Equal(appsv1alpha1.Ignore))
-		Expect(*rs.Spec.Rules[0].Webhook.ClientConfig.Poll.TimeoutSeconds).Should(Equal(int64(60)))
-		Expect(*rs.Spec.Rules[0].Webhook.ClientConfig.Poll.IntervalSeconds).Should(Equal(int64(5)))
+		defaulted := rs.DeepCopy()
+		SetDefaultPodTransitionRule(defaulted)
+		Expect(*defaulted.Spec.Rules[0].Webhook.FailurePolicy).Should(Equal(appsv1alpha1.Ignore))
+		Expect(*defaulted.Spec.Rules[0].Webhook.ClientConfig.Poll.TimeoutSeconds).Should(Equal(int64(60)))
+		Expect(*defaulted.Spec.Rules[0].Webhook.ClientConfig.Poll.IntervalSeconds).Should(Equal(int64(5)))
+
+		obj := rs.DeepCopy()
+		obj.TypeMeta = metav1.TypeMeta{APIVersion: appsv1alpha1.GroupVersion.String(), Kind: "PodTransitionRule"}
+		raw, err := json.Marshal(obj)
+		Expect(err).Should(BeNil())
+		scheme := k8sruntime.NewScheme()
+		runtime.Must(appsv1alpha1.AddToScheme(scheme))
+		decoder, err := admission.NewDecoder(scheme)
+		Expect(err).Should(BeNil())
+		handler := NewMutatingHandler()
+		handler.Logger = logr.Discard()
+		handler.Decoder = decoder
+		resp := handler.Handle(context.TODO(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Create,
+			Object:    k8sruntime.RawExtension{Raw: raw},
+		}})
+		Expect(resp.Allowed).Should(BeTrue())
+		// defaults are resolved by controller, so that namespace defaults created later still apply
+		for _, patch := range resp.Patches {
+			Expect(patch.Path).ShouldNot(HavePrefix("/spec"))
+		}
+	})
+	It("Validate Payload Template", func() {
+		f := field.NewPath("test")
+		template := &appsv1alpha1.PayloadTemplate{
+			ResourcesKey: "pods",
+			Fields: []appsv1alpha1.PayloadField{
+				{Path: "name", FieldPath: "metadata.name"},
+				{Path: "meta.ip", FieldPath: "status.podIP"},
+				{Path: "meta.node", FieldPath: "spec.nodeName"},
+			},
+		}
+		Expect(ValidatePayloadTemplate(template, f)).Should(BeNil())
+		template.ResourcesKey = "traceId"
+		Expect(ValidatePayloadTemplate(template, f)).ShouldNot(BeNil())
+		template.ResourcesKey = ""
+		template.Fields = append(template.Fields, appsv1alpha1.PayloadField{Path: "meta", FieldPath: "metadata.namespace"})
+		Expect(ValidatePayloadTemplate(template, f)).ShouldNot(BeNil())
+		template.Fields[3] = appsv1alpha1.PayloadField{Path: "meta..ns", FieldPath: "metadata.namespace"}
+		Expect(ValidatePayloadTemplate(template, f)).ShouldNot(BeNil())
+		template.Fields[3] = appsv1alpha1.PayloadField{Path: "name", FieldPath: "metadata.namespace"}
+		Expect(ValidatePayloadTemplate(template, f)).ShouldNot(BeNil())
+		template.Fields[3] = appsv1alpha1.PayloadField{Path: "ns"}
+		Expect(ValidatePayloadTemplate(template, f)).ShouldNot(BeNil())
+	})
+	It("Validate Status Writers", func() {
+		req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation:   admissionv1.Update,
+			SubResource: "status",
+			UserInfo:    authenticationv1.UserInfo{Username: "kubernetes-admin"},
+		}}
+		handler := NewStatusValidatingHandler()
+		// disabled by default
+		Expect(handler.Handle(context.TODO(), req).Allowed).Should(BeTrue())
+
+		runtime.Must(feature.DefaultMutableFeatureGate.Set("PodTransitionRuleStatusProtection=true"))
+		defer func() {
+			runtime.Must(feature.DefaultMutableFeatureGate.Set("PodTransitionRuleStatusProtection=false"))
+		}()
+		Expect(handler.Handle(context.TODO(), req).Allowed).Should(BeFalse())
+		req.UserInfo.Username = "system:serviceaccount:kusionstack-system:kusionstack-controller-manager"
+		Expect(handler.Handle(context.TODO(), req).Allowed).Should(BeTrue())
 	})
 })
 
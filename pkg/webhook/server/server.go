@@ -21,10 +21,14 @@ import (
 
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
 
 	"kusionstack.io/operating/pkg/webhook/server/generic"
 )
 
+// conversionPath is the path of conversion webhook referred by CRDs with webhook conversion strategy
+const conversionPath = "/convert"
+
 // Add adds itself to the manager
 func Add(mgr manager.Manager) error {
 	server := mgr.GetWebhookServer()
@@ -45,5 +49,9 @@ func Add(mgr manager.Manager) error {
 		logger.V(3).Info("Registered webhook handler", "path", path)
 	}
 
+	// register conversion webhook, which converts objects of convertible kinds between versions through their hubs
+	server.Register(conversionPath, &conversion.Webhook{})
+	logger.V(3).Info("Registered conversion webhook", "path", conversionPath)
+
 	return nil
 }